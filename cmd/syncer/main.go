@@ -36,6 +36,7 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/tracing"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
@@ -100,6 +101,16 @@ func main() {
 		*internalFSSName, *internalFSSNamespace, "", *operationMode)
 	admissionhandler.COInitParams = &syncer.COInitParams
 
+	// Export the spans over OTLP if configured.
+	shutdownTracing, err := tracing.InitTracing(ctx, "vsphere-syncer")
+	if err != nil {
+		log.Errorf("failed to initialize tracing. Error: %v", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+	defer func() {
+		_ = shutdownTracing(ctx)
+	}()
+
 	// Disconnect VC session on restart
 	defer func() {
 		log.Info("Cleaning up vc sessions")
@@ -116,6 +127,7 @@ func main() {
 			if sig == syscall.SIGTERM {
 				log.Info("SIGTERM signal received")
 				utils.LogoutAllvCenterSessions(ctx)
+				_ = shutdownTracing(ctx)
 				os.Exit(0)
 			}
 		}
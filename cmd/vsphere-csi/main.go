@@ -22,9 +22,11 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	csiconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/tracing"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
@@ -64,6 +66,16 @@ func main() {
 	commonco.SetInitParams(ctx, clusterFlavor, &service.COInitParams, *supervisorFSSName, *supervisorFSSNamespace,
 		*internalFSSName, *internalFSSNamespace, serviceMode, "")
 
+	// Export the spans over OTLP if configured.
+	shutdownTracing, err := tracing.InitTracing(ctx, "vsphere-csi-"+strings.ToLower(serviceMode))
+	if err != nil {
+		log.Errorf("failed to initialize tracing. Error: %v", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+	defer func() {
+		_ = shutdownTracing(ctx)
+	}()
+
 	// If no endpoint is set then exit the program.
 	CSIEndpoint := os.Getenv(csitypes.EnvVarEndpoint)
 	if CSIEndpoint == "" {
@@ -87,6 +99,7 @@ func main() {
 			if sig == syscall.SIGTERM {
 				log.Info("SIGTERM signal received")
 				utils.LogoutAllvCenterSessions(ctx)
+				_ = shutdownTracing(ctx)
 				os.Exit(0)
 			}
 		}
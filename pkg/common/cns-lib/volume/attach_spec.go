@@ -0,0 +1,101 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+
+	"github.com/vmware/govmomi/cns"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// cnsVolumeAttachSpec is the CnsVolumeAttachDetachSpec of the CNS API with the
+// controllerKey and unitNumber fields, which place the disk of the volume on
+// the given controller slot of the VM. The vendored govmomi does not have these
+// fields yet, and only vCenters with FeatureDiskControllerPlacement accept
+// them.
+type cnsVolumeAttachSpec struct {
+	cnstypes.CnsVolumeAttachDetachSpec
+
+	ControllerKey int32  `xml:"controllerKey,omitempty"`
+	UnitNumber    *int32 `xml:"unitNumber,omitempty"`
+}
+
+type cnsAttachVolumeRequest struct {
+	This        vim25types.ManagedObjectReference `xml:"_this"`
+	AttachSpecs []cnsVolumeAttachSpec             `xml:"attachSpecs,omitempty"`
+}
+
+type cnsAttachVolumeBody struct {
+	Req    *cnsAttachVolumeRequest           `xml:"urn:vsan CnsAttachVolume,omitempty"`
+	Res    *cnstypes.CnsAttachVolumeResponse `xml:"urn:vsan CnsAttachVolumeResponse,omitempty"`
+	Fault_ *soap.Fault                       `xml:"http://schemas.xmlsoap.org/soap/envelope/ Fault,omitempty"`
+}
+
+func (b *cnsAttachVolumeBody) Fault() *soap.Fault { return b.Fault_ }
+
+// diskPlacement is the controller slot of a VM a volume is attached to.
+type diskPlacement struct {
+	controllerKey int32
+	unitNumber    int32
+}
+
+// invokeCNSAttachVolume invokes a CNS AttachVolume operation for the given
+// volume and VM. If placement is not nil, CNS attaches the disk of the volume
+// on the given controller slot. If the vCenter rejects the placement, the
+// volume is attached without it.
+func invokeCNSAttachVolume(ctx context.Context, virtualCenter *cnsvsphere.VirtualCenter,
+	vm *cnsvsphere.VirtualMachine, volumeID string, placement *diskPlacement) (*object.Task, error) {
+	log := logger.GetLogger(ctx)
+	spec := cnstypes.CnsVolumeAttachDetachSpec{
+		VolumeId: cnstypes.CnsVolumeId{
+			Id: volumeID,
+		},
+		Vm: vm.Reference(),
+	}
+	if placement == nil {
+		return virtualCenter.CnsClient.AttachVolume(ctx, []cnstypes.CnsVolumeAttachDetachSpec{spec})
+	}
+	unitNumber := placement.unitNumber
+	reqBody := cnsAttachVolumeBody{
+		Req: &cnsAttachVolumeRequest{
+			This: cns.CnsVolumeManagerInstance,
+			AttachSpecs: []cnsVolumeAttachSpec{{
+				CnsVolumeAttachDetachSpec: spec,
+				ControllerKey:             placement.controllerKey,
+				UnitNumber:                &unitNumber,
+			}},
+		},
+	}
+	var resBody cnsAttachVolumeBody
+	if err := virtualCenter.CnsClient.RoundTrip(ctx, &reqBody, &resBody); err != nil {
+		if !soap.IsSoapFault(err) {
+			return nil, err
+		}
+		log.Warnf("CNS AttachVolume of volume %q on controller %d unit %d rejected by vCenter %q, "+
+			"attaching it without placement. Err: %v", volumeID, placement.controllerKey, placement.unitNumber,
+			virtualCenter.Config.Host, err)
+		return virtualCenter.CnsClient.AttachVolume(ctx, []cnstypes.CnsVolumeAttachDetachSpec{spec})
+	}
+	return object.NewTask(virtualCenter.Client.Client, resBody.Res.Returnval), nil
+}
@@ -28,9 +28,12 @@ import (
 	"github.com/vmware/govmomi/cns"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/soap"
 	vim25types "github.com/vmware/govmomi/vim25/types"
 	"github.com/vmware/govmomi/vslm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -38,6 +41,7 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/tracing"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest"
 )
@@ -83,6 +87,21 @@ type Manager interface {
 	// should not be nil.
 	AttachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine,
 		volumeID string, checkNVMeController bool) (string, string, error)
+	// AttachVolumeWithSharing attaches a volume to a virtual machine using the
+	// given virtual disk sharing and disk mode. Unlike AttachVolume, the same
+	// volume can be attached to several virtual machines at the same time.
+	// When AttachVolumeWithSharing failed, the second return value (faultType) and third return value(error)
+	// need to be set, and should not be nil.
+	AttachVolumeWithSharing(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string,
+		sharing vim25types.VirtualDiskSharing, diskMode vim25types.VirtualDiskMode) (string, string, error)
+	// AttachVolumeToLeastUsedController attaches a volume to a virtual machine on
+	// the ParaVirtual SCSI controller with the fewest disks, so that the
+	// volumes are spread across the controllers of the virtual machine.
+	// maxPVSCSIUnitNumbers is the number of unit numbers of a ParaVirtual SCSI
+	// controller. When all the slots are in use, the faultType is
+	// CSIDiskControllerSlotsExhaustedFault.
+	AttachVolumeToLeastUsedController(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string,
+		maxPVSCSIUnitNumbers int32) (string, string, error)
 	// DetachVolume detaches a volume from the virtual machine given the spec.
 	// When DetachVolume failed, the first return value (faultType) and second return value(error) need to be set, and
 	// should not be nil.
@@ -778,6 +797,13 @@ func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVol
 	string, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "CreateVolume")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, createDeleteOperation)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+	defer release()
 	internalCreateVolume := func() (*CnsVolumeInfo, string, error) {
 		log := logger.GetLogger(ctx)
 		var faultType string
@@ -804,6 +830,7 @@ func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVol
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalCreateVolume: returns fault %q", faultType)
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsCreateVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -826,105 +853,346 @@ func ensureOperationContextHasATimeout(ctx context.Context) (context.Context, co
 	return context.WithCancel(ctx)
 }
 
+// startSpan starts the span of the given CNS operation of the manager.
+func (m *defaultManager) startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	var attrs []attribute.KeyValue
+	if m.virtualCenter != nil && m.virtualCenter.Config != nil {
+		attrs = append(attrs, tracing.VCenterKey.String(m.virtualCenter.Config.Host))
+	}
+	return tracing.StartSpan(ctx, "cnsvolume."+operation, attrs...)
+}
+
 // AttachVolume attaches a volume to a virtual machine given the spec.
 func (m *defaultManager) AttachVolume(ctx context.Context,
 	vm *cnsvsphere.VirtualMachine, volumeID string, checkNVMeController bool) (string, string, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "AttachVolume")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, attachDetachOperation)
+	if err != nil {
+		return "", csifault.CSIInternalFault, err
+	}
+	defer release()
 	internalAttachVolume := func() (string, string, error) {
-		log := logger.GetLogger(ctx)
-		var faultType string
 		err := validateManager(ctx, m)
 		if err != nil {
-			faultType = ExtractFaultTypeFromErr(ctx, err)
-			return "", faultType, err
+			return "", ExtractFaultTypeFromErr(ctx, err), err
 		}
-		// Set up the VC connection.
-		err = m.virtualCenter.ConnectCns(ctx)
+		return m.attachVolumeThroughCNS(ctx, vm, volumeID, checkNVMeController, nil)
+	}
+	start := time.Now()
+	resp, faultType, err := internalAttachVolume()
+	log := logger.GetLogger(ctx)
+	log.Debugf("internalAttachVolume: returns fault %q for volume %q", faultType, volumeID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsAttachVolumeOpType,
+			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
+	} else {
+		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsAttachVolumeOpType,
+			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
+	}
+	return resp, faultType, err
+}
+
+// attachVolumeThroughCNS attaches a volume to a virtual machine with CNS
+// AttachVolume. If placement is not nil, the disk of the volume is attached on
+// the given controller slot of the virtual machine.
+func (m *defaultManager) attachVolumeThroughCNS(ctx context.Context, vm *cnsvsphere.VirtualMachine,
+	volumeID string, checkNVMeController bool, placement *diskPlacement) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	var faultType string
+	// Set up the VC connection.
+	err := m.virtualCenter.ConnectCns(ctx)
+	if err != nil {
+		log.Errorf("ConnectCns failed with err: %+v", err)
+		faultType = ExtractFaultTypeFromErr(ctx, err)
+		return "", faultType, err
+	}
+	// Call the CNS AttachVolume.
+	task, err := invokeCNSAttachVolume(ctx, m.virtualCenter, vm, volumeID, placement)
+	if err != nil {
+		log.Errorf("CNS AttachVolume failed from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
+		faultType = ExtractFaultTypeFromErr(ctx, err)
+		return "", faultType, err
+	}
+	// Get the taskInfo.
+
+	var taskInfo *vim25types.TaskInfo
+	if m.tasksListViewEnabled {
+		taskInfo, err = m.waitOnTask(ctx, task.Reference())
+	} else {
+		taskInfo, err = cns.GetTaskInfo(ctx, task)
+	}
+
+	if err != nil || taskInfo == nil {
+		log.Errorf("failed to get taskInfo for AttachVolume task from vCenter %q with err: %v",
+			m.virtualCenter.Config.Host, err)
 		if err != nil {
-			log.Errorf("ConnectCns failed with err: %+v", err)
 			faultType = ExtractFaultTypeFromErr(ctx, err)
-			return "", faultType, err
+		} else {
+			faultType = csifault.CSITaskInfoEmptyFault
 		}
-		// Construct the CNS AttachSpec list.
-		var cnsAttachSpecList []cnstypes.CnsVolumeAttachDetachSpec
-		cnsAttachSpec := cnstypes.CnsVolumeAttachDetachSpec{
-			VolumeId: cnstypes.CnsVolumeId{
-				Id: volumeID,
-			},
-			Vm: vm.Reference(),
+		return "", faultType, err
+	}
+	log.Infof("AttachVolume: volumeID: %q, vm: %q, opId: %q", volumeID, vm.String(), taskInfo.ActivationId)
+	// Get the taskResult
+	taskResult, err := cns.GetTaskResult(ctx, taskInfo)
+	if err != nil {
+		faultType = ExtractFaultTypeFromErr(ctx, err)
+		log.Errorf("unable to find AttachVolume result from vCenter %q with taskID %s and attachResults %v",
+			m.virtualCenter.Config.Host, taskInfo.Task.Value, taskResult)
+		return "", faultType, err
+	}
+
+	if taskResult == nil {
+		return "", csifault.CSITaskResultEmptyFault,
+			logger.LogNewErrorf(log, "taskResult is empty for AttachVolume task: %q, opId: %q",
+				taskInfo.Task.Value, taskInfo.ActivationId)
+	}
+
+	volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
+	if volumeOperationRes.Fault != nil {
+		faultType = ExtractFaultTypeFromVolumeResponseResult(ctx, volumeOperationRes)
+		_, isResourceInUseFault := volumeOperationRes.Fault.Fault.(*vim25types.ResourceInUse)
+		if isResourceInUseFault {
+			log.Infof("observed ResourceInUse fault while attaching volume: %q with vm: %q", volumeID, vm.String())
+			// Check if volume is already attached to the requested node.
+			diskUUID, err := IsDiskAttached(ctx, vm, volumeID, checkNVMeController)
+			if err != nil {
+				return "", faultType, err
+			}
+			if diskUUID != "" {
+				return diskUUID, "", nil
+			}
+		}
+		return "", faultType, logger.LogNewErrorf(log, "failed to attach cns volume: %q to node vm: %q. fault: %q. opId: %q",
+			volumeID, vm.String(), spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
+	}
+	diskUUID := interface{}(taskResult).(*cnstypes.CnsVolumeAttachResult).DiskUUID
+	log.Infof("AttachVolume: Volume attached successfully. volumeID: %q, opId: %q, vm: %q, diskUUID: %q",
+		volumeID, taskInfo.ActivationId, vm.String(), diskUUID)
+	return diskUUID, "", nil
+}
+
+// AttachVolumeWithSharing attaches a volume to a virtual machine with the
+// given sharing and disk mode. CNS AttachVolume does not let the caller choose
+// the sharing mode of the virtual disk, so the FCD backing is added to the VM
+// with a reconfigure call on a ParaVirtual SCSI controller.
+func (m *defaultManager) AttachVolumeWithSharing(ctx context.Context, vm *cnsvsphere.VirtualMachine,
+	volumeID string, sharing vim25types.VirtualDiskSharing, diskMode vim25types.VirtualDiskMode) (
+	string, string, error) {
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "AttachVolumeWithSharing")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, attachDetachOperation)
+	if err != nil {
+		return "", csifault.CSIInternalFault, err
+	}
+	defer release()
+	internalAttachVolumeWithSharing := func() (string, string, error) {
+		log := logger.GetLogger(ctx)
+		err := validateManager(ctx, m)
+		if err != nil {
+			return "", ExtractFaultTypeFromErr(ctx, err), err
 		}
-		cnsAttachSpecList = append(cnsAttachSpecList, cnsAttachSpec)
-		// Call the CNS AttachVolume.
-		task, err := m.virtualCenter.CnsClient.AttachVolume(ctx, cnsAttachSpecList)
+		// Check if the volume is already attached to the requested node.
+		diskUUID, err := IsDiskAttached(ctx, vm, volumeID, false)
 		if err != nil {
-			log.Errorf("CNS AttachVolume failed from vCenter %q with err: %v", m.virtualCenter.Config.Host, err)
-			faultType = ExtractFaultTypeFromErr(ctx, err)
-			return "", faultType, err
+			return "", ExtractFaultTypeFromErr(ctx, err), err
 		}
-		// Get the taskInfo.
-
-		var taskInfo *vim25types.TaskInfo
-		if m.tasksListViewEnabled {
-			taskInfo, err = m.waitOnTask(ctx, task.Reference())
-		} else {
-			taskInfo, err = cns.GetTaskInfo(ctx, task)
+		if diskUUID != "" {
+			log.Infof("AttachVolumeWithSharing: volumeID: %q is already attached to vm: %q", volumeID, vm.String())
+			return diskUUID, "", nil
 		}
-
-		if err != nil || taskInfo == nil {
-			log.Errorf("failed to get taskInfo for AttachVolume task from vCenter %q with err: %v",
-				m.virtualCenter.Config.Host, err)
+		vmDevices, err := vm.Device(ctx)
+		if err != nil {
+			return "", ExtractFaultTypeFromErr(ctx, err),
+				logger.LogNewErrorf(log, "failed to get devices from vm: %q. err: %v", vm.String(), err)
+		}
+		vStorageObject, err := m.RetrieveVStorageObject(ctx, volumeID)
+		if err != nil {
+			return "", ExtractFaultTypeFromErr(ctx, err), err
+		}
+		backing, ok := vStorageObject.Config.Backing.(*vim25types.BaseConfigInfoDiskFileBackingInfo)
+		if !ok {
+			return "", csifault.CSIInternalFault, logger.LogNewErrorf(log,
+				"unexpected backing type %T for volume %q", vStorageObject.Config.Backing, volumeID)
+		}
+		if sharing == vim25types.VirtualDiskSharingSharingMultiWriter {
+			faultType, err := m.validateMultiWriterDisk(ctx, volumeID, backing)
 			if err != nil {
-				faultType = ExtractFaultTypeFromErr(ctx, err)
-			} else {
-				faultType = csifault.CSITaskInfoEmptyFault
+				return "", faultType, err
 			}
-			return "", faultType, err
 		}
-		log.Infof("AttachVolume: volumeID: %q, vm: %q, opId: %q", volumeID, vm.String(), taskInfo.ActivationId)
-		// Get the taskResult
-		taskResult, err := cns.GetTaskResult(ctx, taskInfo)
+		controller, unitNumber, err := getSharedDiskControllerAndUnitNumber(vmDevices)
 		if err != nil {
-			faultType = ExtractFaultTypeFromErr(ctx, err)
-			log.Errorf("unable to find AttachVolume result from vCenter %q with taskID %s and attachResults %v",
-				m.virtualCenter.Config.Host, taskInfo.Task.Value, taskResult)
-			return "", faultType, err
+			return "", csifault.CSIInternalFault, logger.LogNewErrorf(log,
+				"failed to attach volume %q to vm %q. err: %v", volumeID, vm.String(), err)
 		}
+		return m.attachVolumeToController(ctx, vm, volumeID, backing, vmDevices, controller, unitNumber,
+			sharing, diskMode)
+	}
+	start := time.Now()
+	resp, faultType, err := internalAttachVolumeWithSharing()
+	log := logger.GetLogger(ctx)
+	log.Debugf("internalAttachVolumeWithSharing: returns fault %q for volume %q", faultType, volumeID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsAttachVolumeOpType,
+			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
+	} else {
+		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsAttachVolumeOpType,
+			prometheus.PrometheusPassStatus).Observe(time.Since(start).Seconds())
+	}
+	return resp, faultType, err
+}
 
-		if taskResult == nil {
-			return "", csifault.CSITaskResultEmptyFault,
-				logger.LogNewErrorf(log, "taskResult is empty for AttachVolume task: %q, opId: %q",
-					taskInfo.Task.Value, taskInfo.ActivationId)
-		}
+// validateMultiWriterDisk checks that the disk of the given volume, with the
+// given backing of its vStorageObject, can be attached with multi-writer
+// sharing. Outside vSAN datastores, multi-writer sharing requires the disk to
+// be eager zeroed thick.
+func (m *defaultManager) validateMultiWriterDisk(ctx context.Context, volumeID string,
+	backing *vim25types.BaseConfigInfoDiskFileBackingInfo) (string, error) {
+	log := logger.GetLogger(ctx)
+	if backing.ProvisioningType == string(vim25types.BaseConfigInfoDiskFileBackingInfoProvisioningTypeEagerZeroedThick) {
+		return "", nil
+	}
+	var dsMo mo.Datastore
+	ds := object.NewDatastore(m.virtualCenter.Client.Client, backing.Datastore)
+	if err := ds.Properties(ctx, ds.Reference(), []string{"summary.type"}, &dsMo); err != nil {
+		return ExtractFaultTypeFromErr(ctx, err), logger.LogNewErrorf(log,
+			"failed to get the type of datastore %v of volume %q. err: %v", backing.Datastore, volumeID, err)
+	}
+	if dsMo.Summary.Type == string(vim25types.HostFileSystemVolumeFileSystemTypeVsan) {
+		return "", nil
+	}
+	return csifault.CSIDiskNotEagerZeroedFault, logger.LogNewErrorf(log,
+		"volume %q is %q provisioned on a %s datastore. Multi-writer sharing requires an eager zeroed thick "+
+			"disk, which is created with a storage policy with thick provisioning", volumeID,
+		backing.ProvisioningType, dsMo.Summary.Type)
+}
 
-		volumeOperationRes := taskResult.GetCnsVolumeOperationResult()
-		if volumeOperationRes.Fault != nil {
-			faultType = ExtractFaultTypeFromVolumeResponseResult(ctx, volumeOperationRes)
-			_, isResourceInUseFault := volumeOperationRes.Fault.Fault.(*vim25types.ResourceInUse)
-			if isResourceInUseFault {
-				log.Infof("observed ResourceInUse fault while attaching volume: %q with vm: %q", volumeID, vm.String())
-				// Check if volume is already attached to the requested node.
-				diskUUID, err := IsDiskAttached(ctx, vm, volumeID, checkNVMeController)
-				if err != nil {
-					return "", faultType, err
-				}
-				if diskUUID != "" {
-					return diskUUID, "", nil
-				}
-			}
-			return "", faultType, logger.LogNewErrorf(log, "failed to attach cns volume: %q to node vm: %q. fault: %q. opId: %q",
-				volumeID, vm.String(), spew.Sdump(volumeOperationRes.Fault), taskInfo.ActivationId)
+// attachVolumeToController attaches a volume, with the given backing of its
+// vStorageObject, to a virtual machine on the given unit number of the given
+// controller, using the given virtual disk sharing and disk mode.
+func (m *defaultManager) attachVolumeToController(ctx context.Context, vm *cnsvsphere.VirtualMachine,
+	volumeID string, backing *vim25types.BaseConfigInfoDiskFileBackingInfo, vmDevices object.VirtualDeviceList,
+	controller vim25types.BaseVirtualController, unitNumber int32, sharing vim25types.VirtualDiskSharing,
+	diskMode vim25types.VirtualDiskMode) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	datastore := backing.Datastore
+	disk := &vim25types.VirtualDisk{
+		VirtualDevice: vim25types.VirtualDevice{
+			Key:           vmDevices.NewKey(),
+			ControllerKey: controller.GetVirtualController().Key,
+			UnitNumber:    &unitNumber,
+			Backing: &vim25types.VirtualDiskFlatVer2BackingInfo{
+				VirtualDeviceFileBackingInfo: vim25types.VirtualDeviceFileBackingInfo{
+					FileName:  backing.FilePath,
+					Datastore: &datastore,
+				},
+				DiskMode: string(diskMode),
+				Sharing:  string(sharing),
+			},
+		},
+	}
+	configSpec := vim25types.VirtualMachineConfigSpec{
+		DeviceChange: []vim25types.BaseVirtualDeviceConfigSpec{
+			&vim25types.VirtualDeviceConfigSpec{
+				Operation: vim25types.VirtualDeviceConfigSpecOperationAdd,
+				Device:    disk,
+			},
+		},
+	}
+	task, err := vm.Reconfigure(ctx, configSpec)
+	if err != nil {
+		return "", ExtractFaultTypeFromErr(ctx, err), logger.LogNewErrorf(log,
+			"failed to reconfigure vm %q to attach volume %q. err: %v", vm.String(), volumeID, err)
+	}
+	taskInfo, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return "", ExtractFaultTypeFromErr(ctx, err), logger.LogNewErrorf(log,
+			"failed to attach volume %q to vm %q with sharing %q. err: %v", volumeID, vm.String(), sharing, err)
+	}
+	diskUUID, err := IsDiskAttached(ctx, vm, volumeID, false)
+	if err != nil {
+		return "", ExtractFaultTypeFromErr(ctx, err), err
+	}
+	if diskUUID == "" {
+		return "", csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"volume %q is not found on vm %q after reconfigure task %q completed",
+			volumeID, vm.String(), taskInfo.Task.Value)
+	}
+	log.Infof("attachVolumeToController: Volume attached successfully. volumeID: %q, opId: %q, vm: %q, "+
+		"sharing: %q, diskMode: %q, diskUUID: %q", volumeID, taskInfo.ActivationId, vm.String(), sharing,
+		diskMode, diskUUID)
+	return diskUUID, "", nil
+}
+
+// AttachVolumeToLeastUsedController attaches a volume to a virtual machine on
+// the ParaVirtual SCSI controller with the fewest disks.
+func (m *defaultManager) AttachVolumeToLeastUsedController(ctx context.Context, vm *cnsvsphere.VirtualMachine,
+	volumeID string, maxPVSCSIUnitNumbers int32) (string, string, error) {
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "AttachVolumeToLeastUsedController")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, attachDetachOperation)
+	if err != nil {
+		return "", csifault.CSIInternalFault, err
+	}
+	defer release()
+	internalAttachVolumeToLeastUsedController := func() (string, string, error) {
+		log := logger.GetLogger(ctx)
+		err := validateManager(ctx, m)
+		if err != nil {
+			return "", ExtractFaultTypeFromErr(ctx, err), err
+		}
+		// Check if the volume is already attached to the requested node.
+		diskUUID, err := IsDiskAttached(ctx, vm, volumeID, false)
+		if err != nil {
+			return "", ExtractFaultTypeFromErr(ctx, err), err
+		}
+		if diskUUID != "" {
+			log.Infof("AttachVolumeToLeastUsedController: volumeID: %q is already attached to vm: %q",
+				volumeID, vm.String())
+			return diskUUID, "", nil
+		}
+		vmDevices, err := vm.Device(ctx)
+		if err != nil {
+			return "", ExtractFaultTypeFromErr(ctx, err),
+				logger.LogNewErrorf(log, "failed to get devices from vm: %q. err: %v", vm.String(), err)
 		}
-		diskUUID := interface{}(taskResult).(*cnstypes.CnsVolumeAttachResult).DiskUUID
-		log.Infof("AttachVolume: Volume attached successfully. volumeID: %q, opId: %q, vm: %q, diskUUID: %q",
-			volumeID, taskInfo.ActivationId, vm.String(), diskUUID)
-		return diskUUID, "", nil
+		controllers := cnsvsphere.GetDiskControllers(vmDevices, maxPVSCSIUnitNumbers)
+		controller := cnsvsphere.GetLeastUsedDiskController(controllers)
+		if controller == nil {
+			return "", csifault.CSIDiskControllerSlotsExhaustedFault, logger.LogNewErrorf(log,
+				"failed to attach volume %q to vm %q: all the %d slots of its %d ParaVirtual SCSI "+
+					"controllers are in use", volumeID, vm.String(), cnsvsphere.GetMaxBlockVolumes(controllers),
+				len(controllers))
+		}
+		err = m.virtualCenter.CheckFeatureSupported(ctx, cnsvsphere.FeatureDiskControllerPlacement)
+		if err != nil {
+			log.Infof("AttachVolumeToLeastUsedController: attaching volume %q to vm %q without controller "+
+				"placement. Err: %v", volumeID, vm.String(), err)
+			return m.attachVolumeThroughCNS(ctx, vm, volumeID, false, nil)
+		}
+		log.Debugf("AttachVolumeToLeastUsedController: attaching volume %q to controller %d of vm %q with %d disks",
+			volumeID, controller.Controller.GetVirtualController().Key, vm.String(), controller.Disks)
+		return m.attachVolumeThroughCNS(ctx, vm, volumeID, false, &diskPlacement{
+			controllerKey: controller.Controller.GetVirtualController().Key,
+			unitNumber:    controller.FreeUnitNumbers[0],
+		})
 	}
 	start := time.Now()
-	resp, faultType, err := internalAttachVolume()
+	resp, faultType, err := internalAttachVolumeToLeastUsedController()
 	log := logger.GetLogger(ctx)
-	log.Debugf("internalAttachVolume: returns fault %q for volume %q", faultType, volumeID)
+	log.Debugf("internalAttachVolumeToLeastUsedController: returns fault %q for volume %q", faultType, volumeID)
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsAttachVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -939,6 +1207,13 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 	error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "DetachVolume")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, attachDetachOperation)
+	if err != nil {
+		return csifault.CSIInternalFault, err
+	}
+	defer release()
 	internalDetachVolume := func() (string, error) {
 		log := logger.GetLogger(ctx)
 		var faultType string
@@ -1068,6 +1343,7 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalDetachVolume: returns fault %q for volume %q", faultType, volumeID)
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsDetachVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -1081,6 +1357,13 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) (string, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "DeleteVolume")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, createDeleteOperation)
+	if err != nil {
+		return csifault.CSIInternalFault, err
+	}
+	defer release()
 	internalDeleteVolume := func() (string, error) {
 		log := logger.GetLogger(ctx)
 		var faultType string
@@ -1107,6 +1390,7 @@ func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, dele
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalDeleteVolume: returns fault %q for volume %q", faultType, volumeID)
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsDeleteVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -1364,6 +1648,8 @@ func (m *defaultManager) deleteVolumeWithImprovedIdempotency(ctx context.Context
 func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstypes.CnsVolumeMetadataUpdateSpec) error {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "UpdateVolumeMetadata")
+	defer span.End()
 	internalUpdateVolumeMetadata := func() error {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -1442,6 +1728,7 @@ func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstype
 	start := time.Now()
 	err := internalUpdateVolumeMetadata()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsUpdateVolumeMetadataOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -1455,6 +1742,13 @@ func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstype
 func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size int64) (string, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "ExpandVolume")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, createDeleteOperation)
+	if err != nil {
+		return csifault.CSIInternalFault, err
+	}
+	defer release()
 	internalExpandVolume := func() (string, error) {
 		log := logger.GetLogger(ctx)
 		var faultType string
@@ -1482,6 +1776,7 @@ func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size
 	log := logger.GetLogger(ctx)
 	log.Debugf("internalExpandVolume: returns fault %q for volume %q", faultType, volumeID)
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsExpandVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -1745,6 +2040,13 @@ func (m *defaultManager) QueryVolume(ctx context.Context,
 	queryFilter cnstypes.CnsQueryFilter) (*cnstypes.CnsQueryResult, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "QueryVolume")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, queryOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	internalQueryVolume := func() (*cnstypes.CnsQueryResult, error) {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -1769,6 +2071,7 @@ func (m *defaultManager) QueryVolume(ctx context.Context,
 	start := time.Now()
 	resp, err := internalQueryVolume()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsQueryVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -1783,6 +2086,13 @@ func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstype
 	querySelection cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "QueryAllVolume")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, queryOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	internalQueryAllVolume := func() (*cnstypes.CnsQueryResult, error) {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -1807,6 +2117,7 @@ func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstype
 	start := time.Now()
 	resp, err := internalQueryAllVolume()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsQueryAllVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -1822,6 +2133,13 @@ func (m *defaultManager) QueryVolumeInfo(ctx context.Context,
 	volumeIDList []cnstypes.CnsVolumeId) (*cnstypes.CnsQueryVolumeInfoResult, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "QueryVolumeInfo")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, queryOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	internalQueryVolumeInfo := func() (*cnstypes.CnsQueryVolumeInfoResult, error) {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -1878,6 +2196,7 @@ func (m *defaultManager) QueryVolumeInfo(ctx context.Context,
 	start := time.Now()
 	resp, err := internalQueryVolumeInfo()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsQueryVolumeInfoOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -1891,6 +2210,8 @@ func (m *defaultManager) RelocateVolume(ctx context.Context,
 	relocateSpecList ...cnstypes.BaseCnsVolumeRelocateSpec) (*object.Task, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "RelocateVolume")
+	defer span.End()
 	internalRelocateVolume := func() (*object.Task, error) {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -1915,6 +2236,7 @@ func (m *defaultManager) RelocateVolume(ctx context.Context,
 	start := time.Now()
 	resp, err := internalRelocateVolume()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsRelocateVolumeOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -1928,6 +2250,8 @@ func (m *defaultManager) RelocateVolume(ctx context.Context,
 func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "ConfigureVolumeACLs")
+	defer span.End()
 	internalConfigureVolumeACLs := func() error {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -1988,6 +2312,7 @@ func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.
 	start := time.Now()
 	err := internalConfigureVolumeACLs()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsConfigureVolumeACLOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -2066,8 +2391,15 @@ func (m *defaultManager) QueryVolumeAsync(ctx context.Context, queryFilter cnsty
 	querySelection *cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "QueryVolumeAsync")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, queryOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	log := logger.GetLogger(ctx)
-	err := validateManager(ctx, m)
+	err = validateManager(ctx, m)
 	if err != nil {
 		log.Errorf("validateManager failed with err: %+v", err)
 		return nil, err
@@ -2132,6 +2464,13 @@ func (m *defaultManager) QuerySnapshots(ctx context.Context, snapshotQueryFilter
 	*cnstypes.CnsSnapshotQueryResult, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "QuerySnapshots")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, queryOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	internalQuerySnapshots := func() (*cnstypes.CnsSnapshotQueryResult, error) {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -2172,6 +2511,7 @@ func (m *defaultManager) QuerySnapshots(ctx context.Context, snapshotQueryFilter
 	start := time.Now()
 	resp, err := internalQuerySnapshots()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusQuerySnapshotsOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -2407,6 +2747,13 @@ func (m *defaultManager) CreateSnapshot(
 	ctx context.Context, volumeID string, snapshotName string) (*CnsSnapshotInfo, error) {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "CreateSnapshot")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, snapshotOperation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	internalCreateSnapshot := func() (*CnsSnapshotInfo, error) {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -2425,6 +2772,7 @@ func (m *defaultManager) CreateSnapshot(
 	start := time.Now()
 	cnsSnapshotInfo, err := internalCreateSnapshot()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsCreateSnapshotOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -2632,6 +2980,13 @@ func (m *defaultManager) deleteSnapshotWithImprovedIdempotencyCheck(
 func (m *defaultManager) DeleteSnapshot(ctx context.Context, volumeID string, snapshotID string) error {
 	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
 	defer cancelFunc()
+	ctx, span := m.startSpan(ctx, "DeleteSnapshot")
+	defer span.End()
+	release, err := m.acquireOperationSlot(ctx, snapshotOperation)
+	if err != nil {
+		return err
+	}
+	defer release()
 	internalDeleteSnapshot := func() error {
 		log := logger.GetLogger(ctx)
 		err := validateManager(ctx, m)
@@ -2649,8 +3004,9 @@ func (m *defaultManager) DeleteSnapshot(ctx context.Context, volumeID string, sn
 	}
 
 	start := time.Now()
-	err := internalDeleteSnapshot()
+	err = internalDeleteSnapshot()
 	if err != nil {
+		tracing.RecordError(span, err)
 		prometheus.CnsControlOpsHistVec.WithLabelValues(prometheus.PrometheusCnsDeleteSnapshotOpType,
 			prometheus.PrometheusFailStatus).Observe(time.Since(start).Seconds())
 	} else {
@@ -2,11 +2,27 @@ package volume
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi/cns"
+	cnsmethods "github.com/vmware/govmomi/cns/methods"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
 	vim25types "github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/govmomi/vim25/xml"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/tracing"
 )
 
 const createVolumeTaskTimeout = 3 * time.Second
@@ -50,3 +66,98 @@ func performSlowTask(ch chan TaskResult, delay time.Duration) {
 		Err:      nil,
 	}
 }
+
+func TestManagerTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+	ctx, parent := tracing.StartSpan(context.Background(), "parent")
+
+	// The manager fails without vCenter connection.
+	m := &defaultManager{}
+	_, err := m.QueryVolume(ctx, cnstypes.CnsQueryFilter{})
+	assert.Error(t, err)
+	m = &defaultManager{virtualCenter: &cnsvsphere.VirtualCenter{
+		Config: &cnsvsphere.VirtualCenterConfig{Host: "vc-tracing"}}}
+	_, span := m.startSpan(ctx, "DeleteVolume")
+	span.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "cnsvolume.QueryVolume", spans[0].Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "cnsvolume.DeleteVolume", spans[1].Name)
+	assert.Equal(t, []attribute.KeyValue{tracing.VCenterKey.String("vc-tracing")}, spans[1].Attributes)
+}
+
+func TestCNSAttachVolumeRequestPlacement(t *testing.T) {
+	unitNumber := int32(3)
+	body := cnsAttachVolumeBody{
+		Req: &cnsAttachVolumeRequest{
+			AttachSpecs: []cnsVolumeAttachSpec{{
+				CnsVolumeAttachDetachSpec: cnstypes.CnsVolumeAttachDetachSpec{
+					VolumeId: cnstypes.CnsVolumeId{Id: "volume-1"},
+					Vm:       vim25types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1"},
+				},
+				ControllerKey: 1000,
+				UnitNumber:    &unitNumber,
+			}},
+		},
+	}
+	out, err := xml.Marshal(body.Req)
+	if err != nil {
+		t.Fatalf("failed to encode the CNS AttachVolume request: %v", err)
+	}
+	for _, expected := range []string{"<id>volume-1</id>", "<controllerKey>1000</controllerKey>",
+		"<unitNumber>3</unitNumber>"} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("expected %q in the CNS AttachVolume request, got %s", expected, out)
+		}
+	}
+}
+
+// attachRoundTripper rejects the CNS AttachVolume requests with a controller
+// placement, and records the requests it receives.
+type attachRoundTripper struct {
+	requests []soap.HasFault
+}
+
+func (a *attachRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	a.requests = append(a.requests, req)
+	if _, ok := req.(*cnsAttachVolumeBody); ok {
+		return soap.WrapSoapFault(&soap.Fault{String: "Unexpected element controllerKey"})
+	}
+	res.(*cnsmethods.CnsAttachVolumeBody).Res = &cnstypes.CnsAttachVolumeResponse{
+		Returnval: vim25types.ManagedObjectReference{Type: "Task", Value: "task-1"},
+	}
+	return nil
+}
+
+func TestInvokeCNSAttachVolumePlacementRejected(t *testing.T) {
+	rt := &attachRoundTripper{}
+	vc := &cnsvsphere.VirtualCenter{
+		Config:    &cnsvsphere.VirtualCenterConfig{Host: "vc-attach"},
+		CnsClient: &cns.Client{RoundTripper: rt},
+	}
+	vm := &cnsvsphere.VirtualMachine{
+		VirtualMachine: object.NewVirtualMachine(nil,
+			vim25types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1"}),
+	}
+	task, err := invokeCNSAttachVolume(context.Background(), vc, vm, "volume-1",
+		&diskPlacement{controllerKey: 1000, unitNumber: 3})
+	if err != nil {
+		t.Fatalf("expected the volume to be attached without placement, got %v", err)
+	}
+	if task.Reference().Value != "task-1" {
+		t.Errorf("expected task-1, got %v", task.Reference())
+	}
+	if len(rt.requests) != 2 {
+		t.Fatalf("expected the placed attach and the plain attach, got %d requests", len(rt.requests))
+	}
+	plain, ok := rt.requests[1].(*cnsmethods.CnsAttachVolumeBody)
+	if !ok || plain.Req.AttachSpecs[0].VolumeId.Id != "volume-1" || plain.Req.AttachSpecs[0].Vm.Value != "vm-1" {
+		t.Errorf("expected a plain CNS AttachVolume of volume-1 to vm-1, got %+v", rt.requests[1])
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/tracing"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// operationKind is a kind of CNS operation whose concurrency is limited
+// separately from the other kinds.
+type operationKind string
+
+const (
+	// createDeleteOperation covers the create, delete and expand volume
+	// operations.
+	createDeleteOperation operationKind = "create-delete"
+	// attachDetachOperation covers the attach and detach volume operations.
+	attachDetachOperation operationKind = "attach-detach"
+	// queryOperation covers the volume and snapshot query operations.
+	queryOperation operationKind = "query"
+	// snapshotOperation covers the create and delete snapshot operations.
+	snapshotOperation operationKind = "snapshot"
+)
+
+// operationLimiter limits the number of concurrent CNS operations of each
+// kind on a vCenter.
+type operationLimiter struct {
+	limits map[operationKind]int
+	// slots holds a semaphore for each limited kind of operation.
+	slots map[operationKind]chan struct{}
+}
+
+var (
+	// operationLimiters holds the operationLimiter of each vCenter host.
+	operationLimiters = make(map[string]*operationLimiter)
+	// operationLimitersLock guards operationLimiters.
+	operationLimitersLock = &sync.Mutex{}
+)
+
+// getOperationLimits returns the limit for each kind of operation from the
+// given vCenter config. Kinds which are not limited are left out.
+func getOperationLimits(cfg *cnsvsphere.VirtualCenterConfig) map[operationKind]int {
+	limits := make(map[operationKind]int)
+	for kind, limit := range map[operationKind]int{
+		createDeleteOperation: cfg.MaxConcurrentCreateDeleteOps,
+		attachDetachOperation: cfg.MaxConcurrentAttachDetachOps,
+		queryOperation:        cfg.MaxConcurrentQueryOps,
+		snapshotOperation:     cfg.MaxConcurrentSnapshotOps,
+	} {
+		if limit > 0 {
+			limits[kind] = limit
+		}
+	}
+	return limits
+}
+
+// getOperationLimiter returns the operationLimiter for the given vCenter
+// config, or nil if no kind of operation is limited. The operationLimiter is
+// rebuilt if the limits changed, operations holding a slot of the previous one
+// release it there.
+func getOperationLimiter(cfg *cnsvsphere.VirtualCenterConfig) *operationLimiter {
+	limits := getOperationLimits(cfg)
+	if len(limits) == 0 {
+		return nil
+	}
+	operationLimitersLock.Lock()
+	defer operationLimitersLock.Unlock()
+	limiter, ok := operationLimiters[cfg.Host]
+	if ok && equalOperationLimits(limiter.limits, limits) {
+		return limiter
+	}
+	limiter = &operationLimiter{
+		limits: limits,
+		slots:  make(map[operationKind]chan struct{}),
+	}
+	for kind, limit := range limits {
+		limiter.slots[kind] = make(chan struct{}, limit)
+	}
+	operationLimiters[cfg.Host] = limiter
+	return limiter
+}
+
+func equalOperationLimits(a, b map[operationKind]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for kind, limit := range a {
+		if b[kind] != limit {
+			return false
+		}
+	}
+	return true
+}
+
+// acquireOperationSlot waits for a slot for an operation of the given kind on
+// the vCenter of the manager and returns the func releasing it. It returns an
+// error if the context is done before a slot is free. The time spent waiting
+// and the number of waiting operations are exposed in the metrics.
+func (m *defaultManager) acquireOperationSlot(ctx context.Context, kind operationKind) (func(), error) {
+	if m.virtualCenter == nil || m.virtualCenter.Config == nil {
+		return func() {}, nil
+	}
+	limiter := getOperationLimiter(m.virtualCenter.Config)
+	if limiter == nil || limiter.slots[kind] == nil {
+		return func() {}, nil
+	}
+	slots := limiter.slots[kind]
+	host := m.virtualCenter.Config.Host
+	release := func() { <-slots }
+	select {
+	case slots <- struct{}{}:
+		prometheus.CnsOperationQueueWaitHistVec.WithLabelValues(host, string(kind)).Observe(0)
+		return release, nil
+	default:
+	}
+
+	log := logger.GetLogger(ctx)
+	log.Debugf("Waiting for one of the %d slots for %s operations on vCenter %q", cap(slots), kind, host)
+	queueDepth := prometheus.CnsOperationQueueDepthGaugeVec.WithLabelValues(host, string(kind))
+	queueDepth.Inc()
+	defer queueDepth.Dec()
+	start := time.Now()
+	select {
+	case slots <- struct{}{}:
+		prometheus.CnsOperationQueueWaitHistVec.WithLabelValues(host, string(kind)).Observe(
+			time.Since(start).Seconds())
+		return release, nil
+	case <-ctx.Done():
+		prometheus.CnsOperationQueueWaitHistVec.WithLabelValues(host, string(kind)).Observe(
+			time.Since(start).Seconds())
+		err := logger.LogNewErrorf(log, "timed out waiting for one of the %d slots for %s operations "+
+			"on vCenter %q. Error: %v", cap(slots), kind, host, ctx.Err())
+		tracing.RecordError(trace.SpanFromContext(ctx), err)
+		return nil, err
+	}
+}
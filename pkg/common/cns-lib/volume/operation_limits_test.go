@@ -0,0 +1,119 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+)
+
+func newTestLimitedManager(t *testing.T, cfg *cnsvsphere.VirtualCenterConfig) *defaultManager {
+	t.Cleanup(func() {
+		operationLimitersLock.Lock()
+		delete(operationLimiters, cfg.Host)
+		operationLimitersLock.Unlock()
+	})
+	return &defaultManager{virtualCenter: &cnsvsphere.VirtualCenter{Config: cfg}}
+}
+
+func TestAcquireOperationSlotUnlimited(t *testing.T) {
+	m := newTestLimitedManager(t, &cnsvsphere.VirtualCenterConfig{Host: "vc-unlimited"})
+	for i := 0; i < 10; i++ {
+		if _, err := m.acquireOperationSlot(context.Background(), createDeleteOperation); err != nil {
+			t.Fatalf("call %d: expected unlimited operations not to wait, got %v", i, err)
+		}
+	}
+}
+
+func TestAcquireOperationSlot(t *testing.T) {
+	host := "vc-limited"
+	m := newTestLimitedManager(t, &cnsvsphere.VirtualCenterConfig{
+		Host:                         host,
+		MaxConcurrentAttachDetachOps: 1,
+	})
+	ctx := context.Background()
+	release, err := m.acquireOperationSlot(ctx, attachDetachOperation)
+	if err != nil {
+		t.Fatalf("failed to acquire a free slot: %v", err)
+	}
+	// Other kinds of operations are not limited by the attach-detach limit.
+	if _, err := m.acquireOperationSlot(ctx, queryOperation); err != nil {
+		t.Fatalf("expected query operation not to wait, got %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, err := m.acquireOperationSlot(ctx, attachDetachOperation)
+		if err != nil {
+			t.Errorf("failed to acquire a slot after waiting: %v", err)
+		}
+		acquired <- release
+	}()
+	queueDepth := prometheus.CnsOperationQueueDepthGaugeVec.WithLabelValues(host, string(attachDetachOperation))
+	for promtestutil.ToFloat64(queueDepth) != 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-acquired:
+		t.Fatalf("expected operation to wait for the slot to be released")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	(<-acquired)()
+	if got := promtestutil.ToFloat64(queueDepth); got != 0 {
+		t.Errorf("expected no waiting operation, got %v", got)
+	}
+}
+
+func TestAcquireOperationSlotTimeout(t *testing.T) {
+	m := newTestLimitedManager(t, &cnsvsphere.VirtualCenterConfig{
+		Host:                     "vc-timeout",
+		MaxConcurrentSnapshotOps: 1,
+	})
+	release, err := m.acquireOperationSlot(context.Background(), snapshotOperation)
+	if err != nil {
+		t.Fatalf("failed to acquire a free slot: %v", err)
+	}
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := m.acquireOperationSlot(ctx, snapshotOperation); err == nil {
+		t.Errorf("expected operation to give up waiting once its context is done")
+	}
+}
+
+func TestOperationLimiterRebuiltOnConfigChange(t *testing.T) {
+	cfg := &cnsvsphere.VirtualCenterConfig{Host: "vc-reconfigured", MaxConcurrentQueryOps: 1}
+	m := newTestLimitedManager(t, cfg)
+	if _, err := m.acquireOperationSlot(context.Background(), queryOperation); err != nil {
+		t.Fatalf("failed to acquire a free slot: %v", err)
+	}
+	cfg.MaxConcurrentQueryOps = 2
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if _, err := m.acquireOperationSlot(ctx, queryOperation); err != nil {
+			t.Fatalf("call %d: expected the new limit to apply, got %v", i, err)
+		}
+	}
+}
@@ -113,6 +113,22 @@ func IsDiskAttached(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID
 	return "", nil
 }
 
+// getSharedDiskControllerAndUnitNumber returns a ParaVirtual SCSI controller
+// with no bus sharing and a free unit number on it. Multi-writer and read-only
+// shared disks are placed on such controllers, as SCSI bus sharing would give
+// every disk on the bus to the other VMs as well.
+func getSharedDiskControllerAndUnitNumber(vmDevices object.VirtualDeviceList) (
+	types.BaseVirtualController, int32, error) {
+	for _, controller := range cnsvsphere.GetDiskControllers(vmDevices,
+		cnsvsphere.MaxUnitNumbersPerPVSCSIController) {
+		if _, ok := controller.Controller.(*types.ParaVirtualSCSIController); ok &&
+			len(controller.FreeUnitNumbers) > 0 {
+			return controller.Controller, controller.FreeUnitNumbers[0], nil
+		}
+	}
+	return nil, 0, errors.New("no ParaVirtual SCSI controller without bus sharing and with a free slot found")
+}
+
 // getNvmeUUID returns the NVME formatted UUID.
 func getNvmeUUID(ctx context.Context, uuid string) (string, error) {
 	log := logger.GetLogger(ctx)
@@ -342,6 +358,9 @@ func getCnsVolumeInfoFromTaskResult(ctx context.Context, virtualCenter *cnsvsphe
 func ExtractFaultTypeFromErr(ctx context.Context, err error) string {
 	log := logger.GetLogger(ctx)
 	var faultType string
+	if cnsvsphere.IsCircuitOpenError(err) {
+		return csifault.CSIVCenterUnavailableFault
+	}
 	if soap.IsSoapFault(err) {
 		soapFault := soap.ToSoapFault(err)
 		// faultType has the format like "type.XXX", XXX is the specific VimFault type.
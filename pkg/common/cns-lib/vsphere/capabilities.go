@@ -0,0 +1,335 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/cns"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vsan"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// Features gated on the vCenter version. They are named after the feature
+// states gating them in the driver.
+const (
+	// FeatureFileVolume is the support of vSAN file share volumes by CNS.
+	FeatureFileVolume = "file-volume"
+	// FeatureOnlineVolumeExtend is the support of the expansion of attached
+	// volumes by CNS.
+	FeatureOnlineVolumeExtend = "online-volume-extend"
+	// FeatureBlockVolumeSnapshot is the support of block volume snapshots by CNS.
+	FeatureBlockVolumeSnapshot = "block-volume-snapshot"
+	// FeatureDiskControllerPlacement is the support by CNS AttachVolume of the
+	// controller slot the disk of a volume is attached on.
+	FeatureDiskControllerPlacement = "disk-controller-placement"
+)
+
+// vsanClusterConfigQueryConcurrency is the maximum number of clusters whose
+// vSAN config is queried at the same time to discover the vSAN file service
+// state of a vCenter.
+const vsanClusterConfigQueryConcurrency = 8
+
+// vSAN file service states of a vCenter.
+const (
+	// VsanFileServiceEnabled is set if vSAN file service is enabled on at least
+	// one cluster of the vCenter.
+	VsanFileServiceEnabled = "Enabled"
+	// VsanFileServiceDisabled is set if vSAN file service is enabled on no
+	// cluster of the vCenter.
+	VsanFileServiceDisabled = "Disabled"
+	// VsanFileServiceUnknown is set if vSAN file service state could not be
+	// queried, e.g. for lack of privileges.
+	VsanFileServiceUnknown = "Unknown"
+)
+
+// Capabilities of a vCenter, discovered when connecting to it.
+type Capabilities struct {
+	// Version is the vCenter version, e.g. "8.0.2".
+	Version string
+	// Build is the vCenter build number.
+	Build string
+	// APIVersion is the vSphere API version of the vCenter.
+	APIVersion string
+	// VsanAPIVersion is the vSAN API version used for the CNS and vSAN clients.
+	VsanAPIVersion string
+	// Features holds whether each feature gated on the vCenter version is
+	// supported by the vCenter.
+	Features map[string]bool
+	// VsanFileServiceState is one of VsanFileServiceEnabled,
+	// VsanFileServiceDisabled or VsanFileServiceUnknown.
+	VsanFileServiceState string
+	// VsanFileServiceClusters are the names of the clusters with vSAN file
+	// service enabled.
+	VsanFileServiceClusters []string
+	// DiscoveryTime is the time the capabilities were discovered.
+	DiscoveryTime time.Time
+}
+
+// FeatureNotSupportedError is returned for a feature which is not supported
+// by the vCenter.
+type FeatureNotSupportedError struct {
+	Host    string
+	Version string
+	Feature string
+	// Reason is set if the feature is supported by the vCenter version but
+	// not available in its current configuration.
+	Reason string
+}
+
+func (e *FeatureNotSupportedError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("vCenter %q (version %s) does not support feature %q: %s", e.Host, e.Version,
+			e.Feature, e.Reason)
+	}
+	return fmt.Sprintf("vCenter %q (version %s) does not support feature %q", e.Host, e.Version, e.Feature)
+}
+
+// IsFeatureNotSupportedError returns true if the given error is a
+// FeatureNotSupportedError.
+func IsFeatureNotSupportedError(err error) bool {
+	var featureErr *FeatureNotSupportedError
+	return errors.As(err, &featureErr)
+}
+
+// vcCapabilitiesTTL is the duration the discovered capabilities of a vCenter
+// are reused for when connecting to it again.
+var vcCapabilitiesTTL = 10 * time.Minute
+
+var (
+	// vcCapabilities holds the capabilities of each vCenter host.
+	vcCapabilities = make(map[string]*Capabilities)
+	// vcCapabilitiesLock guards vcCapabilities.
+	vcCapabilitiesLock = &sync.RWMutex{}
+)
+
+// GetCapabilities returns the capabilities of the virtual center discovered
+// when connecting to it, or nil if it was never connected to.
+func (vc *VirtualCenter) GetCapabilities() *Capabilities {
+	vcCapabilitiesLock.RLock()
+	defer vcCapabilitiesLock.RUnlock()
+	return vcCapabilities[vc.Config.Host]
+}
+
+// GetAllCapabilities returns the capabilities of all the virtual centers
+// connected to, keyed by host.
+func GetAllCapabilities() map[string]*Capabilities {
+	vcCapabilitiesLock.RLock()
+	defer vcCapabilitiesLock.RUnlock()
+	all := make(map[string]*Capabilities, len(vcCapabilities))
+	for host, capabilities := range vcCapabilities {
+		all[host] = capabilities
+	}
+	return all
+}
+
+// CheckFeatureSupported returns a FeatureNotSupportedError if the given
+// feature is not supported by the virtual center. File volumes are not
+// supported while vSAN file service is disabled on all the clusters.
+func (vc *VirtualCenter) CheckFeatureSupported(ctx context.Context, feature string) error {
+	log := logger.GetLogger(ctx)
+	capabilities := vc.GetCapabilities()
+	if capabilities == nil {
+		if vc.Client == nil {
+			return logger.LogNewErrorf(log, "capabilities of vCenter %q are not known, it was never connected to",
+				vc.Config.Host)
+		}
+		capabilities = getVersionCapabilities(ctx, vc.Client)
+	}
+	supported, ok := capabilities.Features[feature]
+	if !ok {
+		return logger.LogNewErrorf(log, "unknown feature %q checked on vCenter %q", feature, vc.Config.Host)
+	}
+	if !supported {
+		return &FeatureNotSupportedError{Host: vc.Config.Host, Version: capabilities.Version, Feature: feature}
+	}
+	if feature == FeatureFileVolume && capabilities.VsanFileServiceState == VsanFileServiceDisabled {
+		// vSAN file service may have been enabled since it was discovered.
+		if vc.Client != nil && time.Since(capabilities.DiscoveryTime) >= vcCapabilitiesTTL {
+			vc.discoverCapabilities(ctx, vc.Client)
+			capabilities = vc.GetCapabilities()
+		}
+		if capabilities.VsanFileServiceState == VsanFileServiceDisabled {
+			return &FeatureNotSupportedError{Host: vc.Config.Host, Version: capabilities.Version, Feature: feature,
+				Reason: "vSAN file service is not enabled on any cluster"}
+		}
+	}
+	return nil
+}
+
+// discoverCapabilities discovers the capabilities of the virtual center with
+// the given newly created client, caches them and exposes them in the
+// metrics. Failing to discover a capability does not fail the connection.
+// The capabilities discovered less than vcCapabilitiesTTL ago are reused if
+// the vCenter version has not changed.
+func (vc *VirtualCenter) discoverCapabilities(ctx context.Context, client *govmomi.Client) {
+	log := logger.GetLogger(ctx)
+	about := client.ServiceContent.About
+	if cached := vc.GetCapabilities(); cached != nil && time.Since(cached.DiscoveryTime) < vcCapabilitiesTTL &&
+		cached.Version == about.Version && cached.Build == about.Build &&
+		cached.VsanAPIVersion == client.Client.Version {
+		log.Debugf("Reusing capabilities of vCenter %q discovered at %v", vc.Config.Host, cached.DiscoveryTime)
+		return
+	}
+	capabilities := getVersionCapabilities(ctx, client)
+	capabilities.VsanFileServiceState, capabilities.VsanFileServiceClusters =
+		vc.getVsanFileServiceState(ctx, client)
+	log.Infof("Discovered capabilities of vCenter %q: %+v", vc.Config.Host, *capabilities)
+
+	vcCapabilitiesLock.Lock()
+	vcCapabilities[vc.Config.Host] = capabilities
+	vcCapabilitiesLock.Unlock()
+
+	prometheus.VCenterInfoGaugeVec.DeletePartialMatch(map[string]string{"vcenter": vc.Config.Host})
+	prometheus.VCenterInfoGaugeVec.WithLabelValues(vc.Config.Host, capabilities.Version, capabilities.Build,
+		capabilities.APIVersion).Set(1)
+	for feature, supported := range capabilities.Features {
+		value := 0.0
+		if supported {
+			value = 1
+		}
+		prometheus.VCenterFeatureSupportedGaugeVec.WithLabelValues(vc.Config.Host, feature).Set(value)
+	}
+	fileServiceValue := -1.0
+	switch capabilities.VsanFileServiceState {
+	case VsanFileServiceEnabled:
+		fileServiceValue = 1
+	case VsanFileServiceDisabled:
+		fileServiceValue = 0
+	}
+	prometheus.VCenterVsanFileServiceEnabledGaugeVec.WithLabelValues(vc.Config.Host).Set(fileServiceValue)
+}
+
+// getVersionCapabilities returns the capabilities of the virtual center which
+// derive from its version, without querying it.
+func getVersionCapabilities(ctx context.Context, client *govmomi.Client) *Capabilities {
+	log := logger.GetLogger(ctx)
+	about := client.ServiceContent.About
+	vsanAPIVersion := client.Client.Version
+	isvSphere70U3orAbove, err := IsvSphereVersion70U3orAbove(ctx, about)
+	if err != nil {
+		log.Warnf("Failed to check vCenter version %q, assuming it does not support %s. Err: %v",
+			about.Version, FeatureBlockVolumeSnapshot, err)
+	}
+	isvSphere80U3orAbove, err := IsvSphereVersion80U3orAbove(ctx, about)
+	if err != nil {
+		log.Warnf("Failed to check vCenter version %q, assuming it does not support %s. Err: %v",
+			about.Version, FeatureDiskControllerPlacement, err)
+	}
+	return &Capabilities{
+		Version:        about.Version,
+		Build:          about.Build,
+		APIVersion:     about.ApiVersion,
+		VsanAPIVersion: vsanAPIVersion,
+		Features: map[string]bool{
+			FeatureFileVolume: vsanAPIVersion != cns.ReleaseVSAN67u3,
+			FeatureOnlineVolumeExtend: vsanAPIVersion != cns.ReleaseVSAN67u3 &&
+				vsanAPIVersion != cns.ReleaseVSAN70 && vsanAPIVersion != cns.ReleaseVSAN70u1,
+			FeatureBlockVolumeSnapshot:     isvSphere70U3orAbove,
+			FeatureDiskControllerPlacement: isvSphere80U3orAbove,
+		},
+		VsanFileServiceState: VsanFileServiceUnknown,
+		DiscoveryTime:        time.Now(),
+	}
+}
+
+// getVsanFileServiceState returns the vSAN file service state of the virtual
+// center along with the names of its clusters with vSAN file service enabled.
+func (vc *VirtualCenter) getVsanFileServiceState(ctx context.Context, client *govmomi.Client) (string, []string) {
+	log := logger.GetLogger(ctx)
+	host := vc.Config.Host
+	viewManager := view.NewManager(client.Client)
+	containerView, err := viewManager.CreateContainerView(ctx, client.ServiceContent.RootFolder,
+		[]string{"ClusterComputeResource"}, true)
+	if err != nil {
+		log.Warnf("Failed to create cluster view on vCenter %q. Err: %v", host, err)
+		return VsanFileServiceUnknown, nil
+	}
+	defer func() {
+		if err := containerView.Destroy(ctx); err != nil {
+			log.Debugf("Failed to destroy cluster view on vCenter %q. Err: %v", host, err)
+		}
+	}()
+	var clusters []mo.ClusterComputeResource
+	if err := containerView.Retrieve(ctx, []string{"ClusterComputeResource"}, []string{"name"},
+		&clusters); err != nil {
+		log.Warnf("Failed to list clusters on vCenter %q. Err: %v", host, err)
+		return VsanFileServiceUnknown, nil
+	}
+	vsanClient, err := vsan.NewClient(ctx, client.Client)
+	if err != nil {
+		log.Warnf("Failed to create vsan client on vCenter %q. Err: %v", host, err)
+		return VsanFileServiceUnknown, nil
+	}
+	vsanClient.RoundTripper = vc.throttledRoundTripper(&MetricRoundTripper{"vsan", vsanClient.RoundTripper})
+	// The clusters are queried concurrently, as the discovery holds the client
+	// mutex of the virtual center while it connects.
+	var (
+		wg                              sync.WaitGroup
+		clustersLock                    sync.Mutex
+		enabledClusters, failedClusters []string
+	)
+	slots := make(chan struct{}, vsanClusterConfigQueryConcurrency)
+	for _, cluster := range clusters {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(cluster mo.ClusterComputeResource) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			config, err := vsanClient.VsanClusterGetConfig(ctx, cluster.Reference())
+			clustersLock.Lock()
+			defer clustersLock.Unlock()
+			if err != nil {
+				log.Warnf("Failed to get vSAN config of cluster %q on vCenter %q. Err: %v", cluster.Name, host, err)
+				failedClusters = append(failedClusters, cluster.Name)
+				return
+			}
+			if config.FileServiceConfig != nil && config.FileServiceConfig.Enabled {
+				enabledClusters = append(enabledClusters, cluster.Name)
+			}
+		}(cluster)
+	}
+	wg.Wait()
+	return vsanFileServiceState(enabledClusters, failedClusters)
+}
+
+// vsanFileServiceState returns the vSAN file service state of a virtual center
+// given its clusters with vSAN file service enabled and the clusters whose
+// vSAN config could not be queried, along with the sorted enabled clusters.
+// The state is unknown only if no cluster is known to have it enabled.
+func vsanFileServiceState(enabledClusters []string, failedClusters []string) (string, []string) {
+	if len(enabledClusters) != 0 {
+		sort.Strings(enabledClusters)
+		return VsanFileServiceEnabled, enabledClusters
+	}
+	if len(failedClusters) != 0 {
+		return VsanFileServiceUnknown, nil
+	}
+	return VsanFileServiceDisabled, nil
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/simulator"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/govmomi/vsan"
+	vsansim "github.com/vmware/govmomi/vsan/simulator"
+	vsantypes "github.com/vmware/govmomi/vsan/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+)
+
+func forgetTestCapabilities(t *testing.T, host string) {
+	t.Cleanup(func() {
+		vcCapabilitiesLock.Lock()
+		delete(vcCapabilities, host)
+		vcCapabilitiesLock.Unlock()
+	})
+}
+
+// withVsanFileService registers the vSAN endpoint in vcsim, with vSAN file
+// service enabled on one of the clusters if enabled is set.
+func withVsanFileService(enabled bool) func(*simulator.Model) {
+	return func(model *simulator.Model) {
+		registry := vsansim.New()
+		model.Service.RegisterSDK(registry)
+		if !enabled {
+			return
+		}
+		cluster := simulator.Map.Any("ClusterComputeResource").(*simulator.ClusterComputeResource)
+		configSystem := registry.Get(vsan.VsanVcClusterConfigSystemInstance).(*vsansim.ClusterConfigSystem)
+		configSystem.Config = map[vimtypes.ManagedObjectReference]*vsantypes.VsanConfigInfoEx{
+			cluster.Reference(): {FileServiceConfig: &vsantypes.VsanFileServiceConfig{Enabled: true}},
+		}
+	}
+}
+
+// discoverTestCapabilities connects to the given vcsim instance and discovers
+// its capabilities as connect does.
+func discoverTestCapabilities(ctx context.Context, t *testing.T, vc *VirtualCenter) *Capabilities {
+	forgetTestCapabilities(t, vc.Config.Host)
+	client, err := vc.NewClient(ctx, "test")
+	if err != nil {
+		t.Fatalf("failed to connect to vCenter: %v", err)
+	}
+	vc.Client = client
+	vc.discoverCapabilities(ctx, client)
+	capabilities := vc.GetCapabilities()
+	if capabilities == nil {
+		t.Fatalf("expected capabilities to be discovered")
+	}
+	return capabilities
+}
+
+func TestDiscoverCapabilities(t *testing.T) {
+	ctx := context.Background()
+	vc := newTestVirtualCenter(t)
+	capabilities := discoverTestCapabilities(ctx, t, vc)
+	about := vc.Client.ServiceContent.About
+	if capabilities.Version != about.Version || capabilities.Build != about.Build ||
+		capabilities.APIVersion != about.ApiVersion {
+		t.Errorf("expected version %q, build %q and API version %q, got %+v",
+			about.Version, about.Build, about.ApiVersion, *capabilities)
+	}
+	for _, feature := range []string{FeatureFileVolume, FeatureOnlineVolumeExtend, FeatureBlockVolumeSnapshot,
+		FeatureDiskControllerPlacement} {
+		if _, ok := capabilities.Features[feature]; !ok {
+			t.Errorf("expected support of feature %q to be discovered", feature)
+		}
+	}
+	// The vSAN endpoint is not registered in vcsim by default.
+	if capabilities.VsanFileServiceState != VsanFileServiceUnknown {
+		t.Errorf("expected vSAN file service state %q, got %q", VsanFileServiceUnknown,
+			capabilities.VsanFileServiceState)
+	}
+	if _, ok := GetAllCapabilities()[vc.Config.Host]; !ok {
+		t.Errorf("expected capabilities of vCenter %q to be listed", vc.Config.Host)
+	}
+	info := prometheus.VCenterInfoGaugeVec.WithLabelValues(vc.Config.Host, about.Version, about.Build,
+		about.ApiVersion)
+	if got := promtestutil.ToFloat64(info); got != 1 {
+		t.Errorf("expected vCenter info metric to be set, got %v", got)
+	}
+	fileService := prometheus.VCenterVsanFileServiceEnabledGaugeVec.WithLabelValues(vc.Config.Host)
+	if got := promtestutil.ToFloat64(fileService); got != -1 {
+		t.Errorf("expected unknown vSAN file service state metric, got %v", got)
+	}
+}
+
+func TestDiscoverVsanFileServiceState(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		name             string
+		enabled          bool
+		expectedState    string
+		expectedClusters []string
+		expectedMetric   float64
+	}{
+		{name: "disabled", expectedState: VsanFileServiceDisabled},
+		{name: "enabled", enabled: true, expectedState: VsanFileServiceEnabled, expectedClusters: []string{"DC0_C0"},
+			expectedMetric: 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			vc := newTestVirtualCenter(t, withVsanFileService(test.enabled))
+			capabilities := discoverTestCapabilities(ctx, t, vc)
+			if capabilities.VsanFileServiceState != test.expectedState ||
+				!reflect.DeepEqual(capabilities.VsanFileServiceClusters, test.expectedClusters) {
+				t.Errorf("expected vSAN file service state %q on clusters %v, got %q on %v", test.expectedState,
+					test.expectedClusters, capabilities.VsanFileServiceState, capabilities.VsanFileServiceClusters)
+			}
+			fileService := prometheus.VCenterVsanFileServiceEnabledGaugeVec.WithLabelValues(vc.Config.Host)
+			if got := promtestutil.ToFloat64(fileService); got != test.expectedMetric {
+				t.Errorf("expected vSAN file service state metric %v, got %v", test.expectedMetric, got)
+			}
+		})
+	}
+}
+
+func TestVsanFileServiceState(t *testing.T) {
+	for _, test := range []struct {
+		enabled          []string
+		failed           []string
+		expectedState    string
+		expectedClusters []string
+	}{
+		{expectedState: VsanFileServiceDisabled},
+		{failed: []string{"C1"}, expectedState: VsanFileServiceUnknown},
+		{enabled: []string{"C2", "C0"}, failed: []string{"C1"}, expectedState: VsanFileServiceEnabled,
+			expectedClusters: []string{"C0", "C2"}},
+	} {
+		state, clusters := vsanFileServiceState(test.enabled, test.failed)
+		if state != test.expectedState || !reflect.DeepEqual(clusters, test.expectedClusters) {
+			t.Errorf("vsanFileServiceState(%v, %v) = %q, %v, expected %q, %v", test.enabled, test.failed,
+				state, clusters, test.expectedState, test.expectedClusters)
+		}
+	}
+}
+
+func TestDiscoverCapabilitiesCache(t *testing.T) {
+	ctx := context.Background()
+	var configSystem *vsansim.ClusterConfigSystem
+	vc := newTestVirtualCenter(t, func(model *simulator.Model) {
+		registry := vsansim.New()
+		model.Service.RegisterSDK(registry)
+		configSystem = registry.Get(vsan.VsanVcClusterConfigSystemInstance).(*vsansim.ClusterConfigSystem)
+	})
+	capabilities := discoverTestCapabilities(ctx, t, vc)
+	err := vc.CheckFeatureSupported(ctx, FeatureFileVolume)
+	if !IsFeatureNotSupportedError(err) || !strings.Contains(err.Error(), "vSAN file service is not enabled") {
+		t.Fatalf("expected file volumes not to be supported with vSAN file service disabled, got %v", err)
+	}
+
+	// vSAN file service is enabled, while the cached capabilities are reused.
+	cluster := simulator.Map.Any("ClusterComputeResource").(*simulator.ClusterComputeResource)
+	configSystem.Config = map[vimtypes.ManagedObjectReference]*vsantypes.VsanConfigInfoEx{
+		cluster.Reference(): {FileServiceConfig: &vsantypes.VsanFileServiceConfig{Enabled: true}},
+	}
+	vc.discoverCapabilities(ctx, vc.Client)
+	if vc.GetCapabilities() != capabilities {
+		t.Errorf("expected the cached capabilities to be reused")
+	}
+	if err := vc.CheckFeatureSupported(ctx, FeatureFileVolume); !IsFeatureNotSupportedError(err) {
+		t.Errorf("expected the cached vSAN file service state to be used, got %v", err)
+	}
+
+	// The capabilities are discovered again once expired.
+	origTTL := vcCapabilitiesTTL
+	vcCapabilitiesTTL = 0
+	defer func() { vcCapabilitiesTTL = origTTL }()
+	if err := vc.CheckFeatureSupported(ctx, FeatureFileVolume); err != nil {
+		t.Errorf("expected file volumes to be supported once vSAN file service is enabled, got %v", err)
+	}
+	if state := vc.GetCapabilities().VsanFileServiceState; state != VsanFileServiceEnabled {
+		t.Errorf("expected vSAN file service state %q, got %q", VsanFileServiceEnabled, state)
+	}
+}
+
+func TestCheckFeatureSupported(t *testing.T) {
+	ctx := context.Background()
+	host := "vc-capabilities"
+	vc := &VirtualCenter{Config: &VirtualCenterConfig{Host: host}}
+	if err := vc.CheckFeatureSupported(ctx, FeatureBlockVolumeSnapshot); err == nil ||
+		IsFeatureNotSupportedError(err) {
+		t.Errorf("expected an error for a vCenter never connected to, got %v", err)
+	}
+
+	forgetTestCapabilities(t, host)
+	vcCapabilitiesLock.Lock()
+	vcCapabilities[host] = &Capabilities{
+		Version: "7.0.2",
+		Features: map[string]bool{
+			FeatureFileVolume:          true,
+			FeatureBlockVolumeSnapshot: false,
+		},
+	}
+	vcCapabilitiesLock.Unlock()
+	if err := vc.CheckFeatureSupported(ctx, FeatureFileVolume); err != nil {
+		t.Errorf("expected feature %q to be supported, got %v", FeatureFileVolume, err)
+	}
+	err := vc.CheckFeatureSupported(ctx, FeatureBlockVolumeSnapshot)
+	if !IsFeatureNotSupportedError(err) {
+		t.Fatalf("expected feature %q not to be supported, got %v", FeatureBlockVolumeSnapshot, err)
+	}
+	expected := `vCenter "vc-capabilities" (version 7.0.2) does not support feature "block-volume-snapshot"`
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err.Error())
+	}
+	if err := vc.CheckFeatureSupported(ctx, "unknown-feature"); err == nil ||
+		!strings.Contains(err.Error(), "unknown feature") {
+		t.Errorf("expected an error for an unknown feature, got %v", err)
+	}
+}
@@ -49,6 +49,7 @@ func (vc *VirtualCenter) ConnectCns(ctx context.Context) error {
 			log.Errorf("failed to create CNS client on vCenter host %q with err: %v", vc.Config.Host, err)
 			return err
 		}
+		vc.CnsClient.RoundTripper = vc.throttledRoundTripper(vc.CnsClient.RoundTripper)
 	}
 	return nil
 }
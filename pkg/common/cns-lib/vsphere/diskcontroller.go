@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	// MaxUnitNumbersPerPVSCSIController is the number of unit numbers of a
+	// ParaVirtual SCSI controller, one of which is used by the controller.
+	MaxUnitNumbersPerPVSCSIController = 16
+	// MaxUnitNumbersPerPVSCSIControllerInvSphere8 is the number of unit numbers
+	// of a ParaVirtual SCSI controller on vSphere 8.0 and later.
+	MaxUnitNumbersPerPVSCSIControllerInvSphere8 = 64
+)
+
+// DiskController holds the slot usage of a ParaVirtual SCSI controller of a VM
+// to which block volumes can be attached.
+type DiskController struct {
+	// Controller is the ParaVirtual SCSI controller.
+	Controller types.BaseVirtualController
+	// FreeUnitNumbers holds the unit numbers of the controller with no device.
+	FreeUnitNumbers []int32
+	// Disks is the number of disks on the controller.
+	Disks int
+	// Volumes is the number of disks on the controller which are First Class
+	// Disks, i.e. block volumes.
+	Volumes int
+}
+
+// GetDiskControllers returns the slot usage of the ParaVirtual SCSI
+// controllers without bus sharing in the given devices of a VM.
+// maxPVSCSIUnitNumbers is the number of unit numbers of a ParaVirtual SCSI
+// controller, which depends on the vSphere version. NVMe controllers are left
+// out, as the nodes find the disks of block volumes by their SCSI UUID.
+func GetDiskControllers(vmDevices object.VirtualDeviceList, maxPVSCSIUnitNumbers int32) []*DiskController {
+	var controllers []*DiskController
+	for _, device := range vmDevices {
+		controller, ok := device.(*types.ParaVirtualSCSIController)
+		if !ok || controller.SharedBus != types.VirtualSCSISharingNoSharing {
+			continue
+		}
+		usedUnitNumbers := map[int32]bool{controller.ScsiCtlrUnitNumber: true}
+		diskController := &DiskController{Controller: controller}
+		controllerKey := device.GetVirtualDevice().Key
+		for _, d := range vmDevices {
+			virtualDevice := d.GetVirtualDevice()
+			if virtualDevice.ControllerKey != controllerKey || virtualDevice.UnitNumber == nil {
+				continue
+			}
+			usedUnitNumbers[*virtualDevice.UnitNumber] = true
+			if disk, ok := d.(*types.VirtualDisk); ok {
+				diskController.Disks++
+				if disk.VDiskId != nil {
+					diskController.Volumes++
+				}
+			}
+		}
+		for unitNumber := int32(0); unitNumber < maxPVSCSIUnitNumbers; unitNumber++ {
+			if !usedUnitNumbers[unitNumber] {
+				diskController.FreeUnitNumbers = append(diskController.FreeUnitNumbers, unitNumber)
+			}
+		}
+		controllers = append(controllers, diskController)
+	}
+	return controllers
+}
+
+// GetMaxBlockVolumes returns the number of block volumes which can be attached
+// to a VM with the given disk controllers, i.e. the free slots of the
+// controllers and the slots already used by block volumes.
+func GetMaxBlockVolumes(controllers []*DiskController) int64 {
+	var maxVolumes int64
+	for _, controller := range controllers {
+		maxVolumes += int64(len(controller.FreeUnitNumbers) + controller.Volumes)
+	}
+	return maxVolumes
+}
+
+// GetLeastUsedDiskController returns the controller with a free slot which has
+// the fewest disks among the given disk controllers, or nil if all the slots
+// are in use.
+func GetLeastUsedDiskController(controllers []*DiskController) *DiskController {
+	var leastUsed *DiskController
+	for _, controller := range controllers {
+		if len(controller.FreeUnitNumbers) == 0 {
+			continue
+		}
+		if leastUsed == nil || controller.Disks < leastUsed.Disks {
+			leastUsed = controller
+		}
+	}
+	return leastUsed
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func newTestDisk(key, controllerKey, unitNumber int32, volume bool) *types.VirtualDisk {
+	disk := &types.VirtualDisk{
+		VirtualDevice: types.VirtualDevice{
+			Key:           key,
+			ControllerKey: controllerKey,
+			UnitNumber:    &unitNumber,
+		},
+	}
+	if volume {
+		disk.VDiskId = &types.ID{Id: "fcd"}
+	}
+	return disk
+}
+
+func newTestPVSCSIController(key int32, sharedBus types.VirtualSCSISharing) *types.ParaVirtualSCSIController {
+	return &types.ParaVirtualSCSIController{
+		VirtualSCSIController: types.VirtualSCSIController{
+			VirtualController:  types.VirtualController{VirtualDevice: types.VirtualDevice{Key: key}},
+			SharedBus:          sharedBus,
+			ScsiCtlrUnitNumber: 7,
+		},
+	}
+}
+
+func TestGetDiskControllers(t *testing.T) {
+	vmDevices := object.VirtualDeviceList{
+		newTestPVSCSIController(1000, types.VirtualSCSISharingNoSharing),
+		newTestPVSCSIController(1001, types.VirtualSCSISharingNoSharing),
+		// Controllers with bus sharing are not used for block volumes.
+		newTestPVSCSIController(1002, types.VirtualSCSISharingPhysicalSharing),
+		&types.VirtualNVMEController{
+			VirtualController: types.VirtualController{VirtualDevice: types.VirtualDevice{Key: 31000}},
+		},
+		// Boot disk and two block volumes on the first PVSCSI controller.
+		newTestDisk(2000, 1000, 0, false),
+		newTestDisk(2001, 1000, 1, true),
+		newTestDisk(2002, 1000, 2, true),
+		// One block volume on the NVMe controller, which is not used for
+		// block volumes.
+		newTestDisk(2003, 31000, 0, true),
+	}
+
+	controllers := GetDiskControllers(vmDevices, MaxUnitNumbersPerPVSCSIController)
+	if len(controllers) != 2 {
+		t.Fatalf("expected 2 disk controllers, got %d", len(controllers))
+	}
+	expected := []struct {
+		key, freeUnitNumbers, disks, volumes int
+	}{
+		{key: 1000, freeUnitNumbers: 12, disks: 3, volumes: 2},
+		{key: 1001, freeUnitNumbers: 15},
+	}
+	for i, e := range expected {
+		c := controllers[i]
+		if int(c.Controller.GetVirtualController().Key) != e.key || len(c.FreeUnitNumbers) != e.freeUnitNumbers ||
+			c.Disks != e.disks || c.Volumes != e.volumes {
+			t.Errorf("controller %d: got key %d, %d free unit numbers, %d disks, %d volumes, want %+v", i,
+				c.Controller.GetVirtualController().Key, len(c.FreeUnitNumbers), c.Disks, c.Volumes, e)
+		}
+	}
+	for _, unitNumber := range controllers[0].FreeUnitNumbers {
+		if unitNumber == 7 {
+			t.Errorf("unit number 7 of the PVSCSI controller must not be free")
+		}
+	}
+	// 12 + 2 on the first controller and 15 on the second.
+	if maxVolumes := GetMaxBlockVolumes(controllers); maxVolumes != 29 {
+		t.Errorf("GetMaxBlockVolumes() = %d, want 29", maxVolumes)
+	}
+	if leastUsed := GetLeastUsedDiskController(controllers); leastUsed != controllers[1] {
+		t.Errorf("expected the empty PVSCSI controller to be the least used, got %+v", leastUsed)
+	}
+
+	// With 64 unit numbers per PVSCSI controller.
+	controllers = GetDiskControllers(vmDevices, MaxUnitNumbersPerPVSCSIControllerInvSphere8)
+	if maxVolumes := GetMaxBlockVolumes(controllers); maxVolumes != 125 {
+		t.Errorf("GetMaxBlockVolumes() = %d, want 125", maxVolumes)
+	}
+}
+
+func TestGetLeastUsedDiskControllerWhenFull(t *testing.T) {
+	controllers := []*DiskController{
+		{Disks: 15},
+		{Disks: 14},
+	}
+	if leastUsed := GetLeastUsedDiskController(controllers); leastUsed != nil {
+		t.Errorf("expected no controller with a free slot, got %+v", leastUsed)
+	}
+	controllers[0].FreeUnitNumbers = []int32{3}
+	if leastUsed := GetLeastUsedDiskController(controllers); leastUsed != controllers[0] {
+		t.Errorf("expected the only controller with a free slot, got %+v", leastUsed)
+	}
+}
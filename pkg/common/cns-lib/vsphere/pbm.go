@@ -21,7 +21,6 @@ import (
 	"fmt"
 
 	"github.com/vmware/govmomi/pbm"
-	pbmmethods "github.com/vmware/govmomi/pbm/methods"
 	pbmtypes "github.com/vmware/govmomi/pbm/types"
 	vimtypes "github.com/vmware/govmomi/vim25/types"
 
@@ -66,6 +65,7 @@ func (vc *VirtualCenter) ConnectPbm(ctx context.Context) error {
 			log.Errorf("failed to create pbm client with err: %v", err)
 			return err
 		}
+		vc.PbmClient.RoundTripper = vc.throttledRoundTripper(vc.PbmClient.RoundTripper)
 	}
 	return nil
 }
@@ -81,7 +81,8 @@ func (vc *VirtualCenter) DisconnectPbm(ctx context.Context) error {
 	return nil
 }
 
-// GetStoragePolicyIDByName gets storage policy ID by name.
+// GetStoragePolicyIDByName gets storage policy ID by name. The storage policy
+// IDs are cached.
 func (vc *VirtualCenter) GetStoragePolicyIDByName(ctx context.Context, storagePolicyName string) (string, error) {
 	log := logger.GetLogger(ctx)
 	err := vc.ConnectPbm(ctx)
@@ -89,7 +90,7 @@ func (vc *VirtualCenter) GetStoragePolicyIDByName(ctx context.Context, storagePo
 		log.Errorf("Error occurred while connecting to PBM, err: %+v", err)
 		return "", err
 	}
-	storagePolicyID, err := vc.PbmClient.ProfileIDByName(ctx, storagePolicyName)
+	storagePolicyID, err := getPbmCache(vc.Config.Host).getPolicyID(ctx, &pbmClient{vc.PbmClient}, storagePolicyName)
 	if err != nil {
 		log.Errorf("failed to get StoragePolicyID from StoragePolicyName %s with err: %v", storagePolicyName, err)
 		return "", err
@@ -98,7 +99,8 @@ func (vc *VirtualCenter) GetStoragePolicyIDByName(ctx context.Context, storagePo
 }
 
 // PbmCheckCompatibility performs a compatibility check for the given profileID
-// with the given datastores.
+// with the given datastores. The result is cached until it expires or a
+// storage policy changes.
 func (vc *VirtualCenter) PbmCheckCompatibility(ctx context.Context,
 	datastores []vimtypes.ManagedObjectReference, profileID string) (pbm.PlacementCompatibilityResult, error) {
 
@@ -109,27 +111,7 @@ func (vc *VirtualCenter) PbmCheckCompatibility(ctx context.Context,
 		return nil, err
 	}
 
-	hubs := make([]pbmtypes.PbmPlacementHub, 0)
-	for _, ds := range datastores {
-		hubs = append(hubs, pbmtypes.PbmPlacementHub{
-			HubType: ds.Type,
-			HubId:   ds.Value,
-		})
-	}
-	req := pbmtypes.PbmCheckCompatibility{
-		This:         vc.PbmClient.ServiceContent.PlacementSolver,
-		HubsToSearch: hubs,
-		Profile: pbmtypes.PbmProfileId{
-			UniqueId: profileID,
-		},
-	}
-
-	res, err := pbmmethods.PbmCheckCompatibility(ctx, vc.PbmClient, &req)
-	if err != nil {
-		return nil, err
-	}
-
-	return res.Returnval, nil
+	return getPbmCache(vc.Config.Host).checkCompatibility(ctx, &pbmClient{vc.PbmClient}, datastores, profileID)
 }
 
 // PbmRetrieveContent fetches the policy content of all given policies from SPBM.
@@ -0,0 +1,297 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/pbm"
+	pbmmethods "github.com/vmware/govmomi/pbm/methods"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/sync/singleflight"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// pbmCompatibilityCacheTTL is the time the compatibility of a storage
+	// policy with a set of datastores is cached for.
+	pbmCompatibilityCacheTTL = 5 * time.Minute
+	// pbmProfilesRefreshInterval is the minimum interval between two fetches
+	// of the storage policies, done to detect their changes.
+	pbmProfilesRefreshInterval = 30 * time.Second
+)
+
+// Names of the SPBM query caches in the metrics.
+const (
+	pbmPolicyIDCache      = "policy-id"
+	pbmCompatibilityCache = "compatibility"
+)
+
+// pbmQuerier is the part of the PBM API whose results are cached. It is
+// implemented by pbmClient and faked in unit tests.
+type pbmQuerier interface {
+	// Profiles returns the storage requirement profiles.
+	Profiles(ctx context.Context) ([]pbmtypes.BasePbmProfile, error)
+	// CheckCompatibility returns the compatibility of the given datastores
+	// with the given profile.
+	CheckCompatibility(ctx context.Context, datastores []vimtypes.ManagedObjectReference,
+		profileID string) (pbm.PlacementCompatibilityResult, error)
+}
+
+// pbmClient implements pbmQuerier with a PBM client.
+type pbmClient struct {
+	*pbm.Client
+}
+
+// Profiles returns the storage requirement profiles.
+func (c *pbmClient) Profiles(ctx context.Context) ([]pbmtypes.BasePbmProfile, error) {
+	resourceType := pbmtypes.PbmProfileResourceType{
+		ResourceType: string(pbmtypes.PbmProfileResourceTypeEnumSTORAGE),
+	}
+	ids, err := c.QueryProfile(ctx, resourceType, string(pbmtypes.PbmProfileCategoryEnumREQUIREMENT))
+	if err != nil {
+		return nil, err
+	}
+	return c.RetrieveContent(ctx, ids)
+}
+
+// CheckCompatibility returns the compatibility of the given datastores with
+// the given profile.
+func (c *pbmClient) CheckCompatibility(ctx context.Context, datastores []vimtypes.ManagedObjectReference,
+	profileID string) (pbm.PlacementCompatibilityResult, error) {
+	hubs := make([]pbmtypes.PbmPlacementHub, 0)
+	for _, ds := range datastores {
+		hubs = append(hubs, pbmtypes.PbmPlacementHub{
+			HubType: ds.Type,
+			HubId:   ds.Value,
+		})
+	}
+	req := pbmtypes.PbmCheckCompatibility{
+		This:         c.ServiceContent.PlacementSolver,
+		HubsToSearch: hubs,
+		Profile: pbmtypes.PbmProfileId{
+			UniqueId: profileID,
+		},
+	}
+	res, err := pbmmethods.PbmCheckCompatibility(ctx, c, &req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Returnval, nil
+}
+
+// pbmCompatibilityEntry is a cached compatibility check.
+type pbmCompatibilityEntry struct {
+	result pbm.PlacementCompatibilityResult
+	expiry time.Time
+}
+
+// pbmCache caches the storage policy IDs by name and the compatibility of
+// the storage policies with sets of datastores of a vCenter. The storage
+// policies are fetched again after pbmProfilesRefreshInterval when queried,
+// and the cached compatibility checks are dropped if a storage policy was
+// created, updated or deleted meanwhile. Concurrent identical queries are sent
+// once to the vCenter.
+type pbmCache struct {
+	host            string
+	ttl             time.Duration
+	refreshInterval time.Duration
+	// now returns the current time. It is overridden in unit tests.
+	now   func() time.Time
+	group singleflight.Group
+
+	mu sync.Mutex
+	// profilesVersion identifies the state of the storage policies when they
+	// were last fetched.
+	profilesVersion   string
+	profilesFetchTime time.Time
+	// generation is incremented when the cached compatibility checks are
+	// dropped, so that the checks in flight meanwhile are not cached.
+	generation    uint64
+	policyIDs     map[string]string
+	compatibility map[string]pbmCompatibilityEntry
+}
+
+var (
+	// pbmCaches holds the pbmCache of each vCenter host.
+	pbmCaches = make(map[string]*pbmCache)
+	// pbmCachesLock guards pbmCaches.
+	pbmCachesLock = &sync.Mutex{}
+)
+
+func newPbmCache(host string) *pbmCache {
+	return &pbmCache{
+		host:            host,
+		ttl:             pbmCompatibilityCacheTTL,
+		refreshInterval: pbmProfilesRefreshInterval,
+		now:             time.Now,
+		policyIDs:       make(map[string]string),
+		compatibility:   make(map[string]pbmCompatibilityEntry),
+	}
+}
+
+// getPbmCache returns the pbmCache of the given vCenter host.
+func getPbmCache(host string) *pbmCache {
+	pbmCachesLock.Lock()
+	defer pbmCachesLock.Unlock()
+	cache, ok := pbmCaches[host]
+	if !ok {
+		cache = newPbmCache(host)
+		pbmCaches[host] = cache
+	}
+	return cache
+}
+
+// refreshProfiles fetches the storage policies if they were not fetched
+// within the refresh interval or if force is set, and drops the cached
+// compatibility checks if they changed. It returns true if they were fetched.
+func (c *pbmCache) refreshProfiles(ctx context.Context, q pbmQuerier, force bool) (bool, error) {
+	log := logger.GetLogger(ctx)
+	c.mu.Lock()
+	fresh := !c.profilesFetchTime.IsZero() && c.now().Sub(c.profilesFetchTime) < c.refreshInterval
+	c.mu.Unlock()
+	if fresh && !force {
+		return false, nil
+	}
+	_, err, _ := c.group.Do("profiles", func() (interface{}, error) {
+		profiles, err := q.Profiles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		policyIDs := make(map[string]string, len(profiles))
+		versions := make([]string, 0, len(profiles))
+		for _, p := range profiles {
+			profile := p.GetPbmProfile()
+			if _, ok := policyIDs[profile.Name]; !ok {
+				policyIDs[profile.Name] = profile.ProfileId.UniqueId
+			}
+			versions = append(versions, profile.ProfileId.UniqueId+"@"+
+				profile.LastUpdatedTime.UTC().Format(time.RFC3339Nano))
+		}
+		sort.Strings(versions)
+		version := strings.Join(versions, ",")
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !c.profilesFetchTime.IsZero() && version != c.profilesVersion {
+			log.Infof("Storage policies changed on vCenter %q, dropping the cached compatibility checks", c.host)
+			c.compatibility = make(map[string]pbmCompatibilityEntry)
+			c.generation++
+			prometheus.PbmCacheInvalidationsCounterVec.WithLabelValues(c.host).Inc()
+		}
+		c.profilesVersion = version
+		c.profilesFetchTime = c.now()
+		c.policyIDs = policyIDs
+		return nil, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// getPolicyID returns the ID of the storage policy with the given name.
+func (c *pbmCache) getPolicyID(ctx context.Context, q pbmQuerier, name string) (string, error) {
+	fetched, err := c.refreshProfiles(ctx, q, false)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	id, ok := c.policyIDs[name]
+	c.mu.Unlock()
+	if ok && !fetched {
+		prometheus.PbmCacheRequestsCounterVec.WithLabelValues(c.host, pbmPolicyIDCache, "hit").Inc()
+		return id, nil
+	}
+	prometheus.PbmCacheRequestsCounterVec.WithLabelValues(c.host, pbmPolicyIDCache, "miss").Inc()
+	if !ok && !fetched {
+		// The storage policy may have been created since the storage policies
+		// were last fetched.
+		if _, err := c.refreshProfiles(ctx, q, true); err != nil {
+			return "", err
+		}
+		c.mu.Lock()
+		id, ok = c.policyIDs[name]
+		c.mu.Unlock()
+	}
+	if !ok {
+		return "", fmt.Errorf("no pbm profile found with name: %q", name)
+	}
+	return id, nil
+}
+
+// pbmCompatibilityKey returns the key of the compatibility check of the given
+// profile with the given datastores, regardless of their order.
+func pbmCompatibilityKey(datastores []vimtypes.ManagedObjectReference, profileID string) string {
+	refs := make([]string, 0, len(datastores))
+	for _, ds := range datastores {
+		refs = append(refs, ds.Type+":"+ds.Value)
+	}
+	sort.Strings(refs)
+	return profileID + "/" + strings.Join(refs, ",")
+}
+
+// checkCompatibility returns the compatibility of the given datastores with
+// the given profile.
+func (c *pbmCache) checkCompatibility(ctx context.Context, q pbmQuerier,
+	datastores []vimtypes.ManagedObjectReference, profileID string) (pbm.PlacementCompatibilityResult, error) {
+	log := logger.GetLogger(ctx)
+	if _, err := c.refreshProfiles(ctx, q, false); err != nil {
+		log.Warnf("Failed to fetch the storage policies of vCenter %q to detect their changes. Err: %v",
+			c.host, err)
+	}
+	key := pbmCompatibilityKey(datastores, profileID)
+	c.mu.Lock()
+	entry, ok := c.compatibility[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiry) {
+		prometheus.PbmCacheRequestsCounterVec.WithLabelValues(c.host, pbmCompatibilityCache, "hit").Inc()
+		return append(pbm.PlacementCompatibilityResult(nil), entry.result...), nil
+	}
+	prometheus.PbmCacheRequestsCounterVec.WithLabelValues(c.host, pbmCompatibilityCache, "miss").Inc()
+	res, err, _ := c.group.Do(key, func() (interface{}, error) {
+		result, err := q.CheckCompatibility(ctx, datastores, profileID)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.generation == generation {
+			now := c.now()
+			for k, e := range c.compatibility {
+				if !now.Before(e.expiry) {
+					delete(c.compatibility, k)
+				}
+			}
+			c.compatibility[key] = pbmCompatibilityEntry{result: result, expiry: now.Add(c.ttl)}
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(pbm.PlacementCompatibilityResult(nil), res.(pbm.PlacementCompatibilityResult)...), nil
+}
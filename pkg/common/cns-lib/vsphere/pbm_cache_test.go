@@ -0,0 +1,269 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vmware/govmomi/pbm"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+)
+
+// fakePbmQuerier is a PBM client serving the given profiles, counting the
+// queries sent to it.
+type fakePbmQuerier struct {
+	mu                 sync.Mutex
+	profiles           map[string]*pbmtypes.PbmCapabilityProfile
+	profilesCalls      int
+	compatibilityCalls int
+	// release, if set, blocks the compatibility checks until it is closed.
+	release chan struct{}
+}
+
+func newFakePbmQuerier(names ...string) *fakePbmQuerier {
+	q := &fakePbmQuerier{profiles: make(map[string]*pbmtypes.PbmCapabilityProfile)}
+	for _, name := range names {
+		q.setProfile(name, time.Unix(0, 0))
+	}
+	return q
+}
+
+func (q *fakePbmQuerier) setProfile(name string, lastUpdatedTime time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.profiles[name] = &pbmtypes.PbmCapabilityProfile{
+		PbmProfile: pbmtypes.PbmProfile{
+			ProfileId:       pbmtypes.PbmProfileId{UniqueId: name + "-id"},
+			Name:            name,
+			LastUpdatedTime: lastUpdatedTime,
+		},
+	}
+}
+
+func (q *fakePbmQuerier) Profiles(ctx context.Context) ([]pbmtypes.BasePbmProfile, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.profilesCalls++
+	profiles := make([]pbmtypes.BasePbmProfile, 0, len(q.profiles))
+	for _, profile := range q.profiles {
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func (q *fakePbmQuerier) CheckCompatibility(ctx context.Context, datastores []vimtypes.ManagedObjectReference,
+	profileID string) (pbm.PlacementCompatibilityResult, error) {
+	q.mu.Lock()
+	q.compatibilityCalls++
+	release := q.release
+	q.mu.Unlock()
+	if release != nil {
+		<-release
+	}
+	var result pbm.PlacementCompatibilityResult
+	for _, ds := range datastores {
+		result = append(result, pbmtypes.PbmPlacementCompatibilityResult{
+			Hub: pbmtypes.PbmPlacementHub{HubType: ds.Type, HubId: ds.Value},
+		})
+	}
+	return result, nil
+}
+
+func (q *fakePbmQuerier) calls() (int, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.profilesCalls, q.compatibilityCalls
+}
+
+// newTestPbmCache returns a pbmCache whose clock is advanced by the returned
+// func.
+func newTestPbmCache(host string) (*pbmCache, func(time.Duration)) {
+	c := newPbmCache(host)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+func testDatastores(values ...string) []vimtypes.ManagedObjectReference {
+	var datastores []vimtypes.ManagedObjectReference
+	for _, value := range values {
+		datastores = append(datastores, vimtypes.ManagedObjectReference{Type: "Datastore", Value: value})
+	}
+	return datastores
+}
+
+func TestPbmCacheGetPolicyID(t *testing.T) {
+	ctx := context.Background()
+	host := "vc-pbm-policy-id"
+	c, _ := newTestPbmCache(host)
+	q := newFakePbmQuerier("gold")
+	hits := prometheus.PbmCacheRequestsCounterVec.WithLabelValues(host, pbmPolicyIDCache, "hit")
+	misses := prometheus.PbmCacheRequestsCounterVec.WithLabelValues(host, pbmPolicyIDCache, "miss")
+	hitsBefore, missesBefore := promtestutil.ToFloat64(hits), promtestutil.ToFloat64(misses)
+
+	for i := 0; i < 3; i++ {
+		id, err := c.getPolicyID(ctx, q, "gold")
+		if err != nil || id != "gold-id" {
+			t.Fatalf("call %d: expected policy ID \"gold-id\", got %q, err %v", i, id, err)
+		}
+	}
+	if profilesCalls, _ := q.calls(); profilesCalls != 1 {
+		t.Errorf("expected storage policies to be fetched once, got %d", profilesCalls)
+	}
+	if got := promtestutil.ToFloat64(hits) - hitsBefore; got != 2 {
+		t.Errorf("expected 2 hits, got %v", got)
+	}
+	if got := promtestutil.ToFloat64(misses) - missesBefore; got != 1 {
+		t.Errorf("expected 1 miss, got %v", got)
+	}
+
+	// A storage policy created since the last fetch is found.
+	q.setProfile("silver", time.Unix(0, 0))
+	if id, err := c.getPolicyID(ctx, q, "silver"); err != nil || id != "silver-id" {
+		t.Errorf("expected policy ID \"silver-id\", got %q, err %v", id, err)
+	}
+	if _, err := c.getPolicyID(ctx, q, "bronze"); err == nil {
+		t.Errorf("expected an error for an unknown storage policy")
+	}
+}
+
+func TestPbmCacheCheckCompatibility(t *testing.T) {
+	ctx := context.Background()
+	host := "vc-pbm-compatibility"
+	c, advance := newTestPbmCache(host)
+	q := newFakePbmQuerier("gold")
+	hits := prometheus.PbmCacheRequestsCounterVec.WithLabelValues(host, pbmCompatibilityCache, "hit")
+	hitsBefore := promtestutil.ToFloat64(hits)
+
+	result, err := c.checkCompatibility(ctx, q, testDatastores("ds-1", "ds-2"), "gold-id")
+	if err != nil || len(result.CompatibleDatastores()) != 2 {
+		t.Fatalf("expected 2 compatible datastores, got %v, err %v", result, err)
+	}
+	// The order of the datastores does not matter.
+	result, err = c.checkCompatibility(ctx, q, testDatastores("ds-2", "ds-1"), "gold-id")
+	if err != nil || len(result.CompatibleDatastores()) != 2 {
+		t.Fatalf("expected 2 compatible datastores, got %v, err %v", result, err)
+	}
+	if _, compatibilityCalls := q.calls(); compatibilityCalls != 1 {
+		t.Errorf("expected the compatibility to be checked once, got %d", compatibilityCalls)
+	}
+	if got := promtestutil.ToFloat64(hits) - hitsBefore; got != 1 {
+		t.Errorf("expected 1 hit, got %v", got)
+	}
+
+	// Other datastores or policies are checked separately.
+	if _, err := c.checkCompatibility(ctx, q, testDatastores("ds-1"), "gold-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.checkCompatibility(ctx, q, testDatastores("ds-1", "ds-2"), "silver-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, compatibilityCalls := q.calls(); compatibilityCalls != 3 {
+		t.Errorf("expected the compatibility to be checked 3 times, got %d", compatibilityCalls)
+	}
+
+	// Cached checks expire.
+	advance(pbmCompatibilityCacheTTL)
+	if _, err := c.checkCompatibility(ctx, q, testDatastores("ds-1", "ds-2"), "gold-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, compatibilityCalls := q.calls(); compatibilityCalls != 4 {
+		t.Errorf("expected the expired check to be done again, got %d checks", compatibilityCalls)
+	}
+}
+
+func TestPbmCacheInvalidatedOnProfileChange(t *testing.T) {
+	ctx := context.Background()
+	host := "vc-pbm-invalidation"
+	c, advance := newTestPbmCache(host)
+	q := newFakePbmQuerier("gold")
+	invalidations := prometheus.PbmCacheInvalidationsCounterVec.WithLabelValues(host)
+	invalidationsBefore := promtestutil.ToFloat64(invalidations)
+	datastores := testDatastores("ds-1")
+
+	if _, err := c.checkCompatibility(ctx, q, datastores, "gold-id"); err != nil {
+		t.Fatal(err)
+	}
+	// Unchanged storage policies keep the cached checks.
+	advance(pbmProfilesRefreshInterval)
+	if _, err := c.checkCompatibility(ctx, q, datastores, "gold-id"); err != nil {
+		t.Fatal(err)
+	}
+	profilesCalls, compatibilityCalls := q.calls()
+	if profilesCalls != 2 || compatibilityCalls != 1 {
+		t.Errorf("expected 2 fetches of the storage policies and 1 check, got %d and %d",
+			profilesCalls, compatibilityCalls)
+	}
+	// Changes are not detected before the refresh interval.
+	q.setProfile("gold", time.Unix(1, 0))
+	if _, err := c.checkCompatibility(ctx, q, datastores, "gold-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, compatibilityCalls := q.calls(); compatibilityCalls != 1 {
+		t.Errorf("expected the cached check to be used, got %d checks", compatibilityCalls)
+	}
+	advance(pbmProfilesRefreshInterval)
+	if _, err := c.checkCompatibility(ctx, q, datastores, "gold-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, compatibilityCalls := q.calls(); compatibilityCalls != 2 {
+		t.Errorf("expected the check to be done again after a storage policy change, got %d checks",
+			compatibilityCalls)
+	}
+	if got := promtestutil.ToFloat64(invalidations) - invalidationsBefore; got != 1 {
+		t.Errorf("expected 1 invalidation, got %v", got)
+	}
+}
+
+func TestPbmCacheCoalescesConcurrentChecks(t *testing.T) {
+	ctx := context.Background()
+	host := "vc-pbm-concurrent"
+	c, _ := newTestPbmCache(host)
+	q := newFakePbmQuerier("gold")
+	q.release = make(chan struct{})
+	misses := prometheus.PbmCacheRequestsCounterVec.WithLabelValues(host, pbmCompatibilityCache, "miss")
+	missesBefore := promtestutil.ToFloat64(misses)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := c.checkCompatibility(ctx, q, testDatastores("ds-1"), "gold-id")
+			if err != nil || len(result.CompatibleDatastores()) != 1 {
+				t.Errorf("expected 1 compatible datastore, got %v, err %v", result, err)
+			}
+		}()
+	}
+	for promtestutil.ToFloat64(misses)-missesBefore != callers {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(q.release)
+	wg.Wait()
+	if _, compatibilityCalls := q.calls(); compatibilityCalls != 1 {
+		t.Errorf("expected concurrent identical checks to be sent once, got %d", compatibilityCalls)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/soap"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// errInvalidProxyCA is returned when the proxy CA file has no PEM encoded
+// certificate.
+var errInvalidProxyCA = errors.New("no valid PEM encoded certificate found")
+
+// configureProxy sets up the transport of the given soap client to reach the
+// virtual center through the configured proxy. The transport is shared by the
+// CNS, PBM, VSLM and vSAN clients created from the soap client, while the
+// Kubernetes clients are not affected. The proxy environment variables are
+// used if no proxy is configured.
+func (vc *VirtualCenter) configureProxy(ctx context.Context, soapClient *soap.Client) error {
+	log := logger.GetLogger(ctx)
+	if vc.Config.ProxyURL == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(vc.Config.ProxyURL)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to parse proxy URL %q for vCenter %q. Error: %v",
+			vc.Config.ProxyURL, vc.Config.Host, err)
+	}
+	redactedProxyURL := proxyURL.Redacted()
+	// The thumbprint is verified when dialing the vCenter directly only, while
+	// the TLS connection tunneled through the proxy is verified with the CAs.
+	if vc.Config.Thumbprint != "" && vc.Config.CAFile == "" && !vc.Config.Insecure {
+		return logger.LogNewErrorf(log, "the certificate of vCenter %q can not be verified with a thumbprint "+
+			"through proxy %q, specify its CA file instead", vc.Config.Host, redactedProxyURL)
+	}
+	transport := soapClient.DefaultTransport()
+	switch {
+	case proxyURL.Scheme == "https":
+		tlsConfig := &tls.Config{
+			ServerName: proxyURL.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		}
+		if vc.Config.ProxyCAFile != "" {
+			if tlsConfig.RootCAs, err = appendProxyCA(nil, vc.Config.ProxyCAFile); err != nil {
+				return logger.LogNewErrorf(log, "failed to load proxy CA file %q for vCenter %q. Error: %v",
+					vc.Config.ProxyCAFile, vc.Config.Host, err)
+			}
+		}
+		// The transport would connect to an HTTPS proxy with the TLS config of
+		// the vCenter. Connect to the proxy over TLS with its own TLS config
+		// instead, and use it as an HTTP proxy over that connection.
+		proxyAddr := proxyURL.Host
+		if proxyURL.Port() == "" {
+			proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "443")
+		}
+		transport.DialContext = dialProxyTLS(transport.DialContext, proxyAddr, tlsConfig)
+		proxyURL = &url.URL{Scheme: "http", User: proxyURL.User, Host: proxyAddr}
+	case vc.Config.ProxyCAFile != "":
+		return logger.LogNewErrorf(log, "proxy CA file %q for vCenter %q requires an https proxy, got %q",
+			vc.Config.ProxyCAFile, vc.Config.Host, redactedProxyURL)
+	}
+	transport.Proxy = proxyFunc(proxyURL, vc.Config.NoProxy)
+	log.Infof("Connecting to vCenter %q through proxy %q", vc.Config.Host, redactedProxyURL)
+	return nil
+}
+
+// dialProxyTLS returns the http.Transport dial func which establishes a TLS
+// connection, with the given TLS config, to the proxy at the given address,
+// and plain connections to the other addresses with the given dial func.
+func dialProxyTLS(dial func(ctx context.Context, network, addr string) (net.Conn, error), proxyAddr string,
+	tlsConfig *tls.Config) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil || addr != proxyAddr {
+			return conn, err
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// appendProxyCA returns a copy of the given pool of root CAs, or of the
+// system's one if it is nil, with the CA certificates of the given file added.
+func appendProxyCA(pool *x509.CertPool, caFile string) (*x509.CertPool, error) {
+	if pool == nil {
+		var err error
+		pool, err = x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+	} else {
+		pool = pool.Clone()
+	}
+	pem, err := os.ReadFile(filepath.Clean(caFile))
+	if err != nil {
+		return nil, err
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errInvalidProxyCA
+	}
+	return pool, nil
+}
+
+// proxyFunc returns the http.Transport proxy func which sends the requests
+// through the given proxy, except the ones to the hosts matched by the given
+// comma separated no-proxy list.
+func proxyFunc(proxyURL *url.URL, noProxy string) func(*http.Request) (*url.URL, error) {
+	var noProxyEntries []string
+	for _, entry := range strings.Split(noProxy, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			noProxyEntries = append(noProxyEntries, entry)
+		}
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if matchesNoProxy(req.URL.Hostname(), noProxyEntries) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+// matchesNoProxy returns true if the given host is matched by one of the
+// no-proxy entries. An entry is either "*", an IP, a CIDR, or a domain name
+// which matches the domain and its subdomains, with or without a leading ".".
+func matchesNoProxy(host string, noProxyEntries []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range noProxyEntries {
+		if entry == "*" {
+			return true
+		}
+		if ip != nil {
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && cidr.Contains(ip) {
+				return true
+			}
+			if entryIP := net.ParseIP(entry); entryIP != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		domain := strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
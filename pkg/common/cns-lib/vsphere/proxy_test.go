@@ -0,0 +1,276 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// testProxy is a local stand-in for an HTTP(S) proxy which tunnels CONNECT
+// requests and records their targets.
+type testProxy struct {
+	server  *httptest.Server
+	mu      sync.Mutex
+	targets []string
+}
+
+func newTestProxy(t *testing.T, cert *tls.Certificate) *testProxy {
+	p := &testProxy{}
+	p.server = httptest.NewUnstartedServer(http.HandlerFunc(p.connect))
+	if cert != nil {
+		p.server.TLS = &tls.Config{Certificates: []tls.Certificate{*cert}}
+		p.server.StartTLS()
+	} else {
+		p.server.Start()
+	}
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testProxy) connect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.Lock()
+	p.targets = append(p.targets, r.Host)
+	p.mu.Unlock()
+	dst, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	src, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		_ = dst.Close()
+		return
+	}
+	if _, err := io.WriteString(src, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		_ = src.Close()
+		_ = dst.Close()
+		return
+	}
+	go func() {
+		_, _ = io.Copy(dst, src)
+		_ = dst.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(src, dst)
+		_ = src.Close()
+	}()
+}
+
+func (p *testProxy) getTargets() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// newTestProxyCert returns a self-signed certificate for 127.0.0.1 along with
+// the path of a file holding it in PEM format.
+func newTestProxyCert(t *testing.T) (*tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-proxy"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "proxy-ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
+
+// newTestVirtualCenter starts a vcsim instance, set up by the given funcs, and
+// returns the VirtualCenter to connect to it, trusting its certificate.
+func newTestVirtualCenter(t *testing.T, setups ...func(*simulator.Model)) *VirtualCenter {
+	model := simulator.VPX()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	for _, setup := range setups {
+		setup(model)
+	}
+	model.Service.TLS = new(tls.Config)
+	s := model.Service.NewServer()
+	t.Cleanup(func() {
+		s.Close()
+		model.Remove()
+	})
+	caFile, err := s.CertificateFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(s.URL.Port())
+	password, _ := s.URL.User.Password()
+	return &VirtualCenter{
+		Config: &VirtualCenterConfig{
+			Host:     s.URL.Hostname(),
+			Port:     port,
+			Username: s.URL.User.Username(),
+			Password: password,
+			CAFile:   caFile,
+		},
+		ClientMutex: &sync.Mutex{},
+	}
+}
+
+func TestNewClientThroughProxy(t *testing.T) {
+	ctx := context.Background()
+	vc := newTestVirtualCenter(t)
+	proxy := newTestProxy(t, nil)
+	vc.Config.ProxyURL = proxy.server.URL
+	client, err := vc.NewClient(ctx, "test")
+	if err != nil {
+		t.Fatalf("failed to connect to vCenter through proxy: %v", err)
+	}
+	// Service clients share the transport, and so the proxy, of the vim client.
+	pbmClient := client.Client.NewServiceClient("/pbm", "pbm")
+	if pbmClient.DefaultTransport() != client.Client.DefaultTransport() {
+		t.Errorf("expected service clients to use the transport configured with the proxy")
+	}
+	vcAddr := net.JoinHostPort(vc.Config.Host, strconv.Itoa(vc.Config.Port))
+	if targets := proxy.getTargets(); len(targets) == 0 || targets[0] != vcAddr {
+		t.Errorf("expected vCenter %q to be reached through proxy, got %v", vcAddr, targets)
+	}
+}
+
+func TestNewClientBypassesProxy(t *testing.T) {
+	ctx := context.Background()
+	vc := newTestVirtualCenter(t)
+	proxy := newTestProxy(t, nil)
+	vc.Config.ProxyURL = proxy.server.URL
+	vc.Config.NoProxy = "vcenter.example.com, 127.0.0.0/8"
+	if _, err := vc.NewClient(ctx, "test"); err != nil {
+		t.Fatalf("failed to connect to vCenter: %v", err)
+	}
+	if targets := proxy.getTargets(); len(targets) != 0 {
+		t.Errorf("expected vCenter in no-proxy list to be reached directly, got %v", targets)
+	}
+}
+
+func TestNewClientThroughHTTPSProxy(t *testing.T) {
+	ctx := context.Background()
+	cert, proxyCAFile := newTestProxyCert(t)
+	proxy := newTestProxy(t, cert)
+
+	vc := newTestVirtualCenter(t)
+	vc.Config.ProxyURL = proxy.server.URL
+	if _, err := vc.NewClient(ctx, "test"); err == nil {
+		t.Fatalf("expected connection through proxy with an untrusted certificate to fail")
+	}
+
+	vc.Config.ProxyCAFile = proxyCAFile
+	if _, err := vc.NewClient(ctx, "test"); err != nil {
+		t.Fatalf("failed to connect to vCenter through HTTPS proxy: %v", err)
+	}
+	if targets := proxy.getTargets(); len(targets) == 0 {
+		t.Errorf("expected vCenter to be reached through proxy")
+	}
+}
+
+func TestConfigureProxyTLS(t *testing.T) {
+	ctx := context.Background()
+	_, proxyCAFile := newTestProxyCert(t)
+	vcURL := &url.URL{Scheme: "https", Host: "vcenter.example.com", Path: "/sdk"}
+	vc := &VirtualCenter{Config: &VirtualCenterConfig{
+		Host:        "vcenter.example.com",
+		ProxyURL:    "https://proxy.example.com:3128",
+		ProxyCAFile: proxyCAFile,
+	}}
+	soapClient := soap.NewClient(vcURL, false)
+	if err := vc.configureProxy(ctx, soapClient); err != nil {
+		t.Fatalf("configureProxy() failed: %v", err)
+	}
+	// The proxy CA is not trusted for the vCenter.
+	if tlsConfig := soapClient.DefaultTransport().TLSClientConfig; tlsConfig != nil && tlsConfig.RootCAs != nil {
+		t.Errorf("expected the root CAs of the vCenter not to be changed")
+	}
+
+	// A proxy CA file requires an HTTPS proxy.
+	vc.Config.ProxyURL = "http://proxy.example.com:3128"
+	if err := vc.configureProxy(ctx, soap.NewClient(vcURL, false)); err == nil {
+		t.Errorf("expected configureProxy() to fail for a proxy CA file with an HTTP proxy")
+	}
+
+	// The thumbprint of the vCenter can not be verified through the proxy.
+	vc.Config.ProxyCAFile = ""
+	vc.Config.Thumbprint = "AA:BB:CC"
+	if err := vc.configureProxy(ctx, soap.NewClient(vcURL, false)); err == nil {
+		t.Errorf("expected configureProxy() to fail for a vCenter verified with a thumbprint")
+	}
+}
+
+func TestMatchesNoProxy(t *testing.T) {
+	noProxy := []string{"example.com", ".corp.local", "10.0.0.0/8", "192.168.1.10", "::1"}
+	for host, expected := range map[string]bool{
+		"example.com":      true,
+		"vc.example.com":   true,
+		"notexample.com":   false,
+		"vc.corp.local":    true,
+		"corp.local":       true,
+		"10.1.2.3":         true,
+		"11.1.2.3":         false,
+		"192.168.1.10":     true,
+		"192.168.1.11":     false,
+		"::1":              true,
+		"vcenter.internal": false,
+	} {
+		if got := matchesNoProxy(host, noProxy); got != expected {
+			t.Errorf("matchesNoProxy(%q) = %v, expected %v", host, got, expected)
+		}
+	}
+	if !matchesNoProxy("vcenter.internal", []string{"*"}) {
+		t.Errorf("expected \"*\" to match all hosts")
+	}
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"golang.org/x/time/rate"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// defaultCircuitBreakerOpenTimeout is the default time for which the vCenter
+// API calls fail fast once the circuit breaker is open.
+const defaultCircuitBreakerOpenTimeout = 30 * time.Second
+
+// ErrCircuitOpen is returned for the vCenter API calls which fail fast while
+// the circuit breaker of the vCenter is open.
+var ErrCircuitOpen = errors.New("vCenter circuit breaker is open, failing fast")
+
+// IsCircuitOpenError returns true if the given error, or the message of the
+// error it was formatted into, is ErrCircuitOpen.
+func IsCircuitOpenError(err error) bool {
+	return err != nil && (errors.Is(err, ErrCircuitOpen) || strings.Contains(err.Error(), ErrCircuitOpen.Error()))
+}
+
+// circuitState is the state of a circuitBreaker.
+type circuitState int
+
+const (
+	// circuitClosed lets the calls through.
+	circuitClosed circuitState = iota
+	// circuitOpen fails the calls fast.
+	circuitOpen
+	// circuitHalfOpen lets a single call through to probe vCenter.
+	circuitHalfOpen
+)
+
+// callOutcome is the outcome of a vCenter API call for a circuitBreaker.
+type callOutcome int
+
+const (
+	// callSucceeded is a call answered by vCenter, including with a fault.
+	callSucceeded callOutcome = iota
+	// callFailed is a call not answered by vCenter, e.g. for a network error.
+	callFailed
+	// callAbandoned is a call given up by the caller, which tells nothing
+	// about the health of vCenter.
+	callAbandoned
+)
+
+// circuitBreaker fails the calls to a vCenter fast once a number of
+// consecutive calls failed, until a probe call succeeds after a timeout.
+type circuitBreaker struct {
+	host             string
+	failureThreshold int
+	openTimeout      time.Duration
+	now              func() time.Time
+
+	mu                  sync.Mutex
+	state               circuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	probing             bool
+}
+
+func newCircuitBreaker(host string, failureThreshold int, openTimeout time.Duration) *circuitBreaker {
+	prometheus.VCenterCircuitBreakerStateGaugeVec.WithLabelValues(host).Set(float64(circuitClosed))
+	return &circuitBreaker{
+		host:             host,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// allow returns ErrCircuitOpen if the call is to fail fast. Otherwise the
+// outcome of the call is to be reported with done.
+func (cb *circuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case circuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.openTimeout {
+			return ErrCircuitOpen
+		}
+		cb.setState(circuitHalfOpen)
+		cb.probing = true
+	case circuitHalfOpen:
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+	}
+	return nil
+}
+
+// done reports the outcome of a call let through by allow.
+func (cb *circuitBreaker) done(ctx context.Context, outcome callOutcome) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	wasProbe := cb.state == circuitHalfOpen && cb.probing
+	if wasProbe {
+		cb.probing = false
+	}
+	switch outcome {
+	case callSucceeded:
+		cb.consecutiveFailures = 0
+		if cb.state != circuitClosed {
+			logger.GetLogger(ctx).Infof("vCenter %q is reachable again, closing the circuit breaker", cb.host)
+			cb.setState(circuitClosed)
+		}
+	case callFailed:
+		cb.consecutiveFailures++
+		if wasProbe || (cb.state == circuitClosed && cb.consecutiveFailures >= cb.failureThreshold) {
+			logger.GetLogger(ctx).Warnf("%d consecutive calls to vCenter %q failed, failing the calls fast for %v",
+				cb.consecutiveFailures, cb.host, cb.openTimeout)
+			cb.openedAt = cb.now()
+			cb.setState(circuitOpen)
+		}
+	}
+}
+
+func (cb *circuitBreaker) setState(state circuitState) {
+	cb.state = state
+	prometheus.VCenterCircuitBreakerStateGaugeVec.WithLabelValues(cb.host).Set(float64(state))
+}
+
+// apiThrottle holds the rate limiter and the circuit breaker shared by all
+// the clients of a vCenter.
+type apiThrottle struct {
+	rateLimit        float64
+	burst            int
+	limiter          *rate.Limiter
+	failureThreshold int
+	openTimeout      time.Duration
+	circuitBreaker   *circuitBreaker
+}
+
+var (
+	// apiThrottles holds the apiThrottle of each vCenter host.
+	apiThrottles = make(map[string]*apiThrottle)
+	// apiThrottlesLock guards apiThrottles.
+	apiThrottlesLock = &sync.Mutex{}
+)
+
+// getAPIThrottle returns the apiThrottle for the given vCenter config, or nil
+// if neither the rate limit nor the circuit breaker are enabled. The apiThrottle
+// is rebuilt if the config changed.
+func getAPIThrottle(cfg *VirtualCenterConfig) *apiThrottle {
+	if cfg.APIRateLimit <= 0 && cfg.CircuitBreakerFailureThreshold <= 0 {
+		return nil
+	}
+	apiThrottlesLock.Lock()
+	defer apiThrottlesLock.Unlock()
+	throttle, ok := apiThrottles[cfg.Host]
+	if ok && throttle.rateLimit == cfg.APIRateLimit && throttle.burst == cfg.APIBurst &&
+		throttle.failureThreshold == cfg.CircuitBreakerFailureThreshold &&
+		throttle.openTimeout == cfg.CircuitBreakerOpenTimeout {
+		return throttle
+	}
+	throttle = &apiThrottle{
+		rateLimit:        cfg.APIRateLimit,
+		burst:            cfg.APIBurst,
+		failureThreshold: cfg.CircuitBreakerFailureThreshold,
+		openTimeout:      cfg.CircuitBreakerOpenTimeout,
+	}
+	if cfg.APIRateLimit > 0 {
+		throttle.limiter = rate.NewLimiter(rate.Limit(cfg.APIRateLimit), cfg.APIBurst)
+	}
+	if cfg.CircuitBreakerFailureThreshold > 0 {
+		throttle.circuitBreaker = newCircuitBreaker(cfg.Host, cfg.CircuitBreakerFailureThreshold,
+			cfg.CircuitBreakerOpenTimeout)
+	}
+	apiThrottles[cfg.Host] = throttle
+	return throttle
+}
+
+// ThrottledRoundTripper rate limits the vCenter API calls and fails them fast
+// with ErrCircuitOpen while vCenter is unreachable.
+type ThrottledRoundTripper struct {
+	throttle     *apiThrottle
+	roundTripper soap.RoundTripper
+}
+
+// throttledRoundTripper wraps the given round tripper of a client of the
+// vCenter in a ThrottledRoundTripper, if throttling is enabled for it.
+func (vc *VirtualCenter) throttledRoundTripper(rt soap.RoundTripper) soap.RoundTripper {
+	throttle := getAPIThrottle(vc.Config)
+	if throttle == nil {
+		return rt
+	}
+	return &ThrottledRoundTripper{throttle: throttle, roundTripper: rt}
+}
+
+// RoundTrip implements the soap.RoundTripper interface.
+func (trt *ThrottledRoundTripper) RoundTrip(ctx context.Context, req, resp soap.HasFault) error {
+	cb := trt.throttle.circuitBreaker
+	if cb != nil {
+		if err := cb.allow(); err != nil {
+			return err
+		}
+	}
+	if trt.throttle.limiter != nil {
+		if err := trt.throttle.limiter.Wait(ctx); err != nil {
+			if cb != nil {
+				cb.done(ctx, callAbandoned)
+			}
+			return err
+		}
+	}
+	err := trt.roundTripper.RoundTrip(ctx, req, resp)
+	if cb != nil {
+		cb.done(ctx, getCallOutcome(ctx, err))
+	}
+	return err
+}
+
+// getCallOutcome returns the outcome of a vCenter API call which returned the
+// given error.
+func getCallOutcome(ctx context.Context, err error) callOutcome {
+	switch {
+	case err == nil, soap.IsSoapFault(err), soap.IsVimFault(err):
+		return callSucceeded
+	case ctx.Err() != nil:
+		return callAbandoned
+	default:
+		return callFailed
+	}
+}
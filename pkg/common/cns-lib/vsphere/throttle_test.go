@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// fakeRoundTripper returns the given error for every call and counts them.
+type fakeRoundTripper struct {
+	err   error
+	calls int
+}
+
+func (f *fakeRoundTripper) RoundTrip(ctx context.Context, req, resp soap.HasFault) error {
+	f.calls++
+	return f.err
+}
+
+func newTestThrottledRoundTripper(t *testing.T, cfg *VirtualCenterConfig,
+	rt soap.RoundTripper) (*ThrottledRoundTripper, *time.Time) {
+	t.Cleanup(func() {
+		apiThrottlesLock.Lock()
+		delete(apiThrottles, cfg.Host)
+		apiThrottlesLock.Unlock()
+	})
+	vc := &VirtualCenter{Config: cfg}
+	trt, ok := vc.throttledRoundTripper(rt).(*ThrottledRoundTripper)
+	if !ok {
+		t.Fatalf("expected a ThrottledRoundTripper for config %+v", cfg)
+	}
+	now := time.Now()
+	if cb := trt.throttle.circuitBreaker; cb != nil {
+		cb.now = func() time.Time { return now }
+	}
+	return trt, &now
+}
+
+func TestThrottledRoundTripperDisabled(t *testing.T) {
+	vc := &VirtualCenter{Config: &VirtualCenterConfig{Host: "vc-disabled"}}
+	rt := &fakeRoundTripper{}
+	if got := vc.throttledRoundTripper(rt); got != rt {
+		t.Errorf("expected the round tripper not to be wrapped, got %T", got)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	rt := &fakeRoundTripper{err: errors.New("connection refused")}
+	trt, now := newTestThrottledRoundTripper(t, &VirtualCenterConfig{
+		Host:                           "vc-breaker",
+		CircuitBreakerFailureThreshold: 3,
+		CircuitBreakerOpenTimeout:      time.Minute,
+	}, rt)
+
+	for i := 0; i < 3; i++ {
+		if err := trt.RoundTrip(ctx, nil, nil); err != rt.err {
+			t.Fatalf("call %d: expected error %v, got %v", i, rt.err, err)
+		}
+	}
+	// The circuit is open, so calls fail fast without reaching vCenter.
+	err := trt.RoundTrip(ctx, nil, nil)
+	if !errors.Is(err, ErrCircuitOpen) || rt.calls != 3 {
+		t.Fatalf("expected call to fail fast, got %v after %d calls", err, rt.calls)
+	}
+	if !IsCircuitOpenError(fmt.Errorf("failed to query volume. Error: %v", err)) {
+		t.Errorf("expected formatted error to be a circuit open error")
+	}
+
+	// After the timeout, a single probe call is let through. It fails, so the
+	// circuit opens again.
+	*now = now.Add(time.Minute)
+	if err := trt.RoundTrip(ctx, nil, nil); err != rt.err {
+		t.Fatalf("expected probe call to reach vCenter, got %v", err)
+	}
+	if err := trt.RoundTrip(ctx, nil, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected call to fail fast after failed probe, got %v", err)
+	}
+
+	// A successful probe closes the circuit.
+	*now = now.Add(time.Minute)
+	rt.err = nil
+	for i := 0; i < 2; i++ {
+		if err := trt.RoundTrip(ctx, nil, nil); err != nil {
+			t.Fatalf("call %d: expected call to succeed, got %v", i, err)
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresFaults(t *testing.T) {
+	ctx := context.Background()
+	fault := soap.WrapVimFault(&types.NotFound{})
+	rt := &fakeRoundTripper{err: fault}
+	trt, _ := newTestThrottledRoundTripper(t, &VirtualCenterConfig{
+		Host:                           "vc-faults",
+		CircuitBreakerFailureThreshold: 1,
+		CircuitBreakerOpenTimeout:      time.Minute,
+	}, rt)
+	for i := 0; i < 3; i++ {
+		if err := trt.RoundTrip(ctx, nil, nil); err != fault {
+			t.Fatalf("call %d: expected fault %v, got %v", i, fault, err)
+		}
+	}
+	if rt.calls != 3 {
+		t.Errorf("expected faults answered by vCenter not to open the circuit, got %d calls", rt.calls)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	rt := &fakeRoundTripper{}
+	trt, _ := newTestThrottledRoundTripper(t, &VirtualCenterConfig{
+		Host:         "vc-rate-limit",
+		APIRateLimit: 1,
+		APIBurst:     2,
+	}, rt)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	// The burst is let through at once, the next call has to wait for a token
+	// longer than the caller is willing to.
+	for i := 0; i < 2; i++ {
+		if err := trt.RoundTrip(ctx, nil, nil); err != nil {
+			t.Fatalf("call %d: expected call within burst to succeed, got %v", i, err)
+		}
+	}
+	if err := trt.RoundTrip(ctx, nil, nil); err == nil {
+		t.Errorf("expected call above the rate limit to be throttled")
+	}
+	if rt.calls != 2 {
+		t.Errorf("expected 2 calls to reach vCenter, got %d", rt.calls)
+	}
+}
@@ -6,13 +6,14 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"math"
 	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
-	"github.com/vmware/govmomi/cns"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/sts"
 	"github.com/vmware/govmomi/vapi/rest"
@@ -32,6 +33,9 @@ const (
 	// VSphere70u3Version is a 3 digit value to indicate the minimum vSphere
 	// version to use query volume async API.
 	VSphere70u3Version int = 703
+	// VSphere80u3Version is a 3 digit value to indicate the minimum vSphere
+	// version whose CNS AttachVolume places the disk on a given controller slot.
+	VSphere80u3Version int = 803
 )
 
 var (
@@ -213,7 +217,12 @@ func GetVirtualCenterConfig(ctx context.Context, cfg *config.Config) (*VirtualCe
 		QueryLimit:                       cfg.Global.QueryLimit,
 		ListVolumeThreshold:              cfg.Global.ListVolumeThreshold,
 		MigrationDataStoreURL:            cfg.VirtualCenter[host].MigrationDataStoreURL,
+		ProxyURL:                         cfg.VirtualCenter[host].ProxyURL,
+		NoProxy:                          cfg.VirtualCenter[host].NoProxy,
+		ProxyCAFile:                      cfg.VirtualCenter[host].ProxyCAFile,
 	}
+	setAPIThrottleConfig(ctx, vcConfig, cfg.VirtualCenter[host])
+	setConcurrencyLimitsConfig(ctx, vcConfig, cfg.VirtualCenter[host])
 
 	log.Debugf("Setting the queryLimit = %v, ListVolumeThreshold = %v", vcConfig.QueryLimit, vcConfig.ListVolumeThreshold)
 	if strings.TrimSpace(cfg.VirtualCenter[host].Datacenters) != "" {
@@ -288,7 +297,12 @@ func GetVirtualCenterConfigs(ctx context.Context, cfg *config.Config) ([]*Virtua
 			VCClientTimeout:                  vcClientTimeout,
 			QueryLimit:                       cfg.Global.QueryLimit,
 			ListVolumeThreshold:              cfg.Global.ListVolumeThreshold,
+			ProxyURL:                         cfg.VirtualCenter[vCenterIP].ProxyURL,
+			NoProxy:                          cfg.VirtualCenter[vCenterIP].NoProxy,
+			ProxyCAFile:                      cfg.VirtualCenter[vCenterIP].ProxyCAFile,
 		}
+		setAPIThrottleConfig(ctx, vcConfig, cfg.VirtualCenter[vCenterIP])
+		setConcurrencyLimitsConfig(ctx, vcConfig, cfg.VirtualCenter[vCenterIP])
 
 		log.Debugf("Setting the queryLimit = %v, ListVolumeThreshold = %v", vcConfig.QueryLimit, vcConfig.ListVolumeThreshold)
 		if strings.TrimSpace(cfg.VirtualCenter[vCenterIP].Datacenters) != "" {
@@ -313,6 +327,57 @@ func GetVirtualCenterConfigs(ctx context.Context, cfg *config.Config) ([]*Virtua
 	return VirtualCenterConfigs, nil
 }
 
+// setAPIThrottleConfig sets the rate limit and circuit breaker of the vCenter
+// API calls in the given VirtualCenterConfig from the given [VirtualCenter]
+// section of the vSphere config.
+func setAPIThrottleConfig(ctx context.Context, vcConfig *VirtualCenterConfig, cfg *config.VirtualCenterConfig) {
+	log := logger.GetLogger(ctx)
+	if cfg.APIRateLimit < 0 {
+		log.Warnf("Invalid value %v is specified as api-rate-limit for vCenter %q. Disabling the rate limit.",
+			cfg.APIRateLimit, vcConfig.Host)
+	} else if cfg.APIRateLimit > 0 {
+		vcConfig.APIRateLimit = cfg.APIRateLimit
+		vcConfig.APIBurst = cfg.APIBurst
+		if vcConfig.APIBurst <= 0 {
+			vcConfig.APIBurst = int(math.Ceil(cfg.APIRateLimit))
+		}
+	}
+	if cfg.CircuitBreakerFailureThreshold < 0 {
+		log.Warnf("Invalid value %v is specified as circuit-breaker-failure-threshold for vCenter %q. "+
+			"Disabling the circuit breaker.", cfg.CircuitBreakerFailureThreshold, vcConfig.Host)
+	} else if cfg.CircuitBreakerFailureThreshold > 0 {
+		vcConfig.CircuitBreakerFailureThreshold = cfg.CircuitBreakerFailureThreshold
+		vcConfig.CircuitBreakerOpenTimeout = time.Duration(cfg.CircuitBreakerOpenTimeoutInSec) * time.Second
+		if vcConfig.CircuitBreakerOpenTimeout <= 0 {
+			vcConfig.CircuitBreakerOpenTimeout = defaultCircuitBreakerOpenTimeout
+		}
+	}
+}
+
+// setConcurrencyLimitsConfig sets the maximum numbers of concurrent CNS
+// operations of each kind in the given VirtualCenterConfig from the given
+// [VirtualCenter] section of the vSphere config.
+func setConcurrencyLimitsConfig(ctx context.Context, vcConfig *VirtualCenterConfig, cfg *config.VirtualCenterConfig) {
+	log := logger.GetLogger(ctx)
+	for _, limit := range []struct {
+		name  string
+		value int
+		field *int
+	}{
+		{"max-concurrent-create-delete-ops", cfg.MaxConcurrentCreateDeleteOps, &vcConfig.MaxConcurrentCreateDeleteOps},
+		{"max-concurrent-attach-detach-ops", cfg.MaxConcurrentAttachDetachOps, &vcConfig.MaxConcurrentAttachDetachOps},
+		{"max-concurrent-query-ops", cfg.MaxConcurrentQueryOps, &vcConfig.MaxConcurrentQueryOps},
+		{"max-concurrent-snapshot-ops", cfg.MaxConcurrentSnapshotOps, &vcConfig.MaxConcurrentSnapshotOps},
+	} {
+		if limit.value < 0 {
+			log.Warnf("Invalid value %v is specified as %s for vCenter %q. Not limiting the operations.",
+				limit.value, limit.name, vcConfig.Host)
+			continue
+		}
+		*limit.field = limit.value
+	}
+}
+
 // GetVcenterIPs returns list of vCenter IPs from VSphereConfig.
 func GetVcenterIPs(cfg *config.Config) ([]string, error) {
 	var err error
@@ -519,24 +584,23 @@ func GetDatastoreInfoByURL(ctx context.Context, vc *VirtualCenter, clusterID, ds
 	return nil, fmt.Errorf("datastore corresponding to URL %v not found in cluster %v", dsURL, clusterID)
 }
 
-// isVsan67u3Release returns true if it is vSAN 67u3 Release of vCenter.
-func isVsan67u3Release(ctx context.Context, m *defaultVirtualCenterManager, host string) (bool, error) {
-	log := logger.GetLogger(ctx)
-	log.Debug("Checking if vCenter version is of vsan 67u3 release")
-	vc, err := m.GetVirtualCenter(ctx, host)
-	if err != nil || vc == nil {
-		log.Errorf("failed to get vcenter version. Err: %v", err)
-		return false, err
-	}
-	log.Debugf("vCenter version is :%q", vc.Client.Version)
-	return vc.Client.Version == cns.ReleaseVSAN67u3, nil
-}
-
 // IsvSphereVersion70U3orAbove checks if specified version is 7.0 Update 3 or
 // higher. The method takes aboutInfo as input which contains details about
 // VC version, build number and so on. If the version is 7.0 Update 3 or higher,
 // returns true, else returns false along with appropriate errors for the failue.
 func IsvSphereVersion70U3orAbove(ctx context.Context, aboutInfo types.AboutInfo) (bool, error) {
+	return isvSphereVersionOrAbove(ctx, aboutInfo, VSphere70u3Version)
+}
+
+// IsvSphereVersion80U3orAbove checks if specified version is 8.0 Update 3 or
+// higher.
+func IsvSphereVersion80U3orAbove(ctx context.Context, aboutInfo types.AboutInfo) (bool, error) {
+	return isvSphereVersionOrAbove(ctx, aboutInfo, VSphere80u3Version)
+}
+
+// isvSphereVersionOrAbove checks if specified version is the given 3 digit
+// vSphere version or higher.
+func isvSphereVersionOrAbove(ctx context.Context, aboutInfo types.AboutInfo, minVersion int) (bool, error) {
 	log := logger.GetLogger(ctx)
 	items := strings.Split(aboutInfo.Version, ".")
 	version := strings.Join(items[:], "")
@@ -546,8 +610,7 @@ func IsvSphereVersion70U3orAbove(ctx context.Context, aboutInfo types.AboutInfo)
 		if err != nil {
 			return false, logger.LogNewErrorf(log, "error while converting version %q to integer, err %+v", version, err)
 		}
-		// Check if the current vSphere version is 7.0.3 or higher.
-		if vSphereVersionInt >= VSphere70u3Version {
+		if vSphereVersionInt >= minVersion {
 			return true, nil
 		}
 	}
@@ -43,8 +43,10 @@ import (
 	"github.com/vmware/govmomi/vim25/types"
 	"github.com/vmware/govmomi/vsan"
 	"github.com/vmware/govmomi/vslm"
+	"go.opentelemetry.io/otel/trace"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/tracing"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
@@ -151,6 +153,41 @@ type VirtualCenterConfig struct {
 	// when ReloadVCConfigForNewClient is set to true it forces re-read config secret when
 	// new vc client needs to be created
 	ReloadVCConfigForNewClient bool
+	// APIRateLimit is the maximum number of vCenter API calls per second. The
+	// rate limit is disabled if it is 0.
+	APIRateLimit float64
+	// APIBurst is the number of vCenter API calls which can be made at once
+	// above the APIRateLimit.
+	APIBurst int
+	// CircuitBreakerFailureThreshold is the number of consecutive failed vCenter
+	// API calls after which the calls fail fast. The circuit breaker is disabled
+	// if it is 0.
+	CircuitBreakerFailureThreshold int
+	// CircuitBreakerOpenTimeout is the time for which the vCenter API calls fail
+	// fast before a call is let through to probe vCenter.
+	CircuitBreakerOpenTimeout time.Duration
+	// MaxConcurrentCreateDeleteOps is the maximum number of concurrent CNS
+	// create, delete and expand volume operations. Not limited if it is 0.
+	MaxConcurrentCreateDeleteOps int
+	// MaxConcurrentAttachDetachOps is the maximum number of concurrent CNS attach
+	// and detach volume operations. Not limited if it is 0.
+	MaxConcurrentAttachDetachOps int
+	// MaxConcurrentQueryOps is the maximum number of concurrent CNS query
+	// operations. Not limited if it is 0.
+	MaxConcurrentQueryOps int
+	// MaxConcurrentSnapshotOps is the maximum number of concurrent CNS create and
+	// delete snapshot operations. Not limited if it is 0.
+	MaxConcurrentSnapshotOps int
+	// ProxyURL is the HTTP(S) proxy through which the vSphere, CNS, PBM and VSLM
+	// clients reach the virtual center. The proxy environment variables are used
+	// if it is not set.
+	ProxyURL string
+	// NoProxy is a comma separated list of hosts, domains, IPs and CIDRs which
+	// are reached without the ProxyURL.
+	NoProxy string
+	// ProxyCAFile specifies the path to the CA certificate in PEM format of an
+	// HTTPS ProxyURL.
+	ProxyCAFile string
 }
 
 // NewClient creates a new govmomi Client instance.
@@ -176,6 +213,9 @@ func (vc *VirtualCenter) NewClient(ctx context.Context, useragent string) (*govm
 		soapClient.SetThumbprint(url.Host, vc.Config.Thumbprint)
 		log.Debugf("using thumbprint %s for url %s ", vc.Config.Thumbprint, url.Host)
 	}
+	if err := vc.configureProxy(ctx, soapClient); err != nil {
+		return nil, err
+	}
 
 	soapClient.Timeout = time.Duration(vc.Config.VCClientTimeout) * time.Minute
 	log.Debugf("Setting vCenter soap client timeout to %v", soapClient.Timeout)
@@ -219,7 +259,7 @@ func (vc *VirtualCenter) NewClient(ctx context.Context, useragent string) (*govm
 		vc.Config.RoundTripperCount = DefaultRoundTripperCount
 	}
 	rt := vim25.Retry(client.RoundTripper, vim25.TemporaryNetworkError(vc.Config.RoundTripperCount))
-	client.RoundTripper = &MetricRoundTripper{"soap", rt}
+	client.RoundTripper = vc.throttledRoundTripper(&MetricRoundTripper{"soap", rt})
 	return client, nil
 }
 
@@ -314,6 +354,7 @@ func (vc *VirtualCenter) connect(ctx context.Context, requestNewSession bool) er
 			return err
 		}
 		log.Infof("VirtualCenter.connect() successfully created new client")
+		vc.discoverCapabilities(ctx, vc.Client)
 		return nil
 	}
 	if !requestNewSession {
@@ -344,13 +385,14 @@ func (vc *VirtualCenter) connect(ctx context.Context, requestNewSession bool) er
 		}
 		return err
 	}
+	vc.discoverCapabilities(ctx, vc.Client)
 	// Recreate PbmClient if created using timed out VC Client.
 	if vc.PbmClient != nil {
 		if vc.PbmClient, err = pbm.NewClient(ctx, vc.Client.Client); err != nil {
 			log.Errorf("failed to create pbm client with err: %v", err)
 			return err
 		}
-		vc.PbmClient.RoundTripper = &MetricRoundTripper{"pbm", vc.PbmClient.RoundTripper}
+		vc.PbmClient.RoundTripper = vc.throttledRoundTripper(&MetricRoundTripper{"pbm", vc.PbmClient.RoundTripper})
 	}
 	// Recreate CNSClient if created using timed out VC Client.
 	if vc.CnsClient != nil {
@@ -359,6 +401,7 @@ func (vc *VirtualCenter) connect(ctx context.Context, requestNewSession bool) er
 				vc.Config.Host, err)
 			return err
 		}
+		vc.CnsClient.RoundTripper = vc.throttledRoundTripper(vc.CnsClient.RoundTripper)
 	}
 	// Recreate VslmClient if created using timed out VC Client.
 	if vc.VslmClient != nil {
@@ -374,7 +417,7 @@ func (vc *VirtualCenter) connect(ctx context.Context, requestNewSession bool) er
 			log.Errorf("failed to create vsan client with err: %v", err)
 			return err
 		}
-		vc.VsanClient.RoundTripper = &MetricRoundTripper{"vsan", vc.VsanClient.RoundTripper}
+		vc.VsanClient.RoundTripper = vc.throttledRoundTripper(&MetricRoundTripper{"vsan", vc.VsanClient.RoundTripper})
 	}
 	return nil
 }
@@ -770,12 +813,36 @@ func (vc *VirtualCenter) GetAllVirtualMachines(ctx context.Context,
 	return virtualMachines, nil
 }
 
+// longPollMethods are the PropertyCollector calls which block on the vCenter
+// until an update is available or their timeout expires. They are not
+// recorded as spans, as their duration does not reflect the latency of the
+// vCenter, and the property collectors waiting for updates would fill the
+// traces with them.
+var longPollMethods = map[string]struct{}{
+	"WaitForUpdatesEx": {},
+	"WaitForUpdates":   {},
+	"CheckForUpdates":  {},
+}
+
+// RoundTrip implements the soap.RoundTripper interface. It records the
+// duration of the vCenter API call in the metrics, and a span holding its
+// operation ID unless the call is a long poll of a PropertyCollector.
 func (mrt *MetricRoundTripper) RoundTrip(ctx context.Context, req, resp soap.HasFault) error {
 	vreq := reflect.ValueOf(req).Elem().FieldByName("Req").Elem()
 	requestName := vreq.Type().Name()
+	var span trace.Span
+	if _, ok := longPollMethods[requestName]; !ok {
+		ctx, span = tracing.StartSpan(ctx, mrt.clientName+"/"+requestName,
+			tracing.VCenterClientKey.String(mrt.clientName), tracing.VCenterMethodKey.String(requestName))
+		defer span.End()
+		ctx = withOpID(ctx, span)
+	}
 	requestTime := time.Now()
 	err := mrt.roundTripper.RoundTrip(ctx, req, resp)
 	if err != nil {
+		if span != nil {
+			tracing.RecordError(span, err)
+		}
 		timeTaken := time.Since(requestTime).Seconds()
 		prometheus.RequestOpsMetric.WithLabelValues(requestName, mrt.clientName, statusFailUnknown).Observe(timeTaken)
 		return err
@@ -785,3 +852,22 @@ func (mrt *MetricRoundTripper) RoundTrip(ctx context.Context, req, resp soap.Has
 	prometheus.RequestOpsMetric.WithLabelValues(requestName, mrt.clientName, statusSuccess).Observe(timeTaken)
 	return nil
 }
+
+// withOpID returns the context to send the vCenter API call recorded by the
+// given span with, and sets the operation ID of the call on the span. If the
+// caller did not set the operation ID, the IDs of the trace and the span are
+// sent as operation ID while the span is sampled, so that the vCenter logs
+// and the tasks of the call can be matched to the trace.
+func withOpID(ctx context.Context, span trace.Span) context.Context {
+	opID, ok := ctx.Value(types.ID{}).(string)
+	if !ok {
+		spanContext := span.SpanContext()
+		if !spanContext.IsSampled() {
+			return ctx
+		}
+		opID = spanContext.TraceID().String() + "-" + spanContext.SpanID().String()
+		ctx = context.WithValue(ctx, types.ID{}, opID)
+	}
+	span.SetAttributes(tracing.OpIDKey.String(opID))
+	return ctx
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/tracing"
+)
+
+// opIDRoundTripper returns the given error for every call and records the
+// operation ID the calls are sent with.
+type opIDRoundTripper struct {
+	err   error
+	opIDs []string
+}
+
+func (o *opIDRoundTripper) RoundTrip(ctx context.Context, req, resp soap.HasFault) error {
+	opID, _ := ctx.Value(types.ID{}).(string)
+	o.opIDs = append(o.opIDs, opID)
+	return o.err
+}
+
+// newTestExporter records the spans in the returned in-memory exporter for
+// the duration of the test.
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+	return exporter
+}
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) string {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestMetricRoundTripperTracing(t *testing.T) {
+	exporter := newTestExporter(t)
+	rt := &opIDRoundTripper{}
+	mrt := &MetricRoundTripper{"cns", rt}
+	newRequest := func() *methods.RetrieveServiceContentBody {
+		return &methods.RetrieveServiceContentBody{Req: &types.RetrieveServiceContent{}}
+	}
+
+	// The operation ID is derived from the span if the caller did not set it.
+	if err := mrt.RoundTrip(context.Background(), newRequest(), newRequest()); err != nil {
+		t.Fatal(err)
+	}
+	// The operation ID set by the caller is kept.
+	ctx := context.WithValue(context.Background(), types.ID{}, "caller-op-id")
+	if err := mrt.RoundTrip(ctx, newRequest(), newRequest()); err != nil {
+		t.Fatal(err)
+	}
+	rt.err = errors.New("connection refused")
+	if err := mrt.RoundTrip(ctx, newRequest(), newRequest()); err == nil {
+		t.Fatalf("expected the error of the call to be returned")
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	expectedOpID := span.SpanContext.TraceID().String() + "-" + span.SpanContext.SpanID().String()
+	if span.Name != "cns/RetrieveServiceContent" || spanAttribute(span, tracing.VCenterClientKey) != "cns" ||
+		spanAttribute(span, tracing.VCenterMethodKey) != "RetrieveServiceContent" {
+		t.Errorf("unexpected span %q with attributes %v", span.Name, span.Attributes)
+	}
+	if rt.opIDs[0] != expectedOpID || spanAttribute(span, tracing.OpIDKey) != expectedOpID {
+		t.Errorf("expected operation ID %q to be sent and recorded, got %q and %q", expectedOpID, rt.opIDs[0],
+			spanAttribute(span, tracing.OpIDKey))
+	}
+	if rt.opIDs[1] != "caller-op-id" || spanAttribute(spans[1], tracing.OpIDKey) != "caller-op-id" {
+		t.Errorf("expected operation ID \"caller-op-id\" to be sent and recorded, got %q and %q", rt.opIDs[1],
+			spanAttribute(spans[1], tracing.OpIDKey))
+	}
+	if spans[1].Status.Code != codes.Unset || spans[2].Status.Code != codes.Error {
+		t.Errorf("expected only the failed call to be recorded as failed, got %v and %v", spans[1].Status,
+			spans[2].Status)
+	}
+}
+
+func TestMetricRoundTripperTracingDisabled(t *testing.T) {
+	rt := &opIDRoundTripper{}
+	mrt := &MetricRoundTripper{"soap", rt}
+	req := &methods.RetrieveServiceContentBody{Req: &types.RetrieveServiceContent{}}
+	if err := mrt.RoundTrip(context.Background(), req, req); err != nil {
+		t.Fatal(err)
+	}
+	if rt.opIDs[0] != "" {
+		t.Errorf("expected no operation ID to be sent while tracing is disabled, got %q", rt.opIDs[0])
+	}
+}
+
+func TestMetricRoundTripperLongPollNotTraced(t *testing.T) {
+	exporter := newTestExporter(t)
+	rt := &opIDRoundTripper{err: errors.New("connection refused")}
+	mrt := &MetricRoundTripper{"soap", rt}
+	req := &methods.WaitForUpdatesExBody{Req: &types.WaitForUpdatesEx{}}
+	if err := mrt.RoundTrip(context.Background(), req, req); err == nil {
+		t.Fatalf("expected the error of the call to be returned")
+	}
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("expected no span for the long poll, got %d", len(spans))
+	}
+	if rt.opIDs[0] != "" {
+		t.Errorf("expected no operation ID to be sent for the long poll, got %q", rt.opIDs[0])
+	}
+}
@@ -21,8 +21,6 @@ import (
 	"errors"
 	"sync"
 
-	"github.com/vmware/govmomi/cns"
-
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 )
 
@@ -58,6 +56,9 @@ type VirtualCenterManager interface {
 	// IsCnsSnapshotSupported checks if cns volume snapshot is supported
 	// or not on the vCenter Host.
 	IsCnsSnapshotSupported(ctx context.Context, host string) (bool, error)
+	// CheckFeatureSupported returns a FeatureNotSupportedError if the given
+	// feature is not supported by the vCenter Host.
+	CheckFeatureSupported(ctx context.Context, host string, feature string) error
 }
 
 var (
@@ -160,53 +161,43 @@ func (m *defaultVirtualCenterManager) UnregisterAllVirtualCenters(ctx context.Co
 
 // IsvSANFileServicesSupported checks if vSAN file services is supported or not.
 func (m *defaultVirtualCenterManager) IsvSANFileServicesSupported(ctx context.Context, host string) (bool, error) {
-	log := logger.GetLogger(ctx)
-	is67u3Release, err := isVsan67u3Release(ctx, m, host)
-	if err != nil {
-		log.Errorf("Failed to identify the vCenter release with error: %+v", err)
-		return false, err
-	}
-	return !is67u3Release, nil
+	return m.isFeatureSupported(ctx, host, FeatureFileVolume)
 }
 
 // IsOnlineExtendVolumeSupported checks if online extend volume is supported or not.
 func (m *defaultVirtualCenterManager) IsOnlineExtendVolumeSupported(ctx context.Context, host string) (bool, error) {
-	log := logger.GetLogger(ctx)
-
-	// Get VC instance.
-	vcenter, err := m.GetVirtualCenter(ctx, host)
-	if err != nil {
-		log.Errorf("Failed to get vCenter. Err: %v", err)
-		return false, err
-	}
-	vCenterVersion := vcenter.Client.Version
-	if vCenterVersion != cns.ReleaseVSAN67u3 && vCenterVersion != cns.ReleaseVSAN70 &&
-		vCenterVersion != cns.ReleaseVSAN70u1 {
-		return true, nil
-	}
-	log.Infof("Online volume expansion is not supported on vCenter version %q", vCenterVersion)
-	return false, nil
+	return m.isFeatureSupported(ctx, host, FeatureOnlineVolumeExtend)
 }
 
 // IsCnsSnapshotSupported checks if cns snapshot is supported or not.
 func (m *defaultVirtualCenterManager) IsCnsSnapshotSupported(ctx context.Context, host string) (bool, error) {
-	log := logger.GetLogger(ctx)
+	return m.isFeatureSupported(ctx, host, FeatureBlockVolumeSnapshot)
+}
 
-	// Get VC instance.
+// CheckFeatureSupported returns a FeatureNotSupportedError if the given
+// feature is not supported by the vCenter host.
+func (m *defaultVirtualCenterManager) CheckFeatureSupported(ctx context.Context, host string, feature string) error {
+	log := logger.GetLogger(ctx)
 	vcenter, err := m.GetVirtualCenter(ctx, host)
 	if err != nil {
 		log.Errorf("Failed to get vCenter. Err: %v", err)
-		return false, err
-	}
-	vcVersion := vcenter.Client.ServiceContent.About.Version
-	isvSphere70U3orAbove, err := IsvSphereVersion70U3orAbove(ctx, vcenter.Client.ServiceContent.About)
-	if err != nil {
-		return false, logger.LogNewErrorf(log, "Error while checking the vSphere Version %q , Err= %+v",
-			vcVersion, err)
+		return err
 	}
-	if isvSphere70U3orAbove {
+	return vcenter.CheckFeatureSupported(ctx, feature)
+}
+
+// isFeatureSupported checks if the given feature is supported or not by the
+// vCenter host, based on the capabilities discovered when connecting to it.
+func (m *defaultVirtualCenterManager) isFeatureSupported(ctx context.Context, host string,
+	feature string) (bool, error) {
+	log := logger.GetLogger(ctx)
+	err := m.CheckFeatureSupported(ctx, host, feature)
+	if err == nil {
 		return true, nil
 	}
-	log.Infof("CNS Snapshot features are not supported on vCenter version %q", vcVersion)
-	return false, nil
+	if IsFeatureNotSupportedError(err) {
+		log.Infof("%v", err)
+		return false, nil
+	}
+	return false, err
 }
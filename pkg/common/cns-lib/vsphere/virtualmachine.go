@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"strings"
 	"sync"
 
@@ -247,6 +248,47 @@ func (vm *VirtualMachine) GetHostSystem(ctx context.Context) (*object.HostSystem
 	return vmHost, nil
 }
 
+// GetGuestIPAddresses returns the IP addresses reported by VMware Tools for
+// the network adapters of the virtual machine which are among the given
+// addresses, e.g. the InternalIP addresses of the Kubernetes node.
+func (vm *VirtualMachine) GetGuestIPAddresses(ctx context.Context, addresses []string) ([]string, error) {
+	log := logger.GetLogger(ctx)
+	var oVM mo.VirtualMachine
+	err := vm.Properties(ctx, vm.Reference(), []string{"guest.net"}, &oVM)
+	if err != nil {
+		log.Errorf("failed to get guest network properties of vm: %v. err: %+v", vm, err)
+		return nil, err
+	}
+	if oVM.Guest == nil {
+		return nil, nil
+	}
+	return filterGuestIPAddresses(oVM.Guest.Net, addresses), nil
+}
+
+// filterGuestIPAddresses returns the unique IP addresses of the given guest
+// NICs which are among the given addresses.
+func filterGuestIPAddresses(nics []types.GuestNicInfo, addresses []string) []string {
+	allowed := make(map[string]bool)
+	for _, address := range addresses {
+		if ip := net.ParseIP(address); ip != nil {
+			allowed[ip.String()] = true
+		}
+	}
+	var ips []string
+	seen := make(map[string]bool)
+	for _, nic := range nics {
+		for _, ipAddress := range nic.IpAddress {
+			ip := net.ParseIP(ipAddress)
+			if ip == nil || !allowed[ip.String()] || seen[ip.String()] {
+				continue
+			}
+			seen[ip.String()] = true
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips
+}
+
 // GetTagManager returns tagManager using vm client.
 func (vm *VirtualMachine) GetTagManager(ctx context.Context) (*tags.Manager, error) {
 	log := logger.GetLogger(ctx)
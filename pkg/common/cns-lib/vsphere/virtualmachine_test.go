@@ -0,0 +1,45 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsphere
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestFilterGuestIPAddresses(t *testing.T) {
+	nics := []types.GuestNicInfo{
+		{IpAddress: []string{"10.20.30.40", "fe80::250:56ff:fe8a:1", "2001:db8::10"}},
+		{IpAddress: []string{"127.0.0.1", "10.20.30.40", "not-an-ip"}},
+		{IpAddress: []string{"192.168.1.5"}},
+	}
+	// Only the InternalIP addresses of the node are kept, the pod network
+	// address 192.168.1.5 is left out.
+	internalIPs := []string{"10.20.30.40", "2001:0db8::0010", "10.20.30.41"}
+	expected := []string{"10.20.30.40", "2001:db8::10"}
+	if ips := filterGuestIPAddresses(nics, internalIPs); !reflect.DeepEqual(ips, expected) {
+		t.Errorf("expected IPs %v, got %v", expected, ips)
+	}
+	if ips := filterGuestIPAddresses(nics, nil); len(ips) != 0 {
+		t.Errorf("expected no IPs, got %v", ips)
+	}
+	if ips := filterGuestIPAddresses(nil, internalIPs); len(ips) != 0 {
+		t.Errorf("expected no IPs, got %v", ips)
+	}
+}
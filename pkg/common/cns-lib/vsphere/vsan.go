@@ -19,10 +19,24 @@ package vsphere
 import (
 	"context"
 
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
 	"github.com/vmware/govmomi/vsan"
+	vsanmethods "github.com/vmware/govmomi/vsan/methods"
+	vsantypes "github.com/vmware/govmomi/vsan/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 )
 
+// vsanFileServiceSystemInstance is the vSAN file service system managed object
+// which is queried from vsan health.
+var vsanFileServiceSystemInstance = types.ManagedObjectReference{
+	Type:  "VsanFileServiceSystem",
+	Value: "vsan-cluster-file-service-system",
+}
+
+// fileShareQueryLimit is the page size used to query vSAN file shares.
+const fileShareQueryLimit = int64(500)
+
 // ConnectVsan creates a VSAN client for the virtual center.
 func (vc *VirtualCenter) ConnectVsan(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
@@ -36,6 +50,7 @@ func (vc *VirtualCenter) ConnectVsan(ctx context.Context) error {
 			log.Errorf("failed to create vsan client with err: %v", err)
 			return err
 		}
+		vc.VsanClient.RoundTripper = vc.throttledRoundTripper(vc.VsanClient.RoundTripper)
 	}
 	return nil
 }
@@ -50,3 +65,78 @@ func (vc *VirtualCenter) DisconnectVsan(ctx context.Context) error {
 	}
 	return nil
 }
+
+// ReconfigureFileShare applies the given config to the vSAN file share with
+// the given UUID on the given cluster and waits for the reconfiguration to
+// complete. Only the fields set in the config are changed.
+func (vc *VirtualCenter) ReconfigureFileShare(ctx context.Context, cluster types.ManagedObjectReference,
+	shareUUID string, config vsantypes.VsanFileShareConfig) error {
+	log := logger.GetLogger(ctx)
+	err := vc.ConnectVsan(ctx)
+	if err != nil {
+		return err
+	}
+	req := vsantypes.VsanReconfigureFileShare{
+		This:      vsanFileServiceSystemInstance,
+		ShareUuid: shareUUID,
+		Config:    config,
+		Cluster:   &cluster,
+	}
+	res, err := vsanmethods.VsanReconfigureFileShare(ctx, vc.VsanClient, &req)
+	if err != nil {
+		log.Errorf("failed to reconfigure file share %q with err: %v", shareUUID, err)
+		return err
+	}
+	task := object.NewTask(vc.Client.Client, res.Returnval)
+	if err = task.Wait(ctx); err != nil {
+		log.Errorf("reconfigure task for file share %q failed with err: %v", shareUUID, err)
+		return err
+	}
+	log.Infof("File share %q reconfigured with protocols: %v and NFS security type: %q",
+		shareUUID, config.Protocols, config.NfsSecType)
+	return nil
+}
+
+// QueryFileShareUsedCapacity returns the used capacity in MB of all the vSAN
+// file shares on the given cluster, keyed by file share UUID.
+func (vc *VirtualCenter) QueryFileShareUsedCapacity(ctx context.Context,
+	cluster types.ManagedObjectReference) (map[string]int64, error) {
+	log := logger.GetLogger(ctx)
+	err := vc.ConnectVsan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	includeUsedCapacity := true
+	req := vsantypes.VsanClusterQueryFileShares{
+		This: vsanFileServiceSystemInstance,
+		QuerySpec: vsantypes.VsanFileShareQuerySpec{
+			Limit: fileShareQueryLimit,
+			Properties: &vsantypes.VsanFileShareQueryProperties{
+				IncludeUsedCapacity: &includeUsedCapacity,
+			},
+		},
+		Cluster: &cluster,
+	}
+	usedCapacity := make(map[string]int64)
+	for {
+		res, err := vsanmethods.VsanClusterQueryFileShares(ctx, vc.VsanClient, &req)
+		if err != nil {
+			log.Errorf("failed to query file shares on cluster %q with err: %v", cluster.Value, err)
+			return nil, err
+		}
+		if res.Returnval == nil {
+			break
+		}
+		for _, share := range res.Returnval.FileShares {
+			if share.Runtime != nil {
+				usedCapacity[share.Uuid] = share.Runtime.UsedCapacity
+			}
+		}
+		if res.Returnval.NextOffset == "" || res.Returnval.NextOffset == req.QuerySpec.Offset {
+			break
+		}
+		req.QuerySpec.Offset = res.Returnval.NextOffset
+	}
+	log.Debugf("Used capacity of file shares on cluster %q: %v", cluster.Value, usedCapacity)
+	return usedCapacity, nil
+}
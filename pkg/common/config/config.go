@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"regexp"
 	"strconv"
@@ -153,6 +154,10 @@ var (
 	// servers
 	ErrMaxVCenterSupportedForMultiVCenterSetup = errors.New("max 5 vCenters are supported for multi " +
 		"vCenter deployment")
+
+	// ErrInvalidProxyURL is returned when the proxy-url of a vCenter is not an
+	// absolute http or https URL.
+	ErrInvalidProxyURL = errors.New("invalid proxy-url, expected an http or https URL")
 )
 
 // GeneratedVanillaClusterID is used to save unique cluster ID generated
@@ -411,6 +416,13 @@ func validateConfig(ctx context.Context, cfg *Config) error {
 				vcConfig.Datacenters = cfg.Global.Datacenters
 			}
 		}
+		if vcConfig.ProxyURL != "" {
+			proxyURL, err := url.Parse(vcConfig.ProxyURL)
+			if err != nil || (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") || proxyURL.Host == "" {
+				log.Errorf("proxy-url %q specified for vc %s is invalid", vcConfig.ProxyURL, vcServer)
+				return ErrInvalidProxyURL
+			}
+		}
 		insecure := vcConfig.InsecureFlag
 		if !insecure {
 			vcConfig.InsecureFlag = cfg.Global.InsecureFlag
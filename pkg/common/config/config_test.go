@@ -261,3 +261,21 @@ func isConfigEqual(actual *Config, expected *Config) bool {
 	}
 	return true
 }
+
+func TestValidateConfigWithInvalidProxyURL(t *testing.T) {
+	for _, proxyURL := range []string{"proxy.example.com:3128", "socks5://proxy.example.com:1080", "http://"} {
+		cfg := &Config{
+			VirtualCenter: map[string]*VirtualCenterConfig{
+				"1.1.1.1": {
+					User:         "Administrator@vsphere.local",
+					Password:     "Password",
+					InsecureFlag: true,
+					ProxyURL:     proxyURL,
+				},
+			},
+		}
+		if err := validateConfig(ctx, cfg); err != ErrInvalidProxyURL {
+			t.Errorf("Expected ErrInvalidProxyURL for proxy-url %q, got %v", proxyURL, err)
+		}
+	}
+}
@@ -72,6 +72,10 @@ type Config struct {
 		// ListVolumeThreshold specifies the maximum number of differences in volume that can exist between CNS
 		// and kubernetes
 		ListVolumeThreshold int `gcfg:"list-volume-threshold"`
+		// ProtectVolumesFromVMDeletion specifies whether block volumes are protected
+		// from being deleted along with the node VM they are attached to. It can be
+		// overridden per StorageClass with the "protectfromvmdeletion" parameter.
+		ProtectVolumesFromVMDeletion bool `gcfg:"protect-volumes-from-vm-deletion"`
 	}
 
 	// Multiple sets of Net Permissions applied to all file shares
@@ -151,6 +155,41 @@ type VirtualCenterConfig struct {
 	// MigrationDataStore specifies datastore which is set as default datastore in legacy cloud-config
 	// and hence should be used as default datastore.
 	MigrationDataStoreURL string `gcfg:"migration-datastore-url"`
+	// APIRateLimit specifies the maximum number of vCenter API calls per second.
+	// The rate limit is disabled if not set.
+	APIRateLimit float64 `gcfg:"api-rate-limit"`
+	// APIBurst specifies the number of vCenter API calls which can be made at
+	// once above the APIRateLimit.
+	APIBurst int `gcfg:"api-burst"`
+	// CircuitBreakerFailureThreshold specifies the number of consecutive failed
+	// vCenter API calls after which the calls fail fast. The circuit breaker is
+	// disabled if not set.
+	CircuitBreakerFailureThreshold int `gcfg:"circuit-breaker-failure-threshold"`
+	// CircuitBreakerOpenTimeoutInSec specifies the time in seconds for which the
+	// vCenter API calls fail fast before a call is let through to probe vCenter.
+	CircuitBreakerOpenTimeoutInSec int `gcfg:"circuit-breaker-open-timeout-seconds"`
+	// MaxConcurrentCreateDeleteOps specifies the maximum number of concurrent CNS
+	// create, delete and expand volume operations. Not limited if not set.
+	MaxConcurrentCreateDeleteOps int `gcfg:"max-concurrent-create-delete-ops"`
+	// MaxConcurrentAttachDetachOps specifies the maximum number of concurrent CNS
+	// attach and detach volume operations. Not limited if not set.
+	MaxConcurrentAttachDetachOps int `gcfg:"max-concurrent-attach-detach-ops"`
+	// MaxConcurrentQueryOps specifies the maximum number of concurrent CNS query
+	// operations. Not limited if not set.
+	MaxConcurrentQueryOps int `gcfg:"max-concurrent-query-ops"`
+	// MaxConcurrentSnapshotOps specifies the maximum number of concurrent CNS
+	// create and delete snapshot operations. Not limited if not set.
+	MaxConcurrentSnapshotOps int `gcfg:"max-concurrent-snapshot-ops"`
+	// ProxyURL specifies the HTTP(S) proxy to reach vCenter through, e.g.
+	// "http://proxy.example.com:3128". The proxy environment variables are used
+	// if not set.
+	ProxyURL string `gcfg:"proxy-url"`
+	// NoProxy specifies a comma separated list of hosts, domains, IPs and CIDRs
+	// which are reached without the ProxyURL.
+	NoProxy string `gcfg:"no-proxy"`
+	// ProxyCAFile specifies the path of the CA certificate of the ProxyURL, if
+	// it is an HTTPS proxy whose certificate is not signed by a trusted CA.
+	ProxyCAFile string `gcfg:"proxy-ca-file"`
 }
 
 // GCConfig contains information used by guest cluster to access a supervisor
@@ -30,6 +30,12 @@ const (
 	CSIVmNotFoundFault = "csi.fault.nonstorage.VmNotFound"
 	// CSIDiskNotDetachedFault is the fault type when disk is still attached to the vm
 	CSIDiskNotDetachedFault = "csi.fault.nonstorage.DiskNotDetached"
+	// CSIDiskControllerSlotsExhaustedFault is the fault type when all the slots of the disk controllers of the vm
+	// are in use
+	CSIDiskControllerSlotsExhaustedFault = "csi.fault.nonstorage.DiskControllerSlotsExhausted"
+	// CSIDiskNotEagerZeroedFault is the fault type when a disk which is not eager zeroed thick is attached with
+	// multi-writer sharing
+	CSIDiskNotEagerZeroedFault = "csi.fault.invalidconfig.DiskNotEagerZeroed"
 	// CSIDatacenterNotFoundFault is the fault type when Datacenter are not found in the VC
 	CSIDatacenterNotFoundFault = "csi.fault.DatacenterNotFound"
 	// CSIVCenterNotFoundFault is the fault type when VC instance is not found
@@ -68,6 +74,12 @@ const (
 	CSIUnimplementedFault = "csi.fault.Unimplemented"
 	// CSIInvalidStoragePolicyConfigurationFault is the fault type returned when the user provides invalid storage policy.
 	CSIInvalidStoragePolicyConfigurationFault = "csi.fault.invalidconfig.InvalidStoragePolicyConfiguration"
+	// CSIOperationInProgressFault is the fault type returned when another operation is in progress on the
+	// same volume or snapshot.
+	CSIOperationInProgressFault = "csi.fault.nonstorage.OperationInProgress"
+	// CSIVCenterUnavailableFault is the fault type returned when the vCenter API calls fail fast because
+	// the circuit breaker of the vCenter is open.
+	CSIVCenterUnavailableFault = "csi.fault.nonstorage.VCenterUnavailable"
 
 	// Below is the list of faults coming from downstream vCenter components that we want to classify
 	// as non-storage faults.
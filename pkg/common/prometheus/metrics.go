@@ -85,6 +85,11 @@ const (
 	// PrometheusInaccessibleVolumes represents inaccessible volumes.
 	PrometheusInaccessibleVolumes = "inaccessible-volumes"
 
+	// PrometheusOrphanedDisks represents disks attached to node VMs without a VolumeAttachment.
+	PrometheusOrphanedDisks = "orphaned-disks"
+	// PrometheusMissingDisks represents VolumeAttachments of volumes not attached to the node VM.
+	PrometheusMissingDisks = "missing-disks"
+
 	// PrometheusPassStatus represents a successful API run.
 	PrometheusPassStatus = "pass"
 	// PrometheusFailStatus represents an unsuccessful API run.
@@ -142,6 +147,117 @@ var (
 		// Possible volume_health_type - "accessible-volumes", "inaccessible-volumes"
 		[]string{"volume_health_type"})
 
+	// FakeAttachedVolumesGaugeVec is a gauge metric to observe the number of fake attached volumes which are
+	// accessible again and which are still inaccessible.
+	FakeAttachedVolumesGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_fake_attached_volumes_gauge",
+		Help: "Gauge for total number of fake attached volumes which are accessible and inaccessible",
+	},
+		// Possible volume_health_type - "accessible-volumes", "inaccessible-volumes"
+		[]string{"volume_health_type"})
+
+	// VolumeAttachmentDriftGaugeVec is a gauge metric to observe the number of disks attached to node VMs
+	// without a VolumeAttachment and of VolumeAttachments of volumes not attached to the node VM.
+	VolumeAttachmentDriftGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_volume_attachment_drift_gauge",
+		Help: "Gauge for total number of orphaned disks and missing disks of node VMs",
+	},
+		// Possible drift_type - "orphaned-disks", "missing-disks"
+		[]string{"drift_type"})
+
+	// FileVolumeUsedBytesGaugeVec is a gauge metric to observe the used capacity of file volumes.
+	FileVolumeUsedBytesGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_file_volume_used_bytes",
+		Help: "Gauge for the used capacity of the file share backing a file volume",
+	},
+		[]string{"namespace", "pvc"})
+
+	// FileVolumeCapacityBytesGaugeVec is a gauge metric to observe the hard limit of file volumes.
+	FileVolumeCapacityBytesGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_file_volume_capacity_bytes",
+		Help: "Gauge for the hard limit of the file share backing a file volume",
+	},
+		[]string{"namespace", "pvc"})
+
+	// ForceDetachVolumeCounterVec is a counter metric to observe the volumes
+	// force detached from the node VMs of out-of-service nodes.
+	ForceDetachVolumeCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_force_detach_volume_total",
+		Help: "Number of volumes force detached from the node VMs of out-of-service nodes",
+	},
+		// Possible status - "pass", "fail"
+		[]string{"node", "status"})
+
+	// VCenterCircuitBreakerStateGaugeVec is a gauge metric to observe the state of
+	// the circuit breaker of the vCenter API calls.
+	VCenterCircuitBreakerStateGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_vcenter_circuit_breaker_state",
+		Help: "State of the circuit breaker of the vCenter API calls, 0 closed, 1 open and 2 half open",
+	},
+		[]string{"vcenter"})
+
+	// VCenterInfoGaugeVec is a gauge metric set to 1 for the version of each
+	// vCenter discovered at connect time.
+	VCenterInfoGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_vcenter_info",
+		Help: "Version of the vCenter discovered at connect time",
+	},
+		[]string{"vcenter", "version", "build", "api_version"})
+
+	// VCenterFeatureSupportedGaugeVec is a gauge metric to observe whether the
+	// features gated on the vCenter version are supported by each vCenter.
+	VCenterFeatureSupportedGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_vcenter_feature_supported",
+		Help: "Whether the feature is supported by the vCenter, 1 supported and 0 not supported",
+	},
+		// Possible feature - "file-volume", "online-volume-extend", "block-volume-snapshot"
+		[]string{"vcenter", "feature"})
+
+	// VCenterVsanFileServiceEnabledGaugeVec is a gauge metric to observe whether
+	// vSAN file service is enabled on a cluster of each vCenter.
+	VCenterVsanFileServiceEnabledGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_vcenter_vsan_file_service_enabled",
+		Help: "Whether vSAN file service is enabled on a cluster of the vCenter, 1 enabled, 0 disabled and -1 unknown",
+	},
+		[]string{"vcenter"})
+
+	// CnsOperationQueueWaitHistVec is a histogram vector metric to observe the
+	// time CNS operations wait for a slot of their kind of operation.
+	CnsOperationQueueWaitHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vsphere_cns_operation_queue_wait_seconds",
+		Help:    "Histogram vector for the time CNS operations wait for a slot of their kind of operation",
+		Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120},
+	},
+		// Possible operation_kind - "create-delete", "attach-detach", "query", "snapshot"
+		[]string{"vcenter", "operation_kind"})
+
+	// CnsOperationQueueDepthGaugeVec is a gauge metric to observe the number of
+	// CNS operations waiting for a slot of their kind of operation.
+	CnsOperationQueueDepthGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_cns_operation_queue_depth",
+		Help: "Number of CNS operations waiting for a slot of their kind of operation",
+	},
+		// Possible operation_kind - "create-delete", "attach-detach", "query", "snapshot"
+		[]string{"vcenter", "operation_kind"})
+
+	// PbmCacheRequestsCounterVec is a counter metric to observe the hits and
+	// misses of the caches of the SPBM queries.
+	PbmCacheRequestsCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_pbm_cache_requests_total",
+		Help: "Number of SPBM queries served from the cache or sent to the vCenter",
+	},
+		// Possible cache - "policy-id", "compatibility"
+		// Possible result - "hit", "miss"
+		[]string{"vcenter", "cache", "result"})
+
+	// PbmCacheInvalidationsCounterVec is a counter metric to observe the
+	// invalidations of the caches of the SPBM queries on profile changes.
+	PbmCacheInvalidationsCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_pbm_cache_invalidations_total",
+		Help: "Number of invalidations of the SPBM query caches on storage policy changes",
+	},
+		[]string{"vcenter"})
+
 	// FullSyncOpsHistVec is a histogram vector metric to observe CSI Full Sync.
 	FullSyncOpsHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "vsphere_full_sync_ops_histogram",
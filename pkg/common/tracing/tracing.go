@@ -0,0 +1,125 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing records OpenTelemetry spans for the CSI requests, the CNS
+// operations and the vCenter API calls, and exports them over OTLP.
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// TracerName is the name of the tracer of the driver.
+	TracerName = "sigs.k8s.io/vsphere-csi-driver"
+
+	// EnvOTLPEndpoint is the OTLP endpoint the spans of all signals are
+	// exported to.
+	EnvOTLPEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	// EnvOTLPTracesEndpoint is the OTLP endpoint the spans are exported to. It
+	// takes precedence over EnvOTLPEndpoint.
+	EnvOTLPTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	// EnvSDKDisabled disables tracing when set to true, even if an OTLP
+	// endpoint is set.
+	EnvSDKDisabled = "OTEL_SDK_DISABLED"
+)
+
+// Attributes set on the spans.
+const (
+	// VCenterKey is the host of the vCenter an operation is sent to.
+	VCenterKey = attribute.Key("vsphere.vcenter")
+	// VCenterClientKey is the vCenter API endpoint (soap, cns, pbm, vsan)
+	// called.
+	VCenterClientKey = attribute.Key("vsphere.client")
+	// VCenterMethodKey is the vCenter API method called.
+	VCenterMethodKey = attribute.Key("vsphere.method")
+	// OpIDKey is the operation ID sent to vCenter with an API call, which
+	// vCenter logs and sets as the activation ID of the tasks it creates.
+	OpIDKey = attribute.Key("vsphere.opid")
+)
+
+// IsEnabled returns true if an OTLP endpoint is set to export the spans to,
+// and the OpenTelemetry SDK is not disabled.
+func IsEnabled() bool {
+	if strings.EqualFold(os.Getenv(EnvSDKDisabled), "true") {
+		return false
+	}
+	return os.Getenv(EnvOTLPEndpoint) != "" || os.Getenv(EnvOTLPTracesEndpoint) != ""
+}
+
+// InitTracing sets up the export of the spans of the given service over OTLP
+// gRPC, if IsEnabled. The exporter, sampler and resource are configured with
+// the standard OTEL_* environment variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT,
+// OTEL_EXPORTER_OTLP_INSECURE, OTEL_TRACES_SAMPLER or OTEL_RESOURCE_ATTRIBUTES.
+// The trace context is propagated with the W3C Trace Context headers. The
+// returned func flushes the pending spans and stops the export.
+func InitTracing(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	log := logger.GetLogger(ctx)
+	if !IsEnabled() {
+		log.Infof("Tracing is disabled. Set %s to export the spans over OTLP.", EnvOTLPEndpoint)
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to create the OTLP trace exporter. Err: %v", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String(serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to create the tracing resource. Err: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	log.Infof("Tracing is enabled for service %q", serviceName)
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span with the given name and attributes, child of the
+// span of the given context if any.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError records the given error on the span and sets its status to
+// error, if err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestExporter records the spans in the returned in-memory exporter for
+// the duration of the test.
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+	return exporter
+}
+
+func TestIsEnabled(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		endpoint       string
+		tracesEndpoint string
+		disabled       string
+		expected       bool
+	}{
+		{name: "no endpoint"},
+		{name: "endpoint", endpoint: "http://collector:4317", expected: true},
+		{name: "traces endpoint", tracesEndpoint: "http://collector:4317", expected: true},
+		{name: "disabled", endpoint: "http://collector:4317", disabled: "TRUE"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(EnvOTLPEndpoint, test.endpoint)
+			t.Setenv(EnvOTLPTracesEndpoint, test.tracesEndpoint)
+			t.Setenv(EnvSDKDisabled, test.disabled)
+			if got := IsEnabled(); got != test.expected {
+				t.Errorf("expected IsEnabled to return %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestInitTracingDisabled(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv(EnvOTLPEndpoint, "")
+	t.Setenv(EnvOTLPTracesEndpoint, "")
+	provider := otel.GetTracerProvider()
+	shutdown, err := InitTracing(ctx, "vsphere-csi-controller")
+	if err != nil {
+		t.Fatalf("failed to initialize tracing: %v", err)
+	}
+	if otel.GetTracerProvider() != provider {
+		t.Errorf("expected the tracer provider not to be replaced while tracing is disabled")
+	}
+	if err := shutdown(ctx); err != nil {
+		t.Errorf("failed to shut down tracing: %v", err)
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	exporter := newTestExporter(t)
+	ctx, parent := StartSpan(context.Background(), "parent")
+	_, span := StartSpan(ctx, "child", VCenterKey.String("vc-1"))
+	RecordError(span, errors.New("failed"))
+	span.End()
+	_, span = StartSpan(ctx, "succeeded")
+	RecordError(span, nil)
+	span.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	child, succeeded := spans[0], spans[1]
+	if child.Name != "child" || child.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected span \"child\" of the parent span, got %q of %v", child.Name, child.Parent.SpanID())
+	}
+	if len(child.Attributes) != 1 || child.Attributes[0] != VCenterKey.String("vc-1") {
+		t.Errorf("expected the vCenter attribute to be set, got %v", child.Attributes)
+	}
+	if child.Status.Code != codes.Error || child.Status.Description != "failed" || len(child.Events) != 1 {
+		t.Errorf("expected the error to be recorded, got status %+v and events %v", child.Status, child.Events)
+	}
+	if succeeded.Status.Code != codes.Unset || len(succeeded.Events) != 0 {
+		t.Errorf("expected no error to be recorded, got status %+v and events %v", succeeded.Status,
+			succeeded.Events)
+	}
+}
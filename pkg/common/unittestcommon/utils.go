@@ -64,6 +64,13 @@ func GetFakeContainerOrchestratorInterface(orchestratorType int) (commonco.COCom
 				"max-pvscsi-targets-per-vm":         "true",
 				"multi-vcenter-csi-topology":        "true",
 				"listview-tasks":                    "true",
+				"topology-aware-file-volume":        "true",
+				"file-volume-node-acls":             "false",
+				"file-volume-usage":                 "false",
+				"node-volume-limits":                "false",
+				"out-of-service-node-detach":        "false",
+				"volume-attachment-sweep":           "false",
+				"vcenter-capability-status":         "false",
 			},
 		}
 		return fakeCO, nil
@@ -124,6 +131,14 @@ func (nodeTopology *mockNodeVolumeTopology) GetNodeTopologyLabels(ctx context.Co
 	return nil, logger.LogNewError(log, "GetNodeTopologyLabels is not yet implemented.")
 }
 
+// GetNodeMaxVolumes fetches the number of block volumes which can be attached to a node from the
+// CSINodeTopology CR.
+func (nodeTopology *mockNodeVolumeTopology) GetNodeMaxVolumes(ctx context.Context, info *commoncotypes.NodeInfo) (
+	int64, error) {
+	log := logger.GetLogger(ctx)
+	return 0, logger.LogNewError(log, "GetNodeMaxVolumes is not yet implemented.")
+}
+
 // GetSharedDatastoresInTopology retrieves shared datastores of nodes which satisfy a given topology requirement.
 func (cntrlTopology *mockControllerVolumeTopology) GetSharedDatastoresInTopology(ctx context.Context,
 	reqParams interface{}) ([]*cnsvsphere.DatastoreInfo, error) {
@@ -827,6 +827,27 @@ func (volTopology *nodeVolumeTopology) GetNodeTopologyLabels(ctx context.Context
 		nodeInfo.NodeName)
 }
 
+// GetNodeMaxVolumes uses the CSINodeTopology CR to retrieve the number of block volumes
+// which can be attached to a node. It is to be called after GetNodeTopologyLabels.
+func (volTopology *nodeVolumeTopology) GetNodeMaxVolumes(ctx context.Context, nodeInfo *commoncotypes.NodeInfo) (
+	int64, error) {
+	log := logger.GetLogger(ctx)
+	csiNodeTopology := &csinodetopologyv1alpha1.CSINodeTopology{}
+	csiNodeTopologyKey := types.NamespacedName{
+		Name: nodeInfo.NodeName,
+	}
+	err := volTopology.csiNodeTopologyK8sClient.Get(ctx, csiNodeTopologyKey, csiNodeTopology)
+	if err != nil {
+		return 0, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get CsiNodeTopology for the node: %q. Error: %+v", nodeInfo.NodeName, err)
+	}
+	if csiNodeTopology.Status.Status != csinodetopologyv1alpha1.CSINodeTopologySuccess {
+		return 0, logger.LogNewErrorCodef(log, codes.Internal,
+			"CSINodeTopology instance %q is at %q state", nodeInfo.NodeName, csiNodeTopology.Status.Status)
+	}
+	return csiNodeTopology.Status.MaxVolumesPerNode, nil
+}
+
 func (volTopology *nodeVolumeTopology) updateNodeIDForTopology(
 	ctx context.Context,
 	nodeInfo *commoncotypes.NodeInfo,
@@ -107,4 +107,7 @@ type ControllerTopologyService interface {
 type NodeTopologyService interface {
 	// GetNodeTopologyLabels fetches the topology labels of a NodeVM given the NodeInfo.
 	GetNodeTopologyLabels(ctx context.Context, info *NodeInfo) (map[string]string, error)
+	// GetNodeMaxVolumes fetches the number of block volumes which can be attached to a NodeVM
+	// given the NodeInfo. It returns 0 if the number is not known.
+	GetNodeMaxVolumes(ctx context.Context, info *NodeInfo) (int64, error)
 }
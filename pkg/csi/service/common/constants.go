@@ -72,6 +72,97 @@ const (
 	// the given storage policy. For Example: HostLocal: "True".
 	AttributeHostLocal = "hostlocal"
 
+	// AttributeDiskSharing represents the virtual disk sharing mode requested
+	// in the StorageClass for block volumes. It is also set in the volume
+	// context of volumes created with it. For Example: DiskSharing: "multiWriter".
+	AttributeDiskSharing = "disksharing"
+
+	// DiskSharingMultiWriter is the AttributeDiskSharing value which allows
+	// a block volume to be attached to multiple node VMs at the same time.
+	DiskSharingMultiWriter = "multiwriter"
+
+	// AttributeNfsSecurityType represents the NFS security flavor requested in
+	// the StorageClass for file volumes. It is also set in the volume context of
+	// volumes created with it. For Example: NfsSecurityType: "krb5p".
+	AttributeNfsSecurityType = "nfssecuritytype"
+
+	// NfsSecurityTypeSys is the AttributeNfsSecurityType value for AUTH_SYS.
+	NfsSecurityTypeSys = "sys"
+
+	// NfsSecurityTypeKrb5 is the AttributeNfsSecurityType value for Kerberos
+	// authentication.
+	NfsSecurityTypeKrb5 = "krb5"
+
+	// NfsSecurityTypeKrb5i is the AttributeNfsSecurityType value for Kerberos
+	// authentication with integrity checking.
+	NfsSecurityTypeKrb5i = "krb5i"
+
+	// NfsSecurityTypeKrb5p is the AttributeNfsSecurityType value for Kerberos
+	// authentication with privacy (encryption).
+	NfsSecurityTypeKrb5p = "krb5p"
+
+	// NfsKerberosKeytabSecretKey is the key in the node publish secret which
+	// holds the Kerberos keytab used to mount Kerberos enabled file volumes.
+	NfsKerberosKeytabSecretKey = "keytab"
+
+	// AttributeNfsVersion represents the NFS protocol version requested in the
+	// StorageClass for file volumes. It is also set in the volume context of
+	// volumes created with it. For Example: NfsVersion: "3".
+	AttributeNfsVersion = "nfsversion"
+
+	// NfsVersion3 is the AttributeNfsVersion value for NFSv3.
+	NfsVersion3 = "3"
+
+	// NfsVersion41 is the AttributeNfsVersion value for NFSv4.1.
+	NfsVersion41 = "4.1"
+
+	// AttributeFileProtocol represents the file sharing protocol requested in
+	// the StorageClass for file volumes. It is also set in the volume context of
+	// volumes created with it. For Example: FileProtocol: "smb".
+	AttributeFileProtocol = "fileprotocol"
+
+	// FileProtocolNFS is the AttributeFileProtocol value for NFS, the default.
+	FileProtocolNFS = "nfs"
+
+	// FileProtocolSMB is the AttributeFileProtocol value for SMB. SMB file
+	// volumes can only be published on Windows nodes.
+	FileProtocolSMB = "smb"
+
+	// SmbUsernameSecretKey is the key in the node publish secret which holds
+	// the user name used to mount SMB file volumes.
+	SmbUsernameSecretKey = "username"
+
+	// SmbPasswordSecretKey is the key in the node publish secret which holds
+	// the password used to mount SMB file volumes.
+	SmbPasswordSecretKey = "password"
+
+	// SmbDomainSecretKey is the optional key in the node publish secret which
+	// holds the Active Directory domain of the SMB user.
+	SmbDomainSecretKey = "domain"
+
+	// AttributeNetPermissionIps represents the client IP address, IP range or
+	// IP subnet allowed to access file volumes created with the StorageClass.
+	// It overrides the NetPermissions in the driver config. For Example:
+	// NetPermissionIps: "10.20.30.0/24".
+	AttributeNetPermissionIps = "netpermissionips"
+
+	// AttributeNetPermissionAccess represents the access, READ_ONLY, READ_WRITE
+	// or NO_ACCESS, granted to the clients of file volumes created with the
+	// StorageClass. For Example: NetPermissionAccess: "READ_ONLY".
+	AttributeNetPermissionAccess = "netpermissionaccess"
+
+	// AttributeNetPermissionRootSquash represents whether root access is
+	// disallowed for the clients of file volumes created with the StorageClass.
+	// For Example: NetPermissionRootSquash: "true".
+	AttributeNetPermissionRootSquash = "netpermissionrootsquash"
+
+	// AttributeProtectFromVMDeletion represents whether block volumes created
+	// with the StorageClass are protected from being deleted along with the
+	// node VM they are attached to. It overrides the global
+	// protect-volumes-from-vm-deletion config. For Example:
+	// ProtectFromVMDeletion: "true".
+	AttributeProtectFromVMDeletion = "protectfromvmdeletion"
+
 	// HostMoidAnnotationKey represents the Node annotation key that has the value
 	// of VC's ESX host moid of this node.
 	HostMoidAnnotationKey = "vmware-system-esxi-node-moid"
@@ -120,9 +211,24 @@ const (
 	// Nfsv4AccessPointKey is the key for NFSv4 access point.
 	Nfsv4AccessPointKey = "NFSv4.1"
 
+	// Nfsv3AccessPointKey is the key for NFSv3 access point.
+	Nfsv3AccessPointKey = "NFSv3"
+
+	// SmbAccessPointKey is the key for SMB access point.
+	SmbAccessPointKey = "SMB"
+
 	// Nfsv4AccessPoint is the access point of file volume.
 	Nfsv4AccessPoint = "Nfsv4AccessPoint"
 
+	// NfsAccessPoints holds all the access points of a file volume in the
+	// publish context, JSON encoded as a map of protocol to access points.
+	NfsAccessPoints = "NfsAccessPoints"
+
+	// FileVolumeNodeIPs holds the node IPs granted access to a file volume in
+	// the publish context, comma separated, so that they can be read back from
+	// the VolumeAttachment to revoke the access after a controller restart.
+	FileVolumeNodeIPs = "FileVolumeNodeIPs"
+
 	// MinSupportedVCenterMajor is the minimum, major version of vCenter
 	// on which CNS is supported.
 	MinSupportedVCenterMajor int = 6
@@ -394,6 +500,25 @@ const (
 	ListViewPerf = "listview-tasks"
 	// TopologyAwareFileVolume enables provisioning of file volumes in a topology enabled environment
 	TopologyAwareFileVolume = "topology-aware-file-volume"
+	// FileVolumeNodeACLs grants NFS access to vanilla file volumes only to the
+	// IPs of the nodes publishing them instead of the NetPermissions ranges.
+	FileVolumeNodeACLs = "file-volume-node-acls"
+	// FileVolumeUsage enables periodic reporting of the used capacity of
+	// vanilla file volumes by the syncer.
+	FileVolumeUsage = "file-volume-usage"
+	// NodeVolumeLimits publishes the number of block volumes which can be
+	// attached to a node VM from its disk controllers, and spreads the volumes
+	// attached to a node VM across its disk controllers.
+	NodeVolumeLimits = "node-volume-limits"
+	// OutOfServiceNodeDetach detaches the volumes of a node tainted with
+	// node.kubernetes.io/out-of-service from its node VM from the syncer.
+	OutOfServiceNodeDetach = "out-of-service-node-detach"
+	// VolumeAttachmentSweep periodically cross-checks the VolumeAttachments of
+	// vanilla block volumes against the disks attached to the node VMs.
+	VolumeAttachmentSweep = "volume-attachment-sweep"
+	// VCenterCapabilityStatus reports the capabilities discovered on each
+	// vCenter in a cluster scoped VCenterCapabilityStatus instance.
+	VCenterCapabilityStatus = "vcenter-capability-status"
 	// PodVMOnStretchedSupervisor enables Pod Vm Support on stretched supervisor cluster
 	PodVMOnStretchedSupervisor = "podvm-on-stretched-supervisor"
 )
@@ -0,0 +1,154 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// InFlightOperations tracks the operations in progress on volumes and
+// snapshots, keyed by the volume or snapshot ID, or by the name of the volume
+// or snapshot being created. A key is held either by one exclusive operation,
+// or by any number of shared operations which do not conflict with each
+// other. The zero value is ready to use.
+type InFlightOperations struct {
+	lock sync.Mutex
+	// operations holds the operations in progress on each key.
+	operations map[string]*inFlightOperation
+}
+
+// inFlightOperation holds the operations in progress on a key.
+type inFlightOperation struct {
+	// exclusive is the name of the exclusive operation holding the key, if any.
+	exclusive string
+	// shared holds the number of holders of each shared operation holding
+	// the key.
+	shared map[string]int
+}
+
+// name returns the name of an operation holding the key.
+func (o *inFlightOperation) name() string {
+	if o.exclusive != "" {
+		return o.exclusive
+	}
+	names := make([]string, 0, len(o.shared))
+	for name := range o.shared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// TryAcquire marks the given operation as in progress on the given key,
+// exclusively. It returns false along with the name of the operation already
+// in progress if the key is held by another operation.
+func (o *InFlightOperations) TryAcquire(key string, operation string) (string, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if inFlight, ok := o.operations[key]; ok {
+		return inFlight.name(), false
+	}
+	if o.operations == nil {
+		o.operations = make(map[string]*inFlightOperation)
+	}
+	o.operations[key] = &inFlightOperation{exclusive: operation}
+	return "", true
+}
+
+// TryAcquireShared marks the given operation as in progress on the given key,
+// along with the other shared operations on it. It returns false along with
+// the name of the operation already in progress if the key is held by an
+// exclusive operation.
+func (o *InFlightOperations) TryAcquireShared(key string, operation string) (string, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	inFlight, ok := o.operations[key]
+	if !ok {
+		if o.operations == nil {
+			o.operations = make(map[string]*inFlightOperation)
+		}
+		inFlight = &inFlightOperation{shared: make(map[string]int)}
+		o.operations[key] = inFlight
+	}
+	if inFlight.exclusive != "" {
+		return inFlight.exclusive, false
+	}
+	inFlight.shared[operation]++
+	return "", true
+}
+
+// Release marks the exclusive operation in progress on the given key as done.
+func (o *InFlightOperations) Release(key string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	delete(o.operations, key)
+}
+
+// ReleaseShared marks one holder of the given shared operation in progress on
+// the given key as done.
+func (o *InFlightOperations) ReleaseShared(key string, operation string) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	inFlight, ok := o.operations[key]
+	if !ok || inFlight.exclusive != "" {
+		return
+	}
+	if inFlight.shared[operation]--; inFlight.shared[operation] <= 0 {
+		delete(inFlight.shared, operation)
+	}
+	if len(inFlight.shared) == 0 {
+		delete(o.operations, key)
+	}
+}
+
+// Acquire marks the given operation as in progress on the given key,
+// exclusively, and returns the func releasing it. As recommended by the CSI
+// spec, it returns an Aborted error if another operation is in progress on the
+// same key, so that the caller retries once that operation is done instead of
+// running conflicting tasks in CNS. An empty key, which is rejected by the
+// request validation, is not tracked.
+func (o *InFlightOperations) Acquire(ctx context.Context, key string, operation string) (func(), error) {
+	log := logger.GetLogger(ctx)
+	if key == "" {
+		return func() {}, nil
+	}
+	if inFlight, ok := o.TryAcquire(key, operation); !ok {
+		return nil, logger.LogNewErrorCodef(log, codes.Aborted,
+			"cannot run %s on %q, %s is already in progress on it", operation, key, inFlight)
+	}
+	return func() { o.Release(key) }, nil
+}
+
+// AcquireShared is Acquire for an operation which does not conflict with the
+// other shared operations on the same key, but only with the exclusive ones.
+func (o *InFlightOperations) AcquireShared(ctx context.Context, key string, operation string) (func(), error) {
+	log := logger.GetLogger(ctx)
+	if key == "" {
+		return func() {}, nil
+	}
+	if inFlight, ok := o.TryAcquireShared(key, operation); !ok {
+		return nil, logger.LogNewErrorCodef(log, codes.Aborted,
+			"cannot run %s on %q, %s is already in progress on it", operation, key, inFlight)
+	}
+	return func() { o.ReleaseShared(key, operation) }, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestInFlightOperationsOverlapping(t *testing.T) {
+	var o InFlightOperations
+	release, err := o.Acquire(ctx, "vol-1", "DeleteVolume")
+	if err != nil {
+		t.Fatalf("failed to acquire vol-1: %v", err)
+	}
+	// A conflicting operation on the same volume is aborted.
+	if _, err := o.Acquire(ctx, "vol-1", "ControllerPublishVolume"); status.Code(err) != codes.Aborted {
+		t.Errorf("expected Aborted error for overlapping operation, got %v", err)
+	}
+	// Operations on other volumes are not affected.
+	releaseOther, err := o.Acquire(ctx, "vol-2", "ControllerPublishVolume")
+	if err != nil {
+		t.Fatalf("failed to acquire vol-2: %v", err)
+	}
+	releaseOther()
+	release()
+	// The volume can be operated on again once the operation is done.
+	release, err = o.Acquire(ctx, "vol-1", "ControllerPublishVolume")
+	if err != nil {
+		t.Fatalf("failed to acquire vol-1 after release: %v", err)
+	}
+	release()
+}
+
+func TestInFlightOperationsConcurrent(t *testing.T) {
+	var o InFlightOperations
+	const goroutines = 20
+	start := make(chan struct{})
+	acquired := make(chan func(), goroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if release, err := o.Acquire(ctx, "vol-1", "ControllerExpandVolume"); err == nil {
+				acquired <- release
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(acquired)
+	if len(acquired) != 1 {
+		t.Fatalf("expected exactly one of the overlapping operations to proceed, got %d", len(acquired))
+	}
+	for release := range acquired {
+		release()
+	}
+	if inFlight, ok := o.TryAcquire("vol-1", "DeleteVolume"); !ok {
+		t.Errorf("expected vol-1 to be released, %s is still in progress", inFlight)
+	}
+}
+
+func TestInFlightOperationsShared(t *testing.T) {
+	var o InFlightOperations
+	// Shared operations on the same key do not conflict with each other.
+	release1, err := o.AcquireShared(ctx, "vol-1", "ControllerPublishVolume")
+	if err != nil {
+		t.Fatalf("failed to acquire vol-1 shared: %v", err)
+	}
+	release2, err := o.AcquireShared(ctx, "vol-1", "ControllerPublishVolume")
+	if err != nil {
+		t.Fatalf("failed to acquire vol-1 shared twice: %v", err)
+	}
+	release3, err := o.AcquireShared(ctx, "vol-1", "CreateSnapshot")
+	if err != nil {
+		t.Fatalf("failed to acquire vol-1 shared by another operation: %v", err)
+	}
+	// An exclusive operation is aborted until all the shared ones are done.
+	for _, release := range []func(){release1, release3} {
+		if _, err := o.Acquire(ctx, "vol-1", "ControllerExpandVolume"); status.Code(err) != codes.Aborted {
+			t.Errorf("expected Aborted error for exclusive operation, got %v", err)
+		}
+		release()
+	}
+	if _, err := o.Acquire(ctx, "vol-1", "ControllerExpandVolume"); status.Code(err) != codes.Aborted {
+		t.Errorf("expected Aborted error for exclusive operation, got %v", err)
+	}
+	release2()
+	release, err := o.Acquire(ctx, "vol-1", "ControllerExpandVolume")
+	if err != nil {
+		t.Fatalf("failed to acquire vol-1 after the shared operations: %v", err)
+	}
+	// A shared operation is aborted while an exclusive one is in progress.
+	if _, err := o.AcquireShared(ctx, "vol-1", "ControllerPublishVolume"); status.Code(err) != codes.Aborted {
+		t.Errorf("expected Aborted error for shared operation, got %v", err)
+	}
+	release()
+	if inFlight, ok := o.TryAcquireShared("vol-1", "ControllerPublishVolume"); !ok {
+		t.Errorf("expected vol-1 to be released, %s is still in progress", inFlight)
+	}
+}
@@ -27,12 +27,21 @@ import (
 
 var (
 	// BlockVolumeCaps represents how the block volume could be accessed.
-	// CNS block volumes support only SINGLE_NODE_WRITER where the volume is
-	// attached to a single node at any given time.
+	// CNS block volumes support SINGLE_NODE_WRITER where the volume is
+	// attached to a single node at any given time. Raw block volumes created
+	// with multi-writer disk sharing also support MULTI_NODE_MULTI_WRITER and
+	// raw block volumes attached in read-only mode support
+	// MULTI_NODE_READER_ONLY.
 	BlockVolumeCaps = []csi.VolumeCapability_AccessMode{
 		{
 			Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
 		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+		},
+		{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+		},
 	}
 
 	// FileVolumeCaps represents how the file volume could be accessed.
@@ -87,6 +96,9 @@ type CreateVolumeSpec struct {
 	VolumeType              string
 	VsanDirectDatastoreURL  string // Datastore URL from vSan direct storage pool
 	ContentSourceSnapshotID string // SnapshotID from VolumeContentSource in CreateVolumeRequest
+	// SkipConfigNetPermissions is set when the access to a file volume is
+	// granted per node instead of through the NetPermissions in the config.
+	SkipConfigNetPermissions bool
 }
 
 // StorageClassParams represents the storage class parameterss
@@ -95,4 +107,14 @@ type StorageClassParams struct {
 	StoragePolicyName string
 	CSIMigration      string
 	Datastore         string
+	DiskSharing       string
+	NfsSecurityType   string
+	NfsVersion        string
+	FileProtocol      string
+	// NetPermissions overrides the NetPermissions in the driver config for
+	// file volumes when set.
+	NetPermissions *config.NetPermissionConfig
+	// ProtectFromVMDeletion overrides the ProtectVolumesFromVMDeletion in the
+	// driver config for block volumes when set.
+	ProtectFromVMDeletion string
 }
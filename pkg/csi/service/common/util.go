@@ -27,6 +27,7 @@ import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	pbmtypes "github.com/vmware/govmomi/pbm/types"
 	"github.com/vmware/govmomi/vim25/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
 	apiMeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -138,8 +139,29 @@ func GetLabelsMapFromKeyValue(labels []types.KeyValue) map[string]string {
 }
 
 // IsFileVolumeRequest checks whether the request is to create a CNS file volume.
+// Multi-node access modes with raw block volume mode are served by shared
+// block volumes and are not considered as file volume requests.
 func IsFileVolumeRequest(ctx context.Context, capabilities []*csi.VolumeCapability) bool {
 	for _, capability := range capabilities {
+		if capability.GetBlock() != nil {
+			continue
+		}
+		if capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY ||
+			capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER ||
+			capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMultiNodeBlockVolumeRequest checks whether the request is for a raw block
+// volume which is accessed from multiple nodes at the same time.
+func IsMultiNodeBlockVolumeRequest(ctx context.Context, capabilities []*csi.VolumeCapability) bool {
+	for _, capability := range capabilities {
+		if capability.GetBlock() == nil {
+			continue
+		}
 		if capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY ||
 			capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER ||
 			capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
@@ -149,6 +171,21 @@ func IsFileVolumeRequest(ctx context.Context, capabilities []*csi.VolumeCapabili
 	return false
 }
 
+// IsMultiNodeWriterBlockVolumeRequest checks whether the request is for a raw
+// block volume which is written from multiple nodes at the same time.
+func IsMultiNodeWriterBlockVolumeRequest(ctx context.Context, capabilities []*csi.VolumeCapability) bool {
+	for _, capability := range capabilities {
+		if capability.GetBlock() == nil {
+			continue
+		}
+		if capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER ||
+			capability.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
+			return true
+		}
+	}
+	return false
+}
+
 // IsVolumeReadOnly checks the access mode in Volume Capability and decides
 // if volume is readonly or not.
 func IsVolumeReadOnly(capability *csi.VolumeCapability) bool {
@@ -188,6 +225,14 @@ func validateVolumeCapabilities(volCaps []*csi.VolumeCapability,
 				return fmt.Errorf("fstype %s not supported for ReadWriteOnce volume creation",
 					volCap.GetMount().FsType)
 			}
+		} else if volumeType == BlockVolumeType {
+			// Block volumes are shared between nodes only as raw block devices,
+			// as none of the supported filesystems can be mounted on several
+			// nodes at the same time.
+			if volCap.GetBlock() == nil {
+				return fmt.Errorf("%s access mode is supported only with block volume mode for %q volumes",
+					csi.VolumeCapability_AccessMode_Mode_name[int32(volCap.AccessMode.GetMode())], volumeType)
+			}
 		} else if volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY ||
 			volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER ||
 			volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER {
@@ -199,10 +244,6 @@ func validateVolumeCapabilities(volCaps []*csi.VolumeCapability,
 				volCap.GetMount().FsType == "") {
 				return fmt.Errorf("fstype %s not supported for ReadWriteMany or ReadOnlyMany volume creation",
 					volCap.GetMount().FsType)
-			} else if volCap.GetBlock() != nil {
-				// Raw Block volumes are not supported with ReadWriteMany or ReadOnlyMany access modes,
-				return fmt.Errorf("block volume mode is not supported for ReadWriteMany or ReadOnlyMany " +
-					"volume creation")
 			}
 		}
 	}
@@ -236,6 +277,20 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 				scParams.StoragePolicyName = value
 			} else if param == AttributeFsType {
 				log.Warnf("param 'fstype' is deprecated, please use 'csi.storage.k8s.io/fstype' instead")
+			} else if param == AttributeDiskSharing {
+				scParams.DiskSharing = strings.ToLower(value)
+			} else if param == AttributeNfsSecurityType {
+				scParams.NfsSecurityType = strings.ToLower(value)
+			} else if param == AttributeNfsVersion {
+				scParams.NfsVersion = value
+			} else if param == AttributeFileProtocol {
+				scParams.FileProtocol = strings.ToLower(value)
+			} else if isNetPermissionParam(param) {
+				if err := parseNetPermissionParam(scParams, param, value); err != nil {
+					return nil, err
+				}
+			} else if param == AttributeProtectFromVMDeletion {
+				scParams.ProtectFromVMDeletion = strings.ToLower(value)
 			} else {
 				return nil, fmt.Errorf("invalid param: %q and value: %q", param, value)
 			}
@@ -252,6 +307,20 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 				log.Warnf("param 'fstype' is deprecated, please use 'csi.storage.k8s.io/fstype' instead")
 			} else if param == CSIMigrationParams {
 				scParams.CSIMigration = value
+			} else if param == AttributeDiskSharing {
+				scParams.DiskSharing = strings.ToLower(value)
+			} else if param == AttributeNfsSecurityType {
+				scParams.NfsSecurityType = strings.ToLower(value)
+			} else if param == AttributeNfsVersion {
+				scParams.NfsVersion = value
+			} else if param == AttributeFileProtocol {
+				scParams.FileProtocol = strings.ToLower(value)
+			} else if isNetPermissionParam(param) {
+				if err := parseNetPermissionParam(scParams, param, value); err != nil {
+					return nil, err
+				}
+			} else if param == AttributeProtectFromVMDeletion {
+				scParams.ProtectFromVMDeletion = strings.ToLower(value)
 			} else {
 				otherParams[param] = value
 			}
@@ -280,9 +349,156 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 			}
 		}
 	}
+	if scParams.DiskSharing != "" && scParams.DiskSharing != DiskSharingMultiWriter {
+		return nil, fmt.Errorf("invalid value %q for param %q. Supported value is %q",
+			scParams.DiskSharing, AttributeDiskSharing, DiskSharingMultiWriter)
+	}
+	if scParams.NfsSecurityType != "" && !IsValidNfsSecurityType(scParams.NfsSecurityType) {
+		return nil, fmt.Errorf("invalid value %q for param %q. Supported values are %q, %q, %q and %q",
+			scParams.NfsSecurityType, AttributeNfsSecurityType, NfsSecurityTypeSys, NfsSecurityTypeKrb5,
+			NfsSecurityTypeKrb5i, NfsSecurityTypeKrb5p)
+	}
+	if scParams.NfsVersion != "" && !IsValidNfsVersion(scParams.NfsVersion) {
+		return nil, fmt.Errorf("invalid value %q for param %q. Supported values are %q and %q",
+			scParams.NfsVersion, AttributeNfsVersion, NfsVersion3, NfsVersion41)
+	}
+	if scParams.NfsVersion == NfsVersion3 && IsKerberosNfsSecurityType(scParams.NfsSecurityType) {
+		return nil, fmt.Errorf("param %q %q is not supported with NFS version %q",
+			AttributeNfsSecurityType, scParams.NfsSecurityType, scParams.NfsVersion)
+	}
+	if scParams.FileProtocol != "" && scParams.FileProtocol != FileProtocolNFS &&
+		scParams.FileProtocol != FileProtocolSMB {
+		return nil, fmt.Errorf("invalid value %q for param %q. Supported values are %q and %q",
+			scParams.FileProtocol, AttributeFileProtocol, FileProtocolNFS, FileProtocolSMB)
+	}
+	if scParams.FileProtocol == FileProtocolSMB && (scParams.NfsVersion != "" || scParams.NfsSecurityType != "") {
+		return nil, fmt.Errorf("params %q and %q are not supported with param %q %q",
+			AttributeNfsVersion, AttributeNfsSecurityType, AttributeFileProtocol, FileProtocolSMB)
+	}
+	if scParams.ProtectFromVMDeletion != "" {
+		protect, err := strconv.ParseBool(scParams.ProtectFromVMDeletion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for param %q. Supported values are %q and %q",
+				scParams.ProtectFromVMDeletion, AttributeProtectFromVMDeletion, "true", "false")
+		}
+		scParams.ProtectFromVMDeletion = strconv.FormatBool(protect)
+	}
 	return scParams, nil
 }
 
+// IsProtectFromVMDeletionRequested returns true if the block volume with the
+// given volume context is to be protected from being deleted along with the
+// node VM it is attached to. The AttributeProtectFromVMDeletion set from the
+// StorageClass takes precedence over the given default from the driver config.
+func IsProtectFromVMDeletionRequested(volumeContext map[string]string, defaultValue bool) bool {
+	for key, value := range volumeContext {
+		if strings.ToLower(key) != AttributeProtectFromVMDeletion {
+			continue
+		}
+		if protect, err := strconv.ParseBool(value); err == nil {
+			return protect
+		}
+	}
+	return defaultValue
+}
+
+// IsFileVolumeRootSquashRequested returns true if root access is to be
+// squashed for the nodes granted access to the file volume with the given
+// volume context. The AttributeNetPermissionRootSquash set from the
+// StorageClass takes precedence over the given NetPermissions of the driver
+// config, which squash root access if any of them does.
+func IsFileVolumeRootSquashRequested(volumeContext map[string]string,
+	netPermissions map[string]*cnsconfig.NetPermissionConfig) bool {
+	for key, value := range volumeContext {
+		if strings.ToLower(key) != AttributeNetPermissionRootSquash {
+			continue
+		}
+		if rootSquash, err := strconv.ParseBool(value); err == nil {
+			return rootSquash
+		}
+	}
+	for _, netPerm := range netPermissions {
+		if netPerm != nil && netPerm.RootSquash {
+			return true
+		}
+	}
+	return false
+}
+
+// isNetPermissionParam returns true if the given StorageClass parameter is one
+// of the file volume net permission parameters.
+func isNetPermissionParam(param string) bool {
+	return param == AttributeNetPermissionIps || param == AttributeNetPermissionAccess ||
+		param == AttributeNetPermissionRootSquash
+}
+
+// parseNetPermissionParam sets the given net permission parameter in the
+// StorageClassParams. The net permission not given in the parameters default
+// to the ones of cnsconfig.GetDefaultNetPermission.
+func parseNetPermissionParam(scParams *StorageClassParams, param string, value string) error {
+	if scParams.NetPermissions == nil {
+		scParams.NetPermissions = cnsconfig.GetDefaultNetPermission()
+	}
+	switch param {
+	case AttributeNetPermissionIps:
+		if value == "" {
+			return fmt.Errorf("invalid empty value for param %q", param)
+		}
+		scParams.NetPermissions.Ips = value
+	case AttributeNetPermissionAccess:
+		access := vsanfstypes.VsanFileShareAccessType(strings.ToUpper(value))
+		if access != vsanfstypes.VsanFileShareAccessTypeNO_ACCESS &&
+			access != vsanfstypes.VsanFileShareAccessTypeREAD_ONLY &&
+			access != vsanfstypes.VsanFileShareAccessTypeREAD_WRITE {
+			return fmt.Errorf("invalid value %q for param %q. Supported values are %q, %q and %q",
+				value, param, vsanfstypes.VsanFileShareAccessTypeREAD_ONLY,
+				vsanfstypes.VsanFileShareAccessTypeREAD_WRITE, vsanfstypes.VsanFileShareAccessTypeNO_ACCESS)
+		}
+		scParams.NetPermissions.Permissions = access
+	case AttributeNetPermissionRootSquash:
+		rootSquash, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for param %q. Error: %v", value, param, err)
+		}
+		scParams.NetPermissions.RootSquash = rootSquash
+	}
+	return nil
+}
+
+// IsValidNfsSecurityType returns true if the given value is a supported NFS
+// security flavor for file volumes.
+func IsValidNfsSecurityType(secType string) bool {
+	switch secType {
+	case NfsSecurityTypeSys, NfsSecurityTypeKrb5, NfsSecurityTypeKrb5i, NfsSecurityTypeKrb5p:
+		return true
+	}
+	return false
+}
+
+// IsValidNfsVersion returns true if the given value is a supported NFS
+// protocol version for file volumes.
+func IsValidNfsVersion(version string) bool {
+	return version == NfsVersion3 || version == NfsVersion41
+}
+
+// GetFileVolumeAccessPointKey returns the key of the access points to mount
+// a file volume with, from the protocol and NFS version in its volume context.
+func GetFileVolumeAccessPointKey(volumeContext map[string]string) string {
+	if volumeContext[AttributeFileProtocol] == FileProtocolSMB {
+		return SmbAccessPointKey
+	}
+	if volumeContext[AttributeNfsVersion] == NfsVersion3 {
+		return Nfsv3AccessPointKey
+	}
+	return Nfsv4AccessPointKey
+}
+
+// IsKerberosNfsSecurityType returns true if the given NFS security flavor uses
+// Kerberos.
+func IsKerberosNfsSecurityType(secType string) bool {
+	return secType == NfsSecurityTypeKrb5 || secType == NfsSecurityTypeKrb5i || secType == NfsSecurityTypeKrb5p
+}
+
 // GetK8sCloudOperatorServicePort return the port to connect the
 // K8sCloudOperator gRPC service.
 // If environment variable POD_LISTENER_SERVICE_PORT is set and valid,
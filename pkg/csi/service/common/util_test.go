@@ -20,12 +20,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 )
 
 var (
@@ -170,6 +174,55 @@ func TestValidVolumeCapabilitiesForBlock(t *testing.T) {
 	}
 }
 
+func TestValidVolumeCapabilitiesForMultiWriterBlock(t *testing.T) {
+	// volumeMode=block and accessMode=MULTI_NODE_MULTI_WRITER
+	volCap := []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			},
+		},
+	}
+	if err := IsValidVolumeCapabilities(ctx, volCap); err != nil {
+		t.Errorf("Block VolCap = %+v failed validation!", volCap)
+	}
+	if IsFileVolumeRequest(ctx, volCap) {
+		t.Errorf("VolCap = %+v reported as a FILE volume!", volCap)
+	}
+	if !IsMultiNodeBlockVolumeRequest(ctx, volCap) {
+		t.Errorf("VolCap = %+v not reported as a multi-node block volume!", volCap)
+	}
+}
+
+func TestValidVolumeCapabilitiesForReadOnlyManyBlock(t *testing.T) {
+	// volumeMode=block and accessMode=MULTI_NODE_READER_ONLY
+	volCap := []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Block{
+				Block: &csi.VolumeCapability_BlockVolume{},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+			},
+		},
+	}
+	if err := IsValidVolumeCapabilities(ctx, volCap); err != nil {
+		t.Errorf("Block VolCap = %+v failed validation!", volCap)
+	}
+	if IsFileVolumeRequest(ctx, volCap) {
+		t.Errorf("VolCap = %+v reported as a FILE volume!", volCap)
+	}
+	if IsMultiNodeWriterBlockVolumeRequest(ctx, volCap) {
+		t.Errorf("VolCap = %+v reported as a multi-node writer block volume!", volCap)
+	}
+	if !IsVolumeReadOnly(volCap[0]) {
+		t.Errorf("VolCap = %+v not reported as read-only!", volCap)
+	}
+}
+
 func TestInvalidVolumeCapabilitiesForBlock(t *testing.T) {
 	// Invalid case: fstype=nfs and mode=SINGLE_NODE_WRITER
 	volCap := []*csi.VolumeCapability{
@@ -326,29 +379,14 @@ func TestInvalidVolumeCapabilitiesForFile(t *testing.T) {
 		t.Errorf("Invalid file VolCap = %+v passed validation!", volCap)
 	}
 
-	// Invalid case: volumeMode=block and accessMode=MULTI_NODE_MULTI_WRITER
-	volCap = []*csi.VolumeCapability{
-		{
-			AccessType: &csi.VolumeCapability_Block{
-				Block: &csi.VolumeCapability_BlockVolume{},
-			},
-			AccessMode: &csi.VolumeCapability_AccessMode{
-				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
-			},
-		},
-	}
-	if err := IsValidVolumeCapabilities(ctx, volCap); err == nil {
-		t.Errorf("Invalid file VolCap = %+v passed validation!", volCap)
-	}
-
-	// Invalid case: volumeMode=block and accessMode=MULTI_NODE_READER_ONLY
+	// Invalid case: volumeMode=block and accessMode=MULTI_NODE_SINGLE_WRITER
 	volCap = []*csi.VolumeCapability{
 		{
 			AccessType: &csi.VolumeCapability_Block{
 				Block: &csi.VolumeCapability_BlockVolume{},
 			},
 			AccessMode: &csi.VolumeCapability_AccessMode{
-				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_SINGLE_WRITER,
 			},
 		},
 	}
@@ -536,3 +574,275 @@ func TestParseCSISnapshotID(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStorageClassParamsWithDiskSharing(t *testing.T) {
+	params := map[string]string{
+		AttributeDiskSharing: "MultiWriter",
+	}
+	scParam, err := ParseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Fatalf("failed to parse params: %+v, err: %+v", params, err)
+	}
+	if scParam.DiskSharing != DiskSharingMultiWriter {
+		t.Errorf("Expected DiskSharing: %q, Actual: %q", DiskSharingMultiWriter, scParam.DiskSharing)
+	}
+}
+
+func TestParseStorageClassParamsWithInvalidDiskSharing(t *testing.T) {
+	params := map[string]string{
+		AttributeDiskSharing: "singlewriter",
+	}
+	scParam, err := ParseStorageClassParams(ctx, params, false)
+	if err == nil {
+		t.Errorf("error expected but not received. scParam received from ParseStorageClassParams: %v", scParam)
+	}
+	t.Logf("expected err received. err: %v", err)
+}
+
+func TestParseStorageClassParamsWithNfsSecurityType(t *testing.T) {
+	params := map[string]string{
+		AttributeNfsSecurityType: "KRB5P",
+	}
+	scParam, err := ParseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Fatalf("failed to parse params: %+v, err: %+v", params, err)
+	}
+	if scParam.NfsSecurityType != NfsSecurityTypeKrb5p {
+		t.Errorf("Expected NfsSecurityType: %q, Actual: %q", NfsSecurityTypeKrb5p, scParam.NfsSecurityType)
+	}
+	if !IsKerberosNfsSecurityType(scParam.NfsSecurityType) {
+		t.Errorf("Expected %q to be a Kerberos security type", scParam.NfsSecurityType)
+	}
+}
+
+func TestParseStorageClassParamsWithInvalidNfsSecurityType(t *testing.T) {
+	params := map[string]string{
+		AttributeNfsSecurityType: "ntlm",
+	}
+	scParam, err := ParseStorageClassParams(ctx, params, false)
+	if err == nil {
+		t.Errorf("error expected but not received. scParam received from ParseStorageClassParams: %v", scParam)
+	}
+	t.Logf("expected err received. err: %v", err)
+}
+
+func TestParseStorageClassParamsWithNfsVersion(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    map[string]string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "NFSv3",
+			params:   map[string]string{"NfsVersion": "3"},
+			expected: NfsVersion3,
+		},
+		{
+			name:     "NFSv4.1 with Kerberos",
+			params:   map[string]string{AttributeNfsVersion: "4.1", AttributeNfsSecurityType: "krb5"},
+			expected: NfsVersion41,
+		},
+		{
+			name:      "Invalid version",
+			params:    map[string]string{AttributeNfsVersion: "4"},
+			expectErr: true,
+		},
+		{
+			name:      "NFSv3 with Kerberos",
+			params:    map[string]string{AttributeNfsVersion: "3", AttributeNfsSecurityType: "krb5i"},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scParam, err := ParseStorageClassParams(ctx, test.params, false)
+			if test.expectErr {
+				if err == nil {
+					t.Fatalf("expected error for params %v, got %+v", test.params, scParam)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse params: %+v, err: %+v", test.params, err)
+			}
+			if scParam.NfsVersion != test.expected {
+				t.Errorf("Expected NfsVersion: %q, Actual: %q", test.expected, scParam.NfsVersion)
+			}
+		})
+	}
+}
+
+func TestParseStorageClassParamsWithFileProtocol(t *testing.T) {
+	scParam, err := ParseStorageClassParams(ctx, map[string]string{"FileProtocol": "SMB"}, false)
+	if err != nil {
+		t.Fatalf("failed to parse params, err: %+v", err)
+	}
+	if scParam.FileProtocol != FileProtocolSMB {
+		t.Errorf("Expected FileProtocol: %q, Actual: %q", FileProtocolSMB, scParam.FileProtocol)
+	}
+	for _, params := range []map[string]string{
+		{AttributeFileProtocol: "cifs"},
+		{AttributeFileProtocol: "smb", AttributeNfsVersion: "3"},
+		{AttributeFileProtocol: "smb", AttributeNfsSecurityType: "sys"},
+	} {
+		if scParam, err := ParseStorageClassParams(ctx, params, false); err == nil {
+			t.Errorf("expected error for params %v, got %+v", params, scParam)
+		}
+	}
+}
+
+func TestGetFileVolumeAccessPointKey(t *testing.T) {
+	tests := []struct {
+		volumeContext map[string]string
+		expected      string
+	}{
+		{volumeContext: nil, expected: Nfsv4AccessPointKey},
+		{volumeContext: map[string]string{AttributeNfsVersion: NfsVersion41}, expected: Nfsv4AccessPointKey},
+		{volumeContext: map[string]string{AttributeNfsVersion: NfsVersion3}, expected: Nfsv3AccessPointKey},
+		{volumeContext: map[string]string{AttributeFileProtocol: FileProtocolNFS}, expected: Nfsv4AccessPointKey},
+		{volumeContext: map[string]string{AttributeFileProtocol: FileProtocolSMB}, expected: SmbAccessPointKey},
+	}
+	for _, test := range tests {
+		if key := GetFileVolumeAccessPointKey(test.volumeContext); key != test.expected {
+			t.Errorf("GetFileVolumeAccessPointKey(%v) = %q, want %q", test.volumeContext, key, test.expected)
+		}
+	}
+}
+
+func TestParseStorageClassParamsWithNetPermissions(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    map[string]string
+		expected  *cnsconfig.NetPermissionConfig
+		expectErr bool
+	}{
+		{
+			name:     "No net permissions",
+			params:   map[string]string{},
+			expected: nil,
+		},
+		{
+			name: "All net permissions",
+			params: map[string]string{
+				AttributeNetPermissionIps:        "10.20.30.0/24",
+				AttributeNetPermissionAccess:     "read_only",
+				AttributeNetPermissionRootSquash: "true",
+			},
+			expected: &cnsconfig.NetPermissionConfig{
+				Ips:         "10.20.30.0/24",
+				Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_ONLY,
+				RootSquash:  true,
+			},
+		},
+		{
+			name: "Defaults for net permissions not given",
+			params: map[string]string{
+				AttributeNetPermissionAccess: "READ_ONLY",
+			},
+			expected: &cnsconfig.NetPermissionConfig{
+				Ips:         "*",
+				Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_ONLY,
+				RootSquash:  false,
+			},
+		},
+		{
+			name: "Invalid access",
+			params: map[string]string{
+				AttributeNetPermissionAccess: "WRITE_ONLY",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid root squash",
+			params: map[string]string{
+				AttributeNetPermissionRootSquash: "maybe",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Empty IPs",
+			params: map[string]string{
+				AttributeNetPermissionIps: "",
+			},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scParams, err := ParseStorageClassParams(ctx, test.params, false)
+			if test.expectErr {
+				if err == nil {
+					t.Fatalf("error expected but not received. scParams: %+v", scParams)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse params: %+v, err: %+v", test.params, err)
+			}
+			if !reflect.DeepEqual(scParams.NetPermissions, test.expected) {
+				t.Errorf("Expected NetPermissions: %+v, Actual: %+v", test.expected, scParams.NetPermissions)
+			}
+		})
+	}
+}
+
+func TestParseStorageClassParamsWithProtectFromVMDeletion(t *testing.T) {
+	scParam, err := ParseStorageClassParams(ctx, map[string]string{"ProtectFromVMDeletion": "True"}, false)
+	if err != nil {
+		t.Fatalf("failed to parse params, err: %+v", err)
+	}
+	if scParam.ProtectFromVMDeletion != "true" {
+		t.Errorf("Expected ProtectFromVMDeletion: %q, Actual: %q", "true", scParam.ProtectFromVMDeletion)
+	}
+	params := map[string]string{AttributeProtectFromVMDeletion: "always"}
+	if scParam, err := ParseStorageClassParams(ctx, params, true); err == nil {
+		t.Errorf("expected error for params %v, got %+v", params, scParam)
+	}
+}
+
+func TestIsFileVolumeRootSquashRequested(t *testing.T) {
+	rootSquashed := map[string]*cnsconfig.NetPermissionConfig{
+		"A": {Ips: "10.0.0.0/8", RootSquash: false},
+		"B": {Ips: "*", RootSquash: true},
+	}
+	tests := []struct {
+		volumeContext  map[string]string
+		netPermissions map[string]*cnsconfig.NetPermissionConfig
+		expected       bool
+	}{
+		{volumeContext: nil, netPermissions: nil, expected: false},
+		{volumeContext: nil, netPermissions: rootSquashed, expected: true},
+		{volumeContext: map[string]string{AttributeNetPermissionRootSquash: "true"}, expected: true},
+		{volumeContext: map[string]string{AttributeNetPermissionRootSquash: "false"}, netPermissions: rootSquashed,
+			expected: false},
+		{volumeContext: map[string]string{AttributeNetPermissionRootSquash: "invalid"}, netPermissions: rootSquashed,
+			expected: true},
+	}
+	for _, test := range tests {
+		if actual := IsFileVolumeRootSquashRequested(test.volumeContext, test.netPermissions); actual != test.expected {
+			t.Errorf("volumeContext: %v, netPermissions: %v, expected: %v, actual: %v",
+				test.volumeContext, test.netPermissions, test.expected, actual)
+		}
+	}
+}
+
+func TestIsProtectFromVMDeletionRequested(t *testing.T) {
+	tests := []struct {
+		volumeContext map[string]string
+		defaultValue  bool
+		expected      bool
+	}{
+		{volumeContext: nil, defaultValue: false, expected: false},
+		{volumeContext: nil, defaultValue: true, expected: true},
+		{volumeContext: map[string]string{AttributeProtectFromVMDeletion: "true"}, defaultValue: false, expected: true},
+		{volumeContext: map[string]string{AttributeProtectFromVMDeletion: "false"}, defaultValue: true, expected: false},
+		{volumeContext: map[string]string{AttributeProtectFromVMDeletion: "invalid"}, defaultValue: true, expected: true},
+	}
+	for _, test := range tests {
+		if actual := IsProtectFromVMDeletionRequested(test.volumeContext, test.defaultValue); actual != test.expected {
+			t.Errorf("volumeContext: %v, default: %v, expected: %v, actual: %v",
+				test.volumeContext, test.defaultValue, test.expected, actual)
+		}
+	}
+}
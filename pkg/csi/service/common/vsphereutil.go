@@ -453,6 +453,31 @@ func CreateBlockVolumeUtilForMultiVC(ctx context.Context, reqParams interface{})
 	return volumeInfo, "", nil
 }
 
+// GetFileVolumeNetPermissions returns the net permissions of the file shares
+// created with the given StorageClass params, which are the ones of the
+// StorageClass, or else the ones of the given config unless access is granted
+// per node.
+func GetFileVolumeNetPermissions(scParams *StorageClassParams, skipConfigNetPermissions bool,
+	cnsConfig *config.Config) []vsanfstypes.VsanFileShareNetPermission {
+	netPermissions := make([]*config.NetPermissionConfig, 0)
+	if scParams != nil && scParams.NetPermissions != nil {
+		netPermissions = append(netPermissions, scParams.NetPermissions)
+	} else if !skipConfigNetPermissions {
+		for _, netPerm := range cnsConfig.NetPermissions {
+			netPermissions = append(netPermissions, netPerm)
+		}
+	}
+	netPerms := make([]vsanfstypes.VsanFileShareNetPermission, 0)
+	for _, netPerm := range netPermissions {
+		netPerms = append(netPerms, vsanfstypes.VsanFileShareNetPermission{
+			Ips:         netPerm.Ips,
+			Permissions: netPerm.Permissions,
+			AllowRoot:   !netPerm.RootSquash,
+		})
+	}
+	return netPerms
+}
+
 // CreateFileVolumeUtil is the helper function to create CNS file volume with
 // datastores.
 func CreateFileVolumeUtil(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor,
@@ -515,15 +540,12 @@ func CreateFileVolumeUtil(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 		return "", fault, err
 	}
 
-	// Retrieve net permissions from CnsConfig of manager and convert to required
-	// format.
+	// A Kerberos file share is created without net permissions, so that it is
+	// not exported with AUTH_SYS until it is reconfigured with its NFS
+	// security type and net permissions.
 	netPerms := make([]vsanfstypes.VsanFileShareNetPermission, 0)
-	for _, netPerm := range cnsConfig.NetPermissions {
-		netPerms = append(netPerms, vsanfstypes.VsanFileShareNetPermission{
-			Ips:         netPerm.Ips,
-			Permissions: netPerm.Permissions,
-			AllowRoot:   !netPerm.RootSquash,
-		})
+	if spec.ScParams == nil || !IsKerberosNfsSecurityType(spec.ScParams.NfsSecurityType) {
+		netPerms = GetFileVolumeNetPermissions(spec.ScParams, spec.SkipConfigNetPermissions, cnsConfig)
 	}
 
 	clusterID := cnsConfig.Global.ClusterID
@@ -606,6 +628,40 @@ func AttachVolumeUtil(ctx context.Context, volumeManager cnsvolume.Manager,
 	return diskUUID, "", err
 }
 
+// AttachVolumeWithSharingUtil is the helper function to attach CNS volume to
+// specified vm with the given virtual disk sharing and disk mode.
+func AttachVolumeWithSharingUtil(ctx context.Context, volumeManager cnsvolume.Manager,
+	vm *vsphere.VirtualMachine, volumeID string, sharing vim25types.VirtualDiskSharing,
+	diskMode vim25types.VirtualDiskMode) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("vSphere CSI driver is attaching volume: %q to vm: %q with sharing: %q and disk mode: %q",
+		volumeID, vm.String(), sharing, diskMode)
+	diskUUID, faultType, err := volumeManager.AttachVolumeWithSharing(ctx, vm, volumeID, sharing, diskMode)
+	if err != nil {
+		log.Errorf("failed to attach disk %q with VM: %q. err: %+v faultType %q", volumeID, vm.String(), err, faultType)
+		return "", faultType, err
+	}
+	log.Debugf("Successfully attached disk %s to VM %v. Disk UUID is %s", volumeID, vm, diskUUID)
+	return diskUUID, "", nil
+}
+
+// AttachVolumeToLeastUsedControllerUtil is the helper function to attach CNS
+// volume to specified vm on its disk controller with the fewest disks.
+func AttachVolumeToLeastUsedControllerUtil(ctx context.Context, volumeManager cnsvolume.Manager,
+	vm *vsphere.VirtualMachine, volumeID string, maxPVSCSIUnitNumbers int32) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("vSphere CSI driver is attaching volume: %q to least used disk controller of vm: %q",
+		volumeID, vm.String())
+	diskUUID, faultType, err := volumeManager.AttachVolumeToLeastUsedController(ctx, vm, volumeID,
+		maxPVSCSIUnitNumbers)
+	if err != nil {
+		log.Errorf("failed to attach disk %q with VM: %q. err: %+v faultType %q", volumeID, vm.String(), err, faultType)
+		return "", faultType, err
+	}
+	log.Debugf("Successfully attached disk %s to VM %v. Disk UUID is %s", volumeID, vm, diskUUID)
+	return diskUUID, "", nil
+}
+
 // DetachVolumeUtil is the helper function to detach CNS volume from specified
 // vm.
 func DetachVolumeUtil(ctx context.Context, volumeManager cnsvolume.Manager,
@@ -1207,3 +1263,31 @@ func isDataStoreCompatible(ctx context.Context, vc *vsphere.VirtualCenter, spec
 	}
 	return "", nil
 }
+
+// GetFileVolumeNodeACLSpec returns the CnsVolumeACLConfigureSpec which grants,
+// or revokes if delete is set, access to the given file volume for the given
+// node IPs. Root access is squashed for the node IPs if rootSquash is set.
+func GetFileVolumeNodeACLSpec(volumeID string, nodeIPs []string, readOnly bool, rootSquash bool,
+	delete bool) cnstypes.CnsVolumeACLConfigureSpec {
+	accessType := vsanfstypes.VsanFileShareAccessTypeREAD_WRITE
+	if readOnly {
+		accessType = vsanfstypes.VsanFileShareAccessTypeREAD_ONLY
+	}
+	netPermissions := make([]vsanfstypes.VsanFileShareNetPermission, 0, len(nodeIPs))
+	for _, ip := range nodeIPs {
+		netPermissions = append(netPermissions, vsanfstypes.VsanFileShareNetPermission{
+			Ips:         ip,
+			Permissions: accessType,
+			AllowRoot:   !rootSquash,
+		})
+	}
+	return cnstypes.CnsVolumeACLConfigureSpec{
+		VolumeId: cnstypes.CnsVolumeId{Id: volumeID},
+		AccessControlSpecList: []cnstypes.CnsNFSAccessControlSpec{
+			{
+				Permission: netPermissions,
+				Delete:     delete,
+			},
+		},
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/vim25/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 )
@@ -151,3 +152,38 @@ func TestQueryAllVolumeSnapshotsWithQuerySnapshotsUnexpectedFault(t *testing.T)
 	_, _, err := QueryAllVolumeSnapshots(context.TODO(), nil, "", 100)
 	assert.Error(t, err)
 }
+
+func TestGetFileVolumeNodeACLSpec(t *testing.T) {
+	volumeID := "file:4f9b5c2a-0d4e-4c1e-9d6a-1b2c3d4e5f60"
+	nodeIPs := []string{"10.20.30.40", "2001:db8::10"}
+
+	spec := GetFileVolumeNodeACLSpec(volumeID, nodeIPs, false, false, false)
+	if spec.VolumeId.Id != volumeID {
+		t.Errorf("expected volume ID %q, got %q", volumeID, spec.VolumeId.Id)
+	}
+	if len(spec.AccessControlSpecList) != 1 || spec.AccessControlSpecList[0].Delete {
+		t.Fatalf("expected a single grant access control spec, got %+v", spec.AccessControlSpecList)
+	}
+	permissions := spec.AccessControlSpecList[0].Permission
+	if len(permissions) != len(nodeIPs) {
+		t.Fatalf("expected %d permissions, got %+v", len(nodeIPs), permissions)
+	}
+	for i, permission := range permissions {
+		if permission.Ips != nodeIPs[i] || permission.Permissions != vsanfstypes.VsanFileShareAccessTypeREAD_WRITE ||
+			!permission.AllowRoot {
+			t.Errorf("unexpected permission %+v for IP %q", permission, nodeIPs[i])
+		}
+	}
+
+	spec = GetFileVolumeNodeACLSpec(volumeID, nodeIPs, true, true, false)
+	for _, permission := range spec.AccessControlSpecList[0].Permission {
+		if permission.Permissions != vsanfstypes.VsanFileShareAccessTypeREAD_ONLY || permission.AllowRoot {
+			t.Errorf("expected READ_ONLY permission with root squashed, got %+v", permission)
+		}
+	}
+
+	spec = GetFileVolumeNodeACLSpec(volumeID, nodeIPs, false, false, true)
+	if !spec.AccessControlSpecList[0].Delete {
+		t.Errorf("expected access control spec to revoke access")
+	}
+}
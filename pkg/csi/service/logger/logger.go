@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc/codes"
@@ -57,9 +58,14 @@ func GetLogger(ctx context.Context) *zap.SugaredLogger {
 }
 
 // NewContextWithLogger returns a new child context with context UUID set
-// using key CtxId.
+// using key CtxId. If the context holds a recorded OpenTelemetry span, the ID
+// of its trace is used instead, so that the logs can be matched to the trace.
 func NewContextWithLogger(ctx context.Context) context.Context {
-	newCtx := withFields(ctx, zap.String(LogCtxIDKey, uuid.New().String()))
+	traceID := uuid.New().String()
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsSampled() {
+		traceID = spanContext.TraceID().String()
+	}
+	newCtx := withFields(ctx, zap.String(LogCtxIDKey, traceID))
 	return newCtx
 }
 
@@ -17,8 +17,12 @@ limitations under the License.
 package logger
 
 import (
+	"context"
 	"testing"
 
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	"google.golang.org/grpc/codes"
 )
 
@@ -54,6 +58,31 @@ func TestLogNewErrorCodef(t *testing.T) {
 	}
 }
 
+func TestNewContextWithLoggerTraceID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := context.WithValue(context.Background(), loggerKey{}, zap.New(core))
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6,
+		0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	GetLogger(NewContextWithLogger(ctx)).Info("without span")
+	GetLogger(NewContextWithLogger(trace.ContextWithSpanContext(ctx, spanContext))).Info("with span")
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 log entries, got %d", len(entries))
+	}
+	if id, _ := entries[0].ContextMap()[LogCtxIDKey].(string); id == "" || id == traceID.String() {
+		t.Errorf("expected a generated %s without span, got %q", LogCtxIDKey, id)
+	}
+	if id := entries[1].ContextMap()[LogCtxIDKey]; id != traceID.String() {
+		t.Errorf("expected %s %q with span, got %q", LogCtxIDKey, traceID.String(), id)
+	}
+}
+
 func BenchmarkLogNewError(b *testing.B) {
 	log := GetLoggerWithNoContext()
 	b.ResetTimer()
@@ -38,6 +38,9 @@ import (
 	systemApi "github.com/kubernetes-csi/csi-proxy/client/api/system/v1alpha1"
 	systemClient "github.com/kubernetes-csi/csi-proxy/client/groups/system/v1alpha1"
 
+	smb "github.com/kubernetes-csi/csi-proxy/client/api/smb/v1"
+	smbclient "github.com/kubernetes-csi/csi-proxy/client/groups/smb/v1"
+
 	"k8s.io/mount-utils"
 	utilexec "k8s.io/utils/exec"
 )
@@ -51,6 +54,8 @@ type csiProxyMounter struct {
 	DiskClient   *diskclient.Client
 	VolumeClient *volumeclient.Client
 	SystemClient *systemClient.Client
+	// SmbClient is nil when the SMB API of CSI proxy is not available.
+	SmbClient *smbclient.Client
 }
 
 // CSIProxyMounter extends the mount.Interface interface with CSI Proxy methods.
@@ -83,6 +88,16 @@ type CSIProxyMounter interface {
 	StatFS(ctx context.Context, path string) (available, capacity, used, inodesFree, inodes, inodesUsed int64, err error)
 	// GetBIOSSerialNumber - Get bios serial number
 	GetBIOSSerialNumber(ctx context.Context) (string, error)
+	// SMBMount - maps the SMB share at the source UNC path with the given credentials and links it at target.
+	SMBMount(ctx context.Context, source, target, username, password string) error
+	// RemoveSMBGlobalMapping - removes the global mapping of the SMB share at the given UNC path.
+	RemoveSMBGlobalMapping(ctx context.Context, remotePath string) error
+	// SetDiskOnline - brings the disk with the given disk number online without partitioning or formatting it.
+	SetDiskOnline(ctx context.Context, diskNumber string) error
+	// LinkDisk - links target to the raw disk with the given disk number.
+	LinkDisk(ctx context.Context, diskNumber, target string) error
+	// GetDiskSizeInBytes - returns the size in bytes of the disk with the given disk number.
+	GetDiskSizeInBytes(ctx context.Context, diskNumber string) (int64, error)
 }
 
 // NewSafeMounter returns mounter with exec
@@ -118,11 +133,20 @@ func newCSIProxyMounter(ctx context.Context) (*csiProxyMounter, error) {
 	if err != nil {
 		return nil, err
 	}
+	// The SMB API is only needed for SMB file volumes, so the node plugin
+	// keeps running without it.
+	smbClient, err := smbclient.NewClient()
+	if err != nil {
+		logger.GetLogger(ctx).Warnf("CSI proxy SMB API is not available. SMB file volumes cannot be "+
+			"published on this node. err: %v", err)
+		smbClient = nil
+	}
 	return &csiProxyMounter{
 		FsClient:     fsClient,
 		DiskClient:   diskClient,
 		VolumeClient: volumeClient,
 		SystemClient: systemClient,
+		SmbClient:    smbClient,
 		Ctx:          ctx,
 	}, nil
 }
@@ -387,6 +411,100 @@ func (mounter *csiProxyMounter) Mount(source string, target string, fstype strin
 	return nil
 }
 
+// SMBMount - maps the SMB share at the source UNC path with the given credentials and links it at target.
+// The global mapping is shared by all the targets of the share on the node and is reused by the next
+// SMBMount of the share. It is removed by RemoveSMBGlobalMapping once the last target of the share is gone.
+func (mounter *csiProxyMounter) SMBMount(ctx context.Context, source, target, username, password string) error {
+	log := logger.GetLogger(ctx)
+	if mounter.SmbClient == nil {
+		return errors.New("CSI proxy SMB API is not available")
+	}
+	mappingRequest := &smb.NewSmbGlobalMappingRequest{
+		RemotePath: source,
+		Username:   username,
+		Password:   password,
+	}
+	if _, err := mounter.SmbClient.NewSmbGlobalMapping(ctx, mappingRequest); err != nil {
+		log.Errorf("failed to map SMB share %q, err: %v", source, err)
+		return err
+	}
+	linkRequest := &fs.CreateSymlinkRequest{
+		SourcePath: source,
+		TargetPath: normalizeWindowsPath(target),
+	}
+	if _, err := mounter.FsClient.CreateSymlink(ctx, linkRequest); err != nil {
+		log.Errorf("failed to link SMB share %q at %q, err: %v", source, linkRequest.TargetPath, err)
+		return err
+	}
+	return nil
+}
+
+// RemoveSMBGlobalMapping - removes the global mapping of the SMB share at the given UNC path.
+func (mounter *csiProxyMounter) RemoveSMBGlobalMapping(ctx context.Context, remotePath string) error {
+	log := logger.GetLogger(ctx)
+	if mounter.SmbClient == nil {
+		return errors.New("CSI proxy SMB API is not available")
+	}
+	removeRequest := &smb.RemoveSmbGlobalMappingRequest{
+		RemotePath: remotePath,
+	}
+	if _, err := mounter.SmbClient.RemoveSmbGlobalMapping(ctx, removeRequest); err != nil {
+		log.Errorf("failed to remove mapping of SMB share %q, err: %v", remotePath, err)
+		return err
+	}
+	return nil
+}
+
+// SetDiskOnline - brings the disk with the given disk number online without partitioning or formatting it.
+func (mounter *csiProxyMounter) SetDiskOnline(ctx context.Context, diskNumber string) error {
+	log := logger.GetLogger(ctx)
+	diskNum, err := strconv.ParseUint(diskNumber, 10, 32)
+	if err != nil {
+		return fmt.Errorf("parse %s failed with error: %v", diskNumber, err)
+	}
+	setDiskStateRequest := &disk.SetDiskStateRequest{
+		DiskNumber: uint32(diskNum),
+		IsOnline:   true,
+	}
+	if _, err = mounter.DiskClient.SetDiskState(ctx, setDiskStateRequest); err != nil {
+		log.Errorf("failed to set disk state as online for disk: %d, err: %v", setDiskStateRequest.DiskNumber, err)
+		return err
+	}
+	return nil
+}
+
+// LinkDisk - links target to the raw disk with the given disk number.
+func (mounter *csiProxyMounter) LinkDisk(ctx context.Context, diskNumber, target string) error {
+	log := logger.GetLogger(ctx)
+	linkRequest := &fs.CreateSymlinkRequest{
+		SourcePath: PhysicalDrivePath(diskNumber),
+		TargetPath: normalizeWindowsPath(target),
+	}
+	if _, err := mounter.FsClient.CreateSymlink(ctx, linkRequest); err != nil {
+		log.Errorf("failed to link disk %s at %q, err: %v", diskNumber, linkRequest.TargetPath, err)
+		return err
+	}
+	return nil
+}
+
+// GetDiskSizeInBytes - returns the size in bytes of the disk with the given disk number.
+func (mounter *csiProxyMounter) GetDiskSizeInBytes(ctx context.Context, diskNumber string) (int64, error) {
+	log := logger.GetLogger(ctx)
+	diskNum, err := strconv.ParseUint(diskNumber, 10, 32)
+	if err != nil {
+		return -1, fmt.Errorf("parse %s failed with error: %v", diskNumber, err)
+	}
+	diskStatsResponse, err := mounter.DiskClient.GetDiskStats(ctx,
+		&disk.GetDiskStatsRequest{
+			DiskNumber: uint32(diskNum),
+		})
+	if err != nil {
+		log.Errorf("failed to get disk stats for disk number: %d, err: %v", diskNum, err)
+		return -1, err
+	}
+	return diskStatsResponse.TotalBytes, nil
+}
+
 // GetDeviceNameFromMount returns the volume ID for a mount path.
 func (mounter *csiProxyMounter) GetDeviceNameFromMount(ctx context.Context, mountPath string) (string, error) {
 	log := logger.GetLogger(ctx)
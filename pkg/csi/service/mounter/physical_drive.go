@@ -0,0 +1,41 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mounter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// physicalDrivePrefix is the prefix of the device path of raw disks on Windows.
+const physicalDrivePrefix = `\\.\PhysicalDrive`
+
+// PhysicalDrivePath returns the Windows device path of the raw disk with the
+// given disk number.
+func PhysicalDrivePath(diskNumber string) string {
+	return physicalDrivePrefix + diskNumber
+}
+
+// DiskNumberFromPhysicalDrivePath returns the disk number of the given Windows
+// raw disk device path, and false if the path is not a raw disk device path.
+func DiskNumberFromPhysicalDrivePath(path string) (string, bool) {
+	if len(path) <= len(physicalDrivePrefix) || !strings.EqualFold(path[:len(physicalDrivePrefix)], physicalDrivePrefix) {
+		return "", false
+	}
+	diskNumber := path[len(physicalDrivePrefix):]
+	if _, err := strconv.ParseUint(diskNumber, 10, 32); err != nil {
+		return "", false
+	}
+	return diskNumber, true
+}
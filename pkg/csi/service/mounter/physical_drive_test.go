@@ -0,0 +1,27 @@
+package mounter
+
+import (
+	"testing"
+)
+
+func TestDiskNumberFromPhysicalDrivePath(t *testing.T) {
+	tests := []struct {
+		path       string
+		diskNumber string
+		ok         bool
+	}{
+		{path: PhysicalDrivePath("3"), diskNumber: "3", ok: true},
+		{path: `\\.\physicaldrive12`, diskNumber: "12", ok: true},
+		{path: `\\.\PhysicalDrive`, ok: false},
+		{path: `\\.\PhysicalDrive1a`, ok: false},
+		{path: `\\?\Volume{6a1d0b2c-0000-0000-0000-100000000000}\`, ok: false},
+		{path: `c:\var\lib\kubelet\plugins`, ok: false},
+	}
+	for _, test := range tests {
+		diskNumber, ok := DiskNumberFromPhysicalDrivePath(test.path)
+		if diskNumber != test.diskNumber || ok != test.ok {
+			t.Errorf("DiskNumberFromPhysicalDrivePath(%q) = %q, %v, want %q, %v",
+				test.path, diskNumber, ok, test.diskNumber, test.ok)
+		}
+	}
+}
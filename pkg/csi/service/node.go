@@ -192,6 +192,11 @@ func (driver *vsphereCSIDriver) NodePublishVolume(
 
 		// check for Block vs Mount.
 		if _, ok := volCap.GetAccessType().(*csi.VolumeCapability_Block); ok {
+			// ReadOnlyMany block volumes are always published read-only,
+			// irrespective of the readonly flag in the request.
+			if common.IsVolumeReadOnly(volCap) {
+				params.Ro = true
+			}
 			// bind mount device to target.
 			return driver.osUtils.PublishBlockVol(ctx, req, dev, params)
 		}
@@ -242,6 +247,44 @@ func (driver *vsphereCSIDriver) NodeGetVolumeStats(
 			"received empty targetpath %q", targetPath)
 	}
 
+	isBlock, err := driver.osUtils.IsBlockDevice(ctx, targetPath)
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to determine if volume path %q is a raw block device: %v", targetPath, err)
+	}
+	if isBlock {
+		// Raw block volumes have no filesystem to report usage from, so only
+		// the size of the device is returned.
+		blockMetrics, err := driver.osUtils.GetBlockVolumeMetrics(ctx, targetPath)
+		if err != nil {
+			return nil, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+		}
+		capacity, ok := (*(blockMetrics.Capacity)).AsInt64()
+		if !ok {
+			return nil, logger.LogNewErrorCode(log, codes.Unknown, "failed to fetch capacity bytes")
+		}
+		return &csi.NodeGetVolumeStatsResponse{
+			Usage: []*csi.VolumeUsage{
+				{
+					Total: capacity,
+					Unit:  csi.VolumeUsage_BYTES,
+				},
+			},
+		}, nil
+	}
+
+	volCondition, err := driver.osUtils.GetVolumeCondition(ctx, targetPath)
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get condition of volume at path %q: %v", targetPath, err)
+	}
+	if volCondition != nil && volCondition.Abnormal {
+		// Usage of a file share whose access point is gone cannot be read
+		// without hanging on the mount, so only the condition is returned.
+		log.Warnf("NodeGetVolumeStats: volume at path %q is abnormal: %s", targetPath, volCondition.Message)
+		return &csi.NodeGetVolumeStatsResponse{VolumeCondition: volCondition}, nil
+	}
+
 	volMetrics, err := driver.osUtils.GetMetrics(ctx, targetPath)
 	if err != nil {
 		return nil, logger.LogNewErrorCode(log, codes.Internal, err.Error())
@@ -286,6 +329,7 @@ func (driver *vsphereCSIDriver) NodeGetVolumeStats(
 				Unit:      csi.VolumeUsage_INODES,
 			},
 		},
+		VolumeCondition: volCondition,
 	}, nil
 }
 
@@ -317,6 +361,13 @@ func (driver *vsphereCSIDriver) NodeGetCapabilities(
 					},
 				},
 			},
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{
+						Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+					},
+				},
+			},
 		},
 	}, nil
 }
@@ -428,6 +479,9 @@ func (driver *vsphereCSIDriver) NodeGetInfo(
 			NodeID:   nodeID,
 		}
 		accessibleTopology, err = topologyService.GetNodeTopologyLabels(ctx, &nodeInfo)
+		if err == nil && commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.NodeVolumeLimits) {
+			maxVolumesPerNode = getNodeMaxVolumes(ctx, &nodeInfo, maxVolumesPerNode, maxAllowedVolumesPerNode)
+		}
 	}
 
 	if err != nil {
@@ -447,6 +501,35 @@ func (driver *vsphereCSIDriver) NodeGetInfo(
 	return nodeInfoResponse, nil
 }
 
+// getNodeMaxVolumes returns the number of block volumes which can be attached
+// to the node VM as discovered from its disk controllers, capped to the given
+// maxVolumesPerNode if set. The given maxVolumesPerNode is returned if the
+// number is not known, and if it could not be retrieved, or else the given
+// maxAllowedVolumesPerNode.
+func getNodeMaxVolumes(ctx context.Context, nodeInfo *commoncotypes.NodeInfo,
+	maxVolumesPerNode int64, maxAllowedVolumesPerNode int64) int64 {
+	log := logger.GetLogger(ctx)
+	discoveredMaxVolumes, err := topologyService.GetNodeMaxVolumes(ctx, nodeInfo)
+	if err != nil {
+		if maxVolumesPerNode == 0 {
+			maxVolumesPerNode = maxAllowedVolumesPerNode
+		}
+		log.Warnf("NodeGetInfo: failed to get the number of block volumes which can be attached to node %q, "+
+			"using %d. Error: %v", nodeInfo.NodeName, maxVolumesPerNode, err)
+		return maxVolumesPerNode
+	}
+	if discoveredMaxVolumes == 0 {
+		log.Infof("NodeGetInfo: number of block volumes which can be attached to node %q is not known",
+			nodeInfo.NodeName)
+		return maxVolumesPerNode
+	}
+	log.Infof("NodeGetInfo: %d block volumes can be attached to node %q", discoveredMaxVolumes, nodeInfo.NodeName)
+	if maxVolumesPerNode > 0 && maxVolumesPerNode < discoveredMaxVolumes {
+		return maxVolumesPerNode
+	}
+	return discoveredMaxVolumes
+}
+
 // initVolumeTopologyService is a helper method to initialize
 // TopologyService in node.
 func initVolumeTopologyService(ctx context.Context) error {
@@ -49,7 +49,19 @@ const (
 )
 
 // defaultFileMountOptions are the mount flag options used by default while publishing a file volume.
-var defaultFileMountOptions = []string{"hard", "sec=sys", "vers=4", "minorversion=1"}
+// The NFS security flavor is added by getFileVolumeMountOptions.
+var defaultFileMountOptions = []string{"hard", "vers=4", "minorversion=1"}
+
+// nfsv3FileMountOptions are the mount flag options used instead of
+// defaultFileMountOptions while publishing a file volume created for NFSv3.
+var nfsv3FileMountOptions = []string{"hard", "vers=3"}
+
+// krb5KeytabPath is the Kerberos keytab rpc.gssd of the node reads the
+// credentials of the Kerberos enabled file volume mounts from. The keytab of
+// the node publish secret is installed there, so the node image must run
+// rpc.gssd with "-k" set to this path. It is the same path on the host and in
+// the node plugin container.
+var krb5KeytabPath = "/var/lib/kubelet/plugins/csi.vsphere.vmware.com/krb5.keytab"
 
 // NewOsUtils creates OsUtils with a linux specific mounter
 func NewOsUtils(ctx context.Context) (*OsUtils, error) {
@@ -100,6 +112,18 @@ func (osUtils *OsUtils) NodeStageBlockVolume(
 
 	// Check if this is a MountVolume or BlockVolume.
 	if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
+		if params.Ro {
+			// Raw block volumes are not mounted at the staging target, so the
+			// read-only access mode is enforced on the device itself.
+			log.Debugf("nodeStageBlockVolume: Setting device %q read-only for block volume ID %q",
+				dev.RealDev, params.VolID)
+			err = osUtils.setBlockDeviceReadOnly(ctx, dev.RealDev)
+			if err != nil {
+				return nil, logger.LogNewErrorCodef(log, codes.Internal,
+					"error setting block device read-only for volume: %q. Parameters: %v err: %v",
+					params.VolID, params, err)
+			}
+		}
 		// Volume is a block volume, so skip the rest of the steps.
 		log.Infof("nodeStageBlockVolume: Skipping staging for block volume ID %q", params.VolID)
 		return &csi.NodeStageVolumeResponse{}, nil
@@ -342,6 +366,23 @@ func (osUtils *OsUtils) IsBlockVolumePublished(ctx context.Context, volID string
 	return true, nil
 }
 
+// GetVolumeCondition returns the condition of the volume published at the
+// given path. Only NFS file volumes report a condition, derived from the
+// reachability of the access point they were mounted from; nil is returned
+// for other volumes.
+func (osUtils *OsUtils) GetVolumeCondition(ctx context.Context, path string) (*csi.VolumeCondition, error) {
+	mnts, err := gofsutil.GetMounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve existing mount points: %v", err)
+	}
+	for _, m := range mnts {
+		if unescape(ctx, m.Path) == path && strings.HasPrefix(m.Type, "nfs") {
+			return getNfsVolumeCondition(ctx, nfsDialer, m.Device), nil
+		}
+	}
+	return nil, nil
+}
+
 // GetMetrics helps get volume metrics using k8s fsInfo strategy.
 func (osUtils *OsUtils) GetMetrics(ctx context.Context, path string) (*k8svol.Metrics, error) {
 	if path == "" {
@@ -362,6 +403,22 @@ func (osUtils *OsUtils) GetMetrics(ctx context.Context, path string) (*k8svol.Me
 	return metrics, nil
 }
 
+// GetBlockVolumeMetrics returns the metrics of a raw block volume published
+// at the given path. Only the capacity is reported as usage of a raw block
+// device cannot be determined without a filesystem.
+func (osUtils *OsUtils) GetBlockVolumeMetrics(ctx context.Context, path string) (*k8svol.Metrics, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no path given")
+	}
+	capacity, err := osUtils.GetBlockSizeBytes(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	metrics := &k8svol.Metrics{Time: metav1.Now()}
+	metrics.Capacity = resource.NewQuantity(capacity, resource.BinarySI)
+	return metrics, nil
+}
+
 // GetBlockSizeBytes returns the Block size in bytes
 func (osUtils *OsUtils) GetBlockSizeBytes(ctx context.Context, devicePath string) (int64, error) {
 	cmdArgs := []string{"--getsize64", devicePath}
@@ -479,13 +536,9 @@ func (osUtils *OsUtils) PublishBlockVol(
 	}
 	log.Debugf("publishBlockVol: Target %q created", params.Target)
 
-	// Read-only is not supported for BlockVolume. Doing a read-only
-	// bind mount of the device to the target path does not prevent
-	// the underlying block device from being modified, so don't
-	// advertise a false sense of security.
-	if params.Ro {
-		return nil, logger.LogNewErrorCode(log, codes.InvalidArgument,
-			"read only not supported for Block Volume")
+	mntFlags, err := getBlockVolumePublishMountFlags(req.GetVolumeCapability(), params.Ro)
+	if err != nil {
+		return nil, logger.LogNewErrorCode(log, codes.InvalidArgument, err.Error())
 	}
 
 	// Get block device mounts.
@@ -499,7 +552,6 @@ func (osUtils *OsUtils) PublishBlockVol(
 	// Check if device is already mounted.
 	if len(devMnts) == 0 {
 		// Do the bind mount.
-		mntFlags := make([]string, 0)
 		log.Debugf("PublishBlockVolume: Attempting to bind mount %q to %q with mount flags %v",
 			dev.FullPath, params.Target, mntFlags)
 		if err := gofsutil.BindMount(ctx, dev.FullPath, params.Target, mntFlags...); err != nil {
@@ -523,6 +575,35 @@ func (osUtils *OsUtils) PublishBlockVol(
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+// getBlockVolumePublishMountFlags returns the bind mount flags used to publish
+// a raw block volume.
+func getBlockVolumePublishMountFlags(volCap *csi.VolumeCapability, ro bool) ([]string, error) {
+	mntFlags := make([]string, 0)
+	if !ro {
+		return mntFlags, nil
+	}
+	// Doing a read-only bind mount of the device to the target path does not
+	// prevent the underlying block device from being modified, so read-only
+	// is only supported for ReadOnlyMany volumes. Such volumes are attached to
+	// the node VM in non-persistent mode and the device is set read-only
+	// during NodeStageVolume.
+	if !common.IsVolumeReadOnly(volCap) {
+		return nil, errors.New("read only not supported for Block Volume")
+	}
+	return append(mntFlags, "ro"), nil
+}
+
+// setBlockDeviceReadOnly marks the given block device read-only in the kernel.
+func (osUtils *OsUtils) setBlockDeviceReadOnly(ctx context.Context, devicePath string) error {
+	cmd := osUtils.Mounter.Exec.Command("blockdev", "--setro", devicePath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error when setting block device %s read-only: output: %s, err: %v",
+			devicePath, string(output), err)
+	}
+	return nil
+}
+
 // PublishBlockVol mounts file volume to publish target
 func (osUtils *OsUtils) PublishFileVol(
 	ctx context.Context,
@@ -532,6 +613,11 @@ func (osUtils *OsUtils) PublishFileVol(
 	log := logger.GetLogger(ctx)
 	log.Infof("PublishFileVolume called with args: %+v", params)
 
+	if req.GetVolumeContext()[common.AttributeFileProtocol] == common.FileProtocolSMB {
+		return nil, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+			"SMB file volume %q can only be published on Windows nodes", params.VolID)
+	}
+
 	// Extract mount details.
 	fsType, mntFlags, err := osUtils.EnsureMountVol(ctx, req.GetVolumeCapability())
 	if err != nil {
@@ -574,17 +660,24 @@ func (osUtils *OsUtils) PublishFileVol(
 		}
 	}
 
-	// Check for read-only flag on Pod pvc spec.
-	if params.Ro {
-		mntFlags = append(mntFlags, "ro")
+	mntFlags, err = prepareFileVolumeMount(ctx, req, params, mntFlags)
+	if err != nil {
+		return nil, err
 	}
-	// Add defaultFileMountOptions to the mntFlags.
-	mntFlags = append(mntFlags, defaultFileMountOptions...)
-	// Retrieve the file share access point from publish context.
-	mntSrc, ok := req.GetPublishContext()[common.Nfsv4AccessPoint]
-	if !ok {
-		return nil, logger.LogNewErrorCode(log, codes.Internal,
-			"nfs v4 accesspoint not set in publish context")
+	// Retrieve the file share access points from publish context and pick
+	// a reachable one.
+	accessPointKey := common.GetFileVolumeAccessPointKey(req.GetVolumeContext())
+	if accessPointKey == common.Nfsv3AccessPointKey {
+		// The nfs4 filesystem type cannot mount NFSv3 exports.
+		fsType = common.NfsFsType
+	}
+	accessPoints, err := getFileShareAccessPoints(req.GetPublishContext(), accessPointKey)
+	if err != nil {
+		return nil, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+	}
+	mntSrc, err := selectNfsAccessPoint(ctx, nfsDialer, accessPoints)
+	if err != nil {
+		return nil, err
 	}
 	// Directly mount the file share volume to the pod. No bind mount required.
 	log.Debugf("PublishFileVolume: Attempting to mount %q to %q with fstype %q and mountflags %v",
@@ -597,6 +690,91 @@ func (osUtils *OsUtils) PublishFileVol(
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+// prepareFileVolumeMount returns the given mount flags with the read-only flag
+// and the file mount options of the requested NFS version and security flavor
+// added. For Kerberos flavors the keytab of the node publish secret, if any,
+// is installed at krb5KeytabPath, where rpc.gssd reads it from. mount.nfs has
+// no option to pass a keytab.
+func prepareFileVolumeMount(ctx context.Context, req *csi.NodePublishVolumeRequest,
+	params NodePublishParams, mntFlags []string) ([]string, error) {
+	log := logger.GetLogger(ctx)
+	// Check for read-only flag on Pod pvc spec.
+	if params.Ro {
+		mntFlags = append(mntFlags, "ro")
+	}
+	nfsVersion := req.GetVolumeContext()[common.AttributeNfsVersion]
+	nfsSecurityType := req.GetVolumeContext()[common.AttributeNfsSecurityType]
+	fileMountOptions, err := getFileVolumeMountOptions(nfsVersion, nfsSecurityType)
+	if err != nil {
+		return nil, logger.LogNewErrorCode(log, codes.InvalidArgument, err.Error())
+	}
+	mntFlags = append(mntFlags, fileMountOptions...)
+	if !common.IsKerberosNfsSecurityType(nfsSecurityType) {
+		return mntFlags, nil
+	}
+	keytab, ok := req.GetSecrets()[common.NfsKerberosKeytabSecretKey]
+	if !ok {
+		log.Infof("PublishFileVolume: no Kerberos keytab in node publish secrets for volume %q. "+
+			"Using the Kerberos credentials of the node", params.VolID)
+		return mntFlags, nil
+	}
+	if err := writeKrb5Keytab(ctx, krb5KeytabPath, []byte(keytab)); err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to write Kerberos keytab for volume %q: %v", params.VolID, err)
+	}
+	return mntFlags, nil
+}
+
+// getFileVolumeMountOptions returns the mount options used to publish a file
+// volume with the given NFS protocol version and security flavor. NFSv4.1 and
+// AUTH_SYS are used when they are not requested.
+func getFileVolumeMountOptions(nfsVersion string, nfsSecurityType string) ([]string, error) {
+	if nfsSecurityType == "" {
+		nfsSecurityType = common.NfsSecurityTypeSys
+	}
+	if !common.IsValidNfsSecurityType(nfsSecurityType) {
+		return nil, fmt.Errorf("invalid NFS security type %q", nfsSecurityType)
+	}
+	versionMountOptions := defaultFileMountOptions
+	switch nfsVersion {
+	case "", common.NfsVersion41:
+	case common.NfsVersion3:
+		versionMountOptions = nfsv3FileMountOptions
+	default:
+		return nil, fmt.Errorf("invalid NFS version %q", nfsVersion)
+	}
+	mntFlags := make([]string, 0, len(versionMountOptions)+1)
+	mntFlags = append(mntFlags, versionMountOptions...)
+	return append(mntFlags, "sec="+nfsSecurityType), nil
+}
+
+// writeKrb5Keytab writes the given Kerberos keytab at the given path, readable
+// only by root. The keytab is renamed into place so that rpc.gssd never reads
+// a partially written keytab. It is kept after the volume is unpublished, as
+// rpc.gssd reads it again to renew the credentials of the other mounts.
+func writeKrb5Keytab(ctx context.Context, path string, keytab []byte) error {
+	log := logger.GetLogger(ctx)
+	if len(keytab) == 0 {
+		return errors.New("keytab is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, keytab, 0600); err != nil {
+		return err
+	}
+	// os.WriteFile does not change the mode of an existing file.
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	log.Infof("Wrote Kerberos keytab at %q", path)
+	return nil
+}
+
 // GetDevice returns a Device struct with info about the given device, or
 // an error if it doesn't exist or is not a block device.
 func (osUtils *OsUtils) GetDevice(ctx context.Context, path string) (*Device, error) {
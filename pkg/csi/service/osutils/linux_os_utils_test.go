@@ -5,8 +5,22 @@ package osutils
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+	"k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 )
 
 func TestUnescape(t *testing.T) {
@@ -52,3 +66,300 @@ func TestUnescape(t *testing.T) {
 		})
 	}
 }
+
+func blockVolumeCapability(mode csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability {
+	return &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{
+			Block: &csi.VolumeCapability_BlockVolume{},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: mode,
+		},
+	}
+}
+
+func TestGetBlockVolumePublishMountFlags(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          csi.VolumeCapability_AccessMode_Mode
+		ro            bool
+		expectedFlags []string
+		expectErr     bool
+	}{
+		{
+			name:          "ReadWriteOnce",
+			mode:          csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			expectedFlags: []string{},
+		},
+		{
+			name:      "ReadWriteOnceWithReadonly",
+			mode:      csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			ro:        true,
+			expectErr: true,
+		},
+		{
+			name:          "ReadWriteMany",
+			mode:          csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			expectedFlags: []string{},
+		},
+		{
+			name:          "ReadOnlyMany",
+			mode:          csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+			ro:            true,
+			expectedFlags: []string{"ro"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			flags, err := getBlockVolumePublishMountFlags(blockVolumeCapability(test.mode), test.ro)
+			if test.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got mount flags %v", flags)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(flags, test.expectedFlags) {
+				t.Errorf("expected mount flags %v, got %v", test.expectedFlags, flags)
+			}
+		})
+	}
+}
+
+func TestPublishBlockVolRejectsReadonlyForReadWriteOnce(t *testing.T) {
+	ctx := context.Background()
+	osUtils := &OsUtils{}
+	req := &csi.NodePublishVolumeRequest{
+		VolumeId:         "volume-id",
+		VolumeCapability: blockVolumeCapability(csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER),
+		Readonly:         true,
+	}
+	params := NodePublishParams{
+		VolID:  req.VolumeId,
+		Target: filepath.Join(t.TempDir(), "target"),
+		Ro:     true,
+	}
+	_, err := osUtils.PublishBlockVol(ctx, req, &Device{}, params)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument error, got %v", err)
+	}
+}
+
+func TestSetBlockDeviceReadOnly(t *testing.T) {
+	ctx := context.Background()
+	var cmdArgs [][]string
+	fakeExec := &testingexec.FakeExec{}
+	fakeCmd := func(output string, err error) testingexec.FakeCommandAction {
+		return func(cmd string, args ...string) exec.Cmd {
+			cmdArgs = append(cmdArgs, append([]string{cmd}, args...))
+			return &testingexec.FakeCmd{
+				CombinedOutputScript: []testingexec.FakeAction{
+					func() ([]byte, []byte, error) { return []byte(output), nil, err },
+				},
+			}
+		}
+	}
+	fakeExec.CommandScript = []testingexec.FakeCommandAction{
+		fakeCmd("", nil),
+		fakeCmd("blockdev: cannot open /dev/sdb", errors.New("exit status 1")),
+	}
+	osUtils := &OsUtils{
+		Mounter: &mount.SafeFormatAndMount{Exec: fakeExec},
+	}
+
+	if err := osUtils.setBlockDeviceReadOnly(ctx, "/dev/sdb"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expectedArgs := []string{"blockdev", "--setro", "/dev/sdb"}
+	if len(cmdArgs) != 1 || !reflect.DeepEqual(cmdArgs[0], expectedArgs) {
+		t.Errorf("expected command %v, got %v", expectedArgs, cmdArgs)
+	}
+	if err := osUtils.setBlockDeviceReadOnly(ctx, "/dev/sdb"); err == nil {
+		t.Errorf("expected error when blockdev fails")
+	}
+}
+
+func TestGetBlockVolumeMetrics(t *testing.T) {
+	ctx := context.Background()
+	fakeExec := &testingexec.FakeExec{
+		CommandScript: []testingexec.FakeCommandAction{
+			func(cmd string, args ...string) exec.Cmd {
+				expectedArgs := []string{"--getsize64", "/var/lib/kubelet/block/target"}
+				if cmd != "blockdev" || !reflect.DeepEqual(args, expectedArgs) {
+					t.Errorf("unexpected command %s %v", cmd, args)
+				}
+				return &testingexec.FakeCmd{
+					CombinedOutputScript: []testingexec.FakeAction{
+						func() ([]byte, []byte, error) { return []byte("10737418240\n"), nil, nil },
+					},
+				}
+			},
+		},
+	}
+	osUtils := &OsUtils{
+		Mounter: &mount.SafeFormatAndMount{Exec: fakeExec},
+	}
+	metrics, err := osUtils.GetBlockVolumeMetrics(ctx, "/var/lib/kubelet/block/target")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	capacity, ok := metrics.Capacity.AsInt64()
+	if !ok || capacity != 10737418240 {
+		t.Errorf("expected capacity 10737418240, got %v", metrics.Capacity)
+	}
+	if metrics.Available != nil || metrics.Used != nil {
+		t.Errorf("expected available and used to be unset, got %v and %v", metrics.Available, metrics.Used)
+	}
+}
+
+func TestGetFileVolumeMountOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		nfsVersion      string
+		nfsSecurityType string
+		expected        []string
+		expectErr       bool
+	}{
+		{
+			name:     "Default security type",
+			expected: []string{"hard", "vers=4", "minorversion=1", "sec=sys"},
+		},
+		{
+			name:            "AUTH_SYS",
+			nfsSecurityType: "sys",
+			expected:        []string{"hard", "vers=4", "minorversion=1", "sec=sys"},
+		},
+		{
+			name:            "Kerberos",
+			nfsSecurityType: "krb5",
+			expected:        []string{"hard", "vers=4", "minorversion=1", "sec=krb5"},
+		},
+		{
+			name:            "Kerberos with integrity",
+			nfsSecurityType: "krb5i",
+			expected:        []string{"hard", "vers=4", "minorversion=1", "sec=krb5i"},
+		},
+		{
+			name:            "Kerberos with privacy",
+			nfsSecurityType: "krb5p",
+			expected:        []string{"hard", "vers=4", "minorversion=1", "sec=krb5p"},
+		},
+		{
+			name:            "Invalid security type",
+			nfsSecurityType: "lkey",
+			expectErr:       true,
+		},
+		{
+			name:       "NFSv4.1",
+			nfsVersion: "4.1",
+			expected:   []string{"hard", "vers=4", "minorversion=1", "sec=sys"},
+		},
+		{
+			name:       "NFSv3",
+			nfsVersion: "3",
+			expected:   []string{"hard", "vers=3", "sec=sys"},
+		},
+		{
+			name:       "Invalid version",
+			nfsVersion: "2",
+			expectErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mntFlags, err := getFileVolumeMountOptions(test.nfsVersion, test.nfsSecurityType)
+			if test.expectErr {
+				if err == nil {
+					t.Fatalf("expected error for security type %q, got mount flags %v", test.nfsSecurityType, mntFlags)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(mntFlags, test.expected) {
+				t.Errorf("expected mount flags %v, got %v", test.expected, mntFlags)
+			}
+		})
+	}
+	// The default options must not be modified by assembling the mount options.
+	if !reflect.DeepEqual(defaultFileMountOptions, []string{"hard", "vers=4", "minorversion=1"}) {
+		t.Errorf("defaultFileMountOptions modified: %v", defaultFileMountOptions)
+	}
+	if !reflect.DeepEqual(nfsv3FileMountOptions, []string{"hard", "vers=3"}) {
+		t.Errorf("nfsv3FileMountOptions modified: %v", nfsv3FileMountOptions)
+	}
+}
+
+func TestPrepareFileVolumeMount(t *testing.T) {
+	ctx := context.TODO()
+	origKrb5KeytabPath := krb5KeytabPath
+	defer func() { krb5KeytabPath = origKrb5KeytabPath }()
+	krb5KeytabPath = filepath.Join(t.TempDir(), "krb5", "krb5.keytab")
+	keytab := []byte{0x05, 0x02, 0x00, 0x01}
+	newRequest := func(secType string, secrets map[string]string) *csi.NodePublishVolumeRequest {
+		return &csi.NodePublishVolumeRequest{
+			VolumeContext: map[string]string{common.AttributeNfsSecurityType: secType},
+			Secrets:       secrets,
+		}
+	}
+
+	// No keytab is installed for AUTH_SYS.
+	mntFlags, err := prepareFileVolumeMount(ctx, newRequest("sys",
+		map[string]string{common.NfsKerberosKeytabSecretKey: string(keytab)}), NodePublishParams{Ro: true}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"ro", "hard", "vers=4", "minorversion=1", "sec=sys"}; !reflect.DeepEqual(mntFlags, expected) {
+		t.Errorf("expected mount flags %v, got %v", expected, mntFlags)
+	}
+	if _, err := os.Stat(krb5KeytabPath); !os.IsNotExist(err) {
+		t.Errorf("expected no keytab at %q, got %v", krb5KeytabPath, err)
+	}
+
+	// The keytab is installed for rpc.gssd, and not passed to mount.nfs.
+	for i := 0; i < 2; i++ {
+		mntFlags, err = prepareFileVolumeMount(ctx, newRequest("krb5p",
+			map[string]string{common.NfsKerberosKeytabSecretKey: string(keytab)}), NodePublishParams{}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if expected := []string{"hard", "vers=4", "minorversion=1", "sec=krb5p"}; !reflect.DeepEqual(mntFlags, expected) {
+		t.Errorf("expected mount flags %v, got %v", expected, mntFlags)
+	}
+	for _, flag := range mntFlags {
+		if strings.HasPrefix(flag, "keytab=") {
+			t.Errorf("expected no keytab mount option, got %v", mntFlags)
+		}
+	}
+	written, err := os.ReadFile(krb5KeytabPath)
+	if err != nil {
+		t.Fatalf("failed to read keytab: %v", err)
+	}
+	if !reflect.DeepEqual(written, keytab) {
+		t.Errorf("expected keytab %v at %q, got %v", keytab, krb5KeytabPath, written)
+	}
+	fi, err := os.Stat(krb5KeytabPath)
+	if err != nil {
+		t.Fatalf("failed to stat keytab: %v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected keytab mode 0600, got %v", fi.Mode().Perm())
+	}
+
+	// An empty keytab is rejected.
+	_, err = prepareFileVolumeMount(ctx, newRequest("krb5",
+		map[string]string{common.NfsKerberosKeytabSecretKey: ""}), NodePublishParams{}, nil)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected Internal error for empty keytab, got %v", err)
+	}
+	// An invalid security type is rejected.
+	_, err = prepareFileVolumeMount(ctx, newRequest("lkey", nil), NodePublishParams{}, nil)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument error for invalid security type, got %v", err)
+	}
+}
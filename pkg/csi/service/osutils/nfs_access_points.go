@@ -0,0 +1,156 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// nfsPort is the port on which the vSAN file service serves NFS.
+	nfsPort = "2049"
+	// nfsDialTimeout is the timeout used to probe the reachability of an NFS
+	// access point.
+	nfsDialTimeout = 5 * time.Second
+)
+
+// Dialer opens network connections. It is used to probe NFS access points
+// and is replaced in unit tests.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// nfsDialer is the Dialer used to probe NFS access points.
+var nfsDialer Dialer = &net.Dialer{Timeout: nfsDialTimeout}
+
+// getFileShareAccessPoints returns the access points of the given protocol
+// from the publish context, in order of preference. Publish contexts from
+// older controllers only carry the NFSv4.1 access point.
+func getFileShareAccessPoints(pubCtx map[string]string, protocol string) ([]string, error) {
+	var accessPoints []string
+	if encoded, ok := pubCtx[common.NfsAccessPoints]; ok {
+		var allAccessPoints map[string][]string
+		if err := json.Unmarshal([]byte(encoded), &allAccessPoints); err != nil {
+			return nil, fmt.Errorf("failed to parse %s %q from publish context: %v",
+				common.NfsAccessPoints, encoded, err)
+		}
+		accessPoints = append(accessPoints, allAccessPoints[protocol]...)
+	}
+	if protocol == common.Nfsv4AccessPointKey {
+		// The access point picked by the controller comes first.
+		if preferred, ok := pubCtx[common.Nfsv4AccessPoint]; ok {
+			accessPoints = append([]string{preferred}, accessPoints...)
+		}
+	}
+	var uniqueAccessPoints []string
+	for _, accessPoint := range accessPoints {
+		if accessPoint != "" && !common.Contains(uniqueAccessPoints, accessPoint) {
+			uniqueAccessPoints = append(uniqueAccessPoints, accessPoint)
+		}
+	}
+	if len(uniqueAccessPoints) == 0 {
+		return nil, fmt.Errorf("no %s access point set in publish context", protocol)
+	}
+	return uniqueAccessPoints, nil
+}
+
+// splitNfsAccessPoint splits an access point of the form "host:/path" into
+// its host and export path. IPv6 hosts may or may not be enclosed in
+// brackets; the returned host never is.
+func splitNfsAccessPoint(accessPoint string) (string, string, error) {
+	idx := strings.Index(accessPoint, ":/")
+	if idx <= 0 {
+		return "", "", fmt.Errorf("invalid NFS access point %q", accessPoint)
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(accessPoint[:idx], "["), "]")
+	return host, accessPoint[idx+1:], nil
+}
+
+// normalizeNfsAccessPoint returns the access point in the form accepted by
+// mount, with IPv6 hosts enclosed in brackets.
+func normalizeNfsAccessPoint(accessPoint string) (string, error) {
+	host, path, err := splitNfsAccessPoint(accessPoint)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return host + ":" + path, nil
+}
+
+// isNfsAccessPointReachable reports whether the NFS service behind the given
+// access point accepts connections.
+func isNfsAccessPointReachable(ctx context.Context, dialer Dialer, accessPoint string) error {
+	host, _, err := splitNfsAccessPoint(accessPoint)
+	if err != nil {
+		return err
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, nfsPort))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// selectNfsAccessPoint returns the first reachable access point from the
+// given list. A single access point is returned without probing it, so that
+// mount reports the actual failure.
+func selectNfsAccessPoint(ctx context.Context, dialer Dialer, accessPoints []string) (string, error) {
+	log := logger.GetLogger(ctx)
+	if len(accessPoints) == 0 {
+		return "", logger.LogNewErrorCode(log, codes.Internal, "no NFS access point to select from")
+	}
+	if len(accessPoints) == 1 {
+		return normalizeNfsAccessPoint(accessPoints[0])
+	}
+	var probeErrs []string
+	for _, accessPoint := range accessPoints {
+		err := isNfsAccessPointReachable(ctx, dialer, accessPoint)
+		if err == nil {
+			log.Infof("Selected reachable NFS access point %q", accessPoint)
+			return normalizeNfsAccessPoint(accessPoint)
+		}
+		log.Warnf("NFS access point %q is not reachable. Err: %v", accessPoint, err)
+		probeErrs = append(probeErrs, fmt.Sprintf("%s: %v", accessPoint, err))
+	}
+	return "", logger.LogNewErrorCodef(log, codes.Unavailable,
+		"none of the NFS access points are reachable: %s", strings.Join(probeErrs, "; "))
+}
+
+// getNfsVolumeCondition returns the condition of an NFS mount from the
+// reachability of the access point it was mounted from.
+func getNfsVolumeCondition(ctx context.Context, dialer Dialer, mntSrc string) *csi.VolumeCondition {
+	if err := isNfsAccessPointReachable(ctx, dialer, mntSrc); err != nil {
+		return &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  fmt.Sprintf("NFS access point %q is not reachable: %v", mntSrc, err),
+		}
+	}
+	return &csi.VolumeCondition{
+		Abnormal: false,
+		Message:  fmt.Sprintf("NFS access point %q is reachable", mntSrc),
+	}
+}
@@ -0,0 +1,187 @@
+package osutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+// fakeDialer is a Dialer that only connects to the reachable addresses and
+// records every address it was asked to dial.
+type fakeDialer struct {
+	reachable map[string]bool
+	dialed    []string
+}
+
+func (d *fakeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dialed = append(d.dialed, address)
+	if !d.reachable[address] {
+		return nil, errors.New("connection refused")
+	}
+	client, server := net.Pipe()
+	_ = server.Close()
+	return client, nil
+}
+
+func TestGetFileShareAccessPoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		pubCtx   map[string]string
+		protocol string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "LegacyPublishContext",
+			pubCtx:   map[string]string{common.Nfsv4AccessPoint: "10.0.0.1:/vsanfs/share"},
+			protocol: common.Nfsv4AccessPointKey,
+			expected: []string{"10.0.0.1:/vsanfs/share"},
+		},
+		{
+			name: "PreferredAccessPointFirst",
+			pubCtx: map[string]string{
+				common.Nfsv4AccessPoint: "10.0.0.2:/vsanfs/share",
+				common.NfsAccessPoints: `{"NFSv3":["10.0.0.3:/vsanfs/share"],` +
+					`"NFSv4.1":["10.0.0.1:/vsanfs/share","10.0.0.2:/vsanfs/share","fd01::1:/vsanfs/share"]}`,
+			},
+			protocol: common.Nfsv4AccessPointKey,
+			expected: []string{"10.0.0.2:/vsanfs/share", "10.0.0.1:/vsanfs/share", "fd01::1:/vsanfs/share"},
+		},
+		{
+			name: "OtherProtocol",
+			pubCtx: map[string]string{
+				common.Nfsv4AccessPoint: "10.0.0.1:/vsanfs/share",
+				common.NfsAccessPoints:  `{"NFSv3":["10.0.0.3:/vsanfs/share"],"NFSv4.1":["10.0.0.1:/vsanfs/share"]}`,
+			},
+			protocol: "NFSv3",
+			expected: []string{"10.0.0.3:/vsanfs/share"},
+		},
+		{
+			name:     "Missing",
+			pubCtx:   map[string]string{},
+			protocol: common.Nfsv4AccessPointKey,
+			wantErr:  true,
+		},
+		{
+			name:     "Malformed",
+			pubCtx:   map[string]string{common.NfsAccessPoints: "not-json"},
+			protocol: common.Nfsv4AccessPointKey,
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			accessPoints, err := getFileShareAccessPoints(test.pubCtx, test.protocol)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("getFileShareAccessPoints() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(accessPoints, test.expected) {
+				t.Errorf("getFileShareAccessPoints() = %v, want %v", accessPoints, test.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeNfsAccessPoint(t *testing.T) {
+	tests := []struct {
+		in, out string
+		wantErr bool
+	}{
+		{in: "10.0.0.1:/vsanfs/share", out: "10.0.0.1:/vsanfs/share"},
+		{in: "fs.example.com:/vsanfs/share", out: "fs.example.com:/vsanfs/share"},
+		{in: "fd01::1:/vsanfs/share", out: "[fd01::1]:/vsanfs/share"},
+		{in: "[fd01::1]:/vsanfs/share", out: "[fd01::1]:/vsanfs/share"},
+		{in: "/vsanfs/share", wantErr: true},
+		{in: "10.0.0.1", wantErr: true},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			out, err := normalizeNfsAccessPoint(test.in)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("normalizeNfsAccessPoint(%q) error = %v, wantErr %v", test.in, err, test.wantErr)
+			}
+			if out != test.out {
+				t.Errorf("normalizeNfsAccessPoint(%q) = %q, want %q", test.in, out, test.out)
+			}
+		})
+	}
+}
+
+func TestSelectNfsAccessPoint(t *testing.T) {
+	accessPoints := []string{"10.0.0.1:/vsanfs/share", "fd01::1:/vsanfs/share", "10.0.0.2:/vsanfs/share"}
+	tests := []struct {
+		name         string
+		accessPoints []string
+		reachable    map[string]bool
+		expected     string
+		expectedCode codes.Code
+		expectedDial []string
+	}{
+		{
+			name:         "FirstReachable",
+			accessPoints: accessPoints,
+			reachable:    map[string]bool{"10.0.0.1:2049": true, "[fd01::1]:2049": true},
+			expected:     "10.0.0.1:/vsanfs/share",
+			expectedDial: []string{"10.0.0.1:2049"},
+		},
+		{
+			name:         "FailoverToIPv6",
+			accessPoints: accessPoints,
+			reachable:    map[string]bool{"[fd01::1]:2049": true},
+			expected:     "[fd01::1]:/vsanfs/share",
+			expectedDial: []string{"10.0.0.1:2049", "[fd01::1]:2049"},
+		},
+		{
+			name:         "NoneReachable",
+			accessPoints: accessPoints,
+			reachable:    map[string]bool{},
+			expectedCode: codes.Unavailable,
+			expectedDial: []string{"10.0.0.1:2049", "[fd01::1]:2049", "10.0.0.2:2049"},
+		},
+		{
+			name:         "SingleAccessPointNotProbed",
+			accessPoints: []string{"10.0.0.1:/vsanfs/share"},
+			reachable:    map[string]bool{},
+			expected:     "10.0.0.1:/vsanfs/share",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dialer := &fakeDialer{reachable: test.reachable}
+			selected, err := selectNfsAccessPoint(context.Background(), dialer, test.accessPoints)
+			if test.expectedCode != codes.OK {
+				if status.Code(err) != test.expectedCode {
+					t.Fatalf("selectNfsAccessPoint() error = %v, want code %v", err, test.expectedCode)
+				}
+			} else if err != nil {
+				t.Fatalf("selectNfsAccessPoint() unexpected error: %v", err)
+			}
+			if selected != test.expected {
+				t.Errorf("selectNfsAccessPoint() = %q, want %q", selected, test.expected)
+			}
+			if !reflect.DeepEqual(dialer.dialed, test.expectedDial) {
+				t.Errorf("selectNfsAccessPoint() dialed %v, want %v", dialer.dialed, test.expectedDial)
+			}
+		})
+	}
+}
+
+func TestGetNfsVolumeCondition(t *testing.T) {
+	dialer := &fakeDialer{reachable: map[string]bool{"10.0.0.1:2049": true}}
+	condition := getNfsVolumeCondition(context.Background(), dialer, "10.0.0.1:/vsanfs/share")
+	if condition.Abnormal {
+		t.Errorf("expected reachable access point to be normal, got %+v", condition)
+	}
+	condition = getNfsVolumeCondition(context.Background(), dialer, "[fd01::1]:/vsanfs/share")
+	if !condition.Abnormal {
+		t.Errorf("expected unreachable access point to be abnormal, got %+v", condition)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osutils
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+// getSmbCredentials returns the user name and password used to mount SMB file
+// volumes from the given node publish secrets. The user name is qualified
+// with the domain when the secrets carry one.
+func getSmbCredentials(secrets map[string]string) (string, string, error) {
+	username := secrets[common.SmbUsernameSecretKey]
+	password := secrets[common.SmbPasswordSecretKey]
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("keys %q and %q must be set in the node publish secret of SMB file volumes",
+			common.SmbUsernameSecretKey, common.SmbPasswordSecretKey)
+	}
+	if domain := secrets[common.SmbDomainSecretKey]; domain != "" && !strings.Contains(username, `\`) {
+		username = domain + `\` + username
+	}
+	return username, password, nil
+}
+
+// normalizeSmbAccessPoint returns the given SMB access point as a UNC path of
+// the form \\server\share.
+func normalizeSmbAccessPoint(accessPoint string) (string, error) {
+	remotePath := strings.Trim(strings.ReplaceAll(accessPoint, "/", `\`), `\`)
+	if !strings.Contains(remotePath, `\`) {
+		return "", fmt.Errorf("invalid SMB access point %q", accessPoint)
+	}
+	return `\\` + remotePath, nil
+}
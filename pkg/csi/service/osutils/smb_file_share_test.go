@@ -0,0 +1,93 @@
+package osutils
+
+import (
+	"strconv"
+	"testing"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestGetSmbCredentials(t *testing.T) {
+	tests := []struct {
+		name             string
+		secrets          map[string]string
+		expectedUsername string
+		wantErr          bool
+	}{
+		{
+			name: "UserAndPassword",
+			secrets: map[string]string{
+				common.SmbUsernameSecretKey: "svc-k8s",
+				common.SmbPasswordSecretKey: "secret",
+			},
+			expectedUsername: "svc-k8s",
+		},
+		{
+			name: "WithDomain",
+			secrets: map[string]string{
+				common.SmbUsernameSecretKey: "svc-k8s",
+				common.SmbPasswordSecretKey: "secret",
+				common.SmbDomainSecretKey:   "CORP",
+			},
+			expectedUsername: `CORP\svc-k8s`,
+		},
+		{
+			name: "DomainAlreadyInUsername",
+			secrets: map[string]string{
+				common.SmbUsernameSecretKey: `CORP\svc-k8s`,
+				common.SmbPasswordSecretKey: "secret",
+				common.SmbDomainSecretKey:   "OTHER",
+			},
+			expectedUsername: `CORP\svc-k8s`,
+		},
+		{
+			name:    "MissingPassword",
+			secrets: map[string]string{common.SmbUsernameSecretKey: "svc-k8s"},
+			wantErr: true,
+		},
+		{
+			name:    "NoSecrets",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			username, password, err := getSmbCredentials(test.secrets)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("getSmbCredentials() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if username != test.expectedUsername || password != test.secrets[common.SmbPasswordSecretKey] {
+				t.Errorf("getSmbCredentials() = %q, %q, want %q, %q", username, password,
+					test.expectedUsername, test.secrets[common.SmbPasswordSecretKey])
+			}
+		})
+	}
+}
+
+func TestNormalizeSmbAccessPoint(t *testing.T) {
+	tests := []struct {
+		in, out string
+		wantErr bool
+	}{
+		{in: `\\fs.example.com\share`, out: `\\fs.example.com\share`},
+		{in: `//fs.example.com/share/`, out: `\\fs.example.com\share`},
+		{in: `10.0.0.1\share`, out: `\\10.0.0.1\share`},
+		{in: `\\fs.example.com`, wantErr: true},
+		{in: `\\fs.example.com\`, wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			out, err := normalizeSmbAccessPoint(test.in)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("normalizeSmbAccessPoint(%q) error = %v, wantErr %v", test.in, err, test.wantErr)
+			}
+			if out != test.out {
+				t.Errorf("normalizeSmbAccessPoint(%q) = %q, want %q", test.in, out, test.out)
+			}
+		})
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8svol "k8s.io/kubernetes/pkg/volume"
+	"k8s.io/utils/keymutex"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
@@ -41,6 +42,24 @@ const (
 	UUIDPrefix = "VMware-"
 )
 
+// readLink returns the destination of a symbolic link. It is replaced in
+// unit tests.
+var readLink = os.Readlink
+
+// globPaths returns the paths matching a pattern. It is replaced in unit tests.
+var globPaths = filepath.Glob
+
+// smbShareLocks serializes the publishes and unpublishes of an SMB share on the
+// node, so that its global mapping is not removed while it is being linked at
+// a new publish target.
+var smbShareLocks = keymutex.NewHashed(0)
+
+// smbShareLockKey returns the key of the lock of the SMB share at the given
+// UNC path, which is case insensitive.
+func smbShareLockKey(smbShare string) string {
+	return strings.ToLower(smbShare)
+}
+
 // NewOsUtils creates OsUtils with a linux specific mounter
 func NewOsUtils(ctx context.Context) (*OsUtils, error) {
 	log := logger.GetLogger(ctx)
@@ -82,9 +101,22 @@ func (osUtils *OsUtils) NodeStageBlockVolume(
 
 	// Check if this is a MountVolume or BlockVolume.
 	if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
-		// Volume is a raw block volume.
-		return nil, logger.LogNewErrorCodef(log, codes.Internal,
-			"Stage for raw block Volume access type is currently not supported for windows node")
+		// Volume is a raw block volume. The disk is only brought online, it is
+		// linked at the publish target by PublishBlockVol.
+		diskNumber, err := osUtils.getDiskNumber(ctx, req.GetPublishContext())
+		if err != nil {
+			return nil, err
+		}
+		mounter, err := GetMounter(ctx, osUtils)
+		if err != nil {
+			return nil, err
+		}
+		if err := mounter.SetDiskOnline(ctx, diskNumber); err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to bring disk %s of volume %q online, err: %v", diskNumber, params.VolID, err)
+		}
+		log.Infof("nodeStageBlockVolume: Raw block disk %s of volume %q is online", diskNumber, params.VolID)
+		return &csi.NodeStageVolumeResponse{}, nil
 	}
 
 	// Block Volume with Mount access type.
@@ -166,6 +198,15 @@ func (osUtils *OsUtils) CleanupStagePath(ctx context.Context, stagingTarget stri
 		return err
 	}
 
+	// Raw block volumes are not mounted at the staging target.
+	notMounted, err := mounter.IsLikelyNotMountPoint(stagingTarget)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if notMounted {
+		log.Infof("Staging target %q for volume %q is not mounted. Skipping unmount.", stagingTarget, volID)
+		return nil
+	}
 	// unmount Block volume.
 	log.Infof("Attempting to unmount target %q for volume %q", stagingTarget, volID)
 	err = mounter.Unmount(stagingTarget)
@@ -178,22 +219,83 @@ func (osUtils *OsUtils) CleanupStagePath(ctx context.Context, stagingTarget stri
 
 // CleanupPublishPath will unmount and remove publish path
 func (osUtils *OsUtils) CleanupPublishPath(ctx context.Context, target string, volID string) error {
-	//log := logger.GetLogger(ctx)
-	// for windows, unpublish means removing symlink only
+	log := logger.GetLogger(ctx)
+	// for windows, unpublish means removing symlink and the global mapping of
+	// an SMB share no longer published on the node
 	// get the mounter
 	mounter, err := GetMounter(ctx, osUtils)
 	if err != nil {
 		return err
 	}
+	// Remember the SMB share linked at target, if any, to remove its global
+	// mapping once no other target links it.
+	var smbShare string
+	if dest, err := readLink(target); err == nil && isSMBSharePath(dest) {
+		smbShare = dest
+	}
 	// no need to check if target exist first as rmdir do not throw error if path does not exists.
 	err = mounter.Rmdir(ctx, target)
 	if err != nil {
 		return fmt.Errorf(
 			"error unmounting publishTarget: %v", err)
 	}
+	if smbShare == "" {
+		return nil
+	}
+	smbShareLocks.LockKey(smbShareLockKey(smbShare))
+	defer func() {
+		_ = smbShareLocks.UnlockKey(smbShareLockKey(smbShare))
+	}()
+	inUse, err := isSMBShareLinked(smbShare, target)
+	if err != nil {
+		log.Warnf("CleanupPublishPath: failed to check if SMB share %q is still published on the node, "+
+			"leaving its global mapping in place. Err: %v", smbShare, err)
+		return nil
+	}
+	if inUse {
+		return nil
+	}
+	log.Infof("CleanupPublishPath: removing global mapping of SMB share %q of volume %q", smbShare, volID)
+	if err := mounter.RemoveSMBGlobalMapping(ctx, smbShare); err != nil {
+		return fmt.Errorf("error removing global mapping of SMB share %q: %v", smbShare, err)
+	}
 	return nil
 }
 
+// isSMBSharePath returns true if the given path is the UNC path of an SMB
+// share, and not a Win32 device path such as the path of a raw disk.
+func isSMBSharePath(path string) bool {
+	return strings.HasPrefix(path, `\\`) && !strings.HasPrefix(path, `\\.\`) &&
+		!strings.HasPrefix(path, `\\?\`)
+}
+
+// isSMBShareLinked returns true if the given SMB share is linked at the publish
+// target of any other pod volume on the node. The pod volumes are found next to
+// the given publish target, which is removed already.
+func isSMBShareLinked(smbShare string, target string) (bool, error) {
+	// target is <pods dir>\<pod>\volumes\kubernetes.io~csi\<pv>\mount.
+	volumeDir := filepath.Dir(target)
+	pluginDir := filepath.Dir(volumeDir)
+	podDir := filepath.Dir(filepath.Dir(pluginDir))
+	if filepath.Base(target) != "mount" || filepath.Base(pluginDir) != "kubernetes.io~csi" {
+		return false, fmt.Errorf("unexpected publish target %q", target)
+	}
+	pattern := filepath.Join(filepath.Dir(podDir), "*", "volumes", "kubernetes.io~csi", "*", "mount")
+	targets, err := globPaths(pattern)
+	if err != nil {
+		return false, err
+	}
+	for _, other := range targets {
+		if other == target {
+			continue
+		}
+		if dest, err := readLink(other); err == nil && strings.EqualFold(dest, smbShare) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // PublishBlockVol mounts block volume to publish target
 func (osUtils *OsUtils) PublishMountVol(
 	ctx context.Context,
@@ -230,22 +332,149 @@ func (osUtils *OsUtils) PublishBlockVol(
 	dev *Device,
 	params NodePublishParams) (
 	*csi.NodePublishVolumeResponse, error) {
-	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
+	log.Infof("PublishBlockVolume called with args: %+v", params)
 
-	return nil, logger.LogNewErrorCodef(log, codes.Internal,
-		"PublishBlockVol Raw Block devices are currently not supported by windows : %v", req)
+	// CSI proxy cannot set a disk read-only, so the raw disk linked at the
+	// publish target would be writable.
+	if params.Ro {
+		return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"raw block volume %q cannot be published read-only on windows nodes", params.VolID)
+	}
+	diskNumber, err := osUtils.getDiskNumber(ctx, req.GetPublishContext())
+	if err != nil {
+		return nil, err
+	}
+	// Check if target already published.
+	if linkedDiskNumber, ok := osUtils.getLinkedDiskNumber(params.Target); ok {
+		if linkedDiskNumber != diskNumber {
+			return nil, logger.LogNewErrorCodef(log, codes.AlreadyExists,
+				"target %q is already linked to disk %s", params.Target, linkedDiskNumber)
+		}
+		log.Infof("Volume already published to target %q.", params.Target)
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+	mounter, err := GetMounter(ctx, osUtils)
+	if err != nil {
+		return nil, err
+	}
+	// The disk may not have been staged by this instance of the node plugin.
+	if err := mounter.SetDiskOnline(ctx, diskNumber); err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to bring disk %s of volume %q online, err: %v", diskNumber, params.VolID, err)
+	}
+	if err := osUtils.PreparePublishPath(ctx, params.Target); err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"Target path could not be prepared: %v", err)
+	}
+	if err := mounter.LinkDisk(ctx, diskNumber, params.Target); err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"error publish volume to target path: %v", err)
+	}
+	log.Infof("NodePublishVolume for %q successful to path %q", req.GetVolumeId(), params.Target)
+	return &csi.NodePublishVolumeResponse{}, nil
 }
 
-// PublishBlockVol mounts file volume to publish target
+// getDiskNumber returns the windows disk number of the disk whose diskID is
+// set in the given publish context.
+func (osUtils *OsUtils) getDiskNumber(ctx context.Context, pubCtx map[string]string) (string, error) {
+	log := logger.GetLogger(ctx)
+	diskID, err := osUtils.GetDiskID(pubCtx, log)
+	if err != nil {
+		return "", err
+	}
+	mounter, err := GetMounter(ctx, osUtils)
+	if err != nil {
+		return "", err
+	}
+	diskNumber, err := mounter.GetDiskNumber(ctx, diskID)
+	if err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get Disk Number for diskID %q, err: %v", diskID, err)
+	}
+	return diskNumber, nil
+}
+
+// getLinkedDiskNumber returns the disk number of the raw disk linked at the
+// given path, and false if no raw disk is linked at the path.
+func (osUtils *OsUtils) getLinkedDiskNumber(path string) (string, bool) {
+	dest, err := readLink(path)
+	if err != nil {
+		return "", false
+	}
+	return mounter.DiskNumberFromPhysicalDrivePath(dest)
+}
+
+// PublishFileVol links the SMB share of a file volume at publish target.
+// CSI proxy has no NFS API, so only SMB file volumes are supported.
 func (osUtils *OsUtils) PublishFileVol(
 	ctx context.Context,
 	req *csi.NodePublishVolumeRequest,
 	params NodePublishParams) (
 	*csi.NodePublishVolumeResponse, error) {
 	log := logger.GetLogger(ctx)
-	return nil, logger.LogNewErrorCodef(log, codes.Internal,
-		"PublishBlockVol File Volumes are currently not supported by windows : %v", req)
+	log.Infof("PublishFileVolume called with args: %+v", params)
+
+	if req.GetVolumeContext()[common.AttributeFileProtocol] != common.FileProtocolSMB {
+		return nil, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+			"NFS file volume %q cannot be published on windows nodes. Use StorageClass parameter %q set to %q",
+			params.VolID, common.AttributeFileProtocol, common.FileProtocolSMB)
+	}
+	// CSI proxy links the SMB share at the publish target, which cannot be
+	// made read-only on the node. Read-only access modes are rejected for SMB
+	// file volumes at creation.
+	if params.Ro {
+		return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"SMB file volume %q cannot be published read-only on windows nodes", params.VolID)
+	}
+	// Retrieve the SMB share access point from publish context.
+	accessPoints, err := getFileShareAccessPoints(req.GetPublishContext(), common.SmbAccessPointKey)
+	if err != nil {
+		return nil, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+	}
+	remotePath, err := normalizeSmbAccessPoint(accessPoints[0])
+	if err != nil {
+		return nil, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+	}
+	username, password, err := getSmbCredentials(req.GetSecrets())
+	if err != nil {
+		return nil, logger.LogNewErrorCode(log, codes.InvalidArgument, err.Error())
+	}
+
+	mounter, err := GetMounter(ctx, osUtils)
+	if err != nil {
+		return nil, err
+	}
+	// Check if target already published.
+	notMounted, err := mounter.IsLikelyNotMountPoint(params.Target)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"could not determine if target %q is already published, err: %v", params.Target, err)
+	}
+	if err == nil && !notMounted {
+		log.Infof("Volume already published to target %q.", params.Target)
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+	if err := osUtils.PreparePublishPath(ctx, params.Target); err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"Target path could not be prepared: %v", err)
+	}
+	log.Debugf("PublishFileVolume: Attempting to link SMB share %q at %q", remotePath, params.Target)
+	smbShareLocks.LockKey(smbShareLockKey(remotePath))
+	err = mounter.SMBMount(ctx, remotePath, params.Target, username, password)
+	_ = smbShareLocks.UnlockKey(smbShareLockKey(remotePath))
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"error publish volume to target path: %v", err)
+	}
+	log.Infof("NodePublishVolume successful to path %q", params.Target)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// GetVolumeCondition returns the condition of the volume published at the
+// given path. Volume conditions are not reported on Windows.
+func (osUtils *OsUtils) GetVolumeCondition(ctx context.Context, path string) (*csi.VolumeCondition, error) {
+	return nil, nil
 }
 
 // GetMetrics helps get volume metrics using k8s fsInfo strategy.
@@ -271,6 +500,30 @@ func (osUtils *OsUtils) GetMetrics(ctx context.Context, path string) (*k8svol.Me
 	return metrics, nil
 }
 
+// GetBlockVolumeMetrics returns the metrics of a raw block volume published
+// at the given path. Only the capacity is reported as usage of a raw block
+// device cannot be determined without a filesystem.
+func (osUtils *OsUtils) GetBlockVolumeMetrics(ctx context.Context, path string) (*k8svol.Metrics, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no path given")
+	}
+	diskNumber, ok := osUtils.getLinkedDiskNumber(path)
+	if !ok {
+		return nil, fmt.Errorf("no raw block disk linked at path %q", path)
+	}
+	mounter, err := GetMounter(ctx, osUtils)
+	if err != nil {
+		return nil, err
+	}
+	capacity, err := mounter.GetDiskSizeInBytes(ctx, diskNumber)
+	if err != nil {
+		return nil, err
+	}
+	metrics := &k8svol.Metrics{Time: metav1.Now()}
+	metrics.Capacity = resource.NewQuantity(capacity, resource.BinarySI)
+	return metrics, nil
+}
+
 // GetBlockSizeBytes returns the Block size in bytes
 func (osUtils *OsUtils) GetBlockSizeBytes(ctx context.Context, devicePath string) (int64, error) {
 	mounter, err := GetMounter(ctx, osUtils)
@@ -514,5 +767,6 @@ func (osUtils *OsUtils) ShouldContinue(ctx context.Context) {
 
 // Check if device at given path is block device or not
 func (osUtils *OsUtils) IsBlockDevice(ctx context.Context, volumePath string) (bool, error) {
-	return false, nil
+	_, ok := osUtils.getLinkedDiskNumber(volumePath)
+	return ok, nil
 }
@@ -0,0 +1,338 @@
+//go:build windows
+// +build windows
+
+package osutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/mounter"
+)
+
+// fakeCSIProxyMounter is a CSIProxyMounter which keeps the published targets
+// in memory. Methods not overridden panic if called.
+type fakeCSIProxyMounter struct {
+	mounter.CSIProxyMounter
+	// links maps the published targets to their SMB share or raw disk.
+	links    map[string]string
+	username string
+	password string
+	// removedMappings are the SMB shares whose global mapping was removed.
+	removedMappings []string
+	// disks maps the diskIDs of the attached disks to their disk number.
+	disks       map[string]string
+	onlineDisks map[string]bool
+	// diskSizes maps the disk numbers of the attached disks to their size.
+	diskSizes map[string]int64
+}
+
+func (f *fakeCSIProxyMounter) IsLikelyNotMountPoint(path string) (bool, error) {
+	if _, ok := f.links[path]; ok {
+		return false, nil
+	}
+	return true, os.ErrNotExist
+}
+
+func (f *fakeCSIProxyMounter) ExistsPath(ctx context.Context, path string) (bool, error) {
+	_, ok := f.links[path]
+	return ok, nil
+}
+
+func (f *fakeCSIProxyMounter) Rmdir(ctx context.Context, path string) error {
+	delete(f.links, path)
+	return nil
+}
+
+func (f *fakeCSIProxyMounter) MakeDir(ctx context.Context, pathname string) error {
+	return nil
+}
+
+func (f *fakeCSIProxyMounter) SMBMount(ctx context.Context, source, target, username, password string) error {
+	f.links[target] = source
+	f.username = username
+	f.password = password
+	return nil
+}
+
+func (f *fakeCSIProxyMounter) RemoveSMBGlobalMapping(ctx context.Context, remotePath string) error {
+	f.removedMappings = append(f.removedMappings, remotePath)
+	return nil
+}
+
+func (f *fakeCSIProxyMounter) GetDiskNumber(ctx context.Context, diskID string) (string, error) {
+	diskNumber, ok := f.disks[diskID]
+	if !ok {
+		return "", errors.New("no matching disks found")
+	}
+	return diskNumber, nil
+}
+
+func (f *fakeCSIProxyMounter) SetDiskOnline(ctx context.Context, diskNumber string) error {
+	f.onlineDisks[diskNumber] = true
+	return nil
+}
+
+func (f *fakeCSIProxyMounter) LinkDisk(ctx context.Context, diskNumber, target string) error {
+	f.links[target] = mounter.PhysicalDrivePath(diskNumber)
+	return nil
+}
+
+func (f *fakeCSIProxyMounter) GetDiskSizeInBytes(ctx context.Context, diskNumber string) (int64, error) {
+	size, ok := f.diskSizes[diskNumber]
+	if !ok {
+		return -1, os.ErrNotExist
+	}
+	return size, nil
+}
+
+// readLink returns the destination of the fake links.
+func (f *fakeCSIProxyMounter) readLink(path string) (string, error) {
+	dest, ok := f.links[path]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return dest, nil
+}
+
+// glob returns the fake links matching pattern.
+func (f *fakeCSIProxyMounter) glob(pattern string) ([]string, error) {
+	var matches []string
+	for path := range f.links {
+		matched, err := filepath.Match(pattern, path)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, path)
+		}
+	}
+	return matches, nil
+}
+
+func newFakeSMBPublishRequest(volumeContext, secrets map[string]string) *csi.NodePublishVolumeRequest {
+	return &csi.NodePublishVolumeRequest{
+		VolumeId:   "file:6d4f9a2e-3c57-4a2f-9d6c-6cbb8bd5f4e2",
+		TargetPath: `c:\var\lib\kubelet\pods\pod\volumes\kubernetes.io~csi\pvc\mount`,
+		PublishContext: map[string]string{
+			common.AttributeDiskType: common.DiskTypeFileVolume,
+			common.NfsAccessPoints:   `{"SMB":["\\\\fs.example.com\\share"]}`,
+		},
+		VolumeContext: volumeContext,
+		Secrets:       secrets,
+	}
+}
+
+func TestPublishFileVol(t *testing.T) {
+	ctx := context.Background()
+	smbVolumeContext := map[string]string{common.AttributeFileProtocol: common.FileProtocolSMB}
+	smbSecrets := map[string]string{
+		common.SmbUsernameSecretKey: "svc-k8s",
+		common.SmbPasswordSecretKey: "secret",
+		common.SmbDomainSecretKey:   "CORP",
+	}
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+		secrets       map[string]string
+		readOnly      bool
+		expectedCode  codes.Code
+	}{
+		{
+			name:          "SMB",
+			volumeContext: smbVolumeContext,
+			secrets:       smbSecrets,
+		},
+		{
+			name:          "NFS",
+			volumeContext: map[string]string{},
+			secrets:       smbSecrets,
+			expectedCode:  codes.FailedPrecondition,
+		},
+		{
+			name:          "MissingCredentials",
+			volumeContext: smbVolumeContext,
+			expectedCode:  codes.InvalidArgument,
+		},
+		{
+			name:          "ReadOnly",
+			volumeContext: smbVolumeContext,
+			secrets:       smbSecrets,
+			readOnly:      true,
+			expectedCode:  codes.InvalidArgument,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeCSIProxyMounter{links: make(map[string]string)}
+			osUtils := &OsUtils{Mounter: &mount.SafeFormatAndMount{Interface: fake}}
+			req := newFakeSMBPublishRequest(test.volumeContext, test.secrets)
+			params := NodePublishParams{VolID: req.VolumeId, Target: req.TargetPath, Ro: test.readOnly}
+			_, err := osUtils.PublishFileVol(ctx, req, params)
+			if status.Code(err) != test.expectedCode {
+				t.Fatalf("PublishFileVol() error = %v, want code %v", err, test.expectedCode)
+			}
+			if test.expectedCode != codes.OK {
+				if len(fake.links) != 0 {
+					t.Errorf("expected no SMB share to be linked, got %v", fake.links)
+				}
+				return
+			}
+			if fake.links[req.TargetPath] != `\\fs.example.com\share` {
+				t.Errorf("expected SMB share linked at %q, got %v", req.TargetPath, fake.links)
+			}
+			if fake.username != `CORP\svc-k8s` || fake.password != "secret" {
+				t.Errorf("unexpected SMB credentials %q, %q", fake.username, fake.password)
+			}
+			// Publishing again to the same target is a no-op.
+			fake.username = ""
+			if _, err := osUtils.PublishFileVol(ctx, req, params); err != nil {
+				t.Fatalf("PublishFileVol() on published target failed: %v", err)
+			}
+			if fake.username != "" {
+				t.Errorf("expected the published target not to be linked again")
+			}
+		})
+	}
+}
+
+func TestCleanupPublishPathSMB(t *testing.T) {
+	ctx := context.Background()
+	share := `\\fs.example.com\share`
+	target1 := `c:\var\lib\kubelet\pods\pod1\volumes\kubernetes.io~csi\pvc\mount`
+	target2 := `c:\var\lib\kubelet\pods\pod2\volumes\kubernetes.io~csi\pvc\mount`
+	fake := &fakeCSIProxyMounter{links: map[string]string{target1: share, target2: share}}
+	origReadLink, origGlobPaths := readLink, globPaths
+	readLink, globPaths = fake.readLink, fake.glob
+	defer func() { readLink, globPaths = origReadLink, origGlobPaths }()
+	osUtils := &OsUtils{Mounter: &mount.SafeFormatAndMount{Interface: fake}}
+
+	// The global mapping is kept while another pod links the share.
+	if err := osUtils.CleanupPublishPath(ctx, target1, "file:6d4f9a2e"); err != nil {
+		t.Fatalf("CleanupPublishPath() failed: %v", err)
+	}
+	if _, ok := fake.links[target1]; ok || len(fake.removedMappings) != 0 {
+		t.Fatalf("expected %q to be unlinked and no mapping removed, got links %v and removed mappings %v",
+			target1, fake.links, fake.removedMappings)
+	}
+	// The global mapping is removed with the last link of the share.
+	if err := osUtils.CleanupPublishPath(ctx, target2, "file:6d4f9a2e"); err != nil {
+		t.Fatalf("CleanupPublishPath() failed: %v", err)
+	}
+	if len(fake.removedMappings) != 1 || fake.removedMappings[0] != share {
+		t.Errorf("expected mapping of %q to be removed, got %v", share, fake.removedMappings)
+	}
+}
+
+func TestGetBlockVolumeMetrics(t *testing.T) {
+	ctx := context.Background()
+	target := `c:\var\lib\kubelet\plugins\kubernetes.io\csi\volumeDevices\publish\pvc\pod`
+	fake := &fakeCSIProxyMounter{
+		links:     map[string]string{target: mounter.PhysicalDrivePath("2")},
+		diskSizes: map[string]int64{"2": 10737418240},
+	}
+	origReadLink := readLink
+	readLink = fake.readLink
+	defer func() { readLink = origReadLink }()
+	osUtils := &OsUtils{Mounter: &mount.SafeFormatAndMount{Interface: fake}}
+
+	isBlock, err := osUtils.IsBlockDevice(ctx, target)
+	if err != nil || !isBlock {
+		t.Fatalf("IsBlockDevice() = %v, %v, want true", isBlock, err)
+	}
+	metrics, err := osUtils.GetBlockVolumeMetrics(ctx, target)
+	if err != nil {
+		t.Fatalf("GetBlockVolumeMetrics() failed: %v", err)
+	}
+	if capacity, ok := metrics.Capacity.AsInt64(); !ok || capacity != 10737418240 {
+		t.Errorf("GetBlockVolumeMetrics() capacity = %v, want 10737418240", metrics.Capacity)
+	}
+	if metrics.Available != nil || metrics.Used != nil {
+		t.Errorf("expected available and used to be unset, got %v and %v", metrics.Available, metrics.Used)
+	}
+
+	// A path which is not linked to a raw disk is not a raw block volume.
+	other := `c:\var\lib\kubelet\pods\pod\volumes\kubernetes.io~csi\pvc\mount`
+	if isBlock, _ := osUtils.IsBlockDevice(ctx, other); isBlock {
+		t.Errorf("expected %q not to be a block device", other)
+	}
+	if _, err := osUtils.GetBlockVolumeMetrics(ctx, other); err == nil {
+		t.Errorf("expected GetBlockVolumeMetrics() to fail for %q", other)
+	}
+}
+
+func TestPublishBlockVol(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeCSIProxyMounter{
+		links:       make(map[string]string),
+		disks:       map[string]string{"6000c29a98d05e384a43f0ef189aaf5a": "2"},
+		onlineDisks: make(map[string]bool),
+		diskSizes:   map[string]int64{"2": 1024 * 1024 * 1024},
+	}
+	origReadLink := readLink
+	readLink = fake.readLink
+	defer func() { readLink = origReadLink }()
+	osUtils := &OsUtils{Mounter: &mount.SafeFormatAndMount{Interface: fake}}
+	target := `c:\var\lib\kubelet\plugins\kubernetes.io\csi\volumeDevices\publish\pvc\pod`
+	req := &csi.NodePublishVolumeRequest{
+		VolumeId:   "b03f0b6e-cf29-4b98-9411-5168682ace82",
+		TargetPath: target,
+		PublishContext: map[string]string{
+			common.AttributeFirstClassDiskUUID: "6000c29a98d05e384a43f0ef189aaf5a",
+		},
+	}
+	params := NodePublishParams{VolID: req.VolumeId, Target: target}
+
+	if _, err := osUtils.PublishBlockVol(ctx, req, nil, params); err != nil {
+		t.Fatalf("PublishBlockVol() failed: %v", err)
+	}
+	if fake.links[target] != `\\.\PhysicalDrive2` || !fake.onlineDisks["2"] {
+		t.Fatalf("expected disk 2 online and linked at %q, got links %v and online disks %v",
+			target, fake.links, fake.onlineDisks)
+	}
+	// Publishing again to the same target is a no-op.
+	if _, err := osUtils.PublishBlockVol(ctx, req, nil, params); err != nil {
+		t.Fatalf("PublishBlockVol() on published target failed: %v", err)
+	}
+	isBlock, err := osUtils.IsBlockDevice(ctx, target)
+	if err != nil || !isBlock {
+		t.Fatalf("IsBlockDevice() = %v, %v, want true", isBlock, err)
+	}
+	metrics, err := osUtils.GetBlockVolumeMetrics(ctx, target)
+	if err != nil {
+		t.Fatalf("GetBlockVolumeMetrics() failed: %v", err)
+	}
+	if capacity, _ := metrics.Capacity.AsInt64(); capacity != 1024*1024*1024 {
+		t.Errorf("GetBlockVolumeMetrics() capacity = %d, want %d", capacity, 1024*1024*1024)
+	}
+	if err := osUtils.CleanupPublishPath(ctx, target, req.VolumeId); err != nil {
+		t.Fatalf("CleanupPublishPath() failed: %v", err)
+	}
+	if isBlock, _ := osUtils.IsBlockDevice(ctx, target); isBlock {
+		t.Errorf("expected target %q not to be a block device after unpublish", target)
+	}
+
+	// A raw block volume cannot be published read-only.
+	roParams := NodePublishParams{VolID: req.VolumeId, Target: target, Ro: true}
+	if _, err := osUtils.PublishBlockVol(ctx, req, nil, roParams); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("PublishBlockVol() read-only error = %v, want code %v", err, codes.InvalidArgument)
+	}
+	if _, ok := fake.links[target]; ok {
+		t.Errorf("expected read-only publish not to link target %q", target)
+	}
+
+	// A disk which is not attached cannot be published.
+	req.PublishContext[common.AttributeFirstClassDiskUUID] = "6000c29a98d05e384a43f0ef189aaf5b"
+	if _, err := osUtils.PublishBlockVol(ctx, req, nil, params); status.Code(err) != codes.Internal {
+		t.Errorf("PublishBlockVol() of unattached disk error = %v, want code %v", err, codes.Internal)
+	}
+}
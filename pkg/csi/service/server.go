@@ -17,13 +17,18 @@ limitations under the License.
 package service
 
 import (
+	"context"
 	"net"
 	"os"
 	"strings"
 	"sync"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 
 	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
@@ -113,7 +118,10 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer,
 		return logger.LogNewErrorf(log, "failed to listen: %v", err)
 	}
 
-	server := grpc.NewServer()
+	// The CSI requests are traced with the trace context propagated by the
+	// sidecars, if any.
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(otelgrpc.UnaryServerInterceptor(),
+		vCenterUnavailableInterceptor))
 	s.server = server
 
 	// Register the CSI services.
@@ -156,3 +164,15 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer,
 	}
 	return nil
 }
+
+// vCenterUnavailableInterceptor returns the errors of the requests which
+// failed fast because the circuit breaker of the vCenter is open with the
+// Unavailable code, so that the sidecars retry them later.
+func vCenterUnavailableInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil && status.Code(err) != codes.Unavailable && cnsvsphere.IsCircuitOpenError(err) {
+		return resp, status.Error(codes.Unavailable, status.Convert(err).Message())
+	}
+	return resp, err
+}
@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -36,6 +37,7 @@ import (
 	"github.com/vmware/govmomi/vim25/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/migration"
@@ -63,6 +65,7 @@ type NodeManagerInterface interface {
 	GetNodeVMByNameAndUpdateCache(ctx context.Context, nodeName string) (*cnsvsphere.VirtualMachine, error)
 	GetNodeVMByNameOrUUID(ctx context.Context, nodeName string) (*cnsvsphere.VirtualMachine, error)
 	GetNodeNameByUUID(ctx context.Context, nodeUUID string) (string, error)
+	GetK8sNode(ctx context.Context, nodeName string) (*v1.Node, error)
 	GetNodeVMByUuid(ctx context.Context, nodeUuid string) (*cnsvsphere.VirtualMachine, error)
 	GetAllNodes(ctx context.Context) ([]*cnsvsphere.VirtualMachine, error)
 	GetAllNodesByVC(ctx context.Context, vcHost string) ([]*cnsvsphere.VirtualMachine, error)
@@ -105,7 +108,7 @@ var (
 	// variable for list snapshots
 	CNSSnapshotsForListSnapshots = make([]cnstypes.CnsSnapshotQueryResultEntry, 0)
 	CNSVolumeDetailsMap          = make([]map[string]*utils.CnsVolumeDetails, 0)
-	volumeIDToNodeUUIDMap        = make(map[string]string)
+	volumeIDToNodeUUIDMap        = make(map[string][]string)
 )
 
 // New creates a CNS controller.
@@ -550,16 +553,13 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 	volumeSource := req.GetVolumeContentSource()
 	var contentSourceSnapshotID string
 	if isBlockVolumeSnapshotEnabled && volumeSource != nil {
-		isCnsSnapshotSupported, err := c.manager.VcenterManager.IsCnsSnapshotSupported(ctx,
-			c.manager.VcenterConfig.Host)
-		if err != nil {
+		if err := c.manager.VcenterManager.CheckFeatureSupported(ctx, c.manager.VcenterConfig.Host,
+			cnsvsphere.FeatureBlockVolumeSnapshot); cnsvsphere.IsFeatureNotSupportedError(err) {
+			return nil, csifault.CSIUnimplementedFault, logger.LogNewErrorCode(log, codes.Unimplemented, err.Error())
+		} else if err != nil {
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to check if cns snapshot operations are supported on VC due to error: %v", err)
 		}
-		if !isCnsSnapshotSupported {
-			return nil, csifault.CSIUnimplementedFault, logger.LogNewErrorCode(log, codes.Unimplemented,
-				"VC version does not support snapshot operations")
-		}
 		sourceSnapshot := volumeSource.GetSnapshot()
 		if sourceSnapshot == nil {
 			return nil, csifault.CSIInvalidArgumentFault,
@@ -601,6 +601,10 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 			"parsing storage class parameters failed with error: %+v", err)
 	}
+	err = validateVanillaBlockVolumeParams(ctx, req.GetVolumeCapabilities(), scParams)
+	if err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, err
+	}
 
 	if csiMigrationFeatureState && scParams.CSIMigration == "true" {
 		if len(scParams.Datastore) != 0 {
@@ -812,6 +816,18 @@ func (c *controller) createBlockVolume(ctx context.Context, req *csi.CreateVolum
 
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeBlockVolume
+	if scParams.DiskSharing != "" {
+		attributes[common.AttributeDiskSharing] = scParams.DiskSharing
+	}
+	if scParams.ProtectFromVMDeletion != "" {
+		attributes[common.AttributeProtectFromVMDeletion] = scParams.ProtectFromVMDeletion
+	}
+	// The protection is set again when the volume is attached, so failing to
+	// set it here does not fail the volume creation.
+	err = c.protectBlockVolumeFromVMDeletion(ctx, c.manager.VolumeManager, volumeInfo.VolumeID.Id, attributes)
+	if err != nil {
+		log.Warnf("volume %q is not protected from VM deletion yet. Error: %v", volumeInfo.VolumeID.Id, err)
+	}
 	if csiMigrationFeatureState && scParams.CSIMigration == "true" {
 		// In case if feature state switch is enabled after controller is
 		// deployed, we need to initialize the volumeMigrationService.
@@ -978,6 +994,10 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 			"parsing storage class parameters failed with error: %+v", err)
 	}
+	err = validateVanillaBlockVolumeParams(ctx, req.GetVolumeCapabilities(), scParams)
+	if err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, err
+	}
 
 	if scParams.CSIMigration == "true" {
 		if len(c.managers.VcenterConfigs) > 1 {
@@ -1054,16 +1074,14 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to get vCenter/volume manager for volumeID: %q. Error: %+v", cnsVolumeID, err)
 		}
-		isCnsSnapshotSupported, err := c.managers.VcenterManager.IsCnsSnapshotSupported(ctx, vCenterHost)
-		if err != nil {
+		if err := c.managers.VcenterManager.CheckFeatureSupported(ctx, vCenterHost,
+			cnsvsphere.FeatureBlockVolumeSnapshot); cnsvsphere.IsFeatureNotSupportedError(err) {
+			return nil, csifault.CSIUnimplementedFault, logger.LogNewErrorCode(log, codes.Unimplemented, err.Error())
+		} else if err != nil {
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to check if cns snapshot operations are supported on VC %q due to error: %v",
 				vCenterHost, err)
 		}
-		if !isCnsSnapshotSupported {
-			return nil, csifault.CSIUnimplementedFault, logger.LogNewErrorCodef(log, codes.Unimplemented,
-				"VC %q does not support snapshot operations", vCenterHost)
-		}
 		// Query capacity in MB and datastore url for block volume snapshot.
 		volumeIds := []cnstypes.CnsVolumeId{{Id: cnsVolumeID}}
 		cnsVolumeDetailsMap, err := utils.QueryVolumeDetailsUtil(ctx, volumeManager, volumeIds)
@@ -1436,6 +1454,26 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeBlockVolume
+	if scParams.DiskSharing != "" {
+		attributes[common.AttributeDiskSharing] = scParams.DiskSharing
+	}
+	if scParams.ProtectFromVMDeletion != "" {
+		attributes[common.AttributeProtectFromVMDeletion] = scParams.ProtectFromVMDeletion
+	}
+	if c.isProtectFromVMDeletionRequested(attributes) {
+		if volumeMgr == nil {
+			volumeMgr, err = GetVolumeManagerFromVCHost(ctx, c.managers, vcHost)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+			}
+		}
+		// The protection is set again when the volume is attached, so failing to
+		// set it here does not fail the volume creation.
+		err = c.protectBlockVolumeFromVMDeletion(ctx, volumeMgr, volumeInfo.VolumeID.Id, attributes)
+		if err != nil {
+			log.Warnf("volume %q is not protected from VM deletion yet. Error: %v", volumeInfo.VolumeID.Id, err)
+		}
+	}
 
 	if scParams.CSIMigration == "true" {
 		volumePath, err := volumeMigrationService.GetVolumePath(ctx, volumeInfo.VolumeID.Id)
@@ -1614,6 +1652,10 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 			"parsing storage class parameters failed with error: %+v", err)
 	}
+	err = validateVanillaFileVolumeParams(ctx, req.GetVolumeCapabilities(), scParams)
+	if err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, err
+	}
 	// Check if vCenter task for this volume is already registered as part of
 	// improved idempotency CR
 	log.Debugf("Checking if vCenter task for file volume %s is already registered.", req.Name)
@@ -1709,22 +1751,45 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		}
 	}
 
+	var (
+		vc            *cnsvsphere.VirtualCenter
+		volumeManager cnsvolume.Manager
+		cnsConfig     *cnsconfig.Config
+	)
+	if multivCenterCSITopologyEnabled {
+		vc, err = c.managers.VcenterManager.GetVirtualCenter(ctx, c.managers.CnsConfig.Global.VCenterIP)
+		volumeManager = c.managers.VolumeManagers[c.managers.CnsConfig.Global.VCenterIP]
+		cnsConfig = c.managers.CnsConfig
+	} else {
+		vc, err = c.manager.VcenterManager.GetVirtualCenter(ctx, c.manager.VcenterConfig.Host)
+		volumeManager = c.manager.VolumeManager
+		cnsConfig = c.manager.CnsConfig
+	}
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get vCenter. Error: %+v", err)
+	}
+	// Get accessibility.
+	topologyRequirement := req.GetAccessibilityRequirements()
+
+	var fsEnabledClusterToDsInfoMap map[string][]*cnsvsphere.DatastoreInfo
+	if multivCenterCSITopologyEnabled {
+		fsEnabledClusterToDsInfoMap = c.authMgrs[c.managers.CnsConfig.Global.VCenterIP].GetFsEnabledClusterToDsMap(ctx)
+	} else {
+		fsEnabledClusterToDsInfoMap = c.authMgr.GetFsEnabledClusterToDsMap(ctx)
+	}
+
 	if !volTaskAlreadyRegistered {
 		var createVolumeSpec = common.CreateVolumeSpec{
 			CapacityMB: volSizeMB,
 			Name:       req.Name,
 			ScParams:   scParams,
 			VolumeType: common.FileVolumeType,
+			SkipConfigNetPermissions: commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+				common.FileVolumeNodeACLs),
 		}
 
 		filterSuspendedDatastores := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CnsMgrSuspendCreateVolume)
-		var fsEnabledClusterToDsInfoMap map[string][]*cnsvsphere.DatastoreInfo
-		if multivCenterCSITopologyEnabled {
-			fsEnabledClusterToDsInfoMap = c.authMgrs[c.managers.CnsConfig.Global.VCenterIP].GetFsEnabledClusterToDsMap(ctx)
-		} else {
-			fsEnabledClusterToDsInfoMap = c.authMgr.GetFsEnabledClusterToDsMap(ctx)
-		}
-
 		var filteredDatastores []*cnsvsphere.DatastoreInfo
 		for _, datastores := range fsEnabledClusterToDsInfoMap {
 			filteredDatastores = append(filteredDatastores, datastores...)
@@ -1735,37 +1800,401 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 			return nil, csifault.CSIVSanFileServiceDisabledFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
 				"no datastores found to create file volume, vsan file service may be disabled")
 		}
-		if multivCenterCSITopologyEnabled {
-			vc, err := c.managers.VcenterManager.GetVirtualCenter(ctx, c.managers.CnsConfig.Global.VCenterIP)
+		if topologyRequirement != nil {
+			// Check if topology domains have been provided in the vSphere CSI config secret.
+			// NOTE: We do not support kubernetes.io/hostname as a topology label.
+			if cnsConfig.Labels.TopologyCategories == "" && cnsConfig.Labels.Zone == "" &&
+				cnsConfig.Labels.Region == "" {
+				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log, codes.InvalidArgument,
+					"topology category names not specified in the vsphere config secret")
+			}
+			// Get shared accessible datastores for matching topology requirement.
+			topologyDatastores, err := c.topologyMgr.GetSharedDatastoresInTopology(ctx,
+				commoncotypes.VanillaTopologyFetchDSParams{
+					TopologyRequirement: topologyRequirement,
+					Vc:                  vc,
+					StoragePolicyName:   scParams.StoragePolicyName,
+				})
+			if err != nil || len(topologyDatastores) == 0 {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to get shared datastores for topology requirement: %+v. Error: %+v",
+					topologyRequirement, err)
+			}
+			log.Debugf("Shared datastores [%+v] retrieved for topologyRequirement [%+v]", topologyDatastores,
+				topologyRequirement)
+			filteredDatastores = filterDatastoresByURL(filteredDatastores, topologyDatastores)
+			if len(filteredDatastores) == 0 {
+				return nil, csifault.CSIVSanFileServiceDisabledFault, logger.LogNewErrorCodef(log,
+					codes.FailedPrecondition, "no vsan file service enabled datastores found to create file "+
+						"volume for topology requirement: %+v", topologyRequirement)
+			}
+		}
+		volumeID, faultType, err = common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
+			vc, volumeManager, cnsConfig, &createVolumeSpec,
+			filteredDatastores, filterSuspendedDatastores, false, checkCompatibleDataStores)
+		if err != nil {
+			return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to create volume. Error: %+v", err)
+		}
+	}
+
+	attributes := make(map[string]string)
+	attributes[common.AttributeDiskType] = common.DiskTypeFileVolume
+	netPerms := common.GetFileVolumeNetPermissions(scParams,
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolumeNodeACLs), cnsConfig)
+	if fileShareConfig := getFileShareConfig(scParams, netPerms); fileShareConfig != nil {
+		err = configureNewFileShare(ctx, vc, volumeManager, fsEnabledClusterToDsInfoMap,
+			req.Name, volumeID, *fileShareConfig)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to reconfigure file share of file volume %q with %+v. Error: %+v",
+				volumeID, *fileShareConfig, err)
+		}
+	}
+	if scParams.NetPermissions != nil {
+		attributes[common.AttributeNetPermissionRootSquash] = strconv.FormatBool(scParams.NetPermissions.RootSquash)
+	}
+	if scParams.NfsSecurityType != "" {
+		attributes[common.AttributeNfsSecurityType] = scParams.NfsSecurityType
+	}
+	if scParams.NfsVersion != "" {
+		attributes[common.AttributeNfsVersion] = scParams.NfsVersion
+	}
+	if scParams.FileProtocol != "" {
+		attributes[common.AttributeFileProtocol] = scParams.FileProtocol
+	}
+
+	resp := &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      volumeID,
+			CapacityBytes: int64(units.FileSize(volSizeMB * common.MbInBytes)),
+			VolumeContext: attributes,
+		},
+	}
+
+	// For topology aware provisioning, populate the topology segments parameter
+	// in the CreateVolumeResponse struct.
+	if topologyRequirement != nil {
+		volumeIds := []cnstypes.CnsVolumeId{{Id: volumeID}}
+		queryFilter := cnstypes.CnsQueryFilter{
+			VolumeIds: volumeIds,
+		}
+		querySelection := cnstypes.CnsQuerySelection{
+			Names: []string{string(cnstypes.QuerySelectionNameTypeDataStoreUrl)},
+		}
+		queryResult, err := utils.QueryVolumeUtil(ctx, volumeManager, queryFilter, &querySelection, true)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"queryVolumeUtil failed for volumeID: %s, err: %+v", volumeID, err)
+		}
+		if len(queryResult.Volumes) == 0 || queryResult.Volumes[0].DatastoreUrl == "" {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"queryVolumeUtil could not retrieve volume information for volume ID: %q", volumeID)
+		}
+		datastoreURL := queryResult.Volumes[0].DatastoreUrl
+		// Get all nodeVMs in cluster.
+		allNodeVMs, err := c.nodeMgr.GetAllNodes(ctx)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to find VirtualMachines for the registered nodes in the cluster. Error: %v", err)
+		}
+		// Find datastore topology from the retrieved datastoreURL.
+		datastoreAccessibleTopology, err := c.getAccessibleTopologiesForDatastore(ctx, vc, topologyRequirement,
+			allNodeVMs, datastoreURL)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to calculate accessible topologies for the datastore %q", datastoreURL)
+		}
+		// Add topology segments to the CreateVolumeResponse.
+		for _, topoSegments := range datastoreAccessibleTopology {
+			volumeTopology := &csi.Topology{
+				Segments: topoSegments,
+			}
+			resp.Volume.AccessibleTopology = append(resp.Volume.AccessibleTopology, volumeTopology)
+		}
+	}
+	return resp, "", nil
+}
+
+// createFileVolumeForMultiVC creates a file volume based on the CreateVolumeRequest
+// on one of the vCenters in a multi-VC environment.
+func (c *controller) createFileVolumeForMultiVC(ctx context.Context, req *csi.CreateVolumeRequest) (
+	*csi.CreateVolumeResponse, string, error) {
+	log := logger.GetLogger(ctx)
+
+	// Volume Size - Default is 10 GiB.
+	volSizeBytes := int64(common.DefaultGbDiskSize * common.GbInBytes)
+	if req.GetCapacityRange() != nil && req.GetCapacityRange().RequiredBytes != 0 {
+		volSizeBytes = int64(req.GetCapacityRange().GetRequiredBytes())
+	}
+	volSizeMB := int64(common.RoundUpSize(volSizeBytes, common.MbInBytes))
+
+	scParams, err := common.ParseStorageClassParams(ctx, req.Parameters, false)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"parsing storage class parameters failed with error: %+v", err)
+	}
+	err = validateVanillaFileVolumeParams(ctx, req.GetVolumeCapabilities(), scParams)
+	if err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, err
+	}
+
+	var (
+		volTaskAlreadyRegistered bool
+		faultType                string
+		volumeID                 string
+		vcHost                   string
+		vcenter                  *cnsvsphere.VirtualCenter
+		volumeMgr                cnsvolume.Manager
+		combinedErrMssgs         []string
+		// unsupportedVCs counts the vCenters which do not support file volumes.
+		unsupportedVCs int
+	)
+	// Check if vCenter task for this volume is already registered as part of
+	// improved idempotency CR.
+	log.Debugf("Checking if vCenter task for file volume %s is already registered.", req.Name)
+	var operationStore cnsvolumeoperationrequest.VolumeOperationRequest
+	for _, volMgr := range c.managers.VolumeManagers {
+		operationStore = volMgr.GetOperationStore()
+		if operationStore == nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to operation store in volume managers")
+		}
+		break
+	}
+	volumeOperationDetails, err := operationStore.GetRequestDetails(ctx, req.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Debugf("CreateVolume task details for file volume %s are not found.", req.Name)
+		} else {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"error occurred while getting CreateVolume task details for file volume %q. Error: %+v",
+				req.Name, err)
+		}
+	} else if volumeOperationDetails.OperationDetails != nil {
+		if volumeOperationDetails.OperationDetails.VCenterServer != "" {
+			vcHost = volumeOperationDetails.OperationDetails.VCenterServer
+		} else {
+			vcHost = c.managers.CnsConfig.Global.VCenterIP
+		}
+		if volumeOperationDetails.OperationDetails.TaskStatus ==
+			cnsvolumeoperationrequest.TaskInvocationStatusSuccess &&
+			volumeOperationDetails.VolumeID != "" {
+			// If task status is successful for this volume, then it means that volume is
+			// already created and there is no need to create it again.
+			log.Infof("File volume with name %q and id %q is already created on VC %q with opId: %q.",
+				req.Name, volumeOperationDetails.VolumeID, vcHost, volumeOperationDetails.OperationDetails.OpID)
+			volumeID = volumeOperationDetails.VolumeID
+			volTaskAlreadyRegistered = true
+		} else if cnsvolume.IsTaskPending(volumeOperationDetails) {
+			// If task is already created in CNS for this volume but task is in progress,
+			// we need to monitor the task to check if volume creation is complete or not.
+			log.Infof("File volume with name %s has CreateVolume task %s pending on VC %q.",
+				req.Name, volumeOperationDetails.OperationDetails.TaskID, vcHost)
+			vcenter, err = common.GetVCenterFromVCHost(ctx, c.managers.VcenterManager, vcHost)
 			if err != nil {
-				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
-					"failed to get vCenter. Error: %+v", err)
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to fetch vCenter instance %q. Error: %+v", vcHost, err)
 			}
-			volumeID, faultType, err = common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
-				vc, c.managers.VolumeManagers[vc.Config.Host], c.managers.CnsConfig, &createVolumeSpec,
-				filteredDatastores, filterSuspendedDatastores, false, checkCompatibleDataStores)
+			taskMoRef := types.ManagedObjectReference{
+				Type:  "Task",
+				Value: volumeOperationDetails.OperationDetails.TaskID,
+			}
+			task := object.NewTask(vcenter.Client.Client, taskMoRef)
+
+			defer func() {
+				// Persist the operation details before returning. Only success or error
+				// needs to be stored as InProgress details are stored when the task is
+				// created on CNS.
+				if volumeOperationDetails != nil && volumeOperationDetails.OperationDetails != nil &&
+					volumeOperationDetails.OperationDetails.TaskStatus !=
+						cnsvolumeoperationrequest.TaskInvocationStatusInProgress {
+					err := operationStore.StoreRequestDetails(ctx, volumeOperationDetails)
+					if err != nil {
+						log.Warnf("failed to store CreateVolume operation request details with error: %v", err)
+					}
+				}
+			}()
+
+			volumeMgr, err = GetVolumeManagerFromVCHost(ctx, c.managers, vcHost)
 			if err != nil {
-				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
-					"failed to create volume. Error: %+v", err)
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
 			}
-		} else {
-			vc, err := c.manager.VcenterManager.GetVirtualCenter(ctx, c.manager.VcenterConfig.Host)
+			volumeInfo, faultType, err := volumeMgr.MonitorCreateVolumeTask(ctx,
+				&volumeOperationDetails, task, req.Name, c.managers.CnsConfig.Global.ClusterID)
 			if err != nil {
 				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
-					"failed to get vCenter. Error: %+v", err)
+					"failed to monitor task for file volume %s on VC %q. Error: %+v", req.Name, vcHost, err)
+			}
+			volumeID = volumeInfo.VolumeID.Id
+			volTaskAlreadyRegistered = true
+		}
+	}
+
+	// Get the accessibility requirements according to the VC they belong to.
+	// Without accessibility requirements, all the vCenters are candidates.
+	topologyRequirement := req.GetAccessibilityRequirements()
+	vcTopologySegmentsMap := make(map[string][]map[string]string)
+	if topologyRequirement != nil {
+		if c.managers.CnsConfig.Labels.TopologyCategories == "" && c.managers.CnsConfig.Labels.Zone == "" &&
+			c.managers.CnsConfig.Labels.Region == "" {
+			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log, codes.InvalidArgument,
+				"topology category names not specified in the vsphere config secret")
+		}
+		vcTopologySegmentsMap, err = common.GetAccessibilityRequirementsByVC(ctx, topologyRequirement)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get accessibility requirements by VC. Error: %+v", err)
+		}
+		log.Debugf("Topology accessibility requirements per VC are %+v", vcTopologySegmentsMap)
+	} else {
+		for vcHost := range c.managers.VcenterConfigs {
+			vcTopologySegmentsMap[vcHost] = nil
+		}
+	}
+
+	if !volTaskAlreadyRegistered {
+		var createVolumeSpec = common.CreateVolumeSpec{
+			CapacityMB: volSizeMB,
+			Name:       req.Name,
+			ScParams:   scParams,
+			VolumeType: common.FileVolumeType,
+			SkipConfigNetPermissions: commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+				common.FileVolumeNodeACLs),
+		}
+		filterSuspendedDatastores := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CnsMgrSuspendCreateVolume)
+		// Iterate through each VC and its accessibility requirements to try and create a volume.
+		// If it fails for any reason, move unto the next VC in list. The vCenters are tried in
+		// the order of their host names, so that the volume is created on the first one.
+		vcHosts := make([]string, 0, len(vcTopologySegmentsMap))
+		for vcHost := range vcTopologySegmentsMap {
+			vcHosts = append(vcHosts, vcHost)
+		}
+		sort.Strings(vcHosts)
+		for _, vcHost = range vcHosts {
+			topologySegmentsList := vcTopologySegmentsMap[vcHost]
+			if err := c.managers.VcenterManager.CheckFeatureSupported(ctx, vcHost,
+				cnsvsphere.FeatureFileVolume); cnsvsphere.IsFeatureNotSupportedError(err) {
+				log.Warn(err)
+				combinedErrMssgs = append(combinedErrMssgs, err.Error())
+				unsupportedVCs++
+				continue
+			} else if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to verify if vSAN file services is supported or not on vCenter %q. Error:%+v",
+					vcHost, err)
+			}
+			vcenter, err = common.GetVCenterFromVCHost(ctx, c.managers.VcenterManager, vcHost)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to get vCenter instance for host %q. Error: %+v", vcHost, err)
+			}
+			var filteredDatastores []*cnsvsphere.DatastoreInfo
+			if authMgr, ok := c.authMgrs[vcHost]; ok {
+				for _, datastores := range authMgr.GetFsEnabledClusterToDsMap(ctx) {
+					filteredDatastores = append(filteredDatastores, datastores...)
+				}
+			}
+			if len(filteredDatastores) == 0 {
+				errMsg := fmt.Sprintf("no datastores found to create file volume in vCenter %q, "+
+					"vsan file service may be disabled", vcHost)
+				log.Warn(errMsg)
+				combinedErrMssgs = append(combinedErrMssgs, errMsg)
+				faultType = csifault.CSIVSanFileServiceDisabledFault
+				continue
+			}
+			if topologyRequirement != nil {
+				// Get shared accessible datastores for topology segments associated with the vcHost.
+				sharedDatastores, err := placementengine.GetSharedDatastores(ctx,
+					placementengine.VanillaSharedDatastoresParams{
+						Vcenter:              vcenter,
+						TopologySegmentsList: topologySegmentsList,
+					})
+				if err != nil {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+						"failed to get shared datastores for topology segments %+v in vCenter %q. Error: %+v",
+						topologySegmentsList, vcHost, err)
+				}
+				filteredDatastores = filterDatastoresByURL(filteredDatastores, sharedDatastores)
+				if len(filteredDatastores) == 0 {
+					errMsg := fmt.Sprintf("no vsan file service enabled datastores found for accessibility "+
+						"requirements %+v pertaining to vCenter %q", topologySegmentsList, vcHost)
+					log.Warn(errMsg)
+					combinedErrMssgs = append(combinedErrMssgs, errMsg)
+					continue
+				}
+			}
+			volumeMgr, err = GetVolumeManagerFromVCHost(ctx, c.managers, vcHost)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
 			}
 			volumeID, faultType, err = common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
-				vc, c.manager.VolumeManager, c.manager.CnsConfig, &createVolumeSpec,
+				vcenter, volumeMgr, c.managers.CnsConfig, &createVolumeSpec,
 				filteredDatastores, filterSuspendedDatastores, false, checkCompatibleDataStores)
 			if err != nil {
-				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
-					"failed to create volume. Error: %+v", err)
+				log.Error(err)
+				combinedErrMssgs = append(combinedErrMssgs, err.Error())
+				continue
 			}
+			log.Infof("file volume %q created in vCenter %q", volumeID, vcHost)
+			break
+		}
+	}
+	if volumeID == "" {
+		if unsupportedVCs != 0 && unsupportedVCs == len(vcTopologySegmentsMap) {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+				"file volumes are not supported by any of the vCenters. Errors encountered: %+v", combinedErrMssgs)
+		}
+		if faultType == "" {
+			faultType = csifault.CSIInternalFault
+		}
+		return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create file volume. Errors encountered: %+v", combinedErrMssgs)
+	}
+	if vcenter == nil {
+		vcenter, err = common.GetVCenterFromVCHost(ctx, c.managers.VcenterManager, vcHost)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get vCenter instance for host %q. Error: %+v", vcHost, err)
+		}
+	}
+	if volumeMgr == nil {
+		volumeMgr, err = GetVolumeManagerFromVCHost(ctx, c.managers, vcHost)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
 		}
 	}
 
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeFileVolume
+	netPerms := common.GetFileVolumeNetPermissions(scParams,
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolumeNodeACLs), c.managers.CnsConfig)
+	if fileShareConfig := getFileShareConfig(scParams, netPerms); fileShareConfig != nil {
+		authMgr, ok := c.authMgrs[vcHost]
+		if !ok {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get auth manager for vCenter %q", vcHost)
+		}
+		err = configureNewFileShare(ctx, vcenter, volumeMgr, authMgr.GetFsEnabledClusterToDsMap(ctx),
+			req.Name, volumeID, *fileShareConfig)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to reconfigure file share of file volume %q in vCenter %q with %+v. Error: %+v",
+				volumeID, vcHost, *fileShareConfig, err)
+		}
+	}
+	if scParams.NetPermissions != nil {
+		attributes[common.AttributeNetPermissionRootSquash] = strconv.FormatBool(scParams.NetPermissions.RootSquash)
+	}
+	if scParams.NfsSecurityType != "" {
+		attributes[common.AttributeNfsSecurityType] = scParams.NfsSecurityType
+	}
+	if scParams.NfsVersion != "" {
+		attributes[common.AttributeNfsVersion] = scParams.NfsVersion
+	}
+	if scParams.FileProtocol != "" {
+		attributes[common.AttributeFileProtocol] = scParams.FileProtocol
+	}
 
 	resp := &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
@@ -1774,6 +2203,55 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 			VolumeContext: attributes,
 		},
 	}
+
+	// For topology aware provisioning, populate the topology segments parameter
+	// in the CreateVolumeResponse struct.
+	if topologyRequirement != nil {
+		queryFilter := cnstypes.CnsQueryFilter{
+			VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeID}},
+		}
+		querySelection := cnstypes.CnsQuerySelection{
+			Names: []string{string(cnstypes.QuerySelectionNameTypeDataStoreUrl)},
+		}
+		queryResult, err := utils.QueryVolumeUtil(ctx, volumeMgr, queryFilter, &querySelection, true)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"queryVolumeUtil failed for volumeID: %s in vCenter %q. Error: %+v", volumeID, vcHost, err)
+		}
+		if len(queryResult.Volumes) == 0 || queryResult.Volumes[0].DatastoreUrl == "" {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"queryVolumeUtil could not retrieve volume information for volume ID: %q in vCenter %q",
+				volumeID, vcHost)
+		}
+		datastoreURL := queryResult.Volumes[0].DatastoreUrl
+		// Retrieve datastore topology information from CSINodeTopology CRs.
+		allNodeVMs, err := c.nodeMgr.GetAllNodesByVC(ctx, vcHost)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to fetch VirtualMachines for the registered nodes in VC %q. Error: %v", vcHost, err)
+		}
+		datastoreAccessibleTopology, err := c.calculateAccessibleTopologiesForDatastore(ctx, vcenter,
+			vcTopologySegmentsMap[vcHost], allNodeVMs, datastoreURL)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to calculate accessible topologies for the datastore %q", datastoreURL)
+		}
+		// Add topology segments to the CreateVolumeResponse.
+		for _, topoSegments := range datastoreAccessibleTopology {
+			volumeTopology := &csi.Topology{
+				Segments: topoSegments,
+			}
+			resp.Volume.AccessibleTopology = append(resp.Volume.AccessibleTopology, volumeTopology)
+		}
+	}
+
+	// Create CNSVolumeInfo CR for the volume ID.
+	err = volumeInfoService.CreateVolumeInfo(ctx, volumeID, vcHost)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to store volumeID %q for vCenter %q in CNSVolumeInfo CR. Error: %+v",
+			volumeID, vcHost, err)
+	}
 	return resp, "", nil
 }
 
@@ -1792,6 +2270,11 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 	createVolumeInternal := func() (
 		*csi.CreateVolumeResponse, string, error) {
 		log.Infof("CreateVolume: called with args %+v", *req)
+		release, err := volumeOperations.Acquire(ctx, req.Name, "CreateVolume")
+		if err != nil {
+			return nil, csifault.CSIOperationInProgressFault, err
+		}
+		defer release()
 		// TODO: If the err is returned by invoking CNS API, then faultType should be
 		// populated by the underlying layer.
 		// If the request failed due to validate the request, "csi.fault.InvalidArgument" will be return.
@@ -1804,36 +2287,38 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 				"volume capability not supported. Err: %+v", err)
 		}
 		if common.IsFileVolumeRequest(ctx, volumeCapabilities) {
+			volumeType = prometheus.PrometheusFileVolumeType
+			if multivCenterCSITopologyEnabled && len(c.managers.VcenterConfigs) > 1 {
+				// In a multi-VC deployment, the accessibility requirements are used to
+				// pick the vCenter on which the file volume is created.
+				return c.createFileVolumeForMultiVC(ctx, req)
+			}
 			// Error out if TopologyRequirement is provided during file volume provisioning
-			// as this is not supported yet.
-			if req.GetAccessibilityRequirements() != nil {
+			// and topology aware file volumes are not enabled.
+			if req.GetAccessibilityRequirements() != nil &&
+				!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.TopologyAwareFileVolume) {
 				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCode(log, codes.InvalidArgument,
 					"volume topology feature for file volumes is not supported.")
 			}
-			volumeType = prometheus.PrometheusFileVolumeType
 			if multivCenterCSITopologyEnabled {
-				isvSANFileServicesSupported, err := c.managers.VcenterManager.IsvSANFileServicesSupported(ctx,
-					c.managers.CnsConfig.Global.VCenterIP)
-				if err != nil {
+				if err := c.managers.VcenterManager.CheckFeatureSupported(ctx, c.managers.CnsConfig.Global.VCenterIP,
+					cnsvsphere.FeatureFileVolume); cnsvsphere.IsFeatureNotSupportedError(err) {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
+						err.Error())
+				} else if err != nil {
 					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 						"failed to verify if vSAN file services is supported or not. Error:%+v", err)
 				}
-				if !isvSANFileServicesSupported {
-					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
-						"fileshare volume creation is not supported on vSAN 67u3 release")
-				}
 				return c.createFileVolume(ctx, req)
 			} else {
-				isvSANFileServicesSupported, err := c.manager.VcenterManager.IsvSANFileServicesSupported(ctx,
-					c.manager.VcenterConfig.Host)
-				if err != nil {
+				if err := c.manager.VcenterManager.CheckFeatureSupported(ctx, c.manager.VcenterConfig.Host,
+					cnsvsphere.FeatureFileVolume); cnsvsphere.IsFeatureNotSupportedError(err) {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
+						err.Error())
+				} else if err != nil {
 					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 						"failed to verify if vSAN file services is supported or not. Error:%+v", err)
 				}
-				if !isvSANFileServicesSupported {
-					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
-						"fileshare volume creation is not supported on vSAN 67u3 release")
-				}
 				return c.createFileVolume(ctx, req)
 			}
 		}
@@ -1889,6 +2374,11 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 			vCenterHost    string
 			vCenterManager cnsvsphere.VirtualCenterManager
 		)
+		release, err := volumeOperations.Acquire(ctx, req.VolumeId, "DeleteVolume")
+		if err != nil {
+			return nil, csifault.CSIOperationInProgressFault, err
+		}
+		defer release()
 
 		err = validateVanillaDeleteVolumeRequest(ctx, req)
 		if err != nil {
@@ -2018,13 +2508,18 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 	controllerPublishVolumeInternal := func() (
 		*csi.ControllerPublishVolumeResponse, string, error) {
 		log.Infof("ControllerPublishVolume: called with args %+v", *req)
+		release, err := acquirePublishOperation(ctx, req.VolumeId, req.NodeId, "ControllerPublishVolume")
+		if err != nil {
+			return nil, csifault.CSIOperationInProgressFault, err
+		}
+		defer release()
 		// TODO: If the err is returned by invoking CNS API, then faultType should be
 		// populated by the underlying layer.
 		// If the request failed due to validate the request, "csi.fault.InvalidArgument" will be return.
 		// If thr reqeust failed due to object not found, "csi.fault.NotFound" will be return.
 		// For all other cases, the faultType will be set to "csi.fault.Internal" for now.
 		// Later we may need to define different csi faults.
-		err := validateVanillaControllerPublishVolumeRequest(ctx, req)
+		err = validateVanillaControllerPublishVolumeRequest(ctx, req)
 		if err != nil {
 
 			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.Internal,
@@ -2062,18 +2557,42 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 			vSANFileBackingDetails :=
 				queryResult.Volumes[0].BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails)
 			publishInfo[common.AttributeDiskType] = common.DiskTypeFileVolume
-			nfsv4AccessPointFound := false
+			// The node picks one of the access points of the protocol the
+			// volume was created for from NfsAccessPoints. The first NFSv4.1
+			// access point is also set for older nodes.
+			accessPointKey := common.GetFileVolumeAccessPointKey(req.GetVolumeContext())
+			accessPointFound := false
 			for _, kv := range vSANFileBackingDetails.AccessPoints {
-				if kv.Key == common.Nfsv4AccessPointKey {
-					publishInfo[common.Nfsv4AccessPoint] = kv.Value
-					nfsv4AccessPointFound = true
+				if kv.Key == accessPointKey {
+					if accessPointKey == common.Nfsv4AccessPointKey {
+						publishInfo[common.Nfsv4AccessPoint] = kv.Value
+					}
+					accessPointFound = true
 					break
 				}
 			}
-			if !nfsv4AccessPointFound {
+			if !accessPointFound {
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-					"failed to get NFSv4 access point for volume: %q. Returned vSAN file backing details: %+v",
-					req.VolumeId, vSANFileBackingDetails)
+					"failed to get %s access point for volume: %q. Returned vSAN file backing details: %+v",
+					accessPointKey, req.VolumeId, vSANFileBackingDetails)
+			}
+			nfsAccessPoints, err := encodeNfsAccessPoints(vSANFileBackingDetails.AccessPoints)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to encode access points of volume: %q. Err: %v", req.VolumeId, err)
+			}
+			publishInfo[common.NfsAccessPoints] = nfsAccessPoints
+			// Access to SMB file shares is controlled by the SMB credentials.
+			if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolumeNodeACLs) &&
+				accessPointKey != common.SmbAccessPointKey {
+				readOnly := req.GetReadonly() || req.GetVolumeCapability().GetAccessMode().GetMode() ==
+					csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY
+				nodeIPs, err := c.grantFileVolumeAccessToNode(ctx, volumeManager, req.VolumeId, req.NodeId,
+					readOnly, c.isFileVolumeRootSquashRequested(req.GetVolumeContext()))
+				if err != nil {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+				}
+				publishInfo[common.FileVolumeNodeIPs] = strings.Join(nodeIPs, ",")
 			}
 		} else {
 			// Block Volume.
@@ -2105,6 +2624,11 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 						"failed to set keepAfterDeleteVm control flag for VolumeID %q", req.VolumeId)
 				}
+			} else {
+				err = c.protectBlockVolumeFromVMDeletion(ctx, volumeManager, req.VolumeId, req.VolumeContext)
+				if err != nil {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+				}
 			}
 			var nodevm *cnsvsphere.VirtualMachine
 			// if node is not yet updated to run the release of the driver publishing Node VM UUID as Node ID
@@ -2119,9 +2643,39 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 					"failed to find VirtualMachine for node:%q. Error: %v", req.NodeId, err)
 			}
 			log.Debugf("Found VirtualMachine for node:%q.", req.NodeId)
-			// faultType is returned from manager.AttachVolume.
-			diskUUID, faultType, err := common.AttachVolumeUtil(ctx, volumeManager, nodevm, req.VolumeId,
-				false)
+			var diskUUID, faultType string
+			if req.VolumeContext[common.AttributeDiskSharing] == common.DiskSharingMultiWriter {
+				// Volumes created with multi-writer disk sharing are attached to every
+				// node VM with the same sharing mode, regardless of the access mode.
+				diskUUID, faultType, err = common.AttachVolumeWithSharingUtil(ctx, volumeManager, nodevm,
+					req.VolumeId, types.VirtualDiskSharingSharingMultiWriter, types.VirtualDiskModeIndependent_persistent)
+			} else if req.GetVolumeCapability().GetBlock() != nil && req.GetVolumeCapability().GetAccessMode().GetMode() ==
+				csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY {
+				// ReadOnlyMany block volumes are attached to each node VM in
+				// independent non-persistent mode, which opens the backing disk
+				// read-only and discards any write done through the node.
+				diskUUID, faultType, err = common.AttachVolumeWithSharingUtil(ctx, volumeManager, nodevm,
+					req.VolumeId, types.VirtualDiskSharingSharingNone, types.VirtualDiskModeIndependent_nonpersistent)
+			} else if common.IsMultiNodeBlockVolumeRequest(ctx, []*csi.VolumeCapability{req.GetVolumeCapability()}) {
+				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+					"volume %q was not created with %q disk sharing and cannot be attached to multiple nodes",
+					req.VolumeId, common.DiskSharingMultiWriter)
+			} else if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.NodeVolumeLimits) {
+				diskUUID, faultType, err = common.AttachVolumeToLeastUsedControllerUtil(ctx, volumeManager, nodevm,
+					req.VolumeId, getMaxPVSCSIUnitNumbers(ctx))
+			} else {
+				// faultType is returned from manager.AttachVolume.
+				diskUUID, faultType, err = common.AttachVolumeUtil(ctx, volumeManager, nodevm, req.VolumeId,
+					false)
+			}
+			if err != nil && faultType == csifault.CSIDiskControllerSlotsExhaustedFault {
+				return nil, faultType, logger.LogNewErrorCodef(log, codes.ResourceExhausted,
+					"failed to attach disk: %+q with node: %q err %+v", req.VolumeId, req.NodeId, err)
+			}
+			if err != nil && faultType == csifault.CSIDiskNotEagerZeroedFault {
+				return nil, faultType, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+					"failed to attach disk: %+q with node: %q err %+v", req.VolumeId, req.NodeId, err)
+			}
 			if err != nil {
 				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to attach disk: %+q with node: %q err %+v", req.VolumeId, req.NodeId, err)
@@ -2166,13 +2720,18 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 		*csi.ControllerUnpublishVolumeResponse, string, error) {
 		var faultType string
 		log.Infof("ControllerUnpublishVolume: called with args %+v", *req)
+		release, err := acquirePublishOperation(ctx, req.VolumeId, req.NodeId, "ControllerUnpublishVolume")
+		if err != nil {
+			return nil, csifault.CSIOperationInProgressFault, err
+		}
+		defer release()
 		// TODO: If the err is returned by invoking CNS API, then faultType should be
 		// populated by the underlying layer.
 		// If the request failed due to validate the request, "csi.fault.InvalidArgument" will be return.
 		// If thr reqeust failed due to object not found, "csi.fault.NotFound" will be return.
 		// For all other cases, the faultType will be set to "csi.fault.Internal" for now.
 		// Later we may need to define different csi faults.
-		err := validateVanillaControllerUnpublishVolumeRequest(ctx, req)
+		err = validateVanillaControllerUnpublishVolumeRequest(ctx, req)
 		if err != nil {
 			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"validation for UnpublishVolume Request: %+v has failed. Error: %v", *req, err)
@@ -2209,6 +2768,13 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 			}
 			if queryResult.Volumes[0].VolumeType == common.FileVolumeType {
 				volumeType = prometheus.PrometheusFileVolumeType
+				if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolumeNodeACLs) {
+					err = c.revokeFileVolumeAccessFromNode(ctx, volumeManager, req.VolumeId, req.NodeId)
+					if err != nil {
+						return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+					}
+					return &csi.ControllerUnpublishVolumeResponse{}, "", nil
+				}
 				log.Infof("Skipping ControllerUnpublish for file volume %q", req.VolumeId)
 				return &csi.ControllerUnpublishVolumeResponse{}, "", nil
 			}
@@ -2309,6 +2875,11 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 		)
 
 		log.Infof("ControllerExpandVolume: called with args %+v", *req)
+		release, err := volumeOperations.Acquire(ctx, req.VolumeId, "ControllerExpandVolume")
+		if err != nil {
+			return nil, csifault.CSIOperationInProgressFault, err
+		}
+		defer release()
 		// TODO: If the err is returned by invoking CNS API, then faultType should be
 		// populated by the underlying layer.
 		// If the request failed due to validate the request, "csi.fault.InvalidArgument" will be return.
@@ -2593,13 +3164,6 @@ func (c *controller) processQueryResultsListVolumes(ctx context.Context, startin
 
 	for i := startingToken; i < len(cnsVolumes); i++ {
 		if cnsVolumes[i].VolumeType == common.FileVolumeType {
-			// If this is multi-VC configuration, then
-			// skip processing query results for file volumes
-			if multivCenterCSITopologyEnabled && len(c.managers.VcenterConfigs) > 1 {
-				log.Debugf("Skipping processing for file volume %v in multi-VC configuration", cnsVolumes[i].Name)
-				continue
-			}
-
 			volumeType = prometheus.PrometheusFileVolumeType
 			fileVolID := cnsVolumes[i].VolumeId.Id
 
@@ -2640,7 +3204,7 @@ func (c *controller) processQueryResultsListVolumes(ctx context.Context, startin
 		} else {
 			volumeType = prometheus.PrometheusBlockVolumeType
 			blockVolID := cnsVolumes[i].VolumeId.Id
-			nodeVMUUID, found := volumeIDToNodeUUIDMap[blockVolID]
+			nodeVMUUIDs, found := volumeIDToNodeUUIDMap[blockVolID]
 			if found {
 				volCounter += 1
 				volumeId := blockVolID
@@ -2661,7 +3225,7 @@ func (c *controller) processQueryResultsListVolumes(ctx context.Context, startin
 				}
 				// Getting published nodes
 				volStatus := &csi.ListVolumesResponse_VolumeStatus{
-					PublishedNodeIds: []string{nodeVMUUID},
+					PublishedNodeIds: nodeVMUUIDs,
 				}
 				entry := &csi.ListVolumesResponse_Entry{
 					Volume: blockVolumeInfo,
@@ -2777,6 +3341,18 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 		granularMaxSnapshotsPerBlockVolumeInVVOL int
 	)
 	log.Infof("CreateSnapshot: called with args %+v", *req)
+	release, err := volumeOperations.Acquire(ctx, req.Name, "CreateSnapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	// The source volume is held shared, so that snapshotting it conflicts
+	// with ControllerExpandVolume and DeleteVolume of the volume.
+	releaseVolume, err := volumeOperations.AcquireShared(ctx, req.GetSourceVolumeId(), "CreateSnapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer releaseVolume()
 
 	isBlockVolumeSnapshotEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot)
 	if !isBlockVolumeSnapshotEnabled {
@@ -2792,15 +3368,13 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 			"failed to get vCenter/volume manager for volume Id: %q. Error: %v", volumeID, err)
 	}
 
-	isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vCenterHost)
-	if err != nil {
+	if err := vCenterManager.CheckFeatureSupported(ctx, vCenterHost,
+		cnsvsphere.FeatureBlockVolumeSnapshot); cnsvsphere.IsFeatureNotSupportedError(err) {
+		return nil, logger.LogNewErrorCode(log, codes.Unimplemented, err.Error())
+	} else if err != nil {
 		return nil, logger.LogNewErrorCodef(log, codes.Internal,
 			"failed to check if cns snapshot is supported on VC due to error: %v", err)
 	}
-	if !isCnsSnapshotSupported {
-		return nil, logger.LogNewErrorCode(log, codes.Unimplemented,
-			"VC version does not support snapshot operations")
-	}
 	volumeType := prometheus.PrometheusUnknownVolumeType
 	createSnapshotInternal := func() (*csi.CreateSnapshotResponse, error) {
 		// Validate CreateSnapshotRequest
@@ -2938,6 +3512,11 @@ func (c *controller) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshot
 		err            error
 	)
 	log.Infof("DeleteSnapshot: called with args %+v", *req)
+	release, err := volumeOperations.Acquire(ctx, req.SnapshotId, "DeleteSnapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	isBlockVolumeSnapshotEnabled :=
 		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot)
@@ -2957,15 +3536,13 @@ func (c *controller) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshot
 			"failed to get vCenter/volume manager for snapshot Id: %q. Error: %v", req.SnapshotId, err)
 	}
 
-	isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vCenterHost)
-	if err != nil {
+	if err := vCenterManager.CheckFeatureSupported(ctx, vCenterHost,
+		cnsvsphere.FeatureBlockVolumeSnapshot); cnsvsphere.IsFeatureNotSupportedError(err) {
+		return nil, logger.LogNewErrorCode(log, codes.Unimplemented, err.Error())
+	} else if err != nil {
 		return nil, logger.LogNewErrorCodef(log, codes.Internal,
 			"failed to check if cns snapshot is supported on VC due to error: %v", err)
 	}
-	if !isCnsSnapshotSupported {
-		return nil, logger.LogNewErrorCode(log, codes.Unimplemented,
-			"VC version does not support snapshot operations")
-	}
 
 	deleteSnapshotInternal := func() (*csi.DeleteSnapshotResponse, error) {
 		csiSnapshotID := req.GetSnapshotId()
@@ -3040,15 +3617,13 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 					"failed to get vCenter/volume manager for volume Id: %q in VC %s. Error: %v", volID, vCenterHost, err)
 			}
 			// Check for snapshot support
-			isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vCenterHost)
-			if err != nil {
+			if err := vCenterManager.CheckFeatureSupported(ctx, vCenterHost,
+				cnsvsphere.FeatureBlockVolumeSnapshot); cnsvsphere.IsFeatureNotSupportedError(err) {
+				return nil, logger.LogNewErrorCode(log, codes.Unimplemented, err.Error())
+			} else if err != nil {
 				return nil, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to check if cns snapshot is supported on VC %s due to error: %v", vCenterHost, err)
 			}
-			if !isCnsSnapshotSupported {
-				return nil, logger.LogNewErrorCodef(log, codes.Unimplemented,
-					"VC %s version does not support snapshot operations", vCenterHost)
-			}
 			snapshots, nextToken, err = common.ListSnapshotsUtil(ctx, volManager, req.SourceVolumeId,
 				req.SnapshotId, req.StartingToken, maxEntries)
 			if err != nil {
@@ -3063,15 +3638,13 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 					"failed to get vCenter/volume manager for volume Id: %q in VC %s. Error: %v", req.SourceVolumeId, vCenterHost, err)
 			}
 			// Check for snapshot support
-			isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vCenterHost)
-			if err != nil {
+			if err := vCenterManager.CheckFeatureSupported(ctx, vCenterHost,
+				cnsvsphere.FeatureBlockVolumeSnapshot); cnsvsphere.IsFeatureNotSupportedError(err) {
+				return nil, logger.LogNewErrorCode(log, codes.Unimplemented, err.Error())
+			} else if err != nil {
 				return nil, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to check if cns snapshot is supported on VC %s due to error: %v", vCenterHost, err)
 			}
-			if !isCnsSnapshotSupported {
-				return nil, logger.LogNewErrorCodef(log, codes.Unimplemented,
-					"VC %s version does not support snapshot operations", vCenterHost)
-			}
 			snapshots, nextToken, err = common.ListSnapshotsUtil(ctx, volManager, req.SourceVolumeId,
 				req.SnapshotId, req.StartingToken, maxEntries)
 			if err != nil {
@@ -3124,15 +3697,13 @@ func getSnapshotsAndSourceVolumeDetails(ctx context.Context, vCenterManager cnsv
 	)
 	log := logger.GetLogger(ctx)
 	// Check for snapshot support
-	isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vcHost)
-	if err != nil {
+	if err := vCenterManager.CheckFeatureSupported(ctx, vcHost,
+		cnsvsphere.FeatureBlockVolumeSnapshot); cnsvsphere.IsFeatureNotSupportedError(err) {
+		return nil, nil, logger.LogNewErrorCode(log, codes.Unimplemented, err.Error())
+	} else if err != nil {
 		return nil, nil, logger.LogNewErrorCodef(log, codes.Internal,
 			"failed to check if cns snapshot is supported on VC %s due to error: %v", vcHost, err)
 	}
-	if !isCnsSnapshotSupported {
-		return nil, nil, logger.LogNewErrorCodef(log, codes.Unimplemented,
-			"VC %s version does not support snapshot operations", vcHost)
-	}
 	// fetch all snapshot records for each VC
 	snapshotQueryFilter = cnstypes.CnsSnapshotQueryFilter{
 		Cursor: &cnstypes.CnsCursor{
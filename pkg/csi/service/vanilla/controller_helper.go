@@ -18,25 +18,34 @@ package vanilla
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	vsantypes "github.com/vmware/govmomi/vsan/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
 // validateVanillaDeleteVolumeRequest is the helper function to validate
@@ -135,6 +144,100 @@ func validateVanillaListSnapshotRequest(ctx context.Context, req *csi.ListSnapsh
 	return nil
 }
 
+// validateVanillaBlockVolumeParams is the helper function to validate the
+// disk sharing requested in the StorageClass against the volume capabilities
+// of a block volume CreateVolumeRequest. It also rejects StorageClass
+// parameters which only apply to file volumes.
+func validateVanillaBlockVolumeParams(ctx context.Context, volCaps []*csi.VolumeCapability,
+	scParams *common.StorageClassParams) error {
+	log := logger.GetLogger(ctx)
+	if common.IsMultiNodeWriterBlockVolumeRequest(ctx, volCaps) &&
+		scParams.DiskSharing != common.DiskSharingMultiWriter {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"multi-node write access to block volumes requires StorageClass parameter %q set to %q",
+			common.AttributeDiskSharing, common.DiskSharingMultiWriter)
+	}
+	if scParams.DiskSharing != "" && scParams.CSIMigration == "true" {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"StorageClass parameter %q is not supported for in-tree migrated volumes", common.AttributeDiskSharing)
+	}
+	if scParams.NfsSecurityType != "" {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"StorageClass parameter %q is only supported for file volumes", common.AttributeNfsSecurityType)
+	}
+	if scParams.NfsVersion != "" {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"StorageClass parameter %q is only supported for file volumes", common.AttributeNfsVersion)
+	}
+	if scParams.FileProtocol != "" {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"StorageClass parameter %q is only supported for file volumes", common.AttributeFileProtocol)
+	}
+	if scParams.NetPermissions != nil {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"StorageClass parameters %q, %q and %q are only supported for file volumes",
+			common.AttributeNetPermissionIps, common.AttributeNetPermissionAccess,
+			common.AttributeNetPermissionRootSquash)
+	}
+	return nil
+}
+
+// validateVanillaFileVolumeParams is the helper function to validate the
+// StorageClass parameters against the volume capabilities of a file volume
+// CreateVolumeRequest. It rejects the disk sharing, which only applies to block
+// volumes. SMB file volumes cannot be published read-only on windows nodes, so
+// read-only access modes are rejected for them.
+func validateVanillaFileVolumeParams(ctx context.Context, volCaps []*csi.VolumeCapability,
+	scParams *common.StorageClassParams) error {
+	log := logger.GetLogger(ctx)
+	if scParams.DiskSharing != "" {
+		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"StorageClass parameter %q is only supported for block volumes", common.AttributeDiskSharing)
+	}
+	if scParams.FileProtocol != common.FileProtocolSMB {
+		return nil
+	}
+	for _, volCap := range volCaps {
+		if common.IsVolumeReadOnly(volCap) {
+			return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"access mode %q is not supported with StorageClass parameter %q set to %q",
+				volCap.GetAccessMode().GetMode(), common.AttributeFileProtocol, common.FileProtocolSMB)
+		}
+	}
+	return nil
+}
+
+// getClusterForDatastoreURL returns the moref value of the cluster from the
+// given cluster to datastores map which contains the datastore with the given URL.
+func getClusterForDatastoreURL(clusterToDsMap map[string][]*vsphere.DatastoreInfo,
+	datastoreURL string) string {
+	for cluster, datastores := range clusterToDsMap {
+		for _, datastore := range datastores {
+			if datastore.Info.Url == datastoreURL {
+				return cluster
+			}
+		}
+	}
+	return ""
+}
+
+// filterDatastoresByURL returns the datastores from the given list whose URL
+// is also present in allowedDatastores.
+func filterDatastoresByURL(datastores []*vsphere.DatastoreInfo,
+	allowedDatastores []*vsphere.DatastoreInfo) []*vsphere.DatastoreInfo {
+	allowedDatastoreURLs := make(map[string]bool)
+	for _, allowedDatastore := range allowedDatastores {
+		allowedDatastoreURLs[allowedDatastore.Info.Url] = true
+	}
+	var filteredDatastores []*vsphere.DatastoreInfo
+	for _, datastore := range datastores {
+		if allowedDatastoreURLs[datastore.Info.Url] {
+			filteredDatastores = append(filteredDatastores, datastore)
+		}
+	}
+	return filteredDatastores
+}
+
 func convertCnsVolumeType(ctx context.Context, cnsVolumeType string) string {
 	volumeType := prometheus.PrometheusUnknownVolumeType
 	if cnsVolumeType == common.BlockVolumeType {
@@ -146,13 +249,14 @@ func convertCnsVolumeType(ctx context.Context, cnsVolumeType string) string {
 }
 
 func getBlockVolumeIDToNodeUUIDMap(ctx context.Context, c *controller,
-	allnodeVMs []*vsphere.VirtualMachine) (map[string]string, error) {
+	allnodeVMs []*vsphere.VirtualMachine) (map[string][]string, error) {
 	var vCenters []*vsphere.VirtualCenter
 	var err error
 
 	log := logger.GetLogger(ctx)
 	log.Debugf("getBlockVolumeIDToNodeUUIDMap called for Node VMs: %+v", allnodeVMs)
-	volumeIDNodeUUIDMap := make(map[string]string)
+	// Shared raw block volumes can be attached to several node VMs.
+	volumeIDNodeUUIDMap := make(map[string][]string)
 	// Get VirtualCenter object(s)
 	// For multi-VC configuration, create map for volumes in all vCenters
 	if multivCenterCSITopologyEnabled {
@@ -207,7 +311,8 @@ func getBlockVolumeIDToNodeUUIDMap(ctx context.Context, c *controller,
 				if vmDevices.TypeName(device) == "VirtualDisk" {
 					if virtualDisk, ok := device.(*types.VirtualDisk); ok {
 						if virtualDisk.VDiskId != nil {
-							volumeIDNodeUUIDMap[virtualDisk.VDiskId.Id] = info.Config.Uuid
+							volumeIDNodeUUIDMap[virtualDisk.VDiskId.Id] = append(
+								volumeIDNodeUUIDMap[virtualDisk.VDiskId.Id], info.Config.Uuid)
 						}
 					}
 				}
@@ -295,3 +400,335 @@ func GetVolumeManagerFromVCHost(ctx context.Context, managers *common.Managers,
 	}
 	return volumeMgr, nil
 }
+
+// getFileShareConfig returns the vSAN file share config for the protocol, NFS
+// version and security type requested in the StorageClass, which CNS does not
+// set when creating the file share. As Kerberos file shares are created
+// without net permissions, the given net permissions are set along with their
+// security type. nil is returned if the file share created by CNS needs no
+// change.
+func getFileShareConfig(scParams *common.StorageClassParams,
+	netPerms []vsanfstypes.VsanFileShareNetPermission) *vsantypes.VsanFileShareConfig {
+	var config *vsantypes.VsanFileShareConfig
+	if scParams.FileProtocol == common.FileProtocolSMB {
+		config = &vsantypes.VsanFileShareConfig{
+			Protocols: []string{string(vsantypes.VsanFileProtocolSMB)},
+		}
+	} else if scParams.NfsVersion == common.NfsVersion3 {
+		config = &vsantypes.VsanFileShareConfig{
+			Protocols: []string{string(vsantypes.VsanFileProtocolNFSv3)},
+		}
+	}
+	if common.IsKerberosNfsSecurityType(scParams.NfsSecurityType) {
+		if config == nil {
+			config = &vsantypes.VsanFileShareConfig{}
+		}
+		config.NfsSecType = strings.ToUpper(scParams.NfsSecurityType)
+		for _, netPerm := range netPerms {
+			allowRoot := netPerm.AllowRoot
+			config.Permission = append(config.Permission, vsantypes.VsanFileShareNetPermission{
+				Ips:         netPerm.Ips,
+				Permissions: string(netPerm.Permissions),
+				AllowRoot:   &allowRoot,
+			})
+		}
+	}
+	return config
+}
+
+// reconfigureFileShareFunc is the function used by configureNewFileShare to
+// reconfigure the file share of a newly created file volume.
+var reconfigureFileShareFunc = reconfigureFileShare
+
+// configureNewFileShare applies the given config on the vSAN file share
+// backing the newly created file volume with the given name. If the file share
+// cannot be reconfigured, the volume is deleted along with the persisted
+// details of the CreateVolume operation, so that a retried CreateVolume
+// creates the volume again instead of returning it without the config.
+func configureNewFileShare(ctx context.Context, vc *vsphere.VirtualCenter,
+	volumeManager cnsvolume.Manager, clusterToDsMap map[string][]*vsphere.DatastoreInfo,
+	volumeName string, volumeID string, config vsantypes.VsanFileShareConfig) error {
+	log := logger.GetLogger(ctx)
+	err := reconfigureFileShareFunc(ctx, vc, volumeManager, clusterToDsMap, volumeID, config)
+	if err == nil {
+		return nil
+	}
+	log.Infof("Deleting file volume %q as its file share could not be reconfigured", volumeID)
+	if _, delErr := volumeManager.DeleteVolume(ctx, volumeID, true); delErr != nil {
+		return logger.LogNewErrorf(log, "failed to reconfigure file share of file volume %q. Error: %+v. "+
+			"Deleting the volume also failed. Error: %+v", volumeID, err, delErr)
+	}
+	if operationStore := volumeManager.GetOperationStore(); operationStore != nil {
+		if delErr := operationStore.DeleteRequestDetails(ctx, volumeName); delErr != nil {
+			log.Warnf("failed to delete CreateVolume details of file volume %q. Error: %+v", volumeName, delErr)
+		}
+	}
+	return err
+}
+
+// reconfigureFileShare applies the given config on the vSAN file share
+// backing the given file volume. The cluster of the file share is looked up
+// from the given file services enabled cluster to datastores map.
+func reconfigureFileShare(ctx context.Context, vc *vsphere.VirtualCenter,
+	volumeManager cnsvolume.Manager, clusterToDsMap map[string][]*vsphere.DatastoreInfo,
+	volumeID string, config vsantypes.VsanFileShareConfig) error {
+	log := logger.GetLogger(ctx)
+	queryFilter := cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeID}},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{string(cnstypes.QuerySelectionNameTypeDataStoreUrl)},
+	}
+	queryResult, err := utils.QueryVolumeUtil(ctx, volumeManager, queryFilter, &querySelection, true)
+	if err != nil {
+		return logger.LogNewErrorf(log, "queryVolumeUtil failed for volumeID: %s, err: %+v", volumeID, err)
+	}
+	if len(queryResult.Volumes) == 0 || queryResult.Volumes[0].DatastoreUrl == "" {
+		return logger.LogNewErrorf(log,
+			"queryVolumeUtil could not retrieve volume information for volume ID: %q", volumeID)
+	}
+	datastoreURL := queryResult.Volumes[0].DatastoreUrl
+	cluster := getClusterForDatastoreURL(clusterToDsMap, datastoreURL)
+	if cluster == "" {
+		return logger.LogNewErrorf(log, "failed to find vsan file services enabled cluster for datastore %q",
+			datastoreURL)
+	}
+	clusterMoref := types.ManagedObjectReference{
+		Type:  "ClusterComputeResource",
+		Value: cluster,
+	}
+	return vc.ReconfigureFileShare(ctx, clusterMoref,
+		strings.TrimPrefix(volumeID, cnsvolumeinfo.FileVolumePrefix), config)
+}
+
+// fileVolumeNodeIPs holds the node IPs granted access to file volumes, keyed
+// by "<volumeID>/<nodeID>", so that the access can be revoked even when the
+// node VM is gone by the time ControllerUnpublishVolume is called.
+var fileVolumeNodeIPs sync.Map
+
+// volumeOperations tracks the operations in progress on volumes and snapshots,
+// so that conflicting concurrent operations, for example retried by the
+// sidecars after a timeout, are aborted instead of running in CNS.
+var volumeOperations common.InFlightOperations
+
+// publishOperationKey returns the key of volumeOperations for publishing the
+// given volume to the given node. Publishing a volume to a node does not
+// conflict with publishing it to other nodes, e.g. for multi-node volumes.
+func publishOperationKey(volumeID string, nodeID string) string {
+	if volumeID == "" || nodeID == "" {
+		return ""
+	}
+	return volumeID + "/" + nodeID
+}
+
+// acquirePublishOperation marks the given publish or unpublish operation of
+// the given volume on the given node as in progress, and returns the func
+// releasing it. The volume is held shared, so that the operation conflicts
+// with ControllerExpandVolume and DeleteVolume of the volume but not with
+// publishing it to other nodes, and the volume on the node exclusively.
+func acquirePublishOperation(ctx context.Context, volumeID string, nodeID string,
+	operation string) (func(), error) {
+	releaseVolume, err := volumeOperations.AcquireShared(ctx, volumeID, operation)
+	if err != nil {
+		return nil, err
+	}
+	releaseNode, err := volumeOperations.Acquire(ctx, publishOperationKey(volumeID, nodeID), operation)
+	if err != nil {
+		releaseVolume()
+		return nil, err
+	}
+	return func() {
+		releaseNode()
+		releaseVolume()
+	}, nil
+}
+
+// getNodeVM returns the node VM for the given node ID, which is either the
+// node name or the node VM UUID.
+func (c *controller) getNodeVM(ctx context.Context, nodeID string) (*vsphere.VirtualMachine, error) {
+	log := logger.GetLogger(ctx)
+	nodevm, err := c.nodeMgr.GetNodeVMByNameOrUUID(ctx, nodeID)
+	if err == node.ErrNodeNotFound {
+		log.Infof("Performing node VM lookup using node VM UUID: %q", nodeID)
+		nodevm, err = c.nodeMgr.GetNodeVMByUuid(ctx, nodeID)
+	}
+	return nodevm, err
+}
+
+// getNodeFileShareIPs returns the IPs of the given node to grant access to
+// file volumes for, which are the InternalIP addresses of the Kubernetes node
+// reported by VMware Tools for the node VM. The node ID is either the node
+// name or the node VM UUID.
+func (c *controller) getNodeFileShareIPs(ctx context.Context, nodeID string) ([]string, error) {
+	log := logger.GetLogger(ctx)
+	k8sNode, err := c.nodeMgr.GetK8sNode(ctx, nodeID)
+	if apierrors.IsNotFound(err) {
+		nodeName, nameErr := c.nodeMgr.GetNodeNameByUUID(ctx, nodeID)
+		if nameErr == nil && nodeName != "" {
+			k8sNode, err = c.nodeMgr.GetK8sNode(ctx, nodeName)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	nodevm, err := c.getNodeVM(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	internalIPs := k8s.GetNodeInternalIPs(k8sNode)
+	nodeIPs, err := nodevm.GetGuestIPAddresses(ctx, internalIPs)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Node %q has InternalIP addresses %v, of which %v are reported by VMware Tools",
+		nodeID, internalIPs, nodeIPs)
+	return nodeIPs, nil
+}
+
+// grantFileVolumeAccessToNode adds net permissions to the given file volume
+// for the IPs of the given node, and returns the IPs granted access.
+func (c *controller) grantFileVolumeAccessToNode(ctx context.Context, volumeManager cnsvolume.Manager,
+	volumeID string, nodeID string, readOnly bool, rootSquash bool) ([]string, error) {
+	log := logger.GetLogger(ctx)
+	nodeIPs, err := c.getNodeFileShareIPs(ctx, nodeID)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get IP addresses of node: %q. Error: %v", nodeID, err)
+	}
+	if len(nodeIPs) == 0 {
+		return nil, logger.LogNewErrorf(log, "no InternalIP addresses of node %q are reported by VMware Tools "+
+			"for its VirtualMachine", nodeID)
+	}
+	aclSpec := common.GetFileVolumeNodeACLSpec(volumeID, nodeIPs, readOnly, rootSquash, false)
+	err = volumeManager.ConfigureVolumeACLs(ctx, aclSpec)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to grant access to file volume %q for node %q with IPs %v. "+
+			"Error: %v", volumeID, nodeID, nodeIPs, err)
+	}
+	fileVolumeNodeIPs.Store(volumeID+"/"+nodeID, nodeIPs)
+	log.Infof("Granted access to file volume %q for node %q with IPs %v", volumeID, nodeID, nodeIPs)
+	return nodeIPs, nil
+}
+
+// getGrantedFileVolumeNodeIPs returns the IPs granted access to the given file
+// volume for the given node at publish time. They are remembered in memory,
+// and recorded in the publish context of the VolumeAttachment to be read back
+// after a controller restart.
+func getGrantedFileVolumeNodeIPs(ctx context.Context, volumeID string, nodeID string) []string {
+	if ips, ok := fileVolumeNodeIPs.Load(volumeID + "/" + nodeID); ok {
+		return ips.([]string)
+	}
+	volumeAttachment, err := commonco.ContainerOrchestratorUtility.GetVolumeAttachment(ctx, volumeID, nodeID)
+	if err != nil || volumeAttachment == nil {
+		return nil
+	}
+	ips := volumeAttachment.Status.AttachmentMetadata[common.FileVolumeNodeIPs]
+	if ips == "" {
+		return nil
+	}
+	return strings.Split(ips, ",")
+}
+
+// revokeFileVolumeAccessFromNode removes the net permissions of the given file
+// volume for the IPs of the given node: the IPs granted at publish time, and
+// the current IPs of the node in case they have changed since.
+func (c *controller) revokeFileVolumeAccessFromNode(ctx context.Context, volumeManager cnsvolume.Manager,
+	volumeID string, nodeID string) error {
+	log := logger.GetLogger(ctx)
+	nodeIPs := getGrantedFileVolumeNodeIPs(ctx, volumeID, nodeID)
+	currentIPs, err := c.getNodeFileShareIPs(ctx, nodeID)
+	if err != nil {
+		if err != vsphere.ErrVMNotFound && !apierrors.IsNotFound(err) {
+			return logger.LogNewErrorf(log, "failed to get IP addresses of node: %q. Error: %v", nodeID, err)
+		}
+		if len(nodeIPs) == 0 {
+			log.Warnf("Node %q or its VirtualMachine no longer exists and the IPs granted access to file "+
+				"volume %q are not known. Net permissions granted to it, if any, are left in place.",
+				nodeID, volumeID)
+			return nil
+		}
+	}
+	granted := make(map[string]bool)
+	for _, ip := range nodeIPs {
+		granted[ip] = true
+	}
+	for _, ip := range currentIPs {
+		if !granted[ip] {
+			nodeIPs = append(nodeIPs, ip)
+		}
+	}
+	if len(nodeIPs) != 0 {
+		aclSpec := common.GetFileVolumeNodeACLSpec(volumeID, nodeIPs, false, false, true)
+		err := volumeManager.ConfigureVolumeACLs(ctx, aclSpec)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to revoke access to file volume %q for node %q with IPs %v. "+
+				"Error: %v", volumeID, nodeID, nodeIPs, err)
+		}
+	}
+	fileVolumeNodeIPs.Delete(volumeID + "/" + nodeID)
+	log.Infof("Revoked access to file volume %q for node %q with IPs %v", volumeID, nodeID, nodeIPs)
+	return nil
+}
+
+// isProtectFromVMDeletionRequested returns true if the block volume with the
+// given volume context is to be protected from being deleted along with the
+// node VM it is attached to, as set in the StorageClass or the driver config.
+func (c *controller) isProtectFromVMDeletionRequested(volumeContext map[string]string) bool {
+	cfg := c.manager.CnsConfig
+	if multivCenterCSITopologyEnabled {
+		cfg = c.managers.CnsConfig
+	}
+	return common.IsProtectFromVMDeletionRequested(volumeContext, cfg.Global.ProtectVolumesFromVMDeletion)
+}
+
+// isFileVolumeRootSquashRequested returns true if root access is to be
+// squashed for the nodes granted access to the file volume with the given
+// volume context, as set in the StorageClass or the driver config.
+func (c *controller) isFileVolumeRootSquashRequested(volumeContext map[string]string) bool {
+	cfg := c.manager.CnsConfig
+	if multivCenterCSITopologyEnabled {
+		cfg = c.managers.CnsConfig
+	}
+	return common.IsFileVolumeRootSquashRequested(volumeContext, cfg.NetPermissions)
+}
+
+// protectBlockVolumeFromVMDeletion sets the keepAfterDeleteVm control flag on
+// the given block volume if requested for the given volume context.
+func (c *controller) protectBlockVolumeFromVMDeletion(ctx context.Context, volumeManager cnsvolume.Manager,
+	volumeID string, volumeContext map[string]string) error {
+	if !c.isProtectFromVMDeletionRequested(volumeContext) {
+		return nil
+	}
+	log := logger.GetLogger(ctx)
+	if err := volumeManager.ProtectVolumeFromVMDeletion(ctx, volumeID); err != nil {
+		return logger.LogNewErrorf(log, "failed to set keepAfterDeleteVm control flag for volume %q. Error: %v",
+			volumeID, err)
+	}
+	return nil
+}
+
+// encodeNfsAccessPoints returns the given access points of a file share JSON
+// encoded as a map of NFS protocol to access points, so that the node can
+// fail over between them.
+func encodeNfsAccessPoints(accessPoints []types.KeyValue) (string, error) {
+	accessPointsByProtocol := make(map[string][]string)
+	for _, kv := range accessPoints {
+		if kv.Value == "" {
+			continue
+		}
+		accessPointsByProtocol[kv.Key] = append(accessPointsByProtocol[kv.Key], kv.Value)
+	}
+	encoded, err := json.Marshal(accessPointsByProtocol)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// getMaxPVSCSIUnitNumbers returns the number of unit numbers of the
+// ParaVirtual SCSI controllers of node VMs.
+func getMaxPVSCSIUnitNumbers(ctx context.Context) int32 {
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.MaxPVSCSITargetsPerVM) {
+		return vsphere.MaxUnitNumbersPerPVSCSIControllerInvSphere8
+	}
+	return vsphere.MaxUnitNumbersPerPVSCSIController
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
 
@@ -43,9 +44,14 @@ import (
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	vsantypes "github.com/vmware/govmomi/vsan/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	testclient "k8s.io/client-go/kubernetes/fake"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
@@ -256,6 +262,10 @@ func (f *FakeNodeManager) GetNodeNameByUUID(ctx context.Context, nodeUUID string
 	return "", nil
 }
 
+func (f *FakeNodeManager) GetK8sNode(ctx context.Context, nodeName string) (*v1.Node, error) {
+	return f.k8sClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+}
+
 func (f *FakeNodeManager) GetNodeVMByUuid(ctx context.Context, nodeUuid string) (*cnsvsphere.VirtualMachine, error) {
 	var vm *cnsvsphere.VirtualMachine
 	var t *testing.T
@@ -2284,3 +2294,379 @@ func TestCreateSnapshotWithManagedObjectNotFound(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFilterDatastoresByURL(t *testing.T) {
+	newDatastoreInfo := func(url string) *cnsvsphere.DatastoreInfo {
+		return &cnsvsphere.DatastoreInfo{
+			Info: &types.DatastoreInfo{Url: url},
+		}
+	}
+	fsEnabledDatastores := []*cnsvsphere.DatastoreInfo{
+		newDatastoreInfo("ds:///vmfs/volumes/vsan:zone-a/"),
+		newDatastoreInfo("ds:///vmfs/volumes/vsan:zone-b/"),
+	}
+	topologyDatastores := []*cnsvsphere.DatastoreInfo{
+		newDatastoreInfo("ds:///vmfs/volumes/vsan:zone-b/"),
+		newDatastoreInfo("ds:///vmfs/volumes/local-zone-b/"),
+	}
+	filteredDatastores := filterDatastoresByURL(fsEnabledDatastores, topologyDatastores)
+	if len(filteredDatastores) != 1 || filteredDatastores[0].Info.Url != "ds:///vmfs/volumes/vsan:zone-b/" {
+		t.Errorf("unexpected filtered datastores: %+v", filteredDatastores)
+	}
+	if filteredDatastores = filterDatastoresByURL(fsEnabledDatastores, nil); len(filteredDatastores) != 0 {
+		t.Errorf("expected no datastores, got: %+v", filteredDatastores)
+	}
+}
+
+func TestGetClusterForDatastoreURL(t *testing.T) {
+	clusterToDsMap := map[string][]*cnsvsphere.DatastoreInfo{
+		"domain-c1": {{Info: &types.DatastoreInfo{Url: "ds:///vmfs/volumes/vsan:cluster-1/"}}},
+		"domain-c2": {{Info: &types.DatastoreInfo{Url: "ds:///vmfs/volumes/vsan:cluster-2/"}}},
+	}
+	if cluster := getClusterForDatastoreURL(clusterToDsMap, "ds:///vmfs/volumes/vsan:cluster-2/"); cluster != "domain-c2" {
+		t.Errorf("expected cluster %q, got %q", "domain-c2", cluster)
+	}
+	if cluster := getClusterForDatastoreURL(clusterToDsMap, "ds:///vmfs/volumes/local/"); cluster != "" {
+		t.Errorf("expected no cluster, got %q", cluster)
+	}
+}
+
+func TestValidateVanillaFileVolumeParams(t *testing.T) {
+	ctx := context.Background()
+	volCaps := func(mode csi.VolumeCapability_AccessMode_Mode) []*csi.VolumeCapability {
+		return []*csi.VolumeCapability{{
+			AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode},
+		}}
+	}
+	smbParams := &common.StorageClassParams{FileProtocol: common.FileProtocolSMB}
+	if err := validateVanillaFileVolumeParams(ctx,
+		volCaps(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER), smbParams); err != nil {
+		t.Errorf("unexpected error %v for a read-write SMB file volume", err)
+	}
+	if err := validateVanillaFileVolumeParams(ctx,
+		volCaps(csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY), &common.StorageClassParams{}); err != nil {
+		t.Errorf("unexpected error %v for a read-only NFS file volume", err)
+	}
+	err := validateVanillaFileVolumeParams(ctx, volCaps(csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY),
+		smbParams)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a read-only SMB file volume, got %v", err)
+	}
+	err = validateVanillaFileVolumeParams(ctx, volCaps(csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER),
+		&common.StorageClassParams{DiskSharing: common.DiskSharingMultiWriter})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a file volume with disk sharing, got %v", err)
+	}
+}
+
+// volumeAttachmentOrchestrator is a container orchestrator returning the
+// given VolumeAttachment.
+type volumeAttachmentOrchestrator struct {
+	commonco.COCommonInterface
+	volumeAttachment *storagev1.VolumeAttachment
+}
+
+func (o *volumeAttachmentOrchestrator) GetVolumeAttachment(ctx context.Context, volumeID string,
+	nodeName string) (*storagev1.VolumeAttachment, error) {
+	return o.volumeAttachment, nil
+}
+
+func TestGetGrantedFileVolumeNodeIPs(t *testing.T) {
+	ctx := context.Background()
+	volumeID := "file:4f9b5c2a-0d4e-4c1e-9d6a-1b2c3d4e5f60"
+	origOrchestrator := commonco.ContainerOrchestratorUtility
+	defer func() { commonco.ContainerOrchestratorUtility = origOrchestrator }()
+	commonco.ContainerOrchestratorUtility = &volumeAttachmentOrchestrator{
+		volumeAttachment: &storagev1.VolumeAttachment{
+			Status: storagev1.VolumeAttachmentStatus{
+				Attached: true,
+				AttachmentMetadata: map[string]string{
+					common.FileVolumeNodeIPs: "10.20.30.40,2001:db8::10",
+				},
+			},
+		},
+	}
+
+	// The IPs are read back from the VolumeAttachment after a restart.
+	expected := []string{"10.20.30.40", "2001:db8::10"}
+	if ips := getGrantedFileVolumeNodeIPs(ctx, volumeID, "node-1"); !reflect.DeepEqual(ips, expected) {
+		t.Errorf("expected IPs %v from the VolumeAttachment, got %v", expected, ips)
+	}
+	// The IPs remembered in memory take precedence.
+	fileVolumeNodeIPs.Store(volumeID+"/node-1", []string{"10.20.30.41"})
+	defer fileVolumeNodeIPs.Delete(volumeID + "/node-1")
+	expected = []string{"10.20.30.41"}
+	if ips := getGrantedFileVolumeNodeIPs(ctx, volumeID, "node-1"); !reflect.DeepEqual(ips, expected) {
+		t.Errorf("expected IPs %v from memory, got %v", expected, ips)
+	}
+	// No IPs are known for VolumeAttachments published without them.
+	commonco.ContainerOrchestratorUtility = &volumeAttachmentOrchestrator{
+		volumeAttachment: &storagev1.VolumeAttachment{},
+	}
+	if ips := getGrantedFileVolumeNodeIPs(ctx, volumeID, "node-2"); len(ips) != 0 {
+		t.Errorf("expected no IPs, got %v", ips)
+	}
+}
+
+func TestEncodeNfsAccessPoints(t *testing.T) {
+	encoded, err := encodeNfsAccessPoints([]types.KeyValue{
+		{Key: common.Nfsv4AccessPointKey, Value: "10.0.0.1:/vsanfs/share"},
+		{Key: "NFSv3", Value: "10.0.0.1:/vsanfs/share"},
+		{Key: common.Nfsv4AccessPointKey, Value: "fd01::1:/vsanfs/share"},
+		{Key: "NFSv3", Value: ""},
+	})
+	if err != nil {
+		t.Fatalf("encodeNfsAccessPoints() unexpected error: %v", err)
+	}
+	expected := `{"NFSv3":["10.0.0.1:/vsanfs/share"],"NFSv4.1":["10.0.0.1:/vsanfs/share","fd01::1:/vsanfs/share"]}`
+	if encoded != expected {
+		t.Errorf("encodeNfsAccessPoints() = %s, want %s", encoded, expected)
+	}
+}
+
+func TestGetFileShareConfig(t *testing.T) {
+	allowRoot := true
+	tests := []struct {
+		name     string
+		scParams *common.StorageClassParams
+		netPerms []vsanfstypes.VsanFileShareNetPermission
+		expected *vsantypes.VsanFileShareConfig
+	}{
+		{
+			name:     "Defaults",
+			scParams: &common.StorageClassParams{},
+		},
+		{
+			name:     "NFSv4.1 with AUTH_SYS",
+			scParams: &common.StorageClassParams{NfsVersion: common.NfsVersion41, NfsSecurityType: "sys"},
+		},
+		{
+			name:     "NFSv3",
+			scParams: &common.StorageClassParams{NfsVersion: common.NfsVersion3},
+			expected: &vsantypes.VsanFileShareConfig{Protocols: []string{"NFSv3"}},
+		},
+		{
+			name:     "SMB",
+			scParams: &common.StorageClassParams{FileProtocol: common.FileProtocolSMB},
+			netPerms: []vsanfstypes.VsanFileShareNetPermission{{Ips: "*", Permissions: "READ_WRITE"}},
+			expected: &vsantypes.VsanFileShareConfig{Protocols: []string{"SMB"}},
+		},
+		{
+			name:     "Kerberos",
+			scParams: &common.StorageClassParams{NfsSecurityType: common.NfsSecurityTypeKrb5p},
+			netPerms: []vsanfstypes.VsanFileShareNetPermission{{Ips: "*", Permissions: "READ_WRITE", AllowRoot: true}},
+			expected: &vsantypes.VsanFileShareConfig{
+				NfsSecType: "KRB5P",
+				Permission: []vsantypes.VsanFileShareNetPermission{
+					{Ips: "*", Permissions: "READ_WRITE", AllowRoot: &allowRoot},
+				},
+			},
+		},
+		{
+			name:     "Kerberos with access granted per node",
+			scParams: &common.StorageClassParams{NfsSecurityType: common.NfsSecurityTypeKrb5},
+			expected: &vsantypes.VsanFileShareConfig{NfsSecType: "KRB5"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := getFileShareConfig(test.scParams, test.netPerms)
+			if !reflect.DeepEqual(config, test.expected) {
+				t.Errorf("getFileShareConfig() = %+v, want %+v", config, test.expected)
+			}
+		})
+	}
+}
+
+func TestConfigureNewFileShareFailureDeletesVolume(t *testing.T) {
+	ct := getControllerTest(t)
+	volumeName := testVolumeName + "-" + uuid.New().String()
+	datacenters, err := ct.vcenter.GetDatacenters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var datastores []*cnsvsphere.DatastoreInfo
+	dsURLToInfoMap, err := datacenters[0].GetAllDatastores(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dsInfo := range dsURLToInfoMap {
+		datastores = append(datastores, dsInfo)
+		break
+	}
+	// The Kerberos file share is created without net permissions, and gets
+	// them along with its NFS security type when it is reconfigured.
+	createSpec := &common.CreateVolumeSpec{
+		CapacityMB: 1024,
+		Name:       volumeName,
+		ScParams:   &common.StorageClassParams{NfsSecurityType: common.NfsSecurityTypeKrb5p},
+		VolumeType: common.FileVolumeType,
+	}
+	volID, _, err := common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla, ct.vcenter,
+		ct.controller.manager.VolumeManager, ct.controller.manager.CnsConfig, createSpec, datastores,
+		false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryResult, err := ct.vcenter.CnsClient.QueryVolume(ctx, cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volID}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queryResult.Volumes) != 1 || queryResult.Volumes[0].VolumeType != common.FileVolumeType {
+		t.Fatalf("expected file volume %q to be created, got %+v", volID, queryResult.Volumes)
+	}
+	if _, err = ct.operationStore.GetRequestDetails(ctx, volumeName); err != nil {
+		t.Fatalf("expected CreateVolume details of %q to be stored, got %v", volumeName, err)
+	}
+
+	origReconfigureFileShare := reconfigureFileShareFunc
+	defer func() { reconfigureFileShareFunc = origReconfigureFileShare }()
+	reconfigureFileShareFunc = func(ctx context.Context, vc *cnsvsphere.VirtualCenter,
+		volumeManager cnsvolume.Manager, clusterToDsMap map[string][]*cnsvsphere.DatastoreInfo,
+		volumeID string, config vsantypes.VsanFileShareConfig) error {
+		return fmt.Errorf("file share of volume %q not found", volumeID)
+	}
+	err = configureNewFileShare(ctx, ct.vcenter, ct.controller.manager.VolumeManager, nil,
+		volumeName, volID, vsantypes.VsanFileShareConfig{NfsSecType: "KRB5P"})
+	if err == nil {
+		t.Fatal("expected configureNewFileShare to fail")
+	}
+
+	// Verify the volume and its CreateVolume details have been deleted, so
+	// that a retried CreateVolume creates the volume again.
+	queryResult, err = ct.vcenter.CnsClient.QueryVolume(ctx, cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volID}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queryResult.Volumes) != 0 {
+		t.Fatalf("volume should not exist after the failed reconfigure with ID: %s", volID)
+	}
+	if _, err = ct.operationStore.GetRequestDetails(ctx, volumeName); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected CreateVolume details of %q to be deleted, got %v", volumeName, err)
+	}
+}
+
+func TestOverlappingVolumeOperationsAreAborted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// An operation on the volume is in progress, for example a DeleteVolume
+	// retried by the provisioner after a timeout.
+	release, err := volumeOperations.Acquire(ctx, "vol-in-flight", "DeleteVolume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	c := &controller{}
+	_, err = c.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "vol-in-flight"})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected DeleteVolume to be aborted, got %v", err)
+	}
+	_, err = c.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{VolumeId: "vol-in-flight"})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected ControllerExpandVolume to be aborted, got %v", err)
+	}
+}
+
+func TestConcurrentPublishToTwoNodes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The volume is being published to node-1.
+	release, err := acquirePublishOperation(ctx, "vol-1", "node-1", "ControllerPublishVolume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	// Publish the volume to both nodes concurrently. The requests have no
+	// volume capability, so the publish to node-2 fails the validation without
+	// reaching vCenter.
+	c := &controller{}
+	errs := make(map[string]error)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, nodeID := range []string{"node-1", "node-2"} {
+		wg.Add(1)
+		go func(nodeID string) {
+			defer wg.Done()
+			_, err := c.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+				VolumeId: "vol-1",
+				NodeId:   nodeID,
+			})
+			mutex.Lock()
+			defer mutex.Unlock()
+			errs[nodeID] = err
+		}(nodeID)
+	}
+	wg.Wait()
+	if status.Code(errs["node-1"]) != codes.Aborted {
+		t.Errorf("expected ControllerPublishVolume to node-1 to be aborted, got %v", errs["node-1"])
+	}
+	if err := errs["node-2"]; err == nil || status.Code(err) == codes.Aborted {
+		t.Errorf("expected ControllerPublishVolume to node-2 not to be aborted, got %v", err)
+	}
+
+	// Unpublishing the volume from node-1 conflicts with its publish only.
+	_, err = c.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+		VolumeId: "vol-1",
+		NodeId:   "node-1",
+	})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected ControllerUnpublishVolume from node-1 to be aborted, got %v", err)
+	}
+}
+
+func TestOverlappingPublishAndVolumeOperationsAreAborted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &controller{}
+	// The volume is being published to a node.
+	release, err := acquirePublishOperation(ctx, "vol-publish", "node-1", "ControllerPublishVolume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{VolumeId: "vol-publish"})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected ControllerExpandVolume to be aborted by the publish, got %v", err)
+	}
+	_, err = c.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "vol-publish"})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected DeleteVolume to be aborted by the publish, got %v", err)
+	}
+	release()
+
+	// The volume is being expanded, then deleted.
+	for _, operation := range []string{"ControllerExpandVolume", "DeleteVolume"} {
+		release, err = volumeOperations.Acquire(ctx, "vol-publish", operation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+			VolumeId: "vol-publish",
+			NodeId:   "node-1",
+		})
+		if status.Code(err) != codes.Aborted {
+			t.Errorf("expected ControllerPublishVolume to be aborted by %s, got %v", operation, err)
+		}
+		_, err = c.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+			VolumeId: "vol-publish",
+			NodeId:   "node-1",
+		})
+		if status.Code(err) != codes.Aborted {
+			t.Errorf("expected ControllerUnpublishVolume to be aborted by %s, got %v", operation, err)
+		}
+		_, err = c.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{
+			Name:           "snapshot-" + operation,
+			SourceVolumeId: "vol-publish",
+		})
+		if status.Code(err) != codes.Aborted {
+			t.Errorf("expected CreateSnapshot to be aborted by %s, got %v", operation, err)
+		}
+		release()
+	}
+}
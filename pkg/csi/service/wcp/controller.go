@@ -1308,7 +1308,10 @@ func (c *controller) ValidateVolumeCapabilities(ctx context.Context, req *csi.Va
 	log.Infof("ControllerGetCapabilities: called with args %+v", *req)
 	volCaps := req.GetVolumeCapabilities()
 	var confirmed *csi.ValidateVolumeCapabilitiesResponse_Confirmed
-	if err := common.IsValidVolumeCapabilities(ctx, volCaps); err == nil {
+	// Raw block volumes accessed from multiple nodes are only supported in
+	// vanilla clusters.
+	if err := common.IsValidVolumeCapabilities(ctx, volCaps); err == nil &&
+		!common.IsMultiNodeBlockVolumeRequest(ctx, volCaps) {
 		confirmed = &csi.ValidateVolumeCapabilitiesResponse_Confirmed{VolumeCapabilities: volCaps}
 	}
 	return &csi.ValidateVolumeCapabilitiesResponse{
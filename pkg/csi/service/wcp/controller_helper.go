@@ -80,6 +80,10 @@ func validateCreateFileReqParam(paramName, value string) bool {
 // TODO: Need to remove AttributeHostLocal after external provisioner stops
 // sending this parameter.
 func validateWCPCreateVolumeRequest(ctx context.Context, req *csi.CreateVolumeRequest, isBlockRequest bool) error {
+	if common.IsMultiNodeBlockVolumeRequest(ctx, req.GetVolumeCapabilities()) {
+		return status.Error(codes.InvalidArgument,
+			"block volume mode is not supported for ReadWriteMany or ReadOnlyMany volume creation")
+	}
 	// Get create params.
 	params := req.GetParameters()
 	for paramName, value := range params {
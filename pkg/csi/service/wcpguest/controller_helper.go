@@ -100,6 +100,10 @@ func validateGuestClusterCreateVolumeRequest(ctx context.Context, req *csi.Creat
 		common.IsFileVolumeRequest(ctx, req.GetVolumeCapabilities()) {
 		return logger.LogNewErrorCode(log, codes.InvalidArgument, "File volume provisioning is not supported.")
 	}
+	if common.IsMultiNodeBlockVolumeRequest(ctx, req.GetVolumeCapabilities()) {
+		return logger.LogNewErrorCode(log, codes.InvalidArgument,
+			"block volume mode is not supported for ReadWriteMany or ReadOnlyMany volume creation")
+	}
 	return common.ValidateCreateVolumeRequest(ctx, req)
 }
 
@@ -11,3 +11,8 @@ const EmbedCnsFileVolumeClientFileName = "cnsfilevolumeclient_crd.yaml"
 var EmbedTriggerCsiFullSync embed.FS
 
 const EmbedTriggerCsiFullSyncName = "triggercsifullsync_crd.yaml"
+
+//go:embed vcentercapabilitystatus_crd.yaml
+var EmbedVCenterCapabilityStatus embed.FS
+
+const EmbedVCenterCapabilityStatusName = "vcentercapabilitystatus_crd.yaml"
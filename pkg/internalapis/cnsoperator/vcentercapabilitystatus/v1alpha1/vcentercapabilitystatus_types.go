@@ -0,0 +1,93 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VCenterCapabilityStatusCRName is the name of the instance
+// reporting the capabilities of the vCenters.
+const VCenterCapabilityStatusCRName = "csi-vcenter-capabilities"
+
+// VCenterCapabilities contains the capabilities of a vCenter, discovered
+// when connecting to it.
+type VCenterCapabilities struct {
+	// Host is the vCenter host.
+	Host string `json:"host"`
+
+	// Version is the vCenter version.
+	Version string `json:"version,omitempty"`
+
+	// Build is the vCenter build number.
+	Build string `json:"build,omitempty"`
+
+	// APIVersion is the vSphere API version of the vCenter.
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// VsanAPIVersion is the vSAN API version used by the driver.
+	VsanAPIVersion string `json:"vsanAPIVersion,omitempty"`
+
+	// Features holds whether each feature gated on the vCenter version
+	// is supported by the vCenter.
+	Features map[string]bool `json:"features,omitempty"`
+
+	// VsanFileServiceState is the vSAN file service state of the vCenter.
+	// It is Enabled, Disabled or Unknown if it could not be queried.
+	VsanFileServiceState string `json:"vsanFileServiceState,omitempty"`
+
+	// VsanFileServiceClusters are the clusters with vSAN file service enabled.
+	VsanFileServiceClusters []string `json:"vsanFileServiceClusters,omitempty"`
+
+	// LastDiscoveryTime indicates when the capabilities were last discovered.
+	LastDiscoveryTime *metav1.Time `json:"lastDiscoveryTime,omitempty"`
+}
+
+// VCenterCapabilityStatusStatus contains the status for a VCenterCapabilityStatus
+type VCenterCapabilityStatusStatus struct {
+	// VCenters holds the capabilities of each vCenter connected to.
+	VCenters []VCenterCapabilities `json:"vCenters,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VCenterCapabilityStatus is the Schema for the VCenterCapabilityStatus API
+type VCenterCapabilityStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Status represents the capabilities of the vCenters.
+	Status VCenterCapabilityStatusStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VCenterCapabilityStatusList contains a list of VCenterCapabilityStatus
+type VCenterCapabilityStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VCenterCapabilityStatus `json:"items"`
+}
+
+// CreateVCenterCapabilityStatusInstance creates default VCenterCapabilityStatus CR instance
+func CreateVCenterCapabilityStatusInstance() *VCenterCapabilityStatus {
+	return &VCenterCapabilityStatus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: VCenterCapabilityStatusCRName,
+		},
+	}
+}
@@ -54,6 +54,12 @@ type CSINodeTopologyStatus struct {
 	// ErrorMessage will contain the error string when `Status` field is set to "Error".
 	// It will be empty when the `Status` field is set to "Success".
 	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	// MaxVolumesPerNode is the number of block volumes which can be attached
+	// to the NodeVM, computed from the slots of its PVSCSI controllers. It is 0
+	// when it was not computed.
+	//+optional
+	MaxVolumesPerNode int64 `json:"maxVolumesPerNode,omitempty"`
 }
 
 // TopologyLabel will consist of a key-value pair.
@@ -28,6 +28,7 @@ import (
 
 	cnsfilevolclientv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/cnsfilevolumeclient/v1alpha1"
 	triggercsifullsyncv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/triggercsifullsync/v1alpha1"
+	vccapabilityv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/vcentercapabilitystatus/v1alpha1"
 	cnscsisvfeaturestatesv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/featurestates/v1alpha1"
 )
 
@@ -46,6 +47,9 @@ var (
 
 	// TriggerCsiFullSyncPlural is plural of TriggerCsiFullSyncPlural
 	TriggerCsiFullSyncPlural = "triggercsifullsyncs"
+
+	// VCenterCapabilityStatusPlural is plural of VCenterCapabilityStatus
+	VCenterCapabilityStatusPlural = "vcentercapabilitystatuses"
 )
 
 var (
@@ -80,6 +84,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&triggercsifullsyncv1alpha1.TriggerCsiFullSyncList{},
 	)
 
+	scheme.AddKnownTypes(
+		SchemeGroupVersion,
+		&vccapabilityv1alpha1.VCenterCapabilityStatus{},
+		&vccapabilityv1alpha1.VCenterCapabilityStatusList{},
+	)
+
 	scheme.AddKnownTypes(
 		SchemeGroupVersion,
 		&cnscsisvfeaturestatesv1alpha1.CnsCsiSvFeatureStates{},
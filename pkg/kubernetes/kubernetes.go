@@ -346,6 +346,17 @@ func GetNodeUUID(ctx context.Context,
 	return nodeId, nil
 }
 
+// GetNodeInternalIPs returns the InternalIP addresses of the given node.
+func GetNodeInternalIPs(node *v1.Node) []string {
+	var ips []string
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			ips = append(ips, address.Address)
+		}
+	}
+	return ips
+}
+
 // getClientThroughput returns the QPS and Burst for the API server client.
 // QPS and Burst default to 50.
 // The maximum accepted value for QPS or Burst is set to 1000.
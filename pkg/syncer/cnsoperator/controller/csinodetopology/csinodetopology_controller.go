@@ -54,7 +54,16 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer"
 )
 
-const defaultMaxWorkerThreadsForCSINodeTopology = 1
+const (
+	defaultMaxWorkerThreadsForCSINodeTopology = 1
+	// maxVolumesPerNodeRefreshInterval is the interval at which the number of
+	// block volumes which can be attached to a NodeVM is recomputed once its
+	// instance is at Success state, as disk controllers can be added to the
+	// NodeVM at any time. kubelet reads the number only when the node plugin
+	// registers, so an event asks for the node plugin to be restarted when it
+	// changes.
+	maxVolumesPerNodeRefreshInterval = 10 * time.Minute
+)
 
 // backOffDuration is a map of csinodetopology instance name to the time after
 // which a request for this instance will be requeued. Initialized to 1 second
@@ -111,6 +120,16 @@ func Add(mgr manager.Manager, clusterFlavor cnstypes.CnsClusterFlavor,
 	}
 
 	isMultiVCFSSEnabled := coCommonInterface.IsFSSEnabled(ctx, common.MultiVCenterCSITopology)
+	// Number of block volumes which can be attached to NodeVMs is computed only
+	// in Vanilla deployments when node-volume-limits FSS is enabled.
+	var maxPVSCSIUnitNumbers int32
+	if clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		coCommonInterface.IsFSSEnabled(ctx, common.NodeVolumeLimits) {
+		maxPVSCSIUnitNumbers = cnsvsphere.MaxUnitNumbersPerPVSCSIController
+		if coCommonInterface.IsFSSEnabled(ctx, common.MaxPVSCSITargetsPerVM) {
+			maxPVSCSIUnitNumbers = cnsvsphere.MaxUnitNumbersPerPVSCSIControllerInvSphere8
+		}
+	}
 	// Initialize kubernetes client.
 	k8sclient, err := k8s.NewClient(ctx)
 	if err != nil {
@@ -128,22 +147,23 @@ func Add(mgr manager.Manager, clusterFlavor cnstypes.CnsClusterFlavor,
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme,
 		corev1.EventSource{Component: csinodetopologyv1alpha1.GroupName})
 	return add(mgr, newReconciler(mgr, configInfo, recorder,
-		enableTKGsHAinGuest, isMultiVCFSSEnabled, vmOperatorClient, supervisorNamespace))
+		enableTKGsHAinGuest, isMultiVCFSSEnabled, vmOperatorClient, supervisorNamespace, maxPVSCSIUnitNumbers))
 }
 
 // newReconciler returns a new `reconcile.Reconciler`.
 func newReconciler(mgr manager.Manager, configInfo *cnsconfig.ConfigurationInfo, recorder record.EventRecorder,
 	enableTKGsHAinGuest bool, isMultiVCFSSEnabled bool, vmOperatorClient client.Client,
-	supervisorNamespace string) reconcile.Reconciler {
+	supervisorNamespace string, maxPVSCSIUnitNumbers int32) reconcile.Reconciler {
 	return &ReconcileCSINodeTopology{
-		client:              mgr.GetClient(),
-		scheme:              mgr.GetScheme(),
-		configInfo:          configInfo,
-		recorder:            recorder,
-		enableTKGsHAinGuest: enableTKGsHAinGuest,
-		isMultiVCFSSEnabled: isMultiVCFSSEnabled,
-		vmOperatorClient:    vmOperatorClient,
-		supervisorNamespace: supervisorNamespace}
+		client:               mgr.GetClient(),
+		scheme:               mgr.GetScheme(),
+		configInfo:           configInfo,
+		recorder:             recorder,
+		enableTKGsHAinGuest:  enableTKGsHAinGuest,
+		isMultiVCFSSEnabled:  isMultiVCFSSEnabled,
+		vmOperatorClient:     vmOperatorClient,
+		supervisorNamespace:  supervisorNamespace,
+		maxPVSCSIUnitNumbers: maxPVSCSIUnitNumbers}
 }
 
 // add adds a new Controller to mgr with r as the `reconcile.Reconciler`.
@@ -209,6 +229,10 @@ type ReconcileCSINodeTopology struct {
 	isMultiVCFSSEnabled bool
 	vmOperatorClient    client.Client
 	supervisorNamespace string
+	// maxPVSCSIUnitNumbers is the number of unit numbers of the PVSCSI
+	// controllers of NodeVMs. It is 0 when the number of block volumes which
+	// can be attached to NodeVMs is not computed.
+	maxPVSCSIUnitNumbers int32
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -242,8 +266,13 @@ func (r *ReconcileCSINodeTopology) reconcileForVanilla(ctx context.Context, requ
 		// Error reading the object - return with err.
 		return reconcile.Result{}, err
 	}
-	// If the CR status is already at Success, do not reconcile further.
+	// If the CR status is already at Success, do not reconcile further. Only
+	// the number of block volumes which can be attached to the NodeVM is
+	// refreshed, if computed.
 	if instance.Status.Status == csinodetopologyv1alpha1.CSINodeTopologySuccess {
+		if r.maxPVSCSIUnitNumbers > 0 {
+			return r.refreshMaxVolumesPerNode(ctx, instance), nil
+		}
 		log.Infof("CSINodeTopology instance with name %q is already at %q state. No need to "+
 			"reconcile further.", instance.Name, instance.Status.Status)
 		return reconcile.Result{}, err
@@ -292,6 +321,10 @@ func (r *ReconcileCSINodeTopology) reconcileForVanilla(ctx context.Context, requ
 		return reconcile.Result{RequeueAfter: timeout}, nil
 	}
 
+	if r.maxPVSCSIUnitNumbers > 0 {
+		instance.Status.MaxVolumesPerNode = getMaxVolumesPerNode(ctx, nodeVM, r.maxPVSCSIUnitNumbers)
+	}
+
 	if !r.isTopologyEnabled() {
 		// Not a topology aware setup.
 		// Set the Status to Success and return.
@@ -482,6 +515,60 @@ func updateCRStatus(ctx context.Context, r *ReconcileCSINodeTopology, instance *
 	return nil
 }
 
+// getMaxVolumesPerNode returns the number of block volumes which can be
+// attached to the nodeVM from the slots of its disk controllers, or 0 if the
+// devices of the nodeVM could not be retrieved.
+func getMaxVolumesPerNode(ctx context.Context, nodeVM *cnsvsphere.VirtualMachine,
+	maxPVSCSIUnitNumbers int32) int64 {
+	log := logger.GetLogger(ctx)
+	vmDevices, err := nodeVM.Device(ctx)
+	if err != nil {
+		log.Warnf("failed to get devices of nodeVM %v to compute its volume limit. Error: %v",
+			nodeVM.Reference(), err)
+		return 0
+	}
+	controllers := cnsvsphere.GetDiskControllers(vmDevices, maxPVSCSIUnitNumbers)
+	maxVolumes := cnsvsphere.GetMaxBlockVolumes(controllers)
+	log.Infof("NodeVM %v has %d disk controllers which can attach %d block volumes",
+		nodeVM.Reference(), len(controllers), maxVolumes)
+	return maxVolumes
+}
+
+// refreshMaxVolumesPerNode recomputes the number of block volumes which can be
+// attached to the NodeVM of the given instance, and updates the instance if it
+// changed. As kubelet reads the number from NodeGetInfo only when the node
+// plugin registers, a change takes effect only once the node plugin of the
+// node restarts, which an event on the instance asks for. The instance is
+// requeued to be refreshed again later.
+func (r *ReconcileCSINodeTopology) refreshMaxVolumesPerNode(ctx context.Context,
+	instance *csinodetopologyv1alpha1.CSINodeTopology) reconcile.Result {
+	log := logger.GetLogger(ctx)
+	result := reconcile.Result{RequeueAfter: maxVolumesPerNodeRefreshInterval}
+	nodeVM, err := node.GetManager(ctx).GetNodeVMAndUpdateCache(ctx, instance.Spec.NodeUUID, nil)
+	if err != nil {
+		log.Warnf("failed to retrieve nodeVM %q to refresh its volume limit. Error: %+v",
+			instance.Spec.NodeUUID, err)
+		return result
+	}
+	maxVolumes := getMaxVolumesPerNode(ctx, nodeVM, r.maxPVSCSIUnitNumbers)
+	if maxVolumes == 0 || maxVolumes == instance.Status.MaxVolumesPerNode {
+		return result
+	}
+	log.Infof("Number of block volumes which can be attached to node %q changed from %d to %d",
+		instance.Name, instance.Status.MaxVolumesPerNode, maxVolumes)
+	previousMaxVolumes := instance.Status.MaxVolumesPerNode
+	instance.Status.MaxVolumesPerNode = maxVolumes
+	if err := r.client.Update(ctx, instance); err != nil {
+		log.Warnf("failed to update the volume limit of CSINodeTopology instance %q. Error: %+v",
+			instance.Name, err)
+		return result
+	}
+	r.recorder.Eventf(instance, corev1.EventTypeNormal, "MaxVolumesPerNodeChanged",
+		"Number of block volumes which can be attached to node %q changed from %d to %d. Restart the "+
+			"vSphere CSI node plugin on the node for kubelet to use it", instance.Name, previousMaxVolumes, maxVolumes)
+	return result
+}
+
 func getNodeTopologyInfo(ctx context.Context, nodeVM *cnsvsphere.VirtualMachine, cfg *cnsconfig.Config,
 	isMultiVCFSSEnabled bool) ([]csinodetopologyv1alpha1.TopologyLabel, error) {
 	log := logger.GetLogger(ctx)
@@ -0,0 +1,174 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+const (
+	// Event reasons for the fake attached volumes which are accessible again.
+	eventReasonFakeAttachedVolumeAccessible = "FakeAttachedVolumeAccessible"
+	eventReasonFakeAttachedPodEvicted       = "FakeAttachedPodEvicted"
+	eventReasonFakeAttachedPodEvictFailed   = "FakeAttachedPodEvictionFailed"
+)
+
+// fakeAttachedVolumeReconciler finds the PVCs which were fake attached while
+// their volume was inaccessible and whose volume is accessible again. The
+// pods using these PVCs run without the data of the volume until they are
+// restarted, which attaches the volume for real.
+type fakeAttachedVolumeReconciler struct {
+	k8sClient clientset.Interface
+	pvcLister corelisters.PersistentVolumeClaimLister
+	podLister corelisters.PodLister
+	recorder  record.EventRecorder
+	// evictPods is set to evict the pods using the fake attached PVCs whose
+	// volume is accessible again.
+	evictPods bool
+}
+
+// newFakeAttachedVolumeReconciler returns a fakeAttachedVolumeReconciler
+// recording events through the given kubernetes client.
+func newFakeAttachedVolumeReconciler(k8sClient clientset.Interface, metadataSyncer *metadataSyncInformer,
+	evictPods bool) *fakeAttachedVolumeReconciler {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sClient.CoreV1().Events(""),
+		},
+	)
+	return &fakeAttachedVolumeReconciler{
+		k8sClient: k8sClient,
+		pvcLister: metadataSyncer.pvcLister,
+		podLister: metadataSyncer.podLister,
+		recorder:  eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: syncerEventComponent}),
+		evictPods: evictPods,
+	}
+}
+
+// reconcile reports the fake attached PVCs by the health of their volume and
+// emits events on, or evicts, the pods using the PVCs whose volume is
+// accessible again. It relies on the volume health annotation set by
+// csiGetVolumeHealthStatus.
+func (r *fakeAttachedVolumeReconciler) reconcile(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	pvcs, err := r.pvcLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("reconcileFakeAttachedVolumes: failed to list PVCs. Err: %v", err)
+		return
+	}
+	accessibleVolumeCount := 0
+	inaccessibleVolumeCount := 0
+	for _, pvc := range pvcs {
+		if pvc.Annotations[common.AnnFakeAttached] != "yes" {
+			continue
+		}
+		if pvc.Annotations[annVolumeHealth] != common.VolHealthStatusAccessible {
+			inaccessibleVolumeCount++
+			continue
+		}
+		accessibleVolumeCount++
+		pods, err := r.getPodsUsingPVC(pvc)
+		if err != nil {
+			log.Errorf("reconcileFakeAttachedVolumes: failed to get pods using pvc %s/%s. Err: %v",
+				pvc.Namespace, pvc.Name, err)
+			continue
+		}
+		if len(pods) == 0 {
+			continue
+		}
+		podNames := make([]string, 0, len(pods))
+		for _, pod := range pods {
+			podNames = append(podNames, pod.Name)
+		}
+		log.Infof("reconcileFakeAttachedVolumes: volume of pvc %s/%s is accessible again but is fake attached "+
+			"to pods %v", pvc.Namespace, pvc.Name, podNames)
+		if !r.evictPods {
+			r.recorder.Eventf(pvc, v1.EventTypeWarning, eventReasonFakeAttachedVolumeAccessible,
+				"Volume is accessible again but is fake attached to pods %s. Restart the pods to attach the volume",
+				strings.Join(podNames, ", "))
+			continue
+		}
+		r.recorder.Eventf(pvc, v1.EventTypeNormal, eventReasonFakeAttachedVolumeAccessible,
+			"Volume is accessible again, evicting pods %s to attach the volume", strings.Join(podNames, ", "))
+		for _, pod := range pods {
+			r.evictPod(ctx, pod, pvc)
+		}
+	}
+	prometheus.FakeAttachedVolumesGaugeVec.WithLabelValues(
+		prometheus.PrometheusAccessibleVolumes).Set(float64(accessibleVolumeCount))
+	prometheus.FakeAttachedVolumesGaugeVec.WithLabelValues(
+		prometheus.PrometheusInaccessibleVolumes).Set(float64(inaccessibleVolumeCount))
+}
+
+// getPodsUsingPVC returns the running pods which use the given pvc.
+func (r *fakeAttachedVolumeReconciler) getPodsUsingPVC(pvc *v1.PersistentVolumeClaim) ([]*v1.Pod, error) {
+	pods, err := r.podLister.Pods(pvc.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var podsUsingPVC []*v1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+				podsUsingPVC = append(podsUsingPVC, pod)
+				break
+			}
+		}
+	}
+	return podsUsingPVC, nil
+}
+
+// evictPod evicts the given pod using the given fake attached pvc, honoring
+// its PodDisruptionBudgets.
+func (r *fakeAttachedVolumeReconciler) evictPod(ctx context.Context, pod *v1.Pod, pvc *v1.PersistentVolumeClaim) {
+	log := logger.GetLogger(ctx)
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	}
+	if err := r.k8sClient.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction); err != nil {
+		log.Errorf("reconcileFakeAttachedVolumes: failed to evict pod %s/%s. Err: %v", pod.Namespace, pod.Name, err)
+		r.recorder.Eventf(pod, v1.EventTypeWarning, eventReasonFakeAttachedPodEvictFailed,
+			"Failed to evict pod to attach volume of fake attached pvc %s: %v", pvc.Name, err)
+		return
+	}
+	log.Infof("reconcileFakeAttachedVolumes: evicted pod %s/%s to attach volume of pvc %s",
+		pod.Namespace, pod.Name, pvc.Name)
+	r.recorder.Eventf(pod, v1.EventTypeNormal, eventReasonFakeAttachedPodEvicted,
+		"Evicted pod to attach volume of fake attached pvc %s", pvc.Name)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func newTestFakeAttachedPVC(name, health string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "team-a",
+			Annotations: map[string]string{
+				common.AnnFakeAttached: "yes",
+				annVolumeHealth:        health,
+			},
+		},
+	}
+}
+
+func newTestPodUsingPVC(name, pvcName string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "team-a",
+		},
+		Spec: v1.PodSpec{
+			Volumes: []v1.Volume{{
+				Name: "data",
+				VolumeSource: v1.VolumeSource{
+					PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+				},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func newTestFakeAttachedVolumeReconciler(t *testing.T, evictPods bool,
+	objects ...runtime.Object) (*fakeAttachedVolumeReconciler, *k8sfake.Clientset) {
+	k8sclient := k8sfake.NewSimpleClientset(objects...)
+	pvcIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objects {
+		indexer := pvcIndexer
+		if _, ok := obj.(*v1.Pod); ok {
+			indexer = podIndexer
+		}
+		if err := indexer.Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &fakeAttachedVolumeReconciler{
+		k8sClient: k8sclient,
+		pvcLister: corelisters.NewPersistentVolumeClaimLister(pvcIndexer),
+		podLister: corelisters.NewPodLister(podIndexer),
+		recorder:  record.NewFakeRecorder(10),
+		evictPods: evictPods,
+	}, k8sclient
+}
+
+func TestReconcileFakeAttachedVolumes(t *testing.T) {
+	ctx := context.Background()
+	pvcNotFakeAttached := newTestFakeAttachedPVC("not-fake-attached", common.VolHealthStatusAccessible)
+	delete(pvcNotFakeAttached.Annotations, common.AnnFakeAttached)
+	completedPod := newTestPodUsingPVC("completed", "recovered")
+	completedPod.Status.Phase = v1.PodSucceeded
+
+	r, k8sclient := newTestFakeAttachedVolumeReconciler(t, false,
+		newTestFakeAttachedPVC("recovered", common.VolHealthStatusAccessible),
+		newTestFakeAttachedPVC("inaccessible", common.VolHealthStatusInaccessible),
+		pvcNotFakeAttached,
+		newTestPodUsingPVC("app-1", "recovered"),
+		newTestPodUsingPVC("app-2", "inaccessible"),
+		completedPod)
+	r.reconcile(ctx)
+
+	if got := promtestutil.ToFloat64(prometheus.FakeAttachedVolumesGaugeVec.WithLabelValues(
+		prometheus.PrometheusAccessibleVolumes)); got != 1 {
+		t.Errorf("expected 1 accessible fake attached volume, got %v", got)
+	}
+	if got := promtestutil.ToFloat64(prometheus.FakeAttachedVolumesGaugeVec.WithLabelValues(
+		prometheus.PrometheusInaccessibleVolumes)); got != 1 {
+		t.Errorf("expected 1 inaccessible fake attached volume, got %v", got)
+	}
+	events := r.recorder.(*record.FakeRecorder).Events
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	expected := "Warning " + eventReasonFakeAttachedVolumeAccessible +
+		" Volume is accessible again but is fake attached to pods app-1. Restart the pods to attach the volume"
+	if event := <-events; event != expected {
+		t.Errorf("got event %q, want %q", event, expected)
+	}
+	for _, action := range k8sclient.Actions() {
+		if action.GetSubresource() == "eviction" {
+			t.Errorf("expected no pod to be evicted, got %v", action)
+		}
+	}
+}
+
+func TestReconcileFakeAttachedVolumesEvictsPods(t *testing.T) {
+	ctx := context.Background()
+	r, k8sclient := newTestFakeAttachedVolumeReconciler(t, true,
+		newTestFakeAttachedPVC("recovered", common.VolHealthStatusAccessible),
+		newTestPodUsingPVC("app-1", "recovered"),
+		newTestPodUsingPVC("app-2", "other"))
+	var evicted []string
+	k8sclient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(metav1.Object)
+		evicted = append(evicted, eviction.GetName())
+		return true, nil, nil
+	})
+	r.reconcile(ctx)
+
+	if len(evicted) != 1 || evicted[0] != "app-1" {
+		t.Errorf("expected only pod app-1 to be evicted, got %v", evicted)
+	}
+	// One event on the pvc and one on the evicted pod.
+	if events := r.recorder.(*record.FakeRecorder).Events; len(events) != 2 {
+		t.Errorf("expected 2 events, got %d", len(events))
+	}
+}
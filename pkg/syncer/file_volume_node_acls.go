@@ -0,0 +1,307 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/workqueue"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// fileVolumeNodeACLReconciler updates the net permissions granted to a node on
+// the NFS file volumes published to it when the InternalIP addresses of the
+// node change, as ControllerPublishVolume grants access to the node IPs only.
+// The nodes are queued by name, so that the updates of a node are serialized.
+type fileVolumeNodeACLReconciler struct {
+	k8sClient clientset.Interface
+	// netPermissions are the NetPermissions of the driver config, which
+	// squash root access for the nodes if any of them does.
+	netPermissions map[string]*cnsconfig.NetPermissionConfig
+	// nodeQueue holds the names of the nodes whose IPs have changed.
+	nodeQueue workqueue.RateLimitingInterface
+	// grantedIPsLock protects grantedIPs.
+	grantedIPsLock sync.Mutex
+	// grantedIPs maps the names of the nodes whose IPs have changed to the
+	// IPs of the node granted access to its file volumes: the IPs of the node
+	// before its first change, then the IPs granted access by reconcileNode.
+	grantedIPs map[string][]string
+	// getNodeIPs returns the InternalIP addresses of the given node reported
+	// by VMware Tools for its node VM. It is overridden in unit tests.
+	getNodeIPs func(ctx context.Context, k8sNode *v1.Node) ([]string, error)
+	// configureVolumeACLs applies the given spec to the net permissions of a
+	// file volume. It is overridden in unit tests.
+	configureVolumeACLs func(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error
+}
+
+// newFileVolumeNodeACLReconciler returns a fileVolumeNodeACLReconciler
+// updating the net permissions of file volumes through the volume manager of
+// their vCenter.
+func newFileVolumeNodeACLReconciler(k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer) *fileVolumeNodeACLReconciler {
+	r := &fileVolumeNodeACLReconciler{
+		k8sClient:      k8sClient,
+		netPermissions: metadataSyncer.configInfo.Cfg.NetPermissions,
+		nodeQueue: workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(
+			fileVolumeACLRetryIntervalStart, fileVolumeACLRetryIntervalMax), "file-volume-node-acls"),
+		grantedIPs: make(map[string][]string),
+	}
+	r.getNodeIPs = func(ctx context.Context, k8sNode *v1.Node) ([]string, error) {
+		nodeUUID, err := k8s.GetNodeUUID(ctx, k8sClient, k8sNode.Name)
+		if err != nil {
+			return nil, err
+		}
+		nodeVM, err := node.GetManager(ctx).GetNodeVMAndUpdateCache(ctx, nodeUUID, nil)
+		if err != nil {
+			return nil, err
+		}
+		return nodeVM.GetGuestIPAddresses(ctx, k8s.GetNodeInternalIPs(k8sNode))
+	}
+	r.configureVolumeACLs = func(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
+		_, volumeManager, err := getVcHostAndVolumeManagerForVolumeID(ctx, metadataSyncer, spec.VolumeId.Id)
+		if err != nil {
+			return err
+		}
+		return volumeManager.ConfigureVolumeACLs(ctx, spec)
+	}
+	return r
+}
+
+// Run starts the given number of workers reconciling the queued nodes until
+// the given context is done.
+func (r *fileVolumeNodeACLReconciler) Run(ctx context.Context, workers int) {
+	log := logger.GetLogger(ctx)
+	defer r.nodeQueue.ShutDown()
+	log.Info("File volume node ACL reconciler: Start")
+	defer log.Info("File volume node ACL reconciler: End")
+	for i := 0; i < workers; i++ {
+		go wait.Until(func() {
+			for r.processNextNode() {
+			}
+		}, 0, ctx.Done())
+	}
+	<-ctx.Done()
+}
+
+// processNextNode reconciles the next queued node, and queues it again with a
+// backoff if it fails. It returns false once the queue is shut down.
+func (r *fileVolumeNodeACLReconciler) processNextNode() bool {
+	key, quit := r.nodeQueue.Get()
+	if quit {
+		return false
+	}
+	defer r.nodeQueue.Done(key)
+	ctx, log := logger.GetNewContextWithLogger()
+	if err := r.reconcileNode(ctx, key.(string)); err != nil {
+		log.Errorf("processNextNode: failed to update net permissions of file volumes of node %q, "+
+			"retrying. Err: %v", key, err)
+		r.nodeQueue.AddRateLimited(key)
+		return true
+	}
+	r.nodeQueue.Forget(key)
+	return true
+}
+
+// nodeUpdated queues the updated node if its InternalIP addresses have
+// changed.
+func (r *fileVolumeNodeACLReconciler) nodeUpdated(oldObj interface{}, newObj interface{}) {
+	oldNode, ok := oldObj.(*v1.Node)
+	if !ok || oldNode == nil {
+		return
+	}
+	newNode, ok := newObj.(*v1.Node)
+	if !ok || newNode == nil {
+		return
+	}
+	oldIPs := k8s.GetNodeInternalIPs(oldNode)
+	newIPs := k8s.GetNodeInternalIPs(newNode)
+	if len(subtractIPs(newIPs, oldIPs)) == 0 && len(subtractIPs(oldIPs, newIPs)) == 0 {
+		return
+	}
+	r.grantedIPsLock.Lock()
+	// The IPs granted access of a node already known are not changed until
+	// it is reconciled.
+	if _, ok := r.grantedIPs[newNode.Name]; !ok {
+		r.grantedIPs[newNode.Name] = oldIPs
+	}
+	r.grantedIPsLock.Unlock()
+	r.nodeQueue.Add(newNode.Name)
+}
+
+// reconcileNode grants access to the NFS file volumes published to the given
+// node for the IPs of the node which were not granted access yet, and revokes
+// it for the IPs granted access which the node no longer has, unless another
+// node the volume is published to has them.
+func (r *fileVolumeNodeACLReconciler) reconcileNode(ctx context.Context, nodeName string) error {
+	log := logger.GetLogger(ctx)
+	r.grantedIPsLock.Lock()
+	grantedIPs, ok := r.grantedIPs[nodeName]
+	r.grantedIPsLock.Unlock()
+	if !ok {
+		return nil
+	}
+	k8sNode, err := r.k8sClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		log.Infof("reconcileNode: node %q is deleted, no need to process it", nodeName)
+		r.grantedIPsLock.Lock()
+		delete(r.grantedIPs, nodeName)
+		r.grantedIPsLock.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	nodeIPs, err := r.getNodeIPs(ctx, k8sNode)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to get IP addresses of node %q. Err: %v", nodeName, err)
+	}
+	addedIPs := subtractIPs(nodeIPs, grantedIPs)
+	removedIPs := subtractIPs(grantedIPs, nodeIPs)
+	if len(addedIPs) == 0 && len(removedIPs) == 0 {
+		return nil
+	}
+	log.Infof("reconcileNode: IP addresses %v added to and %v removed from node %q, updating the "+
+		"net permissions of its file volumes", addedIPs, removedIPs, nodeName)
+	vaList, err := r.k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to list VolumeAttachments. Err: %v", err)
+	}
+	var failed int
+	for _, va := range vaList.Items {
+		if va.Spec.Attacher != csitypes.Name || va.Spec.NodeName != nodeName ||
+			va.Spec.Source.PersistentVolumeName == nil || !va.Status.Attached {
+			continue
+		}
+		pvName := *va.Spec.Source.PersistentVolumeName
+		pv, err := r.k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+		if err != nil {
+			log.Errorf("reconcileNode: failed to get PV %q published to node %q. Err: %v", pvName, nodeName, err)
+			failed++
+			continue
+		}
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name ||
+			!strings.HasPrefix(pv.Spec.CSI.VolumeHandle, cnsvolumeinfo.FileVolumePrefix) {
+			continue
+		}
+		// Access to SMB file shares is controlled by the SMB credentials.
+		if common.GetFileVolumeAccessPointKey(pv.Spec.CSI.VolumeAttributes) == common.SmbAccessPointKey {
+			continue
+		}
+		volumeID := pv.Spec.CSI.VolumeHandle
+		readOnly := pv.Spec.CSI.ReadOnly || isReadOnlyManyPV(pv)
+		rootSquash := common.IsFileVolumeRootSquashRequested(pv.Spec.CSI.VolumeAttributes, r.netPermissions)
+		var specs []cnstypes.CnsVolumeACLConfigureSpec
+		if len(addedIPs) != 0 {
+			specs = append(specs, common.GetFileVolumeNodeACLSpec(volumeID, addedIPs, readOnly, rootSquash, false))
+		}
+		revokedIPs := subtractIPs(removedIPs, r.getIPsOfOtherPublishedNodes(ctx, vaList.Items, pvName, nodeName))
+		if len(revokedIPs) != 0 {
+			specs = append(specs, common.GetFileVolumeNodeACLSpec(volumeID, revokedIPs, false, false, true))
+		}
+		for _, spec := range specs {
+			if err = r.configureVolumeACLs(ctx, spec); err != nil {
+				log.Errorf("reconcileNode: failed to update net permissions of file volume %q for node %q. "+
+					"Err: %v", volumeID, nodeName, err)
+				failed++
+				break
+			}
+		}
+		if err == nil {
+			log.Infof("reconcileNode: updated net permissions of file volume %q for node %q", volumeID, nodeName)
+		}
+	}
+	if failed != 0 {
+		return logger.LogNewErrorf(log, "failed to update net permissions of %d file volumes of node %q",
+			failed, nodeName)
+	}
+	r.grantedIPsLock.Lock()
+	r.grantedIPs[nodeName] = nodeIPs
+	r.grantedIPsLock.Unlock()
+	return nil
+}
+
+// getIPsOfOtherPublishedNodes returns the IPs of the nodes other than the
+// given node which the given PV is published to: the IPs granted access at
+// publish time, recorded in the attachment metadata of their
+// VolumeAttachments, and their current InternalIP addresses.
+func (r *fileVolumeNodeACLReconciler) getIPsOfOtherPublishedNodes(ctx context.Context,
+	volumeAttachments []storagev1.VolumeAttachment, pvName string, nodeName string) []string {
+	log := logger.GetLogger(ctx)
+	var ips []string
+	for _, va := range volumeAttachments {
+		if va.Spec.Attacher != csitypes.Name || va.Spec.NodeName == nodeName ||
+			va.Spec.Source.PersistentVolumeName == nil || *va.Spec.Source.PersistentVolumeName != pvName {
+			continue
+		}
+		if grantedIPs := va.Status.AttachmentMetadata[common.FileVolumeNodeIPs]; grantedIPs != "" {
+			ips = append(ips, strings.Split(grantedIPs, ",")...)
+		}
+		otherNode, err := r.k8sClient.CoreV1().Nodes().Get(ctx, va.Spec.NodeName, metav1.GetOptions{})
+		if err != nil {
+			log.Warnf("getIPsOfOtherPublishedNodes: failed to get node %q PV %q is published to. Err: %v",
+				va.Spec.NodeName, pvName, err)
+			continue
+		}
+		ips = append(ips, k8s.GetNodeInternalIPs(otherNode)...)
+	}
+	return ips
+}
+
+// isReadOnlyManyPV returns true if the given PV only supports the
+// ReadOnlyMany access mode.
+func isReadOnlyManyPV(pv *v1.PersistentVolume) bool {
+	if len(pv.Spec.AccessModes) == 0 {
+		return false
+	}
+	for _, accessMode := range pv.Spec.AccessModes {
+		if accessMode != v1.ReadOnlyMany {
+			return false
+		}
+	}
+	return true
+}
+
+// subtractIPs returns the IPs in ips which are not in other.
+func subtractIPs(ips []string, other []string) []string {
+	otherIPs := make(map[string]bool)
+	for _, ip := range other {
+		otherIPs[ip] = true
+	}
+	var result []string
+	for _, ip := range ips {
+		if !otherIPs[ip] {
+			result = append(result, ip)
+		}
+	}
+	return result
+}
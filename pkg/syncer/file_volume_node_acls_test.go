@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/workqueue"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestSubtractIPs(t *testing.T) {
+	ips := subtractIPs([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, []string{"10.0.0.2"})
+	if expected := []string{"10.0.0.1", "10.0.0.3"}; !reflect.DeepEqual(ips, expected) {
+		t.Errorf("expected IPs %v, got %v", expected, ips)
+	}
+	if ips := subtractIPs([]string{"10.0.0.1"}, []string{"10.0.0.1"}); len(ips) != 0 {
+		t.Errorf("expected no IPs, got %v", ips)
+	}
+}
+
+func newTestNode(name string, internalIPs ...string) *v1.Node {
+	k8sNode := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	for _, ip := range internalIPs {
+		k8sNode.Status.Addresses = append(k8sNode.Status.Addresses,
+			v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip})
+	}
+	return k8sNode
+}
+
+func TestReconcileFileVolumeNodeACLs(t *testing.T) {
+	ctx := context.Background()
+	fileVolumeID := "file:8c1d7a52-4a0e-4f8b-9e6b-6f1f7c2d9e31"
+	readOnlyVolumeID := "file:2f3b5c1e-7e0a-4d55-a4a3-1bfc0b6a3f10"
+	sharedVolumeID := "file:7a524f8b-9e6b-4f1f-8c2d-9e314a0e8c1d"
+	filePV := newTestCSIPV("pv-file", fileVolumeID)
+	filePV.Spec.CSI.VolumeAttributes = map[string]string{common.AttributeNetPermissionRootSquash: "true"}
+	readOnlyPV := newTestCSIPV("pv-file-ro", readOnlyVolumeID)
+	readOnlyPV.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadOnlyMany}
+	smbPV := newTestCSIPV("pv-smb", "file:4a0e8c1d-7a52-4f8b-9e6b-2f3b5c1e7e0a")
+	smbPV.Spec.CSI.VolumeAttributes = map[string]string{common.AttributeFileProtocol: common.FileProtocolSMB}
+	var vas []runtime.Object
+	for _, pvName := range []string{"pv-file", "pv-file-ro", "pv-block", "pv-smb", "pv-shared"} {
+		va := newTestVolumeAttachment("va-"+pvName, "node-1", pvName)
+		va.Status.Attached = true
+		vas = append(vas, va)
+	}
+	// The shared volume is also published to node-2, which was granted access
+	// for the IP node-1 no longer has.
+	vaShared := newTestVolumeAttachment("va-pv-shared-node-2", "node-2", "pv-shared")
+	vaShared.Status.Attached = true
+	vaShared.Status.AttachmentMetadata = map[string]string{common.FileVolumeNodeIPs: "10.0.0.1"}
+	oldNode := newTestNode("node-1", "10.0.0.1")
+	newNode := newTestNode("node-1", "10.0.0.2", "10.0.0.3")
+	k8sclient := k8sfake.NewSimpleClientset(append(vas, newNode, newTestNode("node-2", "10.0.0.4"),
+		filePV, readOnlyPV, smbPV, newTestCSIPV("pv-shared", sharedVolumeID),
+		newTestCSIPV("pv-block", "6f1f7c2d-9e31-4a0e-8c1d-7a524f8b9e6b"), vaShared)...)
+
+	specs := make(map[string][]cnstypes.CnsVolumeACLConfigureSpec)
+	r := &fileVolumeNodeACLReconciler{
+		k8sClient:  k8sclient,
+		nodeQueue:  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test"),
+		grantedIPs: make(map[string][]string),
+		getNodeIPs: func(ctx context.Context, k8sNode *v1.Node) ([]string, error) {
+			// 10.0.0.3 is not reported by VMware Tools for the node VM.
+			return []string{"10.0.0.2"}, nil
+		},
+		configureVolumeACLs: func(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
+			specs[spec.VolumeId.Id] = append(specs[spec.VolumeId.Id], spec)
+			return nil
+		},
+	}
+	defer r.nodeQueue.ShutDown()
+	r.nodeUpdated(oldNode, newNode)
+	r.nodeUpdated(newNode, newTestNode("node-1", "10.0.0.2"))
+	if r.nodeQueue.Len() != 1 {
+		t.Fatalf("expected node-1 to be queued once, got %d queued nodes", r.nodeQueue.Len())
+	}
+	if err := r.reconcileNode(ctx, "node-1"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(specs) != 3 {
+		t.Fatalf("expected net permissions of the 3 NFS file volumes to be updated, got %+v", specs)
+	}
+	expected := map[string]vsanfstypes.VsanFileShareNetPermission{
+		fileVolumeID: {
+			Ips:         "10.0.0.2",
+			Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_WRITE,
+			AllowRoot:   false,
+		},
+		readOnlyVolumeID: {
+			Ips:         "10.0.0.2",
+			Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_ONLY,
+			AllowRoot:   true,
+		},
+	}
+	for volumeID, permission := range expected {
+		volumeSpecs := specs[volumeID]
+		if len(volumeSpecs) != 2 {
+			t.Fatalf("expected access to volume %q to be granted and revoked, got %+v", volumeID, volumeSpecs)
+		}
+		grant := volumeSpecs[0].AccessControlSpecList[0]
+		if grant.Delete || !reflect.DeepEqual(grant.Permission, []vsanfstypes.VsanFileShareNetPermission{permission}) {
+			t.Errorf("expected access to volume %q to be granted with %+v, got %+v", volumeID, permission, grant)
+		}
+		revoke := volumeSpecs[1].AccessControlSpecList[0]
+		if !revoke.Delete || len(revoke.Permission) != 1 || revoke.Permission[0].Ips != "10.0.0.1" {
+			t.Errorf("expected access to volume %q to be revoked for 10.0.0.1, got %+v", volumeID, revoke)
+		}
+	}
+	if sharedSpecs := specs[sharedVolumeID]; len(sharedSpecs) != 1 || sharedSpecs[0].AccessControlSpecList[0].Delete {
+		t.Errorf("expected access to volume %q to be granted only, got %+v", sharedVolumeID, sharedSpecs)
+	}
+
+	// The node is up to date once reconciled.
+	specs = make(map[string][]cnstypes.CnsVolumeACLConfigureSpec)
+	if err := r.reconcileNode(ctx, "node-1"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(specs) != 0 {
+		t.Errorf("expected no net permissions to be updated, got %+v", specs)
+	}
+}
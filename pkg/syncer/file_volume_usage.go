@@ -0,0 +1,266 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+)
+
+// fileShareUsage is the used capacity and the hard limit of the vSAN file
+// share backing a file volume.
+type fileShareUsage struct {
+	usedInMb     int64
+	capacityInMb int64
+}
+
+// String returns the usage in the format "<used>/<hard limit>", e.g. "1Gi/10Gi".
+func (u fileShareUsage) String() string {
+	return fmt.Sprintf("%s/%s", resource.NewQuantity(u.usedInMb*common.MbInBytes, resource.BinarySI),
+		resource.NewQuantity(u.capacityInMb*common.MbInBytes, resource.BinarySI))
+}
+
+// reportedFileVolumeUsagePVCs holds the PVCs whose file volume usage metrics
+// were set in the last successful file volume usage cycle, keyed by VC.
+var reportedFileVolumeUsagePVCs = make(map[string]map[k8stypes.NamespacedName]struct{})
+
+// csiGetFileVolumeUsage reads the used capacity of the file shares backing the
+// file volumes on the given VC, and reports it on the bound PVCs as metrics
+// and as the annotation annFileShareUsage. It returns the PVCs reported, and
+// an error if the usage of some of the file volumes could not be read.
+func csiGetFileVolumeUsage(ctx context.Context, k8sclient clientset.Interface,
+	metadataSyncer *metadataSyncInformer, vc string) (map[k8stypes.NamespacedName]struct{}, error) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("csiGetFileVolumeUsage for %s: start", vc)
+	reported := make(map[k8stypes.NamespacedName]struct{})
+	queryFilter := cnstypes.CnsQueryFilter{
+		ContainerClusterIds: []string{
+			clusterIDforVolumeMetadata,
+		},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypeBackingObjectDetails),
+			string(cnstypes.QuerySelectionNameTypeVolumeType),
+			string(cnstypes.QuerySelectionNameTypeDataStoreUrl),
+		},
+	}
+	cnsVolumeMgr, err := getVolManagerForVcHost(ctx, vc, metadataSyncer)
+	if err != nil {
+		return reported, logger.LogNewErrorf(log, "csiGetFileVolumeUsage for %s: Failed to get volume manager. Err: %v",
+			vc, err)
+	}
+	queryAllResult, err := cnsVolumeMgr.QueryAllVolume(ctx, queryFilter, querySelection)
+	if err != nil {
+		return reported, logger.LogNewErrorf(log, "csiGetFileVolumeUsage for %s: failed to QueryAllVolume with err=%+v",
+			vc, err)
+	}
+	fileVolumes := make([]cnstypes.CnsVolume, 0)
+	for _, vol := range queryAllResult.Volumes {
+		if vol.VolumeType == string(cnstypes.CnsVolumeTypeFile) {
+			fileVolumes = append(fileVolumes, vol)
+		}
+	}
+	if len(fileVolumes) == 0 {
+		log.Debugf("csiGetFileVolumeUsage for %s: no file volumes found", vc)
+		return reported, nil
+	}
+
+	var vcenter *cnsvsphere.VirtualCenter
+	if isMultiVCenterFssEnabled {
+		vcenter, err = cnsvsphere.GetVirtualCenterInstanceForVCenterHost(ctx, vc, true)
+	} else {
+		vcenter, err = cnsvsphere.GetVirtualCenterInstance(ctx, metadataSyncer.configInfo, false)
+	}
+	if err != nil {
+		return reported, logger.LogNewErrorf(log, "csiGetFileVolumeUsage for %s: failed to get virtual center "+
+			"instance. Err: %v", vc, err)
+	}
+	fsEnabledClusterToDsMap, err := common.GenerateFSEnabledClustersToDsMap(ctx, vcenter)
+	if err != nil {
+		return reported, logger.LogNewErrorf(log, "csiGetFileVolumeUsage for %s: failed to get file service enabled "+
+			"clusters. Err: %v", vc, err)
+	}
+	usedCapacityByShare := make(map[string]int64)
+	var failedClusters []string
+	for cluster, datastores := range fsEnabledClusterToDsMap {
+		if !hasVolumesOnDatastores(fileVolumes, datastores) {
+			continue
+		}
+		usedCapacity, err := vcenter.QueryFileShareUsedCapacity(ctx, vimtypes.ManagedObjectReference{
+			Type:  "ClusterComputeResource",
+			Value: cluster,
+		})
+		if err != nil {
+			log.Errorf("csiGetFileVolumeUsage for %s: failed to get used capacity of file shares on cluster %q. "+
+				"Err: %v", vc, cluster, err)
+			failedClusters = append(failedClusters, cluster)
+			continue
+		}
+		for shareUUID, usedInMb := range usedCapacity {
+			usedCapacityByShare[shareUUID] = usedInMb
+		}
+	}
+	volumeIDToUsage := getFileShareUsages(fileVolumes, usedCapacityByShare)
+
+	// Get K8s PVs in State "Bound".
+	k8sPVs, err := getBoundPVs(ctx, metadataSyncer)
+	if err != nil {
+		return reported, logger.LogNewErrorf(log, "csiGetFileVolumeUsage for %s: Failed to get PVs from kubernetes. "+
+			"Err: %+v", vc, err)
+	}
+	for _, pv := range k8sPVs {
+		if pv.Spec.CSI == nil || pv.Spec.ClaimRef == nil {
+			continue
+		}
+		usage, ok := volumeIDToUsage[pv.Spec.CSI.VolumeHandle]
+		if !ok {
+			continue
+		}
+		pvc, err := metadataSyncer.pvcLister.PersistentVolumeClaims(
+			pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name)
+		if err != nil {
+			log.Warnf("csiGetFileVolumeUsage for %s: Failed to get pvc for namespace %s and name %s. err=%+v",
+				vc, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, err)
+			continue
+		}
+		prometheus.FileVolumeUsedBytesGaugeVec.WithLabelValues(pvc.Namespace, pvc.Name).Set(
+			float64(usage.usedInMb * common.MbInBytes))
+		prometheus.FileVolumeCapacityBytesGaugeVec.WithLabelValues(pvc.Namespace, pvc.Name).Set(
+			float64(usage.capacityInMb * common.MbInBytes))
+		reported[k8stypes.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}] = struct{}{}
+		if err := updateFileShareUsageAnnotation(ctx, k8sclient, pvc, usage.String()); err != nil {
+			log.Errorf("csiGetFileVolumeUsage for %s: Failed to update file share usage of pvc %s/%s. Err: %v",
+				vc, pvc.Namespace, pvc.Name, err)
+		}
+	}
+	if len(failedClusters) != 0 {
+		return reported, logger.LogNewErrorf(log, "csiGetFileVolumeUsage for %s: failed to get used capacity "+
+			"of file shares on clusters %v", vc, failedClusters)
+	}
+	log.Debugf("csiGetFileVolumeUsage for %s: end", vc)
+	return reported, nil
+}
+
+// hasVolumesOnDatastores returns true if any of the given volumes is on one
+// of the given datastores.
+func hasVolumesOnDatastores(volumes []cnstypes.CnsVolume, datastores []*cnsvsphere.DatastoreInfo) bool {
+	for _, vol := range volumes {
+		for _, ds := range datastores {
+			if ds.Info.Url == vol.DatastoreUrl {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getFileShareUsages returns the usage of the given file volumes keyed by
+// volume ID, using the used capacity of the vSAN file shares keyed by file
+// share UUID. Volumes whose file share was not found are skipped.
+func getFileShareUsages(fileVolumes []cnstypes.CnsVolume,
+	usedCapacityByShare map[string]int64) map[string]fileShareUsage {
+	volumeIDToUsage := make(map[string]fileShareUsage)
+	for _, vol := range fileVolumes {
+		usedInMb, ok := usedCapacityByShare[strings.TrimPrefix(vol.VolumeId.Id, cnsvolumeinfo.FileVolumePrefix)]
+		if !ok {
+			continue
+		}
+		usage := fileShareUsage{usedInMb: usedInMb}
+		if backing, ok := vol.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails); ok {
+			usage.capacityInMb = backing.CapacityInMb
+		}
+		volumeIDToUsage[vol.VolumeId.Id] = usage
+	}
+	return volumeIDToUsage
+}
+
+// deleteStaleFileVolumeUsageMetrics removes the file volume usage metrics of
+// the PVCs reported for the given VC in the previous cycle but not in the
+// current one. It is only called once the usage of all the file volumes on the
+// VC was read, so that the metrics are not dropped on a transient failure.
+func deleteStaleFileVolumeUsageMetrics(vc string, reported map[k8stypes.NamespacedName]struct{}) {
+	for pvc := range reportedFileVolumeUsagePVCs[vc] {
+		if _, ok := reported[pvc]; !ok {
+			prometheus.FileVolumeUsedBytesGaugeVec.DeleteLabelValues(pvc.Namespace, pvc.Name)
+			prometheus.FileVolumeCapacityBytesGaugeVec.DeleteLabelValues(pvc.Namespace, pvc.Name)
+		}
+	}
+	reportedFileVolumeUsagePVCs[vc] = reported
+}
+
+// deleteFileVolumeUsageMetricsOfRemovedVCs removes the file volume usage
+// metrics reported for the VCs which are not in the given VCs anymore.
+func deleteFileVolumeUsageMetricsOfRemovedVCs(vcs map[string]struct{}) {
+	for vc := range reportedFileVolumeUsagePVCs {
+		if _, ok := vcs[vc]; !ok {
+			deleteStaleFileVolumeUsageMetrics(vc, nil)
+			delete(reportedFileVolumeUsagePVCs, vc)
+		}
+	}
+}
+
+// updateFileShareUsageAnnotation sets the annFileShareUsage annotation of the
+// given pvc to the given usage if it changed.
+func updateFileShareUsageAnnotation(ctx context.Context, k8sclient clientset.Interface,
+	pvc *v1.PersistentVolumeClaim, usage string) error {
+	log := logger.GetLogger(ctx)
+	if val, found := pvc.Annotations[annFileShareUsage]; found && val == usage {
+		log.Debugf("updateFileShareUsageAnnotation: no change to file share usage of pvc %s/%s, skip update",
+			pvc.Namespace, pvc.Name)
+		return nil
+	}
+	// pvc from pvcLister must not be modified.
+	newPvc := pvc.DeepCopy()
+	metav1.SetMetaDataAnnotation(&newPvc.ObjectMeta, annFileShareUsage, usage)
+	_, err := k8sclient.CoreV1().PersistentVolumeClaims(newPvc.Namespace).Update(ctx, newPvc, metav1.UpdateOptions{})
+	if err != nil && apierrors.IsConflict(err) {
+		log.Debugf("updateFileShareUsageAnnotation: Failed to update pvc %s/%s with err:%+v, "+
+			"will retry the update", pvc.Namespace, pvc.Name, err)
+		// pvc get from pvcLister may be stale, try to get updated pvc from
+		// API server.
+		newPvc, err = k8sclient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		metav1.SetMetaDataAnnotation(&newPvc.ObjectMeta, annFileShareUsage, usage)
+		_, err = k8sclient.CoreV1().PersistentVolumeClaims(newPvc.Namespace).Update(ctx, newPvc,
+			metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	log.Debugf("updateFileShareUsageAnnotation: set file share usage of pvc %s/%s to %s",
+		pvc.Namespace, pvc.Name, usage)
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+)
+
+func TestGetFileShareUsages(t *testing.T) {
+	fileVolumes := []cnstypes.CnsVolume{
+		{
+			VolumeId: cnstypes.CnsVolumeId{Id: "file:2f3b5c1e-7e0a-4d55-a4a3-1bfc0b6a3f10"},
+			BackingObjectDetails: &cnstypes.CnsVsanFileShareBackingDetails{
+				CnsFileBackingDetails: cnstypes.CnsFileBackingDetails{
+					CnsBackingObjectDetails: cnstypes.CnsBackingObjectDetails{CapacityInMb: 10240},
+				},
+			},
+		},
+		{
+			// File share not found on the file service enabled clusters.
+			VolumeId: cnstypes.CnsVolumeId{Id: "file:8c1d7a52-4a0e-4f8b-9e6b-6f1f7c2d9e31"},
+			BackingObjectDetails: &cnstypes.CnsVsanFileShareBackingDetails{
+				CnsFileBackingDetails: cnstypes.CnsFileBackingDetails{
+					CnsBackingObjectDetails: cnstypes.CnsBackingObjectDetails{CapacityInMb: 1024},
+				},
+			},
+		},
+	}
+	usedCapacityByShare := map[string]int64{"2f3b5c1e-7e0a-4d55-a4a3-1bfc0b6a3f10": 512}
+
+	usages := getFileShareUsages(fileVolumes, usedCapacityByShare)
+	if len(usages) != 1 {
+		t.Fatalf("expected usage of 1 volume, got %v", usages)
+	}
+	usage, ok := usages["file:2f3b5c1e-7e0a-4d55-a4a3-1bfc0b6a3f10"]
+	if !ok || usage.usedInMb != 512 || usage.capacityInMb != 10240 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+	if usage.String() != "512Mi/10Gi" {
+		t.Errorf("usage.String() = %q, want %q", usage.String(), "512Mi/10Gi")
+	}
+}
+
+func TestDeleteStaleFileVolumeUsageMetrics(t *testing.T) {
+	defer func() {
+		prometheus.FileVolumeUsedBytesGaugeVec.Reset()
+		reportedFileVolumeUsagePVCs = make(map[string]map[k8stypes.NamespacedName]struct{})
+	}()
+	pvc1 := k8stypes.NamespacedName{Namespace: "team-a", Name: "data-1"}
+	pvc2 := k8stypes.NamespacedName{Namespace: "team-a", Name: "data-2"}
+	pvc3 := k8stypes.NamespacedName{Namespace: "team-b", Name: "data-3"}
+	for _, pvc := range []k8stypes.NamespacedName{pvc1, pvc2, pvc3} {
+		prometheus.FileVolumeUsedBytesGaugeVec.WithLabelValues(pvc.Namespace, pvc.Name).Set(1024)
+	}
+	deleteStaleFileVolumeUsageMetrics("vc-1", map[k8stypes.NamespacedName]struct{}{pvc1: {}, pvc2: {}})
+	deleteStaleFileVolumeUsageMetrics("vc-2", map[k8stypes.NamespacedName]struct{}{pvc3: {}})
+
+	// pvc2 is not reported by vc-1 anymore, while the usage of vc-2 could not
+	// be read, so its metrics are kept.
+	deleteStaleFileVolumeUsageMetrics("vc-1", map[k8stypes.NamespacedName]struct{}{pvc1: {}})
+	if count := promtestutil.CollectAndCount(prometheus.FileVolumeUsedBytesGaugeVec); count != 2 {
+		t.Fatalf("expected metrics of 2 PVCs, got %d", count)
+	}
+	// vc-2 is removed from the config.
+	deleteFileVolumeUsageMetricsOfRemovedVCs(map[string]struct{}{"vc-1": {}})
+	if count := promtestutil.CollectAndCount(prometheus.FileVolumeUsedBytesGaugeVec); count != 1 {
+		t.Fatalf("expected metrics of 1 PVC, got %d", count)
+	}
+	if _, ok := reportedFileVolumeUsagePVCs["vc-2"]; ok {
+		t.Errorf("expected reported PVCs of removed vc-2 to be forgotten")
+	}
+}
+
+func TestUpdateFileShareUsageAnnotation(t *testing.T) {
+	ctx := context.Background()
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "data",
+			Namespace: "team-a",
+		},
+	}
+	k8sclient := k8sfake.NewSimpleClientset(pvc)
+
+	if err := updateFileShareUsageAnnotation(ctx, k8sclient, pvc, "512Mi/10Gi"); err != nil {
+		t.Fatalf("updateFileShareUsageAnnotation() failed: %v", err)
+	}
+	updated, err := k8sclient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Annotations[annFileShareUsage] != "512Mi/10Gi" {
+		t.Errorf("expected annotation %q to be %q, got %v", annFileShareUsage, "512Mi/10Gi", updated.Annotations)
+	}
+	if len(pvc.Annotations) != 0 {
+		t.Errorf("expected the given pvc not to be modified, got annotations %v", pvc.Annotations)
+	}
+
+	// An unchanged usage does not update the pvc.
+	k8sclient.ClearActions()
+	if err := updateFileShareUsageAnnotation(ctx, k8sclient, updated, "512Mi/10Gi"); err != nil {
+		t.Fatalf("updateFileShareUsageAnnotation() failed: %v", err)
+	}
+	if len(k8sclient.Actions()) != 0 {
+		t.Errorf("expected no update of unchanged usage, got actions %v", k8sclient.Actions())
+	}
+}
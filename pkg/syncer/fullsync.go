@@ -19,6 +19,7 @@ package syncer
 import (
 	"context"
 	"errors"
+	"strings"
 	"sync"
 	"time"
 
@@ -33,15 +34,20 @@ import (
 	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/tracing"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
 	cnsvolumeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo/v1alpha1"
 )
 
 // CsiFullSync reconciles volume metadata on a vanilla k8s cluster with volume
 // metadata on CNS.
 func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc string) error {
+	ctx, span := tracing.StartSpan(ctx, "syncer.FullSync", tracing.VCenterKey.String(vc))
+	defer span.End()
 	log := logger.GetLogger(ctx)
 	log.Infof("FullSync for VC %s: start", vc)
 	fullSyncStartTime := time.Now()
@@ -58,6 +64,7 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 		fullSyncStatus := prometheus.PrometheusPassStatus
 		if err != nil {
 			fullSyncStatus = prometheus.PrometheusFailStatus
+			tracing.RecordError(span, err)
 		}
 		prometheus.FullSyncOpsHistVec.WithLabelValues(fullSyncStatus).Observe(
 			(time.Since(fullSyncStartTime)).Seconds())
@@ -278,6 +285,11 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 	go fullSyncDeleteVolumes(ctx, volToBeDeleted, metadataSyncer, &wg, migrationFeatureStateForFullSync, volManager, vc)
 	wg.Wait()
 
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
+		fullSyncProtectVolumesFromVMDeletion(ctx, k8sPVs,
+			metadataSyncer.configInfo.Cfg.Global.ProtectVolumesFromVMDeletion, volManager, vc)
+	}
+
 	// Sync VolumeInfo CRs
 	if isMultiVCenterFssEnabled && len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 {
 		volumeInfoCRFullSync(ctx, metadataSyncer, vc)
@@ -445,7 +457,6 @@ func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVo
 			volumeID = createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskId
 		} else if createSpec.VolumeType == common.FileVolumeType && createSpec.BackingObjectDetails != nil &&
 			createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails) != nil {
-			// We should never reach here in case of multi VC deployment as file share volumes are already filtered out.
 			volumeID = createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails).BackingFileId
 		} else {
 			log.Warnf("Skipping createSpec: %+v as VolumeType is unknown or BackingObjectDetails is not valid",
@@ -602,6 +613,45 @@ func fullSyncUpdateVolumes(ctx context.Context, updateSpecArray []cnstypes.CnsVo
 	}
 }
 
+// fullSyncProtectVolumesFromVMDeletion sets the keepAfterDeleteVm control flag
+// on the CSI block volumes requested to be protected from VM deletion, either
+// in their StorageClass or by the given default from the driver config. This
+// protects the volumes created before the protection was enabled. Volumes
+// protected once are not protected again in the later full syncs, and volumes
+// found protected already, e.g. after a restart of the syncer, are skipped.
+func fullSyncProtectVolumesFromVMDeletion(ctx context.Context, k8sPVs []*v1.PersistentVolume,
+	defaultValue bool, volManager volumes.Manager, vc string) {
+	log := logger.GetLogger(ctx)
+	for _, pv := range k8sPVs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name ||
+			strings.HasPrefix(pv.Spec.CSI.VolumeHandle, cnsvolumeinfo.FileVolumePrefix) {
+			continue
+		}
+		volumeID := pv.Spec.CSI.VolumeHandle
+		if _, ok := volumesProtectedFromVMDeletion.Load(volumeID); ok {
+			continue
+		}
+		if !common.IsProtectFromVMDeletionRequested(pv.Spec.CSI.VolumeAttributes, defaultValue) {
+			continue
+		}
+		vStorageObject, err := volManager.RetrieveVStorageObject(ctx, volumeID)
+		if err != nil {
+			log.Debugf("FullSync for VC %s: failed to retrieve volume %q of PV %q, protecting it from VM "+
+				"deletion. Err: %v", vc, volumeID, pv.Name, err)
+		} else if keep := vStorageObject.Config.KeepAfterDeleteVm; keep != nil && *keep {
+			volumesProtectedFromVMDeletion.Store(volumeID, struct{}{})
+			continue
+		}
+		if err := volManager.ProtectVolumeFromVMDeletion(ctx, volumeID); err != nil {
+			log.Warnf("FullSync for VC %s: failed to protect volume %q of PV %q from VM deletion. Err: %v",
+				vc, volumeID, pv.Name, err)
+			continue
+		}
+		log.Infof("FullSync for VC %s: protected volume %q of PV %q from VM deletion", vc, volumeID, pv.Name)
+		volumesProtectedFromVMDeletion.Store(volumeID, struct{}{})
+	}
+}
+
 // buildCnsMetadataList build metadata list for given PV.
 // Metadata list may include PV metadata, PVC metadata and POD metadata.
 func buildCnsMetadataList(ctx context.Context, pv *v1.PersistentVolume, pvToPVCMap pvcMap,
@@ -793,8 +843,7 @@ func fullSyncGetVolumeSpecs(ctx context.Context, vCenterVersion string, pvList [
 		switch operationType {
 		case "createVolume":
 			var volumeType string
-			if IsMultiAttachAllowed(pv) {
-				// We should never reach here in case of multi VC deployment as file share volumes are already filtered out.
+			if isFileShareVolume(pv) {
 				volumeType = common.FileVolumeType
 			} else {
 				volumeType = common.BlockVolumeType
@@ -826,8 +875,7 @@ func fullSyncGetVolumeSpecs(ctx context.Context, vCenterVersion string, pvList [
 			// to update this volume.
 			log.Debugf("FullSync for VC %s: Volume with id %q added to volume update list", vc, volumeHandle)
 			var volumeType string
-			if IsMultiAttachAllowed(pv) {
-				// We should never reach here in case of multi VC deployment as file share volumes are already filtered out.
+			if isFileShareVolume(pv) {
 				volumeType = common.FileVolumeType
 			} else {
 				volumeType = common.BlockVolumeType
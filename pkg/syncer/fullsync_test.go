@@ -0,0 +1,112 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	vim25types "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+// fakeProtectVolumeManager records the volumes protected from VM deletion.
+type fakeProtectVolumeManager struct {
+	volumes.Manager
+	protected []string
+	failures  map[string]bool
+	// keepAfterDeleteVm holds the volumes protected from VM deletion already.
+	keepAfterDeleteVm map[string]bool
+}
+
+func (m *fakeProtectVolumeManager) RetrieveVStorageObject(ctx context.Context,
+	volumeID string) (*vim25types.VStorageObject, error) {
+	if m.failures[volumeID] {
+		return nil, errors.New("vslm error")
+	}
+	keep := m.keepAfterDeleteVm[volumeID]
+	return &vim25types.VStorageObject{
+		Config: vim25types.VStorageObjectConfigInfo{
+			BaseConfigInfo: vim25types.BaseConfigInfo{Id: vim25types.ID{Id: volumeID}, KeepAfterDeleteVm: &keep},
+		},
+	}, nil
+}
+
+func (m *fakeProtectVolumeManager) ProtectVolumeFromVMDeletion(ctx context.Context, volumeID string) error {
+	if m.failures[volumeID] {
+		return errors.New("vslm error")
+	}
+	m.protected = append(m.protected, volumeID)
+	return nil
+}
+
+func TestFullSyncProtectVolumesFromVMDeletion(t *testing.T) {
+	ctx := context.Background()
+	optedOutPV := newTestCSIPV("pv-opted-out", "vol-opted-out")
+	optedOutPV.Spec.CSI.VolumeAttributes = map[string]string{common.AttributeProtectFromVMDeletion: "false"}
+	migratedPV := newTestCSIPV("pv-migrated", "")
+	migratedPV.Spec.CSI = nil
+	migratedPV.Spec.VsphereVolume = &v1.VsphereVirtualDiskVolumeSource{VolumePath: "[vsanDatastore] kubevols/disk-1.vmdk"}
+	k8sPVs := []*v1.PersistentVolume{
+		newTestCSIPV("pv-1", "vol-1"),
+		newTestCSIPV("pv-2", "vol-2"),
+		newTestCSIPV("pv-file", "file:vol-file"),
+		optedOutPV,
+		migratedPV,
+	}
+	volManager := &fakeProtectVolumeManager{failures: map[string]bool{"vol-2": true}}
+	fullSyncProtectVolumesFromVMDeletion(ctx, k8sPVs, true, volManager, "vc-1")
+	if !reflect.DeepEqual(volManager.protected, []string{"vol-1"}) {
+		t.Errorf("expected volume vol-1 to be protected, got %v", volManager.protected)
+	}
+
+	// Only the volume which failed to be protected is protected again.
+	volManager.failures = nil
+	volManager.protected = nil
+	fullSyncProtectVolumesFromVMDeletion(ctx, k8sPVs, true, volManager, "vc-1")
+	if !reflect.DeepEqual(volManager.protected, []string{"vol-2"}) {
+		t.Errorf("expected volume vol-2 to be protected, got %v", volManager.protected)
+	}
+
+	// Volumes of other drivers and volumes protected already, e.g. before a
+	// restart of the syncer, are not protected again.
+	otherDriverPV := newTestCSIPV("pv-other-driver", "vol-other-driver")
+	otherDriverPV.Spec.CSI.Driver = "csi.example.com"
+	volManager.protected = nil
+	volManager.keepAfterDeleteVm = map[string]bool{"vol-4": true}
+	fullSyncProtectVolumesFromVMDeletion(ctx, []*v1.PersistentVolume{otherDriverPV,
+		newTestCSIPV("pv-4", "vol-4"), newTestCSIPV("pv-5", "vol-5")}, true, volManager, "vc-1")
+	if !reflect.DeepEqual(volManager.protected, []string{"vol-5"}) {
+		t.Errorf("expected volume vol-5 to be protected, got %v", volManager.protected)
+	}
+	if _, ok := volumesProtectedFromVMDeletion.Load("vol-4"); !ok {
+		t.Errorf("expected volume vol-4 to be recorded as protected")
+	}
+
+	// No volume is protected when not requested.
+	volManager.protected = nil
+	fullSyncProtectVolumesFromVMDeletion(ctx, []*v1.PersistentVolume{newTestCSIPV("pv-3", "vol-3")}, false,
+		volManager, "vc-1")
+	if len(volManager.protected) != 0 {
+		t.Errorf("expected no volume to be protected, got %v", volManager.protected)
+	}
+}
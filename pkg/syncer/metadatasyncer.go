@@ -56,6 +56,8 @@ import (
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco/k8sorchestrator"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis"
+	internalapiscnsoperatorconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/config"
 	triggercsifullsyncv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/triggercsifullsync/v1alpha1"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/csinodetopology"
@@ -166,6 +168,88 @@ func getPVtoBackingDiskObjectIdIntervalInMin(ctx context.Context) int {
 	return pvtoBackingDiskObjectIdIntervalInMin
 }
 
+// getFileVolumeUsageIntervalInMin returns file volume usage interval.
+func getFileVolumeUsageIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	fileVolumeUsageIntervalInMin := defaultFileVolumeUsageIntervalInMin
+	if v := os.Getenv("FILE_VOLUME_USAGE_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil {
+			if value <= 0 {
+				log.Warnf("FileVolumeUsage: FileVolumeUsage interval set in env variable "+
+					"FILE_VOLUME_USAGE_INTERVAL_MINUTES %s is equal or less than 0, will use the default interval", v)
+			} else {
+				fileVolumeUsageIntervalInMin = value
+				log.Infof("FileVolumeUsage: FileVolumeUsage interval is set to %d minutes",
+					fileVolumeUsageIntervalInMin)
+			}
+		} else {
+			log.Warnf("FileVolumeUsage: FileVolumeUsage interval set in env variable "+
+				"FILE_VOLUME_USAGE_INTERVAL_MINUTES %s is invalid, will use the default interval", v)
+		}
+	}
+	return fileVolumeUsageIntervalInMin
+}
+
+// getVolumeAttachmentSweepIntervalInMin returns volume attachment sweep interval.
+func getVolumeAttachmentSweepIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	volumeAttachmentSweepIntervalInMin := defaultVolumeAttachmentSweepIntervalInMin
+	if v := os.Getenv("VOLUME_ATTACHMENT_SWEEP_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil {
+			if value <= 0 {
+				log.Warnf("VolumeAttachmentSweep: VolumeAttachmentSweep interval set in env variable "+
+					"VOLUME_ATTACHMENT_SWEEP_INTERVAL_MINUTES %s is equal or less than 0, will use the default interval",
+					v)
+			} else {
+				volumeAttachmentSweepIntervalInMin = value
+				log.Infof("VolumeAttachmentSweep: VolumeAttachmentSweep interval is set to %d minutes",
+					volumeAttachmentSweepIntervalInMin)
+			}
+		} else {
+			log.Warnf("VolumeAttachmentSweep: VolumeAttachmentSweep interval set in env variable "+
+				"VOLUME_ATTACHMENT_SWEEP_INTERVAL_MINUTES %s is invalid, will use the default interval", v)
+		}
+	}
+	return volumeAttachmentSweepIntervalInMin
+}
+
+// isOrphanedDiskDetachEnabled returns true if the disks attached to node VMs
+// without a VolumeAttachment are to be detached by the volume attachment
+// sweep.
+func isOrphanedDiskDetachEnabled(ctx context.Context) bool {
+	log := logger.GetLogger(ctx)
+	v := os.Getenv("VOLUME_ATTACHMENT_SWEEP_DETACH_ORPHANS")
+	if v == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Warnf("VolumeAttachmentSweep: value %s set in env variable VOLUME_ATTACHMENT_SWEEP_DETACH_ORPHANS "+
+			"is invalid, orphaned disks will not be detached", v)
+		return false
+	}
+	log.Infof("VolumeAttachmentSweep: detach of orphaned disks is set to %t", enabled)
+	return enabled
+}
+
+// isFakeAttachedPodEvictionEnabled returns true if the pods using fake
+// attached volumes which are accessible again are to be evicted.
+func isFakeAttachedPodEvictionEnabled(ctx context.Context) bool {
+	log := logger.GetLogger(ctx)
+	v := os.Getenv("FAKE_ATTACHED_POD_EVICTION")
+	if v == "" {
+		return false
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Warnf("FakeAttach: value %s set in env variable FAKE_ATTACHED_POD_EVICTION is invalid, "+
+			"pods using fake attached volumes will not be evicted", v)
+		return false
+	}
+	log.Infof("FakeAttach: eviction of pods using fake attached volumes is set to %t", enabled)
+	return enabled
+}
+
 // InitMetadataSyncer initializes the Metadata Sync Informer.
 func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor,
 	configInfo *cnsconfig.ConfigurationInfo) error {
@@ -485,6 +569,29 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to listen on pods. Error: %v", err)
 	}
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.OutOfServiceNodeDetach) {
+		outOfServiceDetacher := newOutOfServiceNodeDetacher(k8sClient, metadataSyncer)
+		err = metadataSyncer.k8sInformerManager.AddNodeListener(ctx,
+			outOfServiceDetacher.nodeAdded,   // Add.
+			outOfServiceDetacher.nodeUpdated, // Update.
+			nil)                              // Delete.
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to listen on nodes. Error: %v", err)
+		}
+	}
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FileVolumeNodeACLs) {
+		fileVolumeACLReconciler := newFileVolumeNodeACLReconciler(k8sClient, metadataSyncer)
+		err = metadataSyncer.k8sInformerManager.AddNodeListener(ctx,
+			nil,                                 // Add.
+			fileVolumeACLReconciler.nodeUpdated, // Update.
+			nil)                                 // Delete.
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to listen on nodes. Error: %v", err)
+		}
+		go fileVolumeACLReconciler.Run(ctx, fileVolumeACLWorkers)
+	}
 
 	metadataSyncer.pvLister = metadataSyncer.k8sInformerManager.GetPVLister()
 	metadataSyncer.pvcLister = metadataSyncer.k8sInformerManager.GetPVCLister()
@@ -658,11 +765,101 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		}
 	}
 
+	// Trigger get file volume usage on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FileVolumeUsage) {
+		fileVolumeUsageTicker := time.NewTicker(time.Duration(
+			getFileVolumeUsageIntervalInMin(ctx)) * time.Minute)
+		defer fileVolumeUsageTicker.Stop()
+		go func() {
+			for ; true; <-fileVolumeUsageTicker.C {
+				ctx, log = logger.GetNewContextWithLogger()
+				log.Info("get file volume usage is triggered")
+				if !isMultiVCenterFssEnabled {
+					vc := metadataSyncer.configInfo.Cfg.Global.VCenterIP
+					if reported, err := csiGetFileVolumeUsage(ctx, k8sClient, metadataSyncer, vc); err == nil {
+						deleteStaleFileVolumeUsageMetrics(vc, reported)
+					}
+					continue
+				}
+				vcconfigs, err := cnsvsphere.GetVirtualCenterConfigs(ctx, configInfo.Cfg)
+				if err != nil {
+					log.Errorf("failed to get VirtualCenterConfigs. err: %v", err)
+					continue
+				}
+				vcs := make(map[string]struct{})
+				for _, vcconfig := range vcconfigs {
+					vcs[vcconfig.Host] = struct{}{}
+					// Keep the metrics of a VC whose file volume usage could not be read.
+					reported, err := csiGetFileVolumeUsage(ctx, k8sClient, metadataSyncer, vcconfig.Host)
+					if err != nil {
+						continue
+					}
+					deleteStaleFileVolumeUsageMetrics(vcconfig.Host, reported)
+				}
+				deleteFileVolumeUsageMetricsOfRemovedVCs(vcs)
+			}
+		}()
+	}
+
+	// Trigger volume attachment sweep on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.VolumeAttachmentSweep) {
+		volumeAttachmentSweeper := newVolumeAttachmentSweeper(k8sClient, metadataSyncer,
+			isOrphanedDiskDetachEnabled(ctx))
+		volumeAttachmentSweepTicker := time.NewTicker(time.Duration(
+			getVolumeAttachmentSweepIntervalInMin(ctx)) * time.Minute)
+		defer volumeAttachmentSweepTicker.Stop()
+		go func() {
+			for ; true; <-volumeAttachmentSweepTicker.C {
+				ctx, log = logger.GetNewContextWithLogger()
+				log.Info("volume attachment sweep is triggered")
+				volumeAttachmentSweeper.sweep(ctx)
+			}
+		}()
+	}
+
+	// Report the vCenter capabilities on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.VCenterCapabilityStatus) {
+		err := k8s.CreateCustomResourceDefinitionFromManifest(ctx,
+			internalapiscnsoperatorconfig.EmbedVCenterCapabilityStatus,
+			internalapiscnsoperatorconfig.EmbedVCenterCapabilityStatusName)
+		if err != nil {
+			log.Errorf("Failed to create %q CRD. Err: %+v", internalapis.VCenterCapabilityStatusPlural, err)
+			return err
+		}
+		restConfig, err := config.GetConfig()
+		if err != nil {
+			log.Errorf("failed to get Kubernetes config. Err: %+v", err)
+			return err
+		}
+		cnsOperatorClient, err := k8s.NewClientForGroup(ctx, restConfig, cnsoperatorv1alpha1.GroupName)
+		if err != nil {
+			log.Errorf("Failed to create CnsOperator client. Err: %+v", err)
+			return err
+		}
+		vCenterCapabilityStatusTicker := time.NewTicker(vCenterCapabilityStatusIntervalInMin * time.Minute)
+		defer vCenterCapabilityStatusTicker.Stop()
+		go func() {
+			for ; true; <-vCenterCapabilityStatusTicker.C {
+				ctx, log = logger.GetNewContextWithLogger()
+				log.Debug("vCenter capability status update is triggered")
+				err := updateVCenterCapabilityStatus(ctx, cnsOperatorClient, cnsvsphere.GetAllCapabilities())
+				if err != nil {
+					log.Warnf("Failed to report the vCenter capabilities. Err: %v", err)
+				}
+			}
+		}()
+	}
+
 	volumeHealthTicker := time.NewTicker(time.Duration(getVolumeHealthIntervalInMin(ctx)) * time.Minute)
 	defer volumeHealthTicker.Stop()
 
 	// Trigger get volume health status.
 	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
+		fakeAttachedVolumeReconciler := newFakeAttachedVolumeReconciler(k8sClient, metadataSyncer,
+			isFakeAttachedPodEvictionEnabled(ctx))
 		go func() {
 			for ; true; <-volumeHealthTicker.C {
 				ctx, log = logger.GetNewContextWithLogger()
@@ -671,6 +868,11 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 				} else {
 					log.Infof("getVolumeHealthStatus is triggered")
 					csiGetVolumeHealthStatus(ctx, k8sClient, metadataSyncer)
+					// Fake attached volumes are reconciled using the volume health
+					// annotation updated above.
+					if metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FakeAttach) {
+						fakeAttachedVolumeReconciler.reconcile(ctx)
+					}
 				}
 			}
 		}()
@@ -1813,15 +2015,7 @@ func csiPVUpdated(ctx context.Context, newPv *v1.PersistentVolume, oldPv *v1.Per
 		!isdynamicCSIPV && newPv.Spec.CSI != nil {
 		// Static PV is Created.
 		var volumeType string
-		if IsMultiAttachAllowed(oldPv) {
-			// If it is a multi VC setup, then skip this volume as we do not support file share volumes
-			// on a multi VC deployment.
-			if isMultiVCenterFssEnabled && len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 {
-				log.Infof("PVUpdated: %q is a vSphere volume claim in namespace %q."+
-					"File share volumes are not supported in a multi VC setup."+
-					"Skipping PV update.", newPv.Name, newPv.Namespace)
-				return
-			}
+		if isFileShareVolume(oldPv) {
 			volumeType = common.FileVolumeType
 		} else {
 			volumeType = common.BlockVolumeType
@@ -1834,7 +2028,18 @@ func csiPVUpdated(ctx context.Context, newPv *v1.PersistentVolume, oldPv *v1.Per
 
 		// If it is a multi VC deployment, figure out FCD's location based on PV's nodeAffinity rules.
 		if isMultiVCenterFssEnabled && len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 {
-			vcHost, cnsVolumeMgr, err = getVcHostAndVolumeManagerFromPvNodeAffinity(ctx, newPv, metadataSyncer)
+			if volumeType == common.FileVolumeType {
+				// File share volumes created by the driver have their VC recorded in the
+				// CNSVolumeInfo CR. Fall back to nodeAffinity rules for static file share volumes.
+				vcHost, cnsVolumeMgr, err = getVcHostAndVolumeManagerForVolumeID(ctx, metadataSyncer,
+					oldPv.Spec.CSI.VolumeHandle)
+				if err != nil {
+					vcHost, cnsVolumeMgr, err = getVcHostAndVolumeManagerFromPvNodeAffinity(ctx, newPv,
+						metadataSyncer)
+				}
+			} else {
+				vcHost, cnsVolumeMgr, err = getVcHostAndVolumeManagerFromPvNodeAffinity(ctx, newPv, metadataSyncer)
+			}
 			if err != nil {
 				log.Errorf("PVUpdated: Failed to get VC host and volume manager for multi VC setup. "+
 					"Error occoured: %+v", err)
@@ -1978,23 +2183,13 @@ func csiPVDeleted(ctx context.Context, pv *v1.PersistentVolume, metadataSyncer *
 		return
 	}
 
-	if IsMultiAttachAllowed(pv) {
+	if isFileShareVolume(pv) {
 		// If PV is file share volume.
-
-		// If it is a multi VC setup, then skip this volume as we do not support file share volumes
-		// on a multi VC deployment
-		if isMultiVCenterFssEnabled && len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 {
-			log.Debugf("PVDeleted: %q is a vSphere volume claim in namespace %q."+
-				"File share volumes are not supported in a multi VC setup."+
-				"Skipping deletion of PV metadata.", pv.Name, pv.Namespace)
-			return
-		}
-
-		// Setting volumeHandle as empty as there is only 1 VC so volumeID does not matter.
-		vcHost, cnsVolumeMgr, err := getVcHostAndVolumeManagerForVolumeID(ctx, metadataSyncer, "")
+		vcHost, cnsVolumeMgr, err := getVcHostAndVolumeManagerForVolumeID(ctx, metadataSyncer,
+			pv.Spec.CSI.VolumeHandle)
 		if err != nil {
-			log.Errorf("PVDeleted: Failed to get VC host and volume manager for single VC setup. "+
-				"Error occoured: %+v", err)
+			log.Errorf("PVDeleted: Failed to get VC host and volume manager for file volume %q. "+
+				"Error occoured: %+v", pv.Spec.CSI.VolumeHandle, err)
 			return
 		}
 
@@ -0,0 +1,329 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// Event reasons for the volumes force detached from out-of-service nodes.
+	eventReasonVolumeForceDetached      = "VolumeForceDetached"
+	eventReasonVolumeForceDetachFailed  = "VolumeForceDetachFailed"
+	eventReasonOutOfServiceNodeDetached = "OutOfServiceNodeDetached"
+)
+
+// outOfServiceDetachBackoff is the backoff for retrying the detach of the
+// volumes of an out-of-service node until all of them are detached.
+var outOfServiceDetachBackoff = wait.Backoff{
+	Steps:    math.MaxInt32,
+	Duration: 10 * time.Second,
+	Factor:   2.0,
+	Jitter:   0.1,
+	Cap:      5 * time.Minute,
+}
+
+// outOfServiceNodeDetacher detaches the volumes of nodes tainted with
+// node.kubernetes.io/out-of-service from their node VMs through CNS, so that
+// ControllerUnpublishVolume succeeds when the attach/detach controller
+// detaches the volumes from the node, e.g. while the node VM is powered off.
+// The VolumeAttachments are left to the attach/detach controller and the
+// external-attacher: once the pods of the node are deleted, the out-of-service
+// taint makes the attach/detach controller detach the volumes from the node
+// without waiting for them to be unmounted, deleting their VolumeAttachments.
+// The external-attacher then calls ControllerUnpublishVolume, which succeeds
+// as the volumes are no longer attached, and removes the VolumeAttachments.
+// The detacher therefore never updates nor deletes VolumeAttachments.
+type outOfServiceNodeDetacher struct {
+	k8sClient clientset.Interface
+	recorder  record.EventRecorder
+	// nodesInProgress holds the names of the nodes whose volumes are being
+	// detached.
+	nodesInProgress sync.Map
+	// detachVolume detaches the given block volume from the VM of the given
+	// node UUID. It is overridden in unit tests.
+	detachVolume func(ctx context.Context, nodeUUID string, volumeID string) error
+	// revokeFileVolumeAccess revokes the access to the given file volume for
+	// the given node IPs. It is overridden in unit tests.
+	revokeFileVolumeAccess func(ctx context.Context, volumeID string, nodeIPs []string) error
+}
+
+// newOutOfServiceNodeDetacher returns an outOfServiceNodeDetacher recording
+// events through the given kubernetes client.
+func newOutOfServiceNodeDetacher(k8sClient clientset.Interface,
+	metadataSyncer *metadataSyncInformer) *outOfServiceNodeDetacher {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sClient.CoreV1().Events(""),
+		},
+	)
+	d := &outOfServiceNodeDetacher{
+		k8sClient: k8sClient,
+		recorder:  eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: syncerEventComponent}),
+	}
+	d.detachVolume = func(ctx context.Context, nodeUUID string, volumeID string) error {
+		return detachVolumeFromNodeVM(ctx, metadataSyncer, nodeUUID, volumeID)
+	}
+	d.revokeFileVolumeAccess = func(ctx context.Context, volumeID string, nodeIPs []string) error {
+		_, volumeManager, err := getVcHostAndVolumeManagerForVolumeID(ctx, metadataSyncer, volumeID)
+		if err != nil {
+			return err
+		}
+		return volumeManager.ConfigureVolumeACLs(ctx,
+			common.GetFileVolumeNodeACLSpec(volumeID, nodeIPs, false, false, true))
+	}
+	return d
+}
+
+// nodeAdded detaches the volumes of the added node if it is out of service.
+func (d *outOfServiceNodeDetacher) nodeAdded(obj interface{}) {
+	newNode, ok := obj.(*v1.Node)
+	if !ok || newNode == nil || !isNodeOutOfService(newNode) {
+		return
+	}
+	go d.detachVolumesOfNode(newNode)
+}
+
+// nodeUpdated detaches the volumes of the updated node if it has just been
+// tainted as out of service.
+func (d *outOfServiceNodeDetacher) nodeUpdated(oldObj interface{}, newObj interface{}) {
+	oldNode, ok := oldObj.(*v1.Node)
+	if !ok || oldNode == nil {
+		return
+	}
+	newNode, ok := newObj.(*v1.Node)
+	if !ok || newNode == nil {
+		return
+	}
+	if isNodeOutOfService(oldNode) || !isNodeOutOfService(newNode) {
+		return
+	}
+	go d.detachVolumesOfNode(newNode)
+}
+
+// detachVolumesOfNode force detaches the volumes of the given out-of-service
+// node, retrying with outOfServiceDetachBackoff until all of them are detached
+// or the node is no longer out of service.
+func (d *outOfServiceNodeDetacher) detachVolumesOfNode(outOfServiceNode *v1.Node) {
+	ctx, log := logger.GetNewContextWithLogger()
+	if _, inProgress := d.nodesInProgress.LoadOrStore(outOfServiceNode.Name, struct{}{}); inProgress {
+		log.Infof("detachVolumesOfNode: volumes of node %q are already being detached", outOfServiceNode.Name)
+		return
+	}
+	defer d.nodesInProgress.Delete(outOfServiceNode.Name)
+	log.Infof("detachVolumesOfNode: node %q is tainted with %q, detaching its volumes",
+		outOfServiceNode.Name, v1.TaintNodeOutOfService)
+
+	// detached holds the names of the VolumeAttachments whose volume is
+	// detached from the node.
+	detached := make(map[string]struct{})
+	backoff := outOfServiceDetachBackoff
+	for {
+		pending, err := d.detachPendingVolumes(ctx, outOfServiceNode, detached)
+		if err == nil && pending == 0 {
+			break
+		}
+		if err != nil {
+			log.Errorf("detachVolumesOfNode: failed to detach volumes of node %q. Err: %v",
+				outOfServiceNode.Name, err)
+		}
+		delay := backoff.Step()
+		log.Infof("detachVolumesOfNode: %d volumes of node %q are not detached, retrying in %v",
+			pending, outOfServiceNode.Name, delay)
+		time.Sleep(delay)
+		k8sNode, err := d.k8sClient.CoreV1().Nodes().Get(ctx, outOfServiceNode.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || (err == nil && !isNodeOutOfService(k8sNode)) {
+			log.Infof("detachVolumesOfNode: node %q is no longer out of service, stop detaching its volumes",
+				outOfServiceNode.Name)
+			return
+		}
+		if err == nil {
+			outOfServiceNode = k8sNode
+		}
+	}
+	if len(detached) == 0 {
+		log.Infof("detachVolumesOfNode: no volumes attached to node %q", outOfServiceNode.Name)
+		return
+	}
+	d.recorder.Eventf(outOfServiceNode, v1.EventTypeNormal, eventReasonOutOfServiceNodeDetached,
+		"Force detached %d volumes from out-of-service node", len(detached))
+}
+
+// detachPendingVolumes detaches the volumes of the VolumeAttachments of the
+// given out-of-service node which are not in detached yet, and adds the
+// VolumeAttachments of the volumes detached to it. Block volumes are detached
+// from the node VM, while the access of the node to file volumes is revoked.
+// It returns the number of volumes which failed to detach.
+func (d *outOfServiceNodeDetacher) detachPendingVolumes(ctx context.Context, outOfServiceNode *v1.Node,
+	detached map[string]struct{}) (int, error) {
+	log := logger.GetLogger(ctx)
+	volumeAttachments, err := getVolumeAttachmentsOfNode(ctx, d.k8sClient, outOfServiceNode.Name)
+	if err != nil {
+		return 0, logger.LogNewErrorf(log, "failed to list VolumeAttachments of node %q. Err: %v",
+			outOfServiceNode.Name, err)
+	}
+	var nodeUUID string
+	var failed int
+	for _, va := range volumeAttachments {
+		if _, ok := detached[va.Name]; ok {
+			continue
+		}
+		pvName := *va.Spec.Source.PersistentVolumeName
+		pv, err := d.k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+		if err != nil {
+			log.Errorf("detachPendingVolumes: failed to get PV %q attached to node %q. Err: %v",
+				pvName, outOfServiceNode.Name, err)
+			failed++
+			continue
+		}
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+			log.Infof("detachPendingVolumes: skipping PV %q which is not a %q volume", pvName, csitypes.Name)
+			continue
+		}
+		volumeID := pv.Spec.CSI.VolumeHandle
+		if strings.HasPrefix(volumeID, cnsvolumeinfo.FileVolumePrefix) {
+			// File volumes are not attached to the node VM.
+			err = d.revokeFileVolumeAccessOfNode(ctx, va, volumeID, outOfServiceNode)
+		} else {
+			if nodeUUID == "" {
+				nodeUUID, err = k8s.GetNodeUUID(ctx, d.k8sClient, outOfServiceNode.Name)
+				if err != nil || nodeUUID == "" {
+					return failed + 1, logger.LogNewErrorf(log, "failed to get the node VM UUID of node %q. Err: %v",
+						outOfServiceNode.Name, err)
+				}
+			}
+			err = d.detachVolume(ctx, nodeUUID, volumeID)
+		}
+		if err != nil {
+			log.Errorf("detachPendingVolumes: failed to detach volume %q of PV %q from node %q. Err: %v",
+				volumeID, pvName, outOfServiceNode.Name, err)
+			d.recorder.Eventf(pv, v1.EventTypeWarning, eventReasonVolumeForceDetachFailed,
+				"Failed to detach volume from out-of-service node %s: %v", outOfServiceNode.Name, err)
+			prometheus.ForceDetachVolumeCounterVec.WithLabelValues(outOfServiceNode.Name,
+				prometheus.PrometheusFailStatus).Inc()
+			failed++
+			continue
+		}
+		log.Infof("detachPendingVolumes: force detached volume %q of PV %q from node %q",
+			volumeID, pvName, outOfServiceNode.Name)
+		d.recorder.Eventf(pv, v1.EventTypeNormal, eventReasonVolumeForceDetached,
+			"Volume force detached from out-of-service node %s", outOfServiceNode.Name)
+		prometheus.ForceDetachVolumeCounterVec.WithLabelValues(outOfServiceNode.Name,
+			prometheus.PrometheusPassStatus).Inc()
+		detached[va.Name] = struct{}{}
+	}
+	return failed, nil
+}
+
+// revokeFileVolumeAccessOfNode revokes the access of the given out-of-service
+// node to the file volume of the given VolumeAttachment. The access is only
+// revoked if ControllerPublishVolume granted it to the node IPs, which it
+// records in the attachment metadata of the VolumeAttachment.
+func (d *outOfServiceNodeDetacher) revokeFileVolumeAccessOfNode(ctx context.Context,
+	va storagev1.VolumeAttachment, volumeID string, outOfServiceNode *v1.Node) error {
+	grantedIPs, ok := va.Status.AttachmentMetadata[common.FileVolumeNodeIPs]
+	if !ok {
+		return nil
+	}
+	var nodeIPs []string
+	if grantedIPs != "" {
+		nodeIPs = strings.Split(grantedIPs, ",")
+	}
+	// The node IPs may have changed since the volume was published.
+	nodeIPs = append(nodeIPs, subtractIPs(k8s.GetNodeInternalIPs(outOfServiceNode), nodeIPs)...)
+	if len(nodeIPs) == 0 {
+		return nil
+	}
+	return d.revokeFileVolumeAccess(ctx, volumeID, nodeIPs)
+}
+
+// getVolumeAttachmentsOfNode returns the VolumeAttachments of PVs of the
+// driver on the given node.
+func getVolumeAttachmentsOfNode(ctx context.Context, k8sClient clientset.Interface,
+	nodeName string) ([]storagev1.VolumeAttachment, error) {
+	vaList, err := k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var volumeAttachments []storagev1.VolumeAttachment
+	for _, va := range vaList.Items {
+		if va.Spec.Attacher != csitypes.Name || va.Spec.NodeName != nodeName ||
+			va.Spec.Source.PersistentVolumeName == nil {
+			continue
+		}
+		volumeAttachments = append(volumeAttachments, va)
+	}
+	return volumeAttachments, nil
+}
+
+// detachVolumeFromNodeVM detaches the given block volume from the VM of the
+// given node UUID. A node VM which is no longer in the vCenter inventory has
+// no volume attached.
+func detachVolumeFromNodeVM(ctx context.Context, metadataSyncer *metadataSyncInformer,
+	nodeUUID string, volumeID string) error {
+	log := logger.GetLogger(ctx)
+	nodeVM, err := node.GetManager(ctx).GetNodeVMAndUpdateCache(ctx, nodeUUID, nil)
+	if err != nil {
+		if err == cnsvsphere.ErrVMNotFound {
+			log.Infof("detachVolumeFromNodeVM: VM with UUID %q is not present in the VC inventory. "+
+				"Considering volume %q detached.", nodeUUID, volumeID)
+			return nil
+		}
+		return err
+	}
+	volManager, err := getVolManagerForVcHost(ctx, nodeVM.VirtualCenterHost, metadataSyncer)
+	if err != nil {
+		return err
+	}
+	_, err = volManager.DetachVolume(ctx, nodeVM, volumeID)
+	return err
+}
+
+// isNodeOutOfService returns true if the given node has the
+// node.kubernetes.io/out-of-service taint.
+func isNodeOutOfService(k8sNode *v1.Node) bool {
+	for _, taint := range k8sNode.Spec.Taints {
+		if taint.Key == v1.TaintNodeOutOfService {
+			return true
+		}
+	}
+	return false
+}
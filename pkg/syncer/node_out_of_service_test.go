@@ -0,0 +1,210 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func newTestCSIPV(name, volumeHandle string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:       csitypes.Name,
+					VolumeHandle: volumeHandle,
+				},
+			},
+		},
+	}
+}
+
+func newTestVolumeAttachment(name, nodeName, pvName string) *storagev1.VolumeAttachment {
+	return &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: csitypes.Name,
+			NodeName: nodeName,
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+		},
+	}
+}
+
+func TestIsNodeOutOfService(t *testing.T) {
+	k8sNode := &v1.Node{
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{{Key: v1.TaintNodeUnreachable, Effect: v1.TaintEffectNoExecute}},
+		},
+	}
+	if isNodeOutOfService(k8sNode) {
+		t.Errorf("expected node with taint %q not to be out of service", v1.TaintNodeUnreachable)
+	}
+	k8sNode.Spec.Taints = append(k8sNode.Spec.Taints,
+		v1.Taint{Key: v1.TaintNodeOutOfService, Value: "nodeshutdown", Effect: v1.TaintEffectNoExecute})
+	if !isNodeOutOfService(k8sNode) {
+		t.Errorf("expected node with taint %q to be out of service", v1.TaintNodeOutOfService)
+	}
+}
+
+func TestDetachVolumesOfOutOfServiceNode(t *testing.T) {
+	ctx := context.Background()
+	origBackoff := outOfServiceDetachBackoff
+	outOfServiceDetachBackoff = wait.Backoff{Steps: math.MaxInt32, Duration: time.Millisecond}
+	defer func() { outOfServiceDetachBackoff = origBackoff }()
+	outOfServiceNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{{Key: v1.TaintNodeOutOfService, Effect: v1.TaintEffectNoExecute}},
+		},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.2"}},
+		},
+	}
+	csiNode := &storagev1.CSINode{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: storagev1.CSINodeSpec{
+			Drivers: []storagev1.CSINodeDriver{{Name: csitypes.Name, NodeID: "4230d3a2-6d06-ed1b-2f2d-5e5a7a4c3b21"}},
+		},
+	}
+	vaFile := newTestVolumeAttachment("va-file", "node-1", "pv-file")
+	vaFile.Status.AttachmentMetadata = map[string]string{common.FileVolumeNodeIPs: "10.0.0.1"}
+	k8sclient := k8sfake.NewSimpleClientset(outOfServiceNode, csiNode,
+		newTestCSIPV("pv-block", "6f1f7c2d-9e31-4a0e-8c1d-7a524f8b9e6b"),
+		newTestCSIPV("pv-failed", "2f3b5c1e-7e0a-4d55-a4a3-1bfc0b6a3f10"),
+		newTestCSIPV("pv-file", "file:8c1d7a52-4a0e-4f8b-9e6b-6f1f7c2d9e31"),
+		newTestVolumeAttachment("va-block", "node-1", "pv-block"),
+		newTestVolumeAttachment("va-failed", "node-1", "pv-failed"),
+		vaFile,
+		newTestVolumeAttachment("va-other-node", "node-2", "pv-block"))
+
+	detachCalls := make(map[string]int)
+	var revokedIPs []string
+	d := &outOfServiceNodeDetacher{
+		k8sClient: k8sclient,
+		recorder:  record.NewFakeRecorder(10),
+		detachVolume: func(ctx context.Context, nodeUUID string, volumeID string) error {
+			if nodeUUID != "4230d3a2-6d06-ed1b-2f2d-5e5a7a4c3b21" {
+				t.Errorf("unexpected node UUID %q", nodeUUID)
+			}
+			detachCalls[volumeID]++
+			// The first detach of the volume fails and is retried.
+			if volumeID == "2f3b5c1e-7e0a-4d55-a4a3-1bfc0b6a3f10" && detachCalls[volumeID] == 1 {
+				return errors.New("vCenter is not reachable")
+			}
+			return nil
+		},
+		revokeFileVolumeAccess: func(ctx context.Context, volumeID string, nodeIPs []string) error {
+			if volumeID != "file:8c1d7a52-4a0e-4f8b-9e6b-6f1f7c2d9e31" {
+				t.Errorf("unexpected file volume %q", volumeID)
+			}
+			revokedIPs = append(revokedIPs, nodeIPs...)
+			return nil
+		},
+	}
+	d.detachVolumesOfNode(outOfServiceNode)
+
+	expectedCalls := map[string]int{
+		"6f1f7c2d-9e31-4a0e-8c1d-7a524f8b9e6b": 1,
+		"2f3b5c1e-7e0a-4d55-a4a3-1bfc0b6a3f10": 2,
+	}
+	if !reflect.DeepEqual(detachCalls, expectedCalls) {
+		t.Errorf("expected detach calls %v, got %v", expectedCalls, detachCalls)
+	}
+	if expected := []string{"10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(revokedIPs, expected) {
+		t.Errorf("expected access to the file volume to be revoked for %v, got %v", expected, revokedIPs)
+	}
+	// The VolumeAttachments are left to the attach/detach controller.
+	for _, action := range k8sclient.Actions() {
+		if action.GetResource().Resource == "volumeattachments" &&
+			action.GetVerb() != "list" && action.GetVerb() != "get" {
+			t.Errorf("expected VolumeAttachments to be only read, got %q action", action.GetVerb())
+		}
+	}
+	for _, name := range []string{"va-block", "va-failed", "va-file", "va-other-node"} {
+		if _, err := k8sclient.StorageV1().VolumeAttachments().Get(ctx, name, metav1.GetOptions{}); err != nil {
+			t.Errorf("expected VolumeAttachment %q to be kept, got err %v", name, err)
+		}
+	}
+
+	events := d.recorder.(*record.FakeRecorder).Events
+	var warnings, normals int
+	for len(events) > 0 {
+		if strings.HasPrefix(<-events, v1.EventTypeWarning) {
+			warnings++
+		} else {
+			normals++
+		}
+	}
+	// One event per detached volume, one per failed detach and one on the node.
+	if normals != 4 || warnings != 1 {
+		t.Errorf("expected 4 normal events and 1 warning event, got %d and %d", normals, warnings)
+	}
+}
+
+func TestDetachVolumesOfNodeBackInService(t *testing.T) {
+	origBackoff := outOfServiceDetachBackoff
+	outOfServiceDetachBackoff = wait.Backoff{Steps: math.MaxInt32, Duration: time.Millisecond}
+	defer func() { outOfServiceDetachBackoff = origBackoff }()
+	outOfServiceNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: v1.NodeSpec{
+			Taints: []v1.Taint{{Key: v1.TaintNodeOutOfService, Effect: v1.TaintEffectNoExecute}},
+		},
+	}
+	csiNode := &storagev1.CSINode{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: storagev1.CSINodeSpec{
+			Drivers: []storagev1.CSINodeDriver{{Name: csitypes.Name, NodeID: "4230d3a2-6d06-ed1b-2f2d-5e5a7a4c3b21"}},
+		},
+	}
+	// The taint was removed from the node since the detach started.
+	k8sclient := k8sfake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}, csiNode,
+		newTestCSIPV("pv-failed", "2f3b5c1e-7e0a-4d55-a4a3-1bfc0b6a3f10"),
+		newTestVolumeAttachment("va-failed", "node-1", "pv-failed"))
+
+	var detachCalls int
+	d := &outOfServiceNodeDetacher{
+		k8sClient: k8sclient,
+		recorder:  record.NewFakeRecorder(10),
+		detachVolume: func(ctx context.Context, nodeUUID string, volumeID string) error {
+			detachCalls++
+			return errors.New("vCenter is not reachable")
+		},
+	}
+	d.detachVolumesOfNode(outOfServiceNode)
+
+	if detachCalls != 1 {
+		t.Errorf("expected the detach not to be retried for a node back in service, got %d calls", detachCalls)
+	}
+}
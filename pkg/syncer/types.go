@@ -67,6 +67,21 @@ const (
 
 	// default interval for pv to backingdiskobjectid mapping
 	defaultPVtoBackingDiskObjectIdIntervalInMin = 10
+
+	// key for file share usage annotation on PVC
+	annFileShareUsage = "cns.vmware.com/file-share-usage"
+
+	// default interval for file volume usage
+	defaultFileVolumeUsageIntervalInMin = 10
+
+	// default interval for volume attachment sweep
+	defaultVolumeAttachmentSweepIntervalInMin = 30
+
+	// interval for reporting the vCenter capabilities in the VCenterCapabilityStatus instance
+	vCenterCapabilityStatusIntervalInMin = 5
+
+	// event source of the events recorded by the syncer on PVs and nodes
+	syncerEventComponent = "vsphere-csi-syncer"
 )
 
 var (
@@ -92,6 +107,10 @@ var (
 	// the cluster but the corresponding PV for that volume does not exist.
 	// A separate map is maintained for each VC.
 	volumeInfoCrDeletionMap map[string]map[string]bool
+
+	// volumesProtectedFromVMDeletion tracks the volumes on which full sync set
+	// the keepAfterDeleteVm control flag, so that it is set only once.
+	volumesProtectedFromVMDeletion sync.Map
 )
 
 type (
@@ -140,4 +159,10 @@ const (
 	resizeRetryIntervalMax = 5 * time.Minute
 	// resizeWorkers represents the number of running worker threads
 	resizeWorkers = 10
+	// fileVolumeACLRetryIntervalStart represents the start retry interval of the file volume node ACL reconciler
+	fileVolumeACLRetryIntervalStart = 2 * time.Second
+	// fileVolumeACLRetryIntervalMax represents the max retry interval of the file volume node ACL reconciler
+	fileVolumeACLRetryIntervalMax = 5 * time.Minute
+	// fileVolumeACLWorkers represents the number of worker threads of the file volume node ACL reconciler
+	fileVolumeACLWorkers = 4
 )
@@ -296,6 +296,16 @@ func IsMultiAttachAllowed(pv *v1.PersistentVolume) bool {
 	return false
 }
 
+// isFileShareVolume returns true if the PV is backed by a file share. Raw block
+// PVs with multi-node access modes are backed by shared disks and are treated
+// as block volumes.
+func isFileShareVolume(pv *v1.PersistentVolume) bool {
+	if !IsMultiAttachAllowed(pv) {
+		return false
+	}
+	return pv.Spec.VolumeMode == nil || *pv.Spec.VolumeMode != v1.PersistentVolumeBlock
+}
+
 // initVolumeMigrationService is a helper method to initialize
 // volumeMigrationService in Syncer.
 func initVolumeMigrationService(ctx context.Context, metadataSyncer *metadataSyncInformer) error {
@@ -536,7 +546,7 @@ func getVcHostAndVolumeManagerFromPvNodeAffinity(ctx context.Context, pv *v1.Per
 // getPVsInBoundAvailableOrReleasedForVc sends back all K8s volumes in "Bound", "Available"
 // or "Released" states, associated with the given VC.
 // In case of a multi VC setup, it will also filter out all the
-// in-tree PVs as well as file share volumes.
+// in-tree PVs.
 // For all K8s volumes, the corresponding VC is looked up from the in-memory map.
 // In case this info is not available, it is obtained from PV's nodeAffinity rules.
 func getPVsInBoundAvailableOrReleasedForVc(ctx context.Context, metadataSyncer *metadataSyncInformer,
@@ -572,13 +582,6 @@ func getPVsInBoundAvailableOrReleasedForVc(ctx context.Context, metadataSyncer *
 				"Invalid PV %s with empty volume handle.", pv.Name)
 		}
 
-		// Check if the PV is a file share volume.
-		if IsMultiAttachAllowed(pv) {
-			return nil, logger.LogNewErrorf(log,
-				"File share volumes are not supported on a multi VC set up."+
-					"Found file share volume %s.", pv.Name)
-		}
-
 		if volumeInfoService == nil {
 			return nil, logger.LogNewErrorf(log, "VolumeInfoService is not initialized.")
 		}
@@ -603,6 +606,13 @@ func getPVsInBoundAvailableOrReleasedForVc(ctx context.Context, metadataSyncer *
 			topologySegments := getTopologySegmentsFromNodeAffinityRules(ctx, volume)
 			vCenter, err := getVcHostFromTopologySegments(ctx, topologySegments, volume.Name)
 			if err != nil {
+				if isFileShareVolume(volume) {
+					// File share volumes may not carry nodeAffinity rules. Skip them instead
+					// of failing the full sync for the whole VC.
+					log.Warnf("Failed to find which VC file share volume %q belongs to. Skipping it.",
+						volume.Spec.CSI.VolumeHandle)
+					continue
+				}
 				return nil, logger.LogNewErrorf(log,
 					"Failed to find which VC volume %+v belongs to from ndeAffinityrules",
 					volume.Spec.CSI.VolumeHandle)
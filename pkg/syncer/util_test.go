@@ -4,12 +4,19 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/google/uuid"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/k8scloudoperator"
 )
 
@@ -391,3 +398,140 @@ func TestGetTopologySegmentsFromNodeAffinityRulesMultipleMatchExpressions(t *tes
 		}
 	}
 }
+
+type fakeVolumeInfoService struct {
+	volumeIDToVC map[string]string
+}
+
+func (f *fakeVolumeInfoService) GetvCenterForVolumeID(ctx context.Context, volumeID string) (string, error) {
+	vc, ok := f.volumeIDToVC[volumeID]
+	if !ok {
+		return "", fmt.Errorf("volume %q not found", volumeID)
+	}
+	return vc, nil
+}
+
+func (f *fakeVolumeInfoService) CreateVolumeInfo(ctx context.Context, volumeID string, vCenter string) error {
+	f.volumeIDToVC[volumeID] = vCenter
+	return nil
+}
+
+func (f *fakeVolumeInfoService) DeleteVolumeInfo(ctx context.Context, volumeID string) error {
+	delete(f.volumeIDToVC, volumeID)
+	return nil
+}
+
+func (f *fakeVolumeInfoService) ListAllVolumeInfos() []interface{} {
+	return nil
+}
+
+func (f *fakeVolumeInfoService) VolumeInfoCrExistsForVolume(ctx context.Context, volumeID string) (bool, error) {
+	_, ok := f.volumeIDToVC[volumeID]
+	return ok, nil
+}
+
+func TestGetPVsInBoundAvailableOrReleasedForVcWithFileVolumes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newPV := func(name, volumeHandle string, accessMode corev1.PersistentVolumeAccessMode) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{accessMode},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					CSI: &corev1.CSIPersistentVolumeSource{
+						Driver:       csitypes.Name,
+						VolumeHandle: volumeHandle,
+					},
+				},
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+		}
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pv := range []*corev1.PersistentVolume{
+		newPV("pv-block-vc1", "block-1", corev1.ReadWriteOnce),
+		newPV("pv-file-vc1", "file:vc1-share", corev1.ReadWriteMany),
+		newPV("pv-file-vc2", "file:vc2-share", corev1.ReadWriteMany),
+		newPV("pv-file-unknown", "file:unknown-share", corev1.ReadWriteMany),
+	} {
+		if err := indexer.Add(pv); err != nil {
+			t.Fatalf("failed to add PV %q to indexer: %v", pv.Name, err)
+		}
+	}
+	coCommonInterface, err := unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if err != nil {
+		t.Fatalf("failed to create fake CO interface: %v", err)
+	}
+	syncer := &metadataSyncInformer{
+		pvLister:          corelisters.NewPersistentVolumeLister(indexer),
+		coCommonInterface: coCommonInterface,
+		configInfo: &cnsconfig.ConfigurationInfo{
+			Cfg: &cnsconfig.Config{
+				VirtualCenter: map[string]*cnsconfig.VirtualCenterConfig{
+					"vc1": {}, "vc2": {},
+				},
+			},
+		},
+	}
+
+	origMultiVCenterFssEnabled, origVolumeInfoService := isMultiVCenterFssEnabled, volumeInfoService
+	defer func() {
+		isMultiVCenterFssEnabled, volumeInfoService = origMultiVCenterFssEnabled, origVolumeInfoService
+	}()
+	isMultiVCenterFssEnabled = true
+	volumeInfoService = &fakeVolumeInfoService{volumeIDToVC: map[string]string{
+		"block-1":        "vc1",
+		"file:vc1-share": "vc1",
+		"file:vc2-share": "vc2",
+	}}
+
+	tests := []struct {
+		vc              string
+		expectedVolumes []string
+	}{
+		{vc: "vc1", expectedVolumes: []string{"block-1", "file:vc1-share"}},
+		{vc: "vc2", expectedVolumes: []string{"file:vc2-share"}},
+	}
+	for _, test := range tests {
+		pvs, err := getPVsInBoundAvailableOrReleasedForVc(ctx, syncer, test.vc)
+		if err != nil {
+			t.Fatalf("unexpected error listing PVs for %q: %v", test.vc, err)
+		}
+		var volumeIDs []string
+		for _, pv := range pvs {
+			volumeIDs = append(volumeIDs, pv.Spec.CSI.VolumeHandle)
+		}
+		sort.Strings(volumeIDs)
+		if !reflect.DeepEqual(volumeIDs, test.expectedVolumes) {
+			t.Errorf("expected volumes %v for %q, got %v", test.expectedVolumes, test.vc, volumeIDs)
+		}
+	}
+}
+
+func TestIsFileShareVolume(t *testing.T) {
+	blockMode := corev1.PersistentVolumeBlock
+	filesystemMode := corev1.PersistentVolumeFilesystem
+	tests := []struct {
+		name       string
+		accessMode corev1.PersistentVolumeAccessMode
+		volumeMode *corev1.PersistentVolumeMode
+		expected   bool
+	}{
+		{name: "RWO filesystem", accessMode: corev1.ReadWriteOnce, volumeMode: &filesystemMode, expected: false},
+		{name: "RWX without volume mode", accessMode: corev1.ReadWriteMany, expected: true},
+		{name: "RWX filesystem", accessMode: corev1.ReadWriteMany, volumeMode: &filesystemMode, expected: true},
+		{name: "RWX raw block", accessMode: corev1.ReadWriteMany, volumeMode: &blockMode, expected: false},
+		{name: "ROX raw block", accessMode: corev1.ReadOnlyMany, volumeMode: &blockMode, expected: false},
+	}
+	for _, test := range tests {
+		pv := &corev1.PersistentVolume{Spec: corev1.PersistentVolumeSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{test.accessMode},
+			VolumeMode:  test.volumeMode,
+		}}
+		if got := isFileShareVolume(pv); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
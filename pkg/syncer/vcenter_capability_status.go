@@ -0,0 +1,81 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	vccapabilityv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/vcentercapabilitystatus/v1alpha1"
+)
+
+// updateVCenterCapabilityStatus reports the given capabilities of the vCenters,
+// keyed by host, in the VCenterCapabilityStatus instance, creating it if it
+// does not exist.
+func updateVCenterCapabilityStatus(ctx context.Context, cnsOperatorClient client.Client,
+	capabilities map[string]*cnsvsphere.Capabilities) error {
+	log := logger.GetLogger(ctx)
+	vCenters := make([]vccapabilityv1alpha1.VCenterCapabilities, 0, len(capabilities))
+	for host, vcCapabilities := range capabilities {
+		discoveryTime := metav1.NewTime(vcCapabilities.DiscoveryTime)
+		vCenters = append(vCenters, vccapabilityv1alpha1.VCenterCapabilities{
+			Host:                    host,
+			Version:                 vcCapabilities.Version,
+			Build:                   vcCapabilities.Build,
+			APIVersion:              vcCapabilities.APIVersion,
+			VsanAPIVersion:          vcCapabilities.VsanAPIVersion,
+			Features:                vcCapabilities.Features,
+			VsanFileServiceState:    vcCapabilities.VsanFileServiceState,
+			VsanFileServiceClusters: vcCapabilities.VsanFileServiceClusters,
+			LastDiscoveryTime:       &discoveryTime,
+		})
+	}
+	sort.Slice(vCenters, func(i, j int) bool {
+		return vCenters[i].Host < vCenters[j].Host
+	})
+
+	instance := &vccapabilityv1alpha1.VCenterCapabilityStatus{}
+	key := k8stypes.NamespacedName{Namespace: "", Name: vccapabilityv1alpha1.VCenterCapabilityStatusCRName}
+	if err := cnsOperatorClient.Get(ctx, key, instance); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return logger.LogNewErrorf(log, "failed to get VCenterCapabilityStatus instance %q. Error: %v",
+				key.Name, err)
+		}
+		instance = vccapabilityv1alpha1.CreateVCenterCapabilityStatusInstance()
+		instance.Status.VCenters = vCenters
+		if err := cnsOperatorClient.Create(ctx, instance); err != nil {
+			return logger.LogNewErrorf(log, "failed to create VCenterCapabilityStatus instance %q. Error: %v",
+				key.Name, err)
+		}
+		log.Infof("Created VCenterCapabilityStatus instance %q", key.Name)
+		return nil
+	}
+	instance.Status.VCenters = vCenters
+	if err := cnsOperatorClient.Update(ctx, instance); err != nil {
+		return logger.LogNewErrorf(log, "failed to update VCenterCapabilityStatus instance %q. Error: %v",
+			key.Name, err)
+	}
+	log.Debugf("Updated VCenterCapabilityStatus instance %q", key.Name)
+	return nil
+}
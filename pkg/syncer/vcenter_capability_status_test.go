@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis"
+	vccapabilityv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsoperator/vcentercapabilitystatus/v1alpha1"
+)
+
+func TestUpdateVCenterCapabilityStatus(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := internalapis.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cnsOperatorClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	discoveryTime := time.Now()
+	capabilities := map[string]*cnsvsphere.Capabilities{
+		"vc-2": {
+			Version:              "7.0.2",
+			Features:             map[string]bool{cnsvsphere.FeatureBlockVolumeSnapshot: false},
+			VsanFileServiceState: cnsvsphere.VsanFileServiceUnknown,
+			DiscoveryTime:        discoveryTime,
+		},
+		"vc-1": {
+			Version:                 "8.0.2",
+			Build:                   "22385739",
+			APIVersion:              "8.0.2.0",
+			Features:                map[string]bool{cnsvsphere.FeatureBlockVolumeSnapshot: true},
+			VsanFileServiceState:    cnsvsphere.VsanFileServiceEnabled,
+			VsanFileServiceClusters: []string{"cluster-1"},
+			DiscoveryTime:           discoveryTime,
+		},
+	}
+	key := k8stypes.NamespacedName{Name: vccapabilityv1alpha1.VCenterCapabilityStatusCRName}
+
+	// The instance is created on the first update.
+	if err := updateVCenterCapabilityStatus(ctx, cnsOperatorClient, capabilities); err != nil {
+		t.Fatalf("failed to report vCenter capabilities: %v", err)
+	}
+	instance := &vccapabilityv1alpha1.VCenterCapabilityStatus{}
+	if err := cnsOperatorClient.Get(ctx, key, instance); err != nil {
+		t.Fatalf("failed to get VCenterCapabilityStatus instance: %v", err)
+	}
+	var hosts []string
+	for _, vCenter := range instance.Status.VCenters {
+		hosts = append(hosts, vCenter.Host)
+	}
+	if !reflect.DeepEqual(hosts, []string{"vc-1", "vc-2"}) {
+		t.Fatalf("expected vCenters sorted by host, got %v", hosts)
+	}
+	vc1 := instance.Status.VCenters[0]
+	if vc1.Version != "8.0.2" || !vc1.Features[cnsvsphere.FeatureBlockVolumeSnapshot] ||
+		vc1.VsanFileServiceState != cnsvsphere.VsanFileServiceEnabled ||
+		!reflect.DeepEqual(vc1.VsanFileServiceClusters, []string{"cluster-1"}) || vc1.LastDiscoveryTime == nil {
+		t.Errorf("unexpected capabilities reported for vCenter %q: %+v", vc1.Host, vc1)
+	}
+
+	// The instance is updated on the next ones.
+	delete(capabilities, "vc-2")
+	if err := updateVCenterCapabilityStatus(ctx, cnsOperatorClient, capabilities); err != nil {
+		t.Fatalf("failed to report vCenter capabilities: %v", err)
+	}
+	if err := cnsOperatorClient.Get(ctx, key, instance); err != nil {
+		t.Fatalf("failed to get VCenterCapabilityStatus instance: %v", err)
+	}
+	if len(instance.Status.VCenters) != 1 || instance.Status.VCenters[0].Host != "vc-1" {
+		t.Errorf("expected only vCenter \"vc-1\" to be reported, got %+v", instance.Status.VCenters)
+	}
+}
@@ -0,0 +1,357 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"strings"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+const (
+	// Event reasons for the drift between VolumeAttachments and the disks
+	// attached to node VMs.
+	eventReasonOrphanedDisk             = "OrphanedDisk"
+	eventReasonOrphanedDiskDetached     = "OrphanedDiskDetached"
+	eventReasonOrphanedDiskDetachFailed = "OrphanedDiskDetachFailed"
+	eventReasonMissingDisk              = "MissingDisk"
+)
+
+// attachedDisk is a First Class Disk attached to a node VM.
+type attachedDisk struct {
+	// volumeID is the ID of the First Class Disk.
+	volumeID string
+	// fileName is the path of the disk backing, e.g. "[vsanDatastore] fcd/disk.vmdk".
+	fileName string
+}
+
+// volumeAttachmentSweeper cross-checks the VolumeAttachments of the driver
+// against the First Class Disks attached to the node VMs. A disk attached to
+// a node VM which is a CNS volume of the cluster with no VolumeAttachment is
+// orphaned, e.g. after a failed detach or a manual edit of the VM in vCenter,
+// and fails later attaches of the volume to other nodes. A VolumeAttachment
+// reporting a volume attached to a node VM without the disk is missing.
+type volumeAttachmentSweeper struct {
+	k8sClient      clientset.Interface
+	metadataSyncer *metadataSyncInformer
+	recorder       record.EventRecorder
+	// detachOrphans is set to detach the orphaned disks from the node VMs.
+	detachOrphans bool
+	// getAttachedDisks returns the First Class Disks attached to the VM of the
+	// given node UUID and the vCenter host of the VM. It is overridden in unit
+	// tests.
+	getAttachedDisks func(ctx context.Context, nodeUUID string) ([]attachedDisk, string, error)
+	// getBlockVolumeIDs returns the IDs of the CNS block volumes of the cluster
+	// on the given vCenter host. It is overridden in unit tests.
+	getBlockVolumeIDs func(ctx context.Context, vcHost string) (map[string]struct{}, error)
+	// detachVolume detaches the given block volume from the VM of the given
+	// node UUID. It is overridden in unit tests.
+	detachVolume func(ctx context.Context, nodeUUID string, volumeID string) error
+}
+
+// newVolumeAttachmentSweeper returns a volumeAttachmentSweeper recording
+// events through the given kubernetes client.
+func newVolumeAttachmentSweeper(k8sClient clientset.Interface, metadataSyncer *metadataSyncInformer,
+	detachOrphans bool) *volumeAttachmentSweeper {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sClient.CoreV1().Events(""),
+		},
+	)
+	s := &volumeAttachmentSweeper{
+		k8sClient:      k8sClient,
+		metadataSyncer: metadataSyncer,
+		recorder:       eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: syncerEventComponent}),
+		detachOrphans:  detachOrphans,
+	}
+	s.getAttachedDisks = getAttachedDisksOfNodeVM
+	s.getBlockVolumeIDs = func(ctx context.Context, vcHost string) (map[string]struct{}, error) {
+		return getBlockVolumeIDs(ctx, metadataSyncer, vcHost)
+	}
+	s.detachVolume = func(ctx context.Context, nodeUUID string, volumeID string) error {
+		return detachVolumeFromNodeVM(ctx, metadataSyncer, nodeUUID, volumeID)
+	}
+	return s
+}
+
+// sweep reports the orphaned and missing disks of the node VMs of the cluster
+// through events and metrics, and detaches the orphaned disks if
+// detachOrphans is set.
+func (s *volumeAttachmentSweeper) sweep(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("sweepVolumeAttachments: start")
+	csiNodes, err := s.k8sClient.StorageV1().CSINodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("sweepVolumeAttachments: failed to list CSINodes. Err: %v", err)
+		return
+	}
+	// The disks of the node VMs are read before listing the VolumeAttachments,
+	// as the VolumeAttachment of a volume exists before the volume is attached.
+	nodeUUIDs := make(map[string]string)
+	nodeVCHosts := make(map[string]string)
+	nodeDisks := make(map[string][]attachedDisk)
+	for _, csiNode := range csiNodes.Items {
+		nodeUUID := k8s.GetNodeIdFromCSINode(&csiNode)
+		if nodeUUID == "" {
+			continue
+		}
+		disks, vcHost, err := s.getAttachedDisks(ctx, nodeUUID)
+		if err == cnsvsphere.ErrVMNotFound {
+			log.Infof("sweepVolumeAttachments: VM of node %q is not present in the VC inventory", csiNode.Name)
+			continue
+		}
+		if err != nil {
+			log.Warnf("sweepVolumeAttachments: failed to get disks attached to VM of node %q. Err: %v",
+				csiNode.Name, err)
+			continue
+		}
+		nodeUUIDs[csiNode.Name] = nodeUUID
+		nodeVCHosts[csiNode.Name] = vcHost
+		nodeDisks[csiNode.Name] = disks
+	}
+
+	vaList, err := s.k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("sweepVolumeAttachments: failed to list VolumeAttachments. Err: %v", err)
+		return
+	}
+	pvs, err := s.metadataSyncer.pvLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("sweepVolumeAttachments: failed to list PVs. Err: %v", err)
+		return
+	}
+	volumeIDToPV := make(map[string]*v1.PersistentVolume)
+	pvNameToPV := make(map[string]*v1.PersistentVolume)
+	for _, pv := range pvs {
+		pvNameToPV[pv.Name] = pv
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name {
+			volumeIDToPV[pv.Spec.CSI.VolumeHandle] = pv
+		}
+	}
+
+	orphanedDiskCount := 0
+	missingDiskCount := 0
+	vcBlockVolumeIDs := make(map[string]map[string]struct{})
+	for nodeName, disks := range nodeDisks {
+		// Volumes of the VolumeAttachments of the node, keyed by volume ID for
+		// CSI volumes and by volume path for migrated in-tree vSphere volumes.
+		attachments := make(map[string]bool)
+		attachedPVs := make(map[string]*v1.PersistentVolume)
+		// Orphaned disks are not looked for on a node with a VolumeAttachment
+		// whose PV can not be read, as the disk of the PV could be reported as
+		// orphaned.
+		skipOrphans := false
+		for _, va := range vaList.Items {
+			if va.Spec.Attacher != csitypes.Name || va.Spec.NodeName != nodeName ||
+				va.Spec.Source.PersistentVolumeName == nil {
+				continue
+			}
+			pv, ok := pvNameToPV[*va.Spec.Source.PersistentVolumeName]
+			if !ok {
+				// The PV may not be in the informer cache yet.
+				pv, err = s.k8sClient.CoreV1().PersistentVolumes().Get(ctx, *va.Spec.Source.PersistentVolumeName,
+					metav1.GetOptions{})
+				if err != nil {
+					log.Warnf("sweepVolumeAttachments: failed to get PV %q of VolumeAttachment %q. Err: %v",
+						*va.Spec.Source.PersistentVolumeName, va.Name, err)
+					skipOrphans = true
+					continue
+				}
+			}
+			key := getVolumeAttachmentKey(pv)
+			if key == "" {
+				continue
+			}
+			attachments[key] = true
+			if va.Status.Attached && va.DeletionTimestamp == nil {
+				attachedPVs[key] = pv
+			}
+		}
+		diskKeys := make(map[string]bool)
+		for _, disk := range disks {
+			diskKeys[disk.volumeID] = true
+			diskKeys[disk.fileName] = true
+		}
+
+		// Volumes attached to the node VM without a VolumeAttachment.
+		for _, disk := range disks {
+			if skipOrphans || attachments[disk.volumeID] || attachments[disk.fileName] {
+				continue
+			}
+			blockVolumeIDs, ok := vcBlockVolumeIDs[nodeVCHosts[nodeName]]
+			if !ok {
+				blockVolumeIDs, err = s.getBlockVolumeIDs(ctx, nodeVCHosts[nodeName])
+				if err != nil {
+					log.Errorf("sweepVolumeAttachments: failed to get CNS volumes on vCenter %q. Err: %v",
+						nodeVCHosts[nodeName], err)
+					break
+				}
+				vcBlockVolumeIDs[nodeVCHosts[nodeName]] = blockVolumeIDs
+			}
+			// Disks which are not CNS volumes of the cluster are not managed
+			// by the driver.
+			if _, ok := blockVolumeIDs[disk.volumeID]; !ok {
+				continue
+			}
+			orphanedDiskCount++
+			s.handleOrphanedDisk(ctx, nodeName, nodeUUIDs[nodeName], disk, volumeIDToPV[disk.volumeID])
+		}
+
+		// Volumes reported as attached by a VolumeAttachment without the disk
+		// on the node VM.
+		for key, pv := range attachedPVs {
+			if diskKeys[key] {
+				continue
+			}
+			missingDiskCount++
+			log.Warnf("sweepVolumeAttachments: volume %q of PV %q is attached to node %q according to its "+
+				"VolumeAttachment but is not attached to the node VM", key, pv.Name, nodeName)
+			s.recorder.Eventf(pv, v1.EventTypeWarning, eventReasonMissingDisk,
+				"Volume is attached to node %s according to its VolumeAttachment but not attached to the node VM",
+				nodeName)
+		}
+	}
+	prometheus.VolumeAttachmentDriftGaugeVec.WithLabelValues(
+		prometheus.PrometheusOrphanedDisks).Set(float64(orphanedDiskCount))
+	prometheus.VolumeAttachmentDriftGaugeVec.WithLabelValues(
+		prometheus.PrometheusMissingDisks).Set(float64(missingDiskCount))
+	log.Debugf("sweepVolumeAttachments: end")
+}
+
+// handleOrphanedDisk reports the given orphaned disk of the given node on its
+// PV, or on the node if the volume has no PV, and detaches it from the node
+// VM if detachOrphans is set.
+func (s *volumeAttachmentSweeper) handleOrphanedDisk(ctx context.Context, nodeName string, nodeUUID string,
+	disk attachedDisk, pv *v1.PersistentVolume) {
+	log := logger.GetLogger(ctx)
+	var obj runtime.Object = pv
+	if pv == nil {
+		obj = &v1.ObjectReference{Kind: "Node", Name: nodeName, UID: k8stypes.UID(nodeName)}
+	}
+	log.Warnf("sweepVolumeAttachments: volume %q is attached to VM of node %q without a VolumeAttachment",
+		disk.volumeID, nodeName)
+	if !s.detachOrphans {
+		s.recorder.Eventf(obj, v1.EventTypeWarning, eventReasonOrphanedDisk,
+			"Volume %s is attached to VM of node %s without a VolumeAttachment", disk.volumeID, nodeName)
+		return
+	}
+	if err := s.detachVolume(ctx, nodeUUID, disk.volumeID); err != nil {
+		log.Errorf("sweepVolumeAttachments: failed to detach orphaned volume %q from VM of node %q. Err: %v",
+			disk.volumeID, nodeName, err)
+		s.recorder.Eventf(obj, v1.EventTypeWarning, eventReasonOrphanedDiskDetachFailed,
+			"Failed to detach volume %s attached to VM of node %s without a VolumeAttachment: %v",
+			disk.volumeID, nodeName, err)
+		return
+	}
+	log.Infof("sweepVolumeAttachments: detached orphaned volume %q from VM of node %q", disk.volumeID, nodeName)
+	s.recorder.Eventf(obj, v1.EventTypeNormal, eventReasonOrphanedDiskDetached,
+		"Detached volume %s attached to VM of node %s without a VolumeAttachment", disk.volumeID, nodeName)
+}
+
+// getVolumeAttachmentKey returns the volume ID of the given CSI block PV or
+// the volume path of the given migrated in-tree vSphere PV, or "" for other
+// PVs.
+func getVolumeAttachmentKey(pv *v1.PersistentVolume) string {
+	if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name {
+		if strings.HasPrefix(pv.Spec.CSI.VolumeHandle, cnsvolumeinfo.FileVolumePrefix) {
+			return ""
+		}
+		return pv.Spec.CSI.VolumeHandle
+	}
+	if pv.Spec.VsphereVolume != nil {
+		return pv.Spec.VsphereVolume.VolumePath
+	}
+	return ""
+}
+
+// getAttachedDisksOfNodeVM returns the First Class Disks attached to the VM of
+// the given node UUID, using the VM cached by the node manager, and the
+// vCenter host of the VM.
+func getAttachedDisksOfNodeVM(ctx context.Context, nodeUUID string) ([]attachedDisk, string, error) {
+	nodeVM, err := node.GetManager(ctx).GetNodeVMAndUpdateCache(ctx, nodeUUID, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	vmDevices, err := nodeVM.Device(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	var disks []attachedDisk
+	for _, device := range vmDevices {
+		virtualDisk, ok := device.(*vimtypes.VirtualDisk)
+		if !ok || virtualDisk.VDiskId == nil || virtualDisk.VDiskId.Id == "" {
+			continue
+		}
+		disk := attachedDisk{volumeID: virtualDisk.VDiskId.Id}
+		if backing, ok := virtualDisk.Backing.(vimtypes.BaseVirtualDeviceFileBackingInfo); ok {
+			disk.fileName = backing.GetVirtualDeviceFileBackingInfo().FileName
+		}
+		disks = append(disks, disk)
+	}
+	return disks, nodeVM.VirtualCenterHost, nil
+}
+
+// getBlockVolumeIDs returns the IDs of the CNS block volumes of the cluster on
+// the given vCenter host.
+func getBlockVolumeIDs(ctx context.Context, metadataSyncer *metadataSyncInformer,
+	vcHost string) (map[string]struct{}, error) {
+	volManager, err := getVolManagerForVcHost(ctx, vcHost, metadataSyncer)
+	if err != nil {
+		return nil, err
+	}
+	queryFilter := cnstypes.CnsQueryFilter{
+		ContainerClusterIds: []string{
+			clusterIDforVolumeMetadata,
+		},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypeVolumeType),
+		},
+	}
+	queryAllResult, err := volManager.QueryAllVolume(ctx, queryFilter, querySelection)
+	if err != nil {
+		return nil, err
+	}
+	blockVolumeIDs := make(map[string]struct{})
+	for _, vol := range queryAllResult.Volumes {
+		if vol.VolumeType == string(cnstypes.CnsVolumeTypeBlock) {
+			blockVolumeIDs[vol.VolumeId.Id] = struct{}{}
+		}
+	}
+	return blockVolumeIDs, nil
+}
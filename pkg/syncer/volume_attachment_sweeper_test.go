@@ -0,0 +1,190 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
+)
+
+func newTestCSINode(name, nodeUUID string) *storagev1.CSINode {
+	return &storagev1.CSINode{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.CSINodeSpec{
+			Drivers: []storagev1.CSINodeDriver{{Name: csitypes.Name, NodeID: nodeUUID}},
+		},
+	}
+}
+
+func newTestVolumeAttachmentSweeper(t *testing.T, detachOrphans bool, pvs []*v1.PersistentVolume,
+	objects ...runtime.Object) *volumeAttachmentSweeper {
+	pvIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pv := range pvs {
+		if err := pvIndexer.Add(pv); err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, pv)
+	}
+	return &volumeAttachmentSweeper{
+		k8sClient:      k8sfake.NewSimpleClientset(objects...),
+		metadataSyncer: &metadataSyncInformer{pvLister: corelisters.NewPersistentVolumeLister(pvIndexer)},
+		recorder:       record.NewFakeRecorder(10),
+		detachOrphans:  detachOrphans,
+	}
+}
+
+func TestSweepVolumeAttachments(t *testing.T) {
+	ctx := context.Background()
+	attachedVA := newTestVolumeAttachment("va-attached", "node-1", "pv-attached")
+	attachedVA.Status.Attached = true
+	missingVA := newTestVolumeAttachment("va-missing", "node-1", "pv-missing")
+	missingVA.Status.Attached = true
+	// Volume being attached, whose disk is already on the node VM.
+	attachingVA := newTestVolumeAttachment("va-attaching", "node-1", "pv-attaching")
+	migratedPV := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-migrated"},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				VsphereVolume: &v1.VsphereVirtualDiskVolumeSource{VolumePath: "[vsanDatastore] kubevols/disk-1.vmdk"},
+			},
+		},
+	}
+	migratedVA := newTestVolumeAttachment("va-migrated", "node-1", "pv-migrated")
+	migratedVA.Status.Attached = true
+
+	s := newTestVolumeAttachmentSweeper(t, false,
+		[]*v1.PersistentVolume{
+			newTestCSIPV("pv-attached", "vol-attached"),
+			newTestCSIPV("pv-missing", "vol-missing"),
+			newTestCSIPV("pv-attaching", "vol-attaching"),
+			newTestCSIPV("pv-orphaned", "vol-orphaned"),
+			migratedPV,
+		},
+		newTestCSINode("node-1", "uuid-1"),
+		newTestCSINode("node-2", "uuid-2"),
+		attachedVA, missingVA, attachingVA, migratedVA)
+	s.getAttachedDisks = func(ctx context.Context, nodeUUID string) ([]attachedDisk, string, error) {
+		if nodeUUID == "uuid-2" {
+			return nil, "", cnsvsphere.ErrVMNotFound
+		}
+		return []attachedDisk{
+			{volumeID: "vol-attached", fileName: "[vsanDatastore] fcd/attached.vmdk"},
+			{volumeID: "vol-attaching", fileName: "[vsanDatastore] fcd/attaching.vmdk"},
+			{volumeID: "vol-orphaned", fileName: "[vsanDatastore] fcd/orphaned.vmdk"},
+			// Disk of a volume of another cluster.
+			{volumeID: "vol-other-cluster", fileName: "[vsanDatastore] fcd/other.vmdk"},
+			{volumeID: "vol-migrated", fileName: "[vsanDatastore] kubevols/disk-1.vmdk"},
+		}, "vc-1", nil
+	}
+	s.getBlockVolumeIDs = func(ctx context.Context, vcHost string) (map[string]struct{}, error) {
+		return map[string]struct{}{
+			"vol-attached": {}, "vol-missing": {}, "vol-attaching": {}, "vol-orphaned": {}, "vol-migrated": {},
+		}, nil
+	}
+	s.detachVolume = func(ctx context.Context, nodeUUID string, volumeID string) error {
+		t.Errorf("expected no volume to be detached, got %q", volumeID)
+		return nil
+	}
+	s.sweep(ctx)
+
+	if got := promtestutil.ToFloat64(prometheus.VolumeAttachmentDriftGaugeVec.WithLabelValues(
+		prometheus.PrometheusOrphanedDisks)); got != 1 {
+		t.Errorf("expected 1 orphaned disk, got %v", got)
+	}
+	if got := promtestutil.ToFloat64(prometheus.VolumeAttachmentDriftGaugeVec.WithLabelValues(
+		prometheus.PrometheusMissingDisks)); got != 1 {
+		t.Errorf("expected 1 missing disk, got %v", got)
+	}
+	events := s.recorder.(*record.FakeRecorder).Events
+	var reasons []string
+	for len(events) > 0 {
+		reasons = append(reasons, strings.Fields(<-events)[1])
+	}
+	if len(reasons) != 2 {
+		t.Fatalf("expected 2 events, got %v", reasons)
+	}
+	if !(reasons[0] == eventReasonOrphanedDisk && reasons[1] == eventReasonMissingDisk) {
+		t.Errorf("expected events %q and %q, got %v", eventReasonOrphanedDisk, eventReasonMissingDisk, reasons)
+	}
+}
+
+func TestSweepVolumeAttachmentsDetachesOrphans(t *testing.T) {
+	ctx := context.Background()
+	s := newTestVolumeAttachmentSweeper(t, true, nil, newTestCSINode("node-1", "uuid-1"))
+	s.getAttachedDisks = func(ctx context.Context, nodeUUID string) ([]attachedDisk, string, error) {
+		return []attachedDisk{{volumeID: "vol-orphaned", fileName: "[vsanDatastore] fcd/orphaned.vmdk"}}, "vc-1", nil
+	}
+	s.getBlockVolumeIDs = func(ctx context.Context, vcHost string) (map[string]struct{}, error) {
+		return map[string]struct{}{"vol-orphaned": {}}, nil
+	}
+	detached := make(map[string]string)
+	s.detachVolume = func(ctx context.Context, nodeUUID string, volumeID string) error {
+		detached[volumeID] = nodeUUID
+		return nil
+	}
+	s.sweep(ctx)
+
+	if len(detached) != 1 || detached["vol-orphaned"] != "uuid-1" {
+		t.Errorf("expected orphaned volume to be detached from node VM, got %v", detached)
+	}
+	// The volume has no PV, so the event is recorded on the node.
+	events := s.recorder.(*record.FakeRecorder).Events
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if event := <-events; !strings.HasPrefix(event, v1.EventTypeNormal+" "+eventReasonOrphanedDiskDetached) {
+		t.Errorf("got event %q, want reason %q", event, eventReasonOrphanedDiskDetached)
+	}
+}
+
+func TestSweepVolumeAttachmentsSkipsOrphansOfUnknownPV(t *testing.T) {
+	ctx := context.Background()
+	// VolumeAttachment of a PV which can not be read.
+	s := newTestVolumeAttachmentSweeper(t, true, nil, newTestCSINode("node-1", "uuid-1"),
+		newTestVolumeAttachment("va-1", "node-1", "pv-unknown"))
+	s.getAttachedDisks = func(ctx context.Context, nodeUUID string) ([]attachedDisk, string, error) {
+		return []attachedDisk{{volumeID: "vol-1", fileName: "[vsanDatastore] fcd/vol-1.vmdk"}}, "vc-1", nil
+	}
+	s.getBlockVolumeIDs = func(ctx context.Context, vcHost string) (map[string]struct{}, error) {
+		return map[string]struct{}{"vol-1": {}}, nil
+	}
+	s.detachVolume = func(ctx context.Context, nodeUUID string, volumeID string) error {
+		t.Errorf("expected no volume to be detached, got %q", volumeID)
+		return nil
+	}
+	s.sweep(ctx)
+
+	if got := promtestutil.ToFloat64(prometheus.VolumeAttachmentDriftGaugeVec.WithLabelValues(
+		prometheus.PrometheusOrphanedDisks)); got != 0 {
+		t.Errorf("expected no orphaned disk, got %v", got)
+	}
+}